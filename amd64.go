@@ -0,0 +1,465 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type archAmd64 struct {
+	asmArch
+	byteWriter
+	goos string
+}
+
+func newAmd64(as Assembler, goos string) (_ *archAmd64, err error) {
+	return &archAmd64{
+		asmArch:    asmArch{as: as},
+		byteWriter: byteWriter{binary.LittleEndian, [4]string{"QUAD", "LONG", "WORD", "BYTE"}},
+		goos:       goos,
+	}, nil
+}
+
+func (aa *archAmd64) CommentTokens() []string {
+	return []string{"#"}
+}
+
+var (
+	reAmd64Sp    = regexp.MustCompile(`^\$(\d+), %rsp$`)
+	reAmd64Align = regexp.MustCompile(`^\$-(\d+), %rsp$`)
+)
+
+// instrRipRel is an instruction that addresses a label through %rip,
+// the displacement is relative to the end of the instruction itself.
+type instrRipRel struct {
+	*instrBase
+	asm asmfunc
+}
+
+func (ins instrRipRel) Operands() string {
+	arr := make([]interface{}, len(ins.los))
+	for i, v := range ins.los {
+		if v.EA() == -1 {
+			arr[i] = 0
+		} else {
+			arr[i] = v.EA() - ins.ea - ins.Size()
+		}
+	}
+	return fmt.Sprintf(ins.opers, arr...)
+}
+
+func (ins instrRipRel) Rebuild() (dif int64, err error) {
+	for _, v := range ins.los {
+		if v.EA() == -1 {
+			err = fmt.Errorf("nil label: %s", v.ID())
+			return
+		}
+	}
+
+	old := ins.Size()
+	for {
+		sz := ins.Size()
+		if ins.data, err = ins.asm(ins.mnemo, ins.Operands(), ins.ea); err != nil {
+			return
+		}
+		// displacement depends on size
+		if ins.Size() == sz {
+			break
+		}
+	}
+	dif = ins.Size() - old
+	return
+}
+
+func isAmd64Jcc(mnemo string) bool {
+	return mnemo[0] == 'j' && mnemo != "jmp" && mnemo != "jmpq"
+}
+
+func (aa *archAmd64) Instr(ea int64, mnemo string, opers string, los []LabelOperand) (_ Instr, err error) {
+	ib := &instrBase{
+		kind:  InstrKind_Normal,
+		ea:    ea,
+		mnemo: mnemo,
+		opers: opers,
+		los:   los,
+	}
+
+	if len(los) == 0 {
+		if ib.data, err = aa.asm(mnemo, opers, ea); err != nil {
+			return
+		}
+	}
+
+	// pushq %rbp
+	// popq %rbp
+	// subq $40, %rsp
+	// addq $40, %rsp
+	switch mnemo {
+	case "pushq", "push":
+		ib.sp = -8
+	case "popq", "pop":
+		ib.sp = 8
+	case "subq", "addq", "sub", "add":
+		if res := reAmd64Sp.FindStringSubmatch(opers); len(res) > 0 {
+			var sp int64
+			if sp, err = strconv.ParseInt(res[1], 10, 64); err != nil {
+				return
+			}
+			if mnemo[0] == 's' {
+				sp = -sp
+			}
+
+			ib.sp = sp
+			return ib, nil
+		}
+	// andq $-32, %rsp realigns the stack, the sp is 8 aligned at worst so
+	// up to N-8 bytes are skipped
+	case "andq", "and":
+		if res := reAmd64Align.FindStringSubmatch(opers); len(res) > 0 {
+			var align int64
+			if align, err = strconv.ParseInt(res[1], 10, 64); err != nil {
+				return
+			}
+			if align > 8 {
+				ib.sp = -(align - 8)
+			}
+			return ib, nil
+		}
+	}
+
+	// subq %rax, %rsp
+	// movq %rcx, %rsp
+	switch mnemo {
+	case "subq", "addq", "sub", "add", "movq", "mov", "leaq", "lea":
+		ops := splitOperands(opers)
+		ib.dynsp = isDynSP(ops, len(ops)-1, "%rsp", "%rbp")
+	}
+
+	if mnemo == ".p2align" {
+		ib.kind = InstrKind_P2Align
+		return instrRebuild{instrBase: ib, asm: aa.asm}, nil
+	}
+
+	switch {
+	case isData(mnemo):
+		ib.kind = InstrKind_Data
+	case mnemo == "ret" || mnemo == "retq":
+		ib.kind = InstrKind_Ret
+	case mnemo == "call" || mnemo == "callq":
+		ib.kind = InstrKind_Call
+		if strings.HasPrefix(opers, "*") {
+			ib.kind = InstrKind_Indirect_Call
+		}
+		// return address
+		ib.sp = -8
+	case mnemo == "jmp" || mnemo == "jmpq":
+		ib.kind = InstrKind_Jmp
+		if strings.HasPrefix(opers, "*") {
+			ib.kind = InstrKind_Indirect_Jmp
+		}
+	case isAmd64Jcc(mnemo):
+		ib.kind = InstrKind_Cond_Jmp
+	}
+
+	if len(los) > 0 {
+		if strings.Contains(opers, "(%%rip)") {
+			ir := instrRipRel{instrBase: ib, asm: aa.asm}
+			if ib.data, err = aa.asm(mnemo, ir.Operands(), ea); err != nil {
+				return
+			}
+			return ir, nil
+		}
+
+		var stub int64
+		if ib.kind == InstrKind_Call || ib.kind == InstrKind_Jmp || ib.kind == InstrKind_Cond_Jmp {
+			stub = ea
+		}
+		il := instrLabel{instrBase: ib, asm: aa.asm, stub: stub}
+		if ib.data, err = aa.asm(mnemo, il.Operands(), ea); err != nil {
+			return
+		}
+		return il, nil
+	}
+
+	return ib, nil
+}
+
+func (aa *archAmd64) WriteProg(w io.Writer, p *Prog) error {
+	// TEXT symbols are only 32 byte aligned
+	if err := p.checkAlign(5); err != nil {
+		return err
+	}
+	return aa.writeProg(w, p)
+}
+
+func (aa *archAmd64) EntryBlock() (*BasicBlock, error) {
+	return buildEntryBlock(aa.asm,
+		"leaq", "-7(%rip), %rax",
+		"movq", "%rax, 8(%rsp)",
+		"retq", "",
+	)
+}
+
+func (aa *archAmd64) WriteHead(w io.Writer) (err error) {
+	return
+}
+
+// amd64Op is the move of sz bytes, the loads extend the narrow integers by
+// the sign, clang relies on the caller for the signext arguments.
+func amd64Op(sz int, fp, signed, load bool) string {
+	switch sz {
+	case 1:
+		switch {
+		case load && signed:
+			return "MOVBQSX"
+		case load:
+			return "MOVBQZX"
+		}
+		return "MOVB"
+	case 2:
+		switch {
+		case load && signed:
+			return "MOVWQSX"
+		case load:
+			return "MOVWQZX"
+		}
+		return "MOVW"
+	case 4:
+		if fp {
+			return "MOVSS"
+		}
+		return "MOVL"
+	case 8:
+		if fp {
+			return "MOVSD"
+		}
+		return "MOVQ"
+	default:
+		panic(fmt.Sprintf("unexpected parameter size: %d", sz))
+	}
+}
+
+func (aa *archAmd64) WriteFunc(w io.Writer, f *Function, spsize, fpos int64) (err error) {
+	if aa.goos == "windows" {
+		return aa.writeWindowsFunc(w, f, spsize)
+	}
+
+	if spsize != 0 && !f.NativeStack {
+		// return address and stack realignment
+		if _, err = fmt.Fprintf(w, `
+_entry:
+	MOVQ (TLS), R14
+	LEAQ -%d(SP), R12
+	CMPQ R12, 16(R14)
+	JBE  _stack_grow
+`, spsize+16); err != nil {
+			return
+		}
+	}
+
+	if _, err = fmt.Fprintf(w, "\n%s:\n", f.Name[1:]); err != nil {
+		return
+	}
+
+	intRegs := []string{"DI", "SI", "DX", "CX", "R8", "R9"}
+	getReg := func(idx int, fp bool) string {
+		if fp {
+			return "X" + strconv.Itoa(idx)
+		}
+		return intRegs[idx]
+	}
+
+	var ri, fi, soff int
+	nextOff := func(sz int) (r int) {
+		r = (soff + sz - 1) &^ (sz - 1)
+		soff = r + sz
+		return
+	}
+	nextReg := func(fp bool) (string, error) {
+		if fp {
+			if fi == 8 {
+				return "", errors.New("too many float arguments")
+			}
+			fi++
+			return getReg(fi-1, true), nil
+		}
+		if ri == len(intRegs) {
+			return "", errors.New("too many integer arguments")
+		}
+		ri++
+		return getReg(ri-1, false), nil
+	}
+	for _, v := range f.Args {
+		reg, err1 := nextReg(v.IsFloat)
+		if err1 != nil {
+			return newDiag(DiagKind_UnsupportedParam, f.Pos, fmt.Errorf("%s: %w", f.Name, err1))
+		}
+		if _, err = fmt.Fprintf(w, "\t%s %s+%d(FP), %s\n",
+			amd64Op(v.Size, v.IsFloat, v.Signed, true),
+			v.Name, nextOff(v.Size), reg,
+		); err != nil {
+			return
+		}
+	}
+
+	// BX is callee-saved in SysV, the native stack top is the last argument
+	if f.NativeStack {
+		_, err = fmt.Fprintf(w, `	MOVQ ·_subr%s(SB), AX
+	MOVQ SP, BX
+	MOVQ nstack+%d(FP), SP
+	CALL AX
+	MOVQ BX, SP
+`, f.Name, nextOff(8))
+	} else {
+		_, err = fmt.Fprintf(w, `	MOVQ ·_subr%s(SB), AX
+	MOVQ SP, BX
+	ANDQ $-16, SP
+	CALL AX
+	MOVQ BX, SP
+`, f.Name)
+	}
+	if err != nil {
+		return
+	}
+
+	soff = nextOff(8)
+	if f.Ret != nil {
+		ret := "AX"
+		if f.Ret.IsFloat {
+			ret = "X0"
+		}
+		if _, err = fmt.Fprintf(w, "\t%s %s, %s+%d(FP)\n",
+			amd64Op(f.Ret.Size, f.Ret.IsFloat, f.Ret.Signed, false), ret,
+			f.Ret.Name, nextOff(f.Ret.Size)); err != nil {
+			return
+		}
+	}
+	if _, err = fmt.Fprint(w, "\tRET\n"); err != nil {
+		return
+	}
+
+	if spsize != 0 && !f.NativeStack {
+		if _, err = fmt.Fprintf(w, `
+_stack_grow:
+	CALL runtime·morestack_noctxt<>(SB)
+	JMP  _entry
+`); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// writeWindowsFunc follows the microsoft x64 abi, the arguments take the
+// registers by their positions, the rest are on the stack after the 32
+// bytes shadow space.
+func (aa *archAmd64) writeWindowsFunc(w io.Writer, f *Function, spsize int64) (err error) {
+	intRegs := []string{"CX", "DX", "R8", "R9"}
+	nstack := 0
+	if len(f.Args) > len(intRegs) {
+		nstack = len(f.Args) - len(intRegs)
+	}
+	// the shadow space and the stack arguments, 16 byte aligned
+	frame := (32 + nstack*8 + 15) &^ 15
+
+	if spsize != 0 && !f.NativeStack {
+		// return address and stack realignment
+		if _, err = fmt.Fprintf(w, `
+_entry:
+	MOVQ (TLS), R14
+	LEAQ -%d(SP), R12
+	CMPQ R12, 16(R14)
+	JBE  _stack_grow
+`, spsize+16+int64(frame)); err != nil {
+			return
+		}
+	}
+
+	if _, err = fmt.Fprintf(w, "\n%s:\n", f.Name[1:]); err != nil {
+		return
+	}
+
+	var soff int
+	nextOff := func(sz int) (r int) {
+		r = (soff + sz - 1) &^ (sz - 1)
+		soff = r + sz
+		return
+	}
+	offs := make([]int, len(f.Args))
+	for i, v := range f.Args {
+		offs[i] = nextOff(v.Size)
+		if i >= len(intRegs) {
+			continue
+		}
+		reg := intRegs[i]
+		if v.IsFloat {
+			reg = "X" + strconv.Itoa(i)
+		}
+		if _, err = fmt.Fprintf(w, "\t%s %s+%d(FP), %s\n",
+			amd64Op(v.Size, v.IsFloat, v.Signed, true), v.Name, offs[i], reg); err != nil {
+			return
+		}
+	}
+
+	// BX is callee-saved, the stack arguments are copied from the old SP
+	if f.NativeStack {
+		_, err = fmt.Fprintf(w, `	MOVQ ·_subr%s(SB), AX
+	MOVQ SP, BX
+	MOVQ nstack+%d(FP), SP
+	SUBQ $%d, SP
+`, f.Name, nextOff(8), frame)
+	} else {
+		_, err = fmt.Fprintf(w, `	MOVQ ·_subr%s(SB), AX
+	MOVQ SP, BX
+	SUBQ $%d, SP
+	ANDQ $-16, SP
+`, f.Name, frame)
+	}
+	if err != nil {
+		return
+	}
+	for i := len(intRegs); i < len(f.Args); i++ {
+		if _, err = fmt.Fprintf(w, "\t%s %d(BX), R11\n\tMOVQ R11, %d(SP)\n",
+			amd64Op(f.Args[i].Size, false, f.Args[i].Signed, true), offs[i]+8, 32+(i-len(intRegs))*8); err != nil {
+			return
+		}
+	}
+	if _, err = fmt.Fprint(w, "\tCALL AX\n\tMOVQ BX, SP\n"); err != nil {
+		return
+	}
+
+	soff = nextOff(8)
+	if f.Ret != nil {
+		ret := "AX"
+		if f.Ret.IsFloat {
+			ret = "X0"
+		}
+		if _, err = fmt.Fprintf(w, "\t%s %s, %s+%d(FP)\n",
+			amd64Op(f.Ret.Size, f.Ret.IsFloat, f.Ret.Signed, false), ret,
+			f.Ret.Name, nextOff(f.Ret.Size)); err != nil {
+			return
+		}
+	}
+	if _, err = fmt.Fprint(w, "\tRET\n"); err != nil {
+		return
+	}
+
+	if spsize != 0 && !f.NativeStack {
+		if _, err = fmt.Fprintf(w, `
+_stack_grow:
+	CALL runtime·morestack_noctxt<>(SB)
+	JMP  _entry
+`); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (aa *archAmd64) SubrEntry(w io.Writer) (string, error) {
+	return "", nil
+}
@@ -0,0 +1,666 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// thumbAssembler is implemented by the assemblers of arm that also encode
+// the thumb instructions.
+type thumbAssembler interface {
+	Thumb() (Assembler, error)
+}
+
+type archArm struct {
+	asmArch
+	// by .code 16 or .thumb_func, the instructions are encoded by thumbAs
+	thumb   bool
+	thumbAs Assembler
+	// the instructions left in the it block
+	itLeft int
+	// the .thumb_func markers, they are at the start of the functions
+	thumbFuncs []Instr
+	// the mode directives in order, the code is arm before the first one
+	modes []armMode
+}
+
+type armMode struct {
+	ins   Instr
+	thumb bool
+}
+
+func newArm(as Assembler) (_ *archArm, err error) {
+	return &archArm{asmArch: asmArch{as: as}}, nil
+}
+
+var (
+	armNop   = []byte{0x00, 0xf0, 0x20, 0xe3}
+	thumbNop = []byte{0x00, 0xbf}
+)
+
+func (aa *archArm) CommentTokens() []string {
+	return []string{"@"}
+}
+
+func (aa *archArm) Close() {
+	aa.asmArch.Close()
+	if aa.thumbAs != nil {
+		aa.thumbAs.Close()
+	}
+}
+
+func (aa *archArm) setThumb(thumb bool) (err error) {
+	aa.thumb = thumb
+	if !thumb || aa.thumbAs != nil {
+		return
+	}
+	ta, ok := aa.as.(thumbAssembler)
+	if !ok {
+		return fmt.Errorf("thumb is unsupported by the assembler")
+	}
+	aa.thumbAs, err = ta.Thumb()
+	return
+}
+
+// setMode follows the mode directives, ok is false for the others.
+func (aa *archArm) setMode(mnemo, opers string) (ok bool, err error) {
+	switch {
+	case mnemo == ".code":
+		err = aa.setThumb(opers == "16")
+	case mnemo == ".thumb", mnemo == ".thumb_func":
+		err = aa.setThumb(true)
+	case mnemo == ".arm":
+		err = aa.setThumb(false)
+	default:
+		return
+	}
+	return true, err
+}
+
+func (aa *archArm) thumbAsm(mnemo, opers string, address int64) ([]byte, error) {
+	return aa.thumbAs.Asm(mnemo, opers, address)
+}
+
+// itAsm encodes the instruction in the it block, the assembler puts its
+// own it in front of it.
+func (aa *archArm) itAsm(mnemo, opers string, address int64) (data []byte, err error) {
+	if data, err = aa.thumbAsm(mnemo, opers, address); err != nil {
+		return
+	}
+	if len(data) >= 4 && data[1] == 0xbf && data[0]&0xf != 0 {
+		data = data[2:]
+	}
+	return
+}
+
+func (aa *archArm) Prefetch(ins []asmInput) {
+	// the modes are followed by the directives in the same order
+	var arm, thumb []asmInput
+	var mode bool
+	for _, v := range ins {
+		switch {
+		case v.mnemo == ".code":
+			mode = v.opers == "16"
+		case v.mnemo == ".thumb", v.mnemo == ".thumb_func":
+			mode = true
+		case v.mnemo == ".arm":
+			mode = false
+		case mode:
+			thumb = append(thumb, v)
+		default:
+			arm = append(arm, v)
+		}
+	}
+	if ba, ok := aa.as.(batchAssembler); ok && len(arm) > 0 {
+		ba.Prefetch(arm)
+	}
+	if len(thumb) > 0 && aa.thumbAs == nil {
+		// Instr reports the error
+		if ta, ok := aa.as.(thumbAssembler); ok {
+			aa.thumbAs, _ = ta.Thumb()
+		}
+	}
+	if ba, ok := aa.thumbAs.(batchAssembler); ok && len(thumb) > 0 {
+		ba.Prefetch(thumb)
+	}
+}
+
+var armConds = []string{
+	"eq", "ne", "cs", "hs", "cc", "lo", "mi", "pl", "vs", "vc", "hi", "ls", "ge", "lt", "gt", "le", "al",
+}
+
+var armCondBases = map[string]bool{
+	"b": true, "bl": true, "blx": true, "bx": true, "pop": true, "ldm": true, "ldmia": true, "mov": true, "ldr": true,
+}
+
+// armSplitCond splits the condition code of the branches, beq.w is b.
+func armSplitCond(mnemo string) (base string, cond bool) {
+	mnemo = strings.TrimSuffix(strings.TrimSuffix(mnemo, ".w"), ".n")
+	if n := len(mnemo); n > 2 && armCondBases[mnemo[:n-2]] {
+		for _, v := range armConds {
+			if mnemo[n-2:] == v {
+				return mnemo[:n-2], true
+			}
+		}
+	}
+	return mnemo, false
+}
+
+var armRegs = map[string]bool{
+	"r0": true, "r1": true, "r2": true, "r3": true, "r4": true, "r5": true, "r6": true, "r7": true,
+	"r8": true, "r9": true, "r10": true, "r11": true, "r12": true,
+	"sb": true, "sl": true, "fp": true, "ip": true, "lr": true,
+}
+
+// armBranch returns the kind of the branch, target is false for the ones
+// by register or by the memory.
+func armBranch(mnemo, opers string) (kind InstrKind, target bool) {
+	base, cond := armSplitCond(mnemo)
+	ret := InstrKind_Ret
+	jmp, ijmp := InstrKind_Jmp, InstrKind_Indirect_Jmp
+	if cond {
+		ret, jmp, ijmp = InstrKind_Cond_Jmp, InstrKind_Cond_Jmp, InstrKind_Cond_Jmp
+	}
+
+	switch base {
+	case "b":
+		return jmp, true
+	case "bl", "blx":
+		// blx r3
+		if armRegs[opers] {
+			return InstrKind_Indirect_Call, false
+		}
+		return InstrKind_Call, true
+	case "bx":
+		if opers == "lr" {
+			return ret, false
+		}
+		return ijmp, false
+	case "cbz", "cbnz":
+		return InstrKind_Cond_Jmp, true
+	case "tbb", "tbh":
+		return InstrKind_Jmp, false
+	case "pop", "ldm", "ldmia":
+		// pop {r4, pc}
+		// ldm sp!, {r4, pc}
+		if strings.HasSuffix(opers, "pc}") {
+			return ret, false
+		}
+	case "mov":
+		if opers == "pc, lr" {
+			return ret, false
+		}
+	case "ldr":
+		// ldr pc, [sp], #4
+		if opers == "pc, [sp], #4" {
+			return ret, false
+		}
+		if strings.HasPrefix(opers, "pc,") {
+			return ijmp, false
+		}
+	}
+	return InstrKind_Normal, false
+}
+
+// the loads from the literal pools
+var armLiteral = map[string]bool{
+	"ldr": true, "ldrb": true, "ldrh": true, "ldrsb": true, "ldrsh": true, "ldrd": true,
+	"vldr": true, "pld": true, "adr": true,
+}
+
+var (
+	reArmSp   = regexp.MustCompile(`^sp, (?:sp, )?#(\d+)$`)
+	reArmList = regexp.MustCompile(`^(?:sp!, )?\{(.+)\}$`)
+)
+
+// armRegList counts the registers of {r4-r7, lr}.
+func armRegList(s string) (n int64, err error) {
+	for _, v := range splitOperands(s) {
+		r := strings.SplitN(v, "-", 2)
+		if len(r) == 1 {
+			n++
+			continue
+		}
+		var lo, hi int64
+		if lo, err = strconv.ParseInt(strings.TrimLeft(r[0], "rsd"), 10, 64); err != nil {
+			return
+		}
+		if hi, err = strconv.ParseInt(strings.TrimLeft(r[1], "rsd"), 10, 64); err != nil {
+			return
+		}
+		n += hi - lo + 1
+	}
+	return
+}
+
+// armSP is the stack change of push, pop and the sp arithmetic.
+func armSP(mnemo, opers string) (sp int64, err error) {
+	base := strings.TrimSuffix(mnemo, ".w")
+	switch base {
+	case "push", "pop", "stmdb", "stmfd", "ldm", "ldmia", "ldmfd", "vpush", "vpop":
+		res := reArmList.FindStringSubmatch(opers)
+		if len(res) == 0 || (base != "push" && base != "pop" && base != "vpush" && base != "vpop" &&
+			!strings.HasPrefix(opers, "sp!,")) {
+			return
+		}
+		if sp, err = armRegList(res[1]); err != nil {
+			return
+		}
+		sp *= 4
+		// vpush {d8, d9}
+		if strings.HasPrefix(res[1], "d") {
+			sp *= 2
+		}
+		if base == "push" || base == "stmdb" || base == "stmfd" || base == "vpush" {
+			sp = -sp
+		}
+	case "sub", "add", "subw", "addw":
+		res := reArmSp.FindStringSubmatch(opers)
+		if len(res) == 0 {
+			return
+		}
+		if sp, err = strconv.ParseInt(res[1], 10, 64); err != nil {
+			return
+		}
+		if base[0] == 's' {
+			sp = -sp
+		}
+	}
+	return
+}
+
+var reArmIT = regexp.MustCompile(`^it[te]{0,3}$`)
+
+func (aa *archArm) Instr(ea int64, mnemo string, opers string, los []LabelOperand) (_ Instr, err error) {
+	ib := &instrBase{
+		kind:  InstrKind_Normal,
+		ea:    ea,
+		mnemo: mnemo,
+		opers: opers,
+		los:   los,
+	}
+
+	// .code 16
+	// .thumb_func
+	if ok, err1 := aa.setMode(mnemo, opers); ok || err1 != nil {
+		if mnemo == ".thumb_func" {
+			aa.thumbFuncs = append(aa.thumbFuncs, ib)
+		}
+		aa.modes = append(aa.modes, armMode{ib, aa.thumb})
+		ib.los = nil
+		return ib, err1
+	}
+
+	asm := aa.asm
+	if aa.thumb {
+		asm = aa.thumbAsm
+		if aa.itLeft > 0 {
+			asm = aa.itAsm
+			aa.itLeft--
+		}
+	}
+	// itte eq
+	if aa.thumb && reArmIT.MatchString(mnemo) {
+		aa.itLeft = len(mnemo) - 1
+	}
+
+	if len(los) > 0 {
+		// .long sym(GOT_PREL)-((.LPC0_0+8)-.Ltmp0)
+		if strings.Contains(opers, "(GOT") {
+			err = fmt.Errorf("got is unsupported, use the hidden visibility: %s %s", mnemo, opers)
+			return
+		}
+		// movw r0, :lower16:sym
+		if strings.Contains(opers, "16:%d") {
+			err = fmt.Errorf("absolute address is unsupported, use -fPIC: %s %s", mnemo, opers)
+			return
+		}
+	} else if ib.data, err = asm(mnemo, opers, ea); err != nil {
+		return
+	}
+
+	// push {r4, lr}
+	// sub sp, sp, #8
+	if ib.sp, err = armSP(mnemo, opers); err != nil {
+		return
+	}
+	// sub sp, sp, r0
+	// mov sp, r0
+	switch strings.TrimSuffix(mnemo, ".w") {
+	case "sub", "add", "mov":
+		// r7 is the frame pointer of thumb
+		ib.dynsp = ib.sp == 0 && isDynSP(splitOperands(opers), 0, "sp", "r11", "r7", "fp")
+	}
+
+	if mnemo == ".p2align" {
+		// the constant pools of double are 8 byte aligned, vldr only needs 4
+		if ib.opers = p2alignClamp(opers, 2); ib.opers != opers {
+			if ib.data, err = asm(mnemo, ib.opers, ea); err != nil {
+				return
+			}
+		}
+		ib.kind = InstrKind_P2Align
+		return instrRebuild{instrBase: ib, asm: asm}, nil
+	}
+
+	var target bool
+	if isData(mnemo) {
+		ib.kind = InstrKind_Data
+	} else {
+		ib.kind, target = armBranch(mnemo, opers)
+	}
+
+	if len(los) > 0 {
+		var stub int64
+		if ib.kind == InstrKind_Call || ib.kind == InstrKind_Jmp || ib.kind == InstrKind_Cond_Jmp {
+			stub = ea
+		}
+		il := instrLabel{instrBase: ib, asm: asm, stub: stub}
+		if ib.data, err = asm(mnemo, il.Operands(), ea); err != nil {
+			return
+		}
+		if target {
+			return instrArmBranch{instrLabel: il, aa: aa, thumb: aa.thumb}, nil
+		}
+		return il, nil
+	}
+
+	return ib, nil
+}
+
+// isThumbAt reports whether the code at ea is thumb.
+func (aa *archArm) isThumbAt(ea int64) (thumb bool) {
+	for _, v := range aa.modes {
+		if v.ins.EA() > ea {
+			break
+		}
+		thumb = v.thumb
+	}
+	return
+}
+
+// instrArmBranch is the branch to a label, bl to the function in the other
+// mode is blx like the linker does.
+type instrArmBranch struct {
+	instrLabel
+	aa    *archArm
+	thumb bool
+}
+
+func (ins instrArmBranch) Rebuild() (dif int64, err error) {
+	if lo := ins.LabelOperand(); lo.EA() != -1 && ins.aa.isThumbAt(lo.EA()) != ins.thumb {
+		switch base, cond := armSplitCond(ins.mnemo); {
+		case base == "blx":
+		case base == "bl" && !cond:
+			ins.mnemo = "blx"
+		default:
+			err = fmt.Errorf("branch between arm and thumb: %s %s", ins.mnemo, ins.opers)
+			return
+		}
+	}
+	return ins.instrLabel.Rebuild()
+}
+
+// WriteProg packs the 2 byte thumb instructions into WORD, arm has no BYTE.
+func (aa *archArm) WriteProg(w io.Writer, p *Prog) (err error) {
+	// TEXT symbols are only 4 byte aligned, see .p2align
+	if err = p.checkAlign(2); err != nil {
+		return
+	}
+
+	var buf []byte
+	var comments []string
+	flush := func() (err error) {
+		for ; len(buf) >= 4; buf = buf[4:] {
+			if _, err = fmt.Fprintf(w, "\tWORD $0x%x", binary.LittleEndian.Uint32(buf)); err != nil {
+				return
+			}
+			if len(comments) > 0 {
+				if _, err = fmt.Fprintf(w, " // %s", strings.Join(comments, "; ")); err != nil {
+					return
+				}
+				comments = nil
+			}
+			if _, err = w.Write([]byte{'\n'}); err != nil {
+				return
+			}
+		}
+		return
+	}
+
+	for _, bb := range p.bbs {
+		if bb.ID != "" {
+			var sdif string
+			if sz := len(buf); sz > 0 {
+				sdif = fmt.Sprintf(" // +%d", sz)
+			}
+			if _, err = fmt.Fprintf(w, "\n// %s:%s\n", bb.ID, sdif); err != nil {
+				return
+			}
+		}
+		for _, v := range bb.Instrs {
+			if v.Size() == 0 {
+				continue
+			}
+			if v.Kind() != InstrKind_Data && v.Kind() != InstrKind_P2Align {
+				comment := fmt.Sprintf("%s\t%s", v.Mnemonic(), v.Operands())
+				if lbl := v.LabelNames(); lbl != "" {
+					comment = fmt.Sprintf("%s // %s", comment, lbl)
+				}
+				comments = append(comments, comment)
+			}
+			buf = append(buf, v.Byte()...)
+			if err = flush(); err != nil {
+				return
+			}
+		}
+	}
+	// thumb may end in the middle of a word
+	if len(buf) > 0 {
+		buf = append(buf, make([]byte, 4-len(buf))...)
+		err = flush()
+	}
+	return
+}
+
+func (aa *archArm) EntryBlock() (*BasicBlock, error) {
+	return buildEntryBlock(aa.asm,
+		"sub", "r0, pc, #8",
+		"str", "r0, [sp, #4]",
+		"bx", "lr",
+	)
+}
+
+func (aa *archArm) WriteHead(w io.Writer) (err error) {
+	return
+}
+
+func (aa *archArm) isThumbFunc(fpos int64) bool {
+	for _, v := range aa.thumbFuncs {
+		if v.EA() == fpos {
+			return true
+		}
+	}
+	return false
+}
+
+// armVldrS is vldr sN, [sp, #off], go has no odd single registers.
+func armVldrS(n, off int) uint32 {
+	return 0xed9d0a00 | uint32(n&1)<<22 | uint32(n>>1)<<12 | uint32(off/4)
+}
+
+func (aa *archArm) WriteFunc(w io.Writer, f *Function, spsize, fpos int64) (err error) {
+	type stackArg struct {
+		*Parameter
+		off, slot int
+	}
+
+	// aapcs-vfp, r0-r3 and s0-s15 with the back-filling, the rest are on
+	// the stack and 8 byte aligned by the size
+	var regs []string
+	var stack []stackArg
+	var ncrn, nsaa, soff int
+	var vfp uint16
+	var vfpFull bool
+	nextOff := func(sz, align int) (r int) {
+		r = (soff + align - 1) &^ (align - 1)
+		soff = r + sz
+		return
+	}
+
+	// the narrow integers are extended to a word by the sign of the type
+	loadOp := func(v *Parameter) string {
+		switch {
+		case v.Size == 1 && v.Signed:
+			return "MOVB"
+		case v.Size == 1:
+			return "MOVBU"
+		case v.Size == 2 && v.Signed:
+			return "MOVH"
+		case v.Size == 2:
+			return "MOVHU"
+		}
+		return "MOVW"
+	}
+	for _, v := range f.Args {
+		off := nextOff(v.Size, v.Align)
+		n := (v.Size + 3) / 4
+
+		if v.IsFloat && !vfpFull {
+			mask := uint16(1)<<n - 1
+			i := 0
+			for ; i < 16 && vfp&(mask<<i) != 0; i += n {
+			}
+			if i < 16 {
+				vfp |= mask << i
+				switch {
+				case n == 2:
+					regs = append(regs, fmt.Sprintf("MOVD %s+%d(FP), F%d", v.Name, off, i/2))
+				case i%2 == 0:
+					regs = append(regs, fmt.Sprintf("MOVF %s+%d(FP), F%d", v.Name, off, i/2))
+				default:
+					regs = append(regs, fmt.Sprintf("WORD $0x%x // vldr s%d, %s+%d(FP)", armVldrS(i, off+4), i, v.Name, off))
+				}
+				continue
+			}
+			vfpFull = true
+		} else if !v.IsFloat {
+			if n == 2 {
+				ncrn = (ncrn + 1) &^ 1
+			}
+			if ncrn+n <= 4 {
+				if n == 2 {
+					regs = append(regs, fmt.Sprintf("MOVW %s_lo+%d(FP), R%d", v.Name, off, ncrn),
+						fmt.Sprintf("MOVW %s_hi+%d(FP), R%d", v.Name, off+4, ncrn+1))
+				} else {
+					regs = append(regs, fmt.Sprintf("%s %s+%d(FP), R%d", loadOp(v), v.Name, off, ncrn))
+				}
+				ncrn += n
+				continue
+			}
+			ncrn = 4
+		}
+
+		nsaa = (nsaa + n*4 - 1) &^ (n*4 - 1)
+		stack = append(stack, stackArg{v, off, nsaa})
+		nsaa += n * 4
+	}
+	outsize := (nsaa + 7) &^ 7
+
+	if spsize != 0 {
+		// the outgoing arguments and the stack realignment
+		if _, err = fmt.Fprintf(w, `
+_entry:
+	MOVW 8(g), R8
+	SUB $%d, R13, R9
+	CMP R8, R9
+	BLS _stack_grow
+`, spsize+int64(outsize)+8); err != nil {
+			return
+		}
+	}
+
+	if _, err = fmt.Fprintf(w, "\n%s:\n", f.Name[1:]); err != nil {
+		return
+	}
+	for _, v := range regs {
+		if _, err = fmt.Fprintf(w, "\t%s\n", v); err != nil {
+			return
+		}
+	}
+
+	// r4 and r5 are callee-saved, the stack is 8 byte aligned at the call
+	if _, err = fmt.Fprint(w, "\tMOVW R13, R4\n\tMOVW R14, R5\n"); err != nil {
+		return
+	}
+	if outsize != 0 {
+		if _, err = fmt.Fprintf(w, "\tSUB $%d, R13, R12\n\tBIC $7, R12, R13\n", outsize); err != nil {
+			return
+		}
+	} else if _, err = fmt.Fprint(w, "\tBIC $7, R13\n"); err != nil {
+		return
+	}
+	for _, v := range stack {
+		// the arguments are after the return address
+		op := loadOp(v.Parameter)
+		for i := 0; i < v.Size; i += 4 {
+			if _, err = fmt.Fprintf(w, "\t%s %d(R4), R12\n\tMOVW R12, %d(R13)\n", op, v.off+i+4, v.slot+i); err != nil {
+				return
+			}
+		}
+	}
+
+	// the thumb function is called by the odd address
+	var thumb string
+	if aa.isThumbFunc(fpos) {
+		thumb = "\tORR $1, R12\n"
+	}
+	if _, err = fmt.Fprintf(w, `	MOVW ·_subr%s(SB), R12
+%s	BL (R12)
+	MOVW R4, R13
+	MOVW R5, R14
+`, f.Name, thumb); err != nil {
+		return
+	}
+
+	soff = nextOff(0, f.PtrSize)
+	if v := f.Ret; v != nil {
+		off := nextOff(v.Size, v.Align)
+		switch {
+		case v.IsFloat && v.Size == 4:
+			_, err = fmt.Fprintf(w, "\tMOVF F0, %s+%d(FP)\n", v.Name, off)
+		case v.IsFloat:
+			_, err = fmt.Fprintf(w, "\tMOVD F0, %s+%d(FP)\n", v.Name, off)
+		case v.Size == 8:
+			_, err = fmt.Fprintf(w, "\tMOVW R0, %s_lo+%d(FP)\n\tMOVW R1, %s_hi+%d(FP)\n", v.Name, off, v.Name, off+4)
+		default:
+			_, err = fmt.Fprintf(w, "\t%s R0, %s+%d(FP)\n", map[int]string{1: "MOVB", 2: "MOVH", 4: "MOVW"}[v.Size], v.Name, off)
+		}
+		if err != nil {
+			return
+		}
+	}
+	if _, err = fmt.Fprint(w, "\tRET\n"); err != nil {
+		return
+	}
+
+	if spsize != 0 {
+		if _, err = fmt.Fprintf(w, `
+_stack_grow:
+	MOVW R14, R3
+	CALL runtime·morestack_noctxt<>(SB)
+	JMP _entry
+`); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (aa *archArm) SubrEntry(w io.Writer) (string, error) {
+	return "", nil
+}
@@ -3,51 +3,93 @@ package main
 import (
 	"bytes"
 	"encoding/binary"
-	"errors"
 	"fmt"
 	"io"
 	"regexp"
 	"strconv"
 	"strings"
-
-	"github.com/keystone-engine/keystone/bindings/go/keystone"
 )
 
 type archArm64 struct {
-	ks       *keystone.Keystone
+	asmArch
+	wordWriter
 	alignOff int64
+	goos     string
 }
 
-func newArm64() (_ *archArm64, err error) {
-	ks, err := keystone.New(keystone.ARCH_ARM64, keystone.MODE_LITTLE_ENDIAN)
-	if err != nil {
-		return
-	}
-
-	return &archArm64{ks: ks}, nil
+func newArm64(as Assembler, goos string) (_ *archArm64, err error) {
+	return &archArm64{asmArch: asmArch{as: as}, wordWriter: wordWriter{binary.LittleEndian}, goos: goos}, nil
 }
 
-func (aa *archArm64) Close() {
-	aa.ks.Close()
+func (aa *archArm64) CommentTokens() []string {
+	// darwin, gnu
+	return []string{";", "//"}
 }
 
-func (aa *archArm64) asm(mnemo, opers string, address int64) (data []byte, err error) {
-	data, _, ok := aa.ks.Assemble(fmt.Sprintf("%s %s", mnemo, opers), uint64(address))
-	if !ok {
-		if err = aa.ks.LastError(); err == nil {
-			err = fmt.Errorf("[%d] %s %s, asm failed", address, mnemo, opers)
-		}
+var reArm64GotLoad = regexp.MustCompile(`^(\w+), \[(\w+), (.+)\]$`)
+
+// x18 is the teb on windows, the code for linux may use it as a temporary
+var reArm64X18 = regexp.MustCompile(`^(?:[xw]\d+, )?[xw]18\b`)
+
+// isArm64X18Write checks the destinations of x18, the first operand, or
+// the second one of ldp.
+func isArm64X18Write(mnemo, opers string) bool {
+	switch {
+	case !arm64WritesFirst(mnemo):
+		return false
+	case mnemo == "ldp":
+		return reArm64X18.MatchString(opers)
 	}
-	return
+	return strings.HasPrefix(opers, "x18,") || strings.HasPrefix(opers, "w18,")
 }
 
-func (aa *archArm64) CommentToken() string {
-	return ";"
+// arm64WritesFirst reports whether the first operand is a destination.
+func arm64WritesFirst(mnemo string) bool {
+	switch {
+	case strings.HasPrefix(mnemo, "st"), strings.HasPrefix(mnemo, "cb"), strings.HasPrefix(mnemo, "tb"),
+		mnemo == "cmp", mnemo == "cmn", mnemo == "tst", mnemo == "ccmp", mnemo == "ccmn",
+		mnemo == "prfm", arm64BranchKind(mnemo) != InstrKind_Normal:
+		return false
+	}
+	return true
 }
 
-var reArm64Sp = regexp.MustCompile(`^([^\[]+\[sp, #(-\d+)\]!|[^\[]+\[sp\], #(\d+)|sp, sp, #(\d+))$`)
+// arm64BranchKind classifies the control flow, the pointer authentication
+// ones (braa, blraaz, retab) too.
+func arm64BranchKind(mnemo string) InstrKind {
+	switch mnemo {
+	case "ret", "retaa", "retab":
+		return InstrKind_Ret
+	case "bl":
+		return InstrKind_Call
+	case "b":
+		return InstrKind_Jmp
+	case "cbz", "cbnz", "tbz", "tbnz":
+		return InstrKind_Cond_Jmp
+	case "br", "braa", "brab", "braaz", "brabz":
+		return InstrKind_Indirect_Jmp
+	case "blr", "blraa", "blrab", "blraaz", "blrabz":
+		return InstrKind_Indirect_Call
+	}
+	// b.eq, bc.eq
+	if strings.HasPrefix(mnemo, "b.") || strings.HasPrefix(mnemo, "bc.") {
+		return InstrKind_Cond_Jmp
+	}
+	return InstrKind_Normal
+}
 
 func (aa *archArm64) Instr(ea int64, mnemo string, opers string, los []LabelOperand) (_ Instr, err error) {
+	// ldr x0, [x0, :got_lo12:sym]
+	// ldr x0, [x0, _sym@GOTPAGEOFF]
+	if mnemo == "ldr" && len(los) > 0 && los[0].got {
+		mnemo, opers = "add", reArm64GotLoad.ReplaceAllString(opers, "$1, $2, $3")
+	}
+
+	if aa.goos == "windows" && isArm64X18Write(mnemo, opers) {
+		err = fmt.Errorf("x18 is reserved on windows, use the windows target: %s %s", mnemo, opers)
+		return
+	}
+
 	ib := &instrBase{
 		kind:  InstrKind_Normal,
 		ea:    ea,
@@ -62,51 +104,20 @@ func (aa *archArm64) Instr(ea int64, mnemo string, opers string, los []LabelOper
 		}
 	}
 
-	// stp x24, x23, [sp, #-64]!
-	// ldp x24, x23, [sp], #64
-	// add sp, sp, #64
-	// sub sp, sp, #96
-	if mnemo == "stp" || mnemo == "ldp" || mnemo == "add" || mnemo == "sub" {
-		if res := reArm64Sp.FindStringSubmatch(opers); len(res) > 0 {
-			var sp int64
-			for i := len(res) - 1; i >= 0; i-- {
-				if res[i] != "" {
-					if sp, err = strconv.ParseInt(res[i], 10, 64); err != nil {
-						return
-					}
-					break
-				}
-			}
-			if mnemo == "sub" {
-				sp = -sp
-			}
-
-			ib.sp = sp
-			return ib, nil
-		}
-	}
-
 	if mnemo == ".p2align" {
 		ib.kind = InstrKind_P2Align
 		return instrRebuild{instrBase: ib, asm: aa.asm}, nil
 	}
 
-	switch {
-	case isData(mnemo):
+	if isData(mnemo) {
 		ib.kind = InstrKind_Data
-	case mnemo == "ret":
-		ib.kind = InstrKind_Ret
-	case mnemo == "bl":
-		ib.kind = InstrKind_Call
-	case mnemo == "b":
-		ib.kind = InstrKind_Jmp
-	case strings.HasPrefix(mnemo, "b."):
-		ib.kind = InstrKind_Cond_Jmp
+	} else {
+		ib.kind = arm64BranchKind(mnemo)
 	}
 
 	if len(los) > 0 {
 		var stub int64
-		if mnemo == "bl" || mnemo == "b" || strings.HasPrefix(mnemo, "b.") {
+		if ib.kind == InstrKind_Call || ib.kind == InstrKind_Jmp || ib.kind == InstrKind_Cond_Jmp {
 			stub = ea
 		}
 		il := instrLabel{instrBase: ib, asm: aa.asm, stub: stub}
@@ -119,99 +130,11 @@ func (aa *archArm64) Instr(ea int64, mnemo string, opers string, los []LabelOper
 	return ib, nil
 }
 
-func (aa *archArm64) bytes(w io.Writer, data []byte, comment string) (_ []byte, err error) {
-	flag := comment == ""
-	for len(data) >= 4 {
-		switch {
-		// disable because
-		// 1. cause align padding, (WORD PAD DWORD)
-		// 2. operand must be great than 2^32
-		// case len(data) >= 8:
-		// 	_, err = fmt.Fprintf(w, "\tDWORD $0x%x", binary.LittleEndian.Uint64(data[:8]))
-		// 	data = data[8:]
-		case len(data) >= 4:
-			_, err = fmt.Fprintf(w, "\tWORD $0x%x", binary.LittleEndian.Uint32(data[:4]))
-			data = data[4:]
-		}
-		if err != nil {
-			return
-		}
-
-		if !flag {
-			flag = true
-
-			if _, err = fmt.Fprintf(w, " // %s", comment); err != nil {
-				return
-			}
-		}
-
-		if _, err = w.Write([]byte{'\n'}); err != nil {
-			return
-		}
-	}
-	return data, nil
-}
-
-func (aa *archArm64) writeBB(w io.Writer, bb *BasicBlock, prev []byte) (_ []byte, err error) {
-	if len(prev) > 0 {
-		if bb.Instrs[0].Kind() != InstrKind_Data {
-			err = errors.New("only data instr can be appended")
-			return
-		}
-	}
-
-	for _, v := range bb.Instrs {
-		if len(prev) > 0 || (v.Kind() == InstrKind_Data || v.Kind() == InstrKind_P2Align) &&
-			v.Mnemonic() != ".long" && v.Mnemonic() != ".quad" {
-			prev = append(prev, v.Byte()...)
-			continue
-		}
-
-		comment := fmt.Sprintf("%s\t%s", v.Mnemonic(), v.Operands())
-		if lbl := v.LabelNames(); lbl != "" {
-			comment = fmt.Sprintf("%s // %s", comment, lbl)
-		}
-		if data, err1 := aa.bytes(w, v.Byte(), comment); err1 != nil {
-			err = err1
-			return
-		} else if len(data) > 0 {
-			err = errors.New("what's up?")
-			return
-		}
-	}
-
-	if len(prev) > 0 {
-		return aa.bytes(w, prev, "")
-	}
-	return
-}
-
 func (aa *archArm64) WriteProg(w io.Writer, p *Prog) error {
-	var prev []byte
-	var sz int64
 	var buf bytes.Buffer
-	mw := io.MultiWriter(w, &buf)
-	for _, bb := range p.bbs {
-		if bb.ID != "" {
-			var sdif string
-			if sz := len(prev); sz > 0 {
-				sdif = fmt.Sprintf(" // +%d", sz)
-			}
-			if _, err := fmt.Fprintf(mw, "\n// %s:%s\n", bb.ID, sdif); err != nil {
-				return err
-			}
-		}
-		if data, err := aa.writeBB(mw, bb, prev); err != nil {
-			return err
-		} else if len(data) > 0 {
-			prev = data
-		} else {
-			prev = prev[:0]
-		}
-		sz += bb.Size()
-	}
-	if len(prev) > 0 {
-		return errors.New("remaining prev data")
+	sz, err := aa.writeProg(io.MultiWriter(w, &buf), p)
+	if err != nil {
+		return err
 	}
 
 	pad := 2048 - (sz & 4095)
@@ -219,7 +142,7 @@ func (aa *archArm64) WriteProg(w io.Writer, p *Prog) error {
 		pad += 4096
 	}
 	if pad&3 != 0 {
-		return errors.New("what's up?")
+		return fmt.Errorf("code size is not a multiple of 4: %d", sz)
 	}
 	aa.alignOff = sz + pad
 
@@ -244,33 +167,6 @@ func (aa *archArm64) WriteProg(w io.Writer, p *Prog) error {
 	return nil
 }
 
-func buildEntryBlock(asm asmfunc, op ...string) (bb *BasicBlock, err error) {
-	bb = &BasicBlock{}
-	var ea int64
-	addInstr := func(mnemo, opers string) (err error) {
-		data, err := asm(mnemo, opers, ea)
-		if err != nil {
-			return
-		}
-		ea += int64(len(data))
-		bb.Instrs = append(bb.Instrs, &instrBase{
-			kind:  InstrKind_Normal,
-			ea:    ea,
-			mnemo: mnemo,
-			opers: opers,
-			data:  data,
-		})
-		return
-	}
-
-	for i := 0; i < len(op)/2; i++ {
-		if err = addInstr(op[i*2], op[i*2+1]); err != nil {
-			return
-		}
-	}
-	return
-}
-
 func (aa *archArm64) EntryBlock() (*BasicBlock, error) {
 	return buildEntryBlock(aa.asm,
 		"adr", "x0, 0",
@@ -285,7 +181,7 @@ func (aa *archArm64) WriteHead(w io.Writer) (err error) {
 }
 
 func (aa *archArm64) WriteFunc(w io.Writer, f *Function, spsize, fpos int64) (err error) {
-	if spsize != 0 {
+	if spsize != 0 && !f.NativeStack {
 		if _, err = fmt.Fprintf(w, `
 _entry:
 	MOVD 16(g), R16
@@ -300,7 +196,6 @@ _entry:
 	if _, err = fmt.Fprintf(w, "\n%s:\n", f.Name[1:]); err != nil {
 		return
 	}
-
 	getReg := func(idx int, fp bool) string {
 		idxs := strconv.Itoa(idx)
 		if fp {
@@ -326,7 +221,7 @@ _entry:
 			}
 			return "MOVD"
 		default:
-			panic("oops")
+			panic(fmt.Sprintf("unexpected parameter size: %d", sz))
 		}
 	}
 
@@ -358,8 +253,31 @@ _entry:
 		return
 	}
 
+	// the native stack top is the last argument
+	var noff int
+	if f.NativeStack {
+		noff = nextOff(8)
+	}
 	soff = nextOff(8)
-	if f.Ret == nil {
+	if f.NativeStack {
+		// R22 keeps the old RSP
+		_, err = fmt.Fprintf(w,
+			`	MOVD R29, R19
+	MOVD R30, R20
+	MOVD RSP, R22
+	MOVD nstack+%d(FP), R10
+	MOVD R10, RSP
+	CALL (%s)
+	MOVD R22, RSP
+`, noff, rcall)
+		if err == nil && f.Ret != nil {
+			_, err = fmt.Fprintf(w, "\t%s %s, %s+%d(FP)\n", getOp(f.Ret.Size, f.Ret.IsFloat), getReg(0, f.Ret.IsFloat),
+				f.Ret.Name, nextOff(f.Ret.Size))
+		}
+		if err == nil {
+			_, err = fmt.Fprint(w, "\tMOVD R20, R30\n\tMOVD R19, R29\n\tRET\n")
+		}
+	} else if f.Ret == nil {
 		_, err = fmt.Fprintf(w, "\tJMP (%s)\n", rcall)
 	} else {
 		_, err = fmt.Fprintf(w,
@@ -377,7 +295,7 @@ _entry:
 		return
 	}
 
-	if spsize != 0 {
+	if spsize != 0 && !f.NativeStack {
 		if _, err = fmt.Fprintf(w, `
 _stack_grow:
 	MOVD R30, R3
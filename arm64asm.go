@@ -0,0 +1,1677 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// pure go encoder for the A64 subset emitted by clang,
+// both generic and apple (add.4s v0, v1, v2) syntax are accepted.
+
+var arm64Nop = []byte{0x1f, 0x20, 0x03, 0xd5}
+
+func arm64Asm(mnemo, opers string, address int64) (data []byte, err error) {
+	if strings.HasPrefix(mnemo, ".") {
+		return asmDirective(mnemo, opers, address, binary.LittleEndian, arm64Nop)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(a64Error)
+			if !ok {
+				panic(r)
+			}
+			err = fmt.Errorf("[%d] %s %s, %s", address, mnemo, opers, string(e))
+		}
+	}()
+
+	a := newA64(mnemo, opers, address)
+	data = make([]byte, 4)
+	binary.LittleEndian.PutUint32(data, a.encode())
+	return
+}
+
+type a64Error string
+
+func a64Fail(format string, args ...interface{}) {
+	panic(a64Error(fmt.Sprintf(format, args...)))
+}
+
+type a64Kind int
+
+const (
+	a64None a64Kind = iota
+	a64X
+	a64W
+	a64SP
+	a64WSP
+	a64B
+	a64H
+	a64S
+	a64D
+	a64Q
+	a64V
+)
+
+type a64Reg struct {
+	kind a64Kind
+	n    uint32
+	arr  string // 4s, 16b...
+	elem string // b, h, s, d
+	idx  int    // element index, -1 if none
+}
+
+func (r a64Reg) isGP() bool {
+	return r.kind >= a64X && r.kind <= a64WSP
+}
+
+func (r a64Reg) isFP() bool {
+	return r.kind >= a64B && r.kind <= a64Q
+}
+
+// 64-bit general register
+func (r a64Reg) sf() uint32 {
+	if r.kind == a64X || r.kind == a64SP {
+		return 1
+	}
+	return 0
+}
+
+type a64OpType int
+
+const (
+	a64OpReg a64OpType = iota
+	a64OpImm
+	a64OpFImm
+	a64OpShift
+	a64OpExt
+	a64OpMem
+	a64OpList
+	a64OpName
+)
+
+type a64Op struct {
+	typ  a64OpType
+	reg  a64Reg
+	imm  int64
+	fimm float64
+
+	name   string // shift/extend/condition/prefetch name
+	hasAmt bool
+
+	// memory
+	base, index *a64Op
+	ext         *a64Op
+	pre         bool
+
+	list []a64Reg
+}
+
+type a64 struct {
+	mnemo string
+	t     string // vector arrangement or element type
+	ops   []a64Op
+	pc    int64
+}
+
+func newA64(mnemo, opers string, pc int64) *a64 {
+	a := &a64{mnemo: strings.ToLower(mnemo), pc: pc}
+	// apple syntax: add.4s
+	if idx := strings.IndexByte(a.mnemo, '.'); idx != -1 && !strings.HasPrefix(a.mnemo, "b.") && !strings.HasPrefix(a.mnemo, "bc.") {
+		a.mnemo, a.t = a.mnemo[:idx], a.mnemo[idx+1:]
+	}
+	for _, v := range splitOperands(opers) {
+		a.ops = append(a.ops, parseA64Op(v))
+	}
+	if a.t == "" {
+	out:
+		for _, v := range a.ops {
+			switch {
+			case v.typ == a64OpReg && v.reg.kind == a64V:
+				a.t = v.reg.arr
+				if a.t == "" {
+					a.t = v.reg.elem
+				}
+				break out
+			case v.typ == a64OpList:
+				a.t = v.list[0].arr
+				if a.t == "" {
+					a.t = v.list[0].elem
+				}
+				break out
+			}
+		}
+	}
+	return a
+}
+
+var a64RegKinds = map[byte]a64Kind{
+	'x': a64X, 'w': a64W, 'b': a64B, 'h': a64H, 's': a64S, 'd': a64D, 'q': a64Q, 'v': a64V,
+}
+
+func parseA64Reg(s string) (r a64Reg, ok bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	r.idx = -1
+	switch s {
+	case "sp":
+		return a64Reg{kind: a64SP, n: 31, idx: -1}, true
+	case "wsp":
+		return a64Reg{kind: a64WSP, n: 31, idx: -1}, true
+	case "xzr":
+		return a64Reg{kind: a64X, n: 31, idx: -1}, true
+	case "wzr":
+		return a64Reg{kind: a64W, n: 31, idx: -1}, true
+	case "fp":
+		return a64Reg{kind: a64X, n: 29, idx: -1}, true
+	case "lr":
+		return a64Reg{kind: a64X, n: 30, idx: -1}, true
+	}
+	if s == "" {
+		return
+	}
+	kind, found := a64RegKinds[s[0]]
+	if !found {
+		return
+	}
+	end := 1
+	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+		end++
+	}
+	n, err := strconv.Atoi(s[1:end])
+	if err != nil || n > 31 || (n == 31 && (kind == a64X || kind == a64W)) {
+		return
+	}
+	r.kind, r.n = kind, uint32(n)
+	rest := s[end:]
+	if kind != a64V {
+		return r, rest == ""
+	}
+	// v0.4s v0.s[1] v0[1]
+	if strings.HasPrefix(rest, ".") {
+		rest = rest[1:]
+		end = strings.IndexByte(rest, '[')
+		if end == -1 {
+			end = len(rest)
+		}
+		if t := rest[:end]; t[0] >= '0' && t[0] <= '9' {
+			r.arr = t
+		} else {
+			r.elem = t
+		}
+		rest = rest[end:]
+	}
+	if strings.HasPrefix(rest, "[") && strings.HasSuffix(rest, "]") {
+		if r.idx, err = strconv.Atoi(rest[1 : len(rest)-1]); err != nil {
+			return
+		}
+		rest = ""
+	}
+	return r, rest == ""
+}
+
+func parseA64Imm(s string) (op a64Op, ok bool) {
+	s = strings.TrimSpace(strings.TrimPrefix(s, "#"))
+	if s == "" {
+		return
+	}
+	if c := s[0]; !(c >= '0' && c <= '9' || c == '-' || c == '+' || c == '(' || c == '~') {
+		return
+	}
+	lower := strings.ToLower(s)
+	if strings.Contains(s, ".") || (!strings.Contains(lower, "0x") && strings.ContainsAny(lower, "e")) {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return
+		}
+		return a64Op{typ: a64OpFImm, fimm: f}, true
+	}
+	v, err := evalExpr(s)
+	if err != nil {
+		return
+	}
+	return a64Op{typ: a64OpImm, imm: v}, true
+}
+
+var a64Shifts = map[string]bool{"lsl": true, "lsr": true, "asr": true, "ror": true, "msl": true}
+
+var a64Exts = map[string]uint32{
+	"uxtb": 0, "uxth": 1, "uxtw": 2, "uxtx": 3,
+	"sxtb": 4, "sxth": 5, "sxtw": 6, "sxtx": 7,
+}
+
+func parseA64Op(s string) (op a64Op) {
+	s = strings.TrimSpace(s)
+	switch {
+	case strings.HasPrefix(s, "["):
+		return parseA64Mem(s)
+	case strings.HasPrefix(s, "{"):
+		return parseA64List(s)
+	}
+
+	if r, ok := parseA64Reg(s); ok {
+		return a64Op{typ: a64OpReg, reg: r}
+	}
+	if op, ok := parseA64Imm(s); ok {
+		return op
+	}
+
+	// lsl #2, sxtw, sxtw #2
+	name, amt := s, ""
+	if idx := strings.IndexAny(s, " \t#"); idx != -1 {
+		name, amt = strings.TrimSpace(s[:idx]), strings.TrimSpace(s[idx:])
+	}
+	name = strings.ToLower(name)
+	_, isExt := a64Exts[name]
+	if a64Shifts[name] || isExt {
+		op = a64Op{typ: a64OpShift, name: name}
+		if isExt {
+			op.typ = a64OpExt
+		}
+		if amt != "" {
+			imm, ok := parseA64Imm(amt)
+			if !ok || imm.typ != a64OpImm {
+				a64Fail("invalid shift amount: %s", s)
+			}
+			op.imm, op.hasAmt = imm.imm, true
+		}
+		return
+	}
+	if amt != "" {
+		a64Fail("invalid operand: %s", s)
+	}
+	return a64Op{typ: a64OpName, name: name}
+}
+
+func parseA64Mem(s string) (op a64Op) {
+	op.typ = a64OpMem
+	if strings.HasSuffix(s, "!") {
+		op.pre = true
+		s = strings.TrimSpace(s[:len(s)-1])
+	}
+	if !strings.HasSuffix(s, "]") {
+		a64Fail("invalid memory operand: %s", s)
+	}
+	arr := splitOperands(s[1 : len(s)-1])
+	for i, v := range arr {
+		sub := parseA64Op(v)
+		switch {
+		case i == 0:
+			if sub.typ != a64OpReg || !sub.reg.isGP() {
+				a64Fail("invalid base register: %s", s)
+			}
+			op.base = &sub
+		case i == 1:
+			op.index = &sub
+		case i == 2:
+			op.ext = &sub
+		default:
+			a64Fail("invalid memory operand: %s", s)
+		}
+	}
+	if op.base == nil {
+		a64Fail("invalid memory operand: %s", s)
+	}
+	return
+}
+
+func parseA64List(s string) (op a64Op) {
+	op.typ = a64OpList
+	op.reg.idx = -1
+	end := strings.LastIndexByte(s, '}')
+	if end == -1 {
+		a64Fail("invalid register list: %s", s)
+	}
+	// {v0.s, v1.s}[1]
+	if rest := strings.TrimSpace(s[end+1:]); rest != "" {
+		if !strings.HasPrefix(rest, "[") || !strings.HasSuffix(rest, "]") {
+			a64Fail("invalid register list: %s", s)
+		}
+		idx, err := strconv.Atoi(rest[1 : len(rest)-1])
+		if err != nil {
+			a64Fail("invalid register list: %s", s)
+		}
+		op.reg.idx = idx
+	}
+	for _, v := range splitOperands(s[1:end]) {
+		// {v0.4s-v3.4s}
+		if lh := strings.SplitN(v, "-", 2); len(lh) == 2 {
+			first, ok1 := parseA64Reg(lh[0])
+			last, ok2 := parseA64Reg(lh[1])
+			if !ok1 || !ok2 || first.kind != a64V {
+				a64Fail("invalid register list: %s", s)
+			}
+			for n := first.n; ; n = (n + 1) & 31 {
+				r := first
+				r.n = n
+				op.list = append(op.list, r)
+				if n == last.n {
+					break
+				}
+			}
+			continue
+		}
+		r, ok := parseA64Reg(v)
+		if !ok || r.kind != a64V {
+			a64Fail("invalid register list: %s", s)
+		}
+		op.list = append(op.list, r)
+	}
+	if len(op.list) == 0 || len(op.list) > 4 {
+		a64Fail("invalid register list: %s", s)
+	}
+	for i := 1; i < len(op.list); i++ {
+		if op.list[i].n != (op.list[i-1].n+1)&31 {
+			a64Fail("register list must be sequential: %s", s)
+		}
+	}
+	if op.reg.idx != -1 {
+		for i := range op.list {
+			op.list[i].idx = op.reg.idx
+		}
+	}
+	return
+}
+
+////////////////////////
+
+func (a *a64) nops(n ...int) {
+	for _, v := range n {
+		if len(a.ops) == v {
+			return
+		}
+	}
+	a64Fail("invalid operand count")
+}
+
+func (a *a64) op(i int) *a64Op {
+	if i >= len(a.ops) {
+		a64Fail("missing operand")
+	}
+	return &a.ops[i]
+}
+
+func (a *a64) isReg(i int, kinds ...a64Kind) bool {
+	if i >= len(a.ops) || a.ops[i].typ != a64OpReg {
+		return false
+	}
+	for _, v := range kinds {
+		if a.ops[i].reg.kind == v {
+			return true
+		}
+	}
+	return len(kinds) == 0
+}
+
+func (a *a64) isGP(i int) bool {
+	return i < len(a.ops) && a.ops[i].typ == a64OpReg && a.ops[i].reg.isGP()
+}
+
+func (a *a64) isFP(i int) bool {
+	return i < len(a.ops) && a.ops[i].typ == a64OpReg && a.ops[i].reg.isFP()
+}
+
+func (a *a64) isV(i int) bool {
+	return a.isReg(i, a64V)
+}
+
+func (a *a64) isImm(i int) bool {
+	return i < len(a.ops) && a.ops[i].typ == a64OpImm
+}
+
+// general register, sp selects whether register 31 is sp or zr
+func (a *a64) gp(i int, sp bool) (n, sf uint32) {
+	op := a.op(i)
+	if op.typ != a64OpReg || !op.reg.isGP() {
+		a64Fail("operand %d: expect general register", i+1)
+	}
+	isSP := op.reg.kind == a64SP || op.reg.kind == a64WSP
+	if op.reg.n == 31 && isSP != sp {
+		a64Fail("operand %d: invalid use of sp/zr", i+1)
+	}
+	return op.reg.n, op.reg.sf()
+}
+
+func (a *a64) gpSF(i int, sp bool, sf uint32) uint32 {
+	n, s := a.gp(i, sp)
+	if s != sf {
+		a64Fail("operand %d: register width mismatch", i+1)
+	}
+	return n
+}
+
+// 0: b, 1: h, 2: s, 3: d, 4: q
+func (a *a64) fp(i int) (n, size uint32) {
+	op := a.op(i)
+	if op.typ != a64OpReg || !op.reg.isFP() {
+		a64Fail("operand %d: expect fp/simd register", i+1)
+	}
+	return op.reg.n, uint32(op.reg.kind - a64B)
+}
+
+func (a *a64) fpSize(i int, size uint32) uint32 {
+	n, s := a.fp(i)
+	if s != size {
+		a64Fail("operand %d: register size mismatch", i+1)
+	}
+	return n
+}
+
+func (a *a64) v(i int) uint32 {
+	op := a.op(i)
+	if op.typ != a64OpReg || op.reg.kind != a64V {
+		a64Fail("operand %d: expect vector register", i+1)
+	}
+	return op.reg.n
+}
+
+func (a *a64) vIdx(i int) (n uint32, idx int) {
+	n = a.v(i)
+	if idx = a.ops[i].reg.idx; idx < 0 {
+		a64Fail("operand %d: expect vector element", i+1)
+	}
+	return
+}
+
+func (a *a64) imm(i int) int64 {
+	op := a.op(i)
+	if op.typ != a64OpImm {
+		a64Fail("operand %d: expect immediate", i+1)
+	}
+	return op.imm
+}
+
+func (a *a64) fimm(i int) float64 {
+	op := a.op(i)
+	switch op.typ {
+	case a64OpFImm:
+		return op.fimm
+	case a64OpImm:
+		return float64(op.imm)
+	}
+	a64Fail("operand %d: expect float immediate", i+1)
+	return 0
+}
+
+func (a *a64) uimm(i int, bits uint) uint32 {
+	v := a.imm(i)
+	if v < 0 || v >= 1<<bits {
+		a64Fail("operand %d: immediate out of range", i+1)
+	}
+	return uint32(v)
+}
+
+// pc relative offset, scaled
+func (a *a64) rel(i int, bits uint, shift uint) uint32 {
+	off := a.imm(i) - a.pc
+	if off&(1<<shift-1) != 0 {
+		a64Fail("operand %d: misaligned target", i+1)
+	}
+	off >>= shift
+	if off < -(1<<(bits-1)) || off >= 1<<(bits-1) {
+		a64Fail("operand %d: target out of range", i+1)
+	}
+	return uint32(off) & (1<<bits - 1)
+}
+
+var a64Conds = map[string]uint32{
+	"eq": 0, "ne": 1, "cs": 2, "hs": 2, "cc": 3, "lo": 3, "mi": 4, "pl": 5,
+	"vs": 6, "vc": 7, "hi": 8, "ls": 9, "ge": 10, "lt": 11, "gt": 12, "le": 13,
+	"al": 14, "nv": 15,
+}
+
+func (a *a64) cond(i int) uint32 {
+	op := a.op(i)
+	c, ok := a64Conds[op.name]
+	if op.typ != a64OpName || !ok {
+		a64Fail("operand %d: expect condition", i+1)
+	}
+	return c
+}
+
+// optional shift operand, returns shift type and amount
+func (a *a64) shift(i int, allow ...string) (typ string, amt uint32) {
+	if i >= len(a.ops) {
+		return "lsl", 0
+	}
+	op := a.ops[i]
+	if op.typ != a64OpShift || !op.hasAmt {
+		a64Fail("operand %d: expect shift", i+1)
+	}
+	for _, v := range allow {
+		if v == op.name {
+			if op.imm < 0 || op.imm > 63 {
+				a64Fail("operand %d: shift out of range", i+1)
+			}
+			return op.name, uint32(op.imm)
+		}
+	}
+	a64Fail("operand %d: invalid shift", i+1)
+	return
+}
+
+////////////////////////
+
+func (a *a64) encode() uint32 {
+	switch a.mnemo {
+	case "nop":
+		return 0xd503201f
+	case "yield":
+		return 0xd503203f
+	case "isb":
+		return 0xd5033fdf
+	case "dmb", "dsb":
+		return a.barrier()
+	case "brk", "hlt", "udf":
+		a.nops(1)
+		base := map[string]uint32{"brk": 0xd4200000, "hlt": 0xd4400000, "udf": 0}[a.mnemo]
+		if a.mnemo == "udf" {
+			return a.uimm(0, 16)
+		}
+		return base | a.uimm(0, 16)<<5
+	case "hint":
+		a.nops(1)
+		return 0xd503201f&^(0x7f<<5) | a.uimm(0, 7)<<5
+	case "bti":
+		a.nops(0, 1)
+		var t uint32
+		if len(a.ops) > 0 {
+			v, ok := map[string]uint32{"c": 1, "j": 2, "jc": 3}[a.ops[0].name]
+			if a.ops[0].typ != a64OpName || !ok {
+				a64Fail("invalid bti target")
+			}
+			t = v
+		}
+		return 0xd503241f | t<<6
+	case "paciaz", "paciasp", "pacibz", "pacibsp", "autiaz", "autiasp", "autibz", "autibsp", "xpaclri":
+		a.nops(0)
+		return map[string]uint32{
+			"paciaz": 0xd503231f, "paciasp": 0xd503233f, "pacibz": 0xd503235f, "pacibsp": 0xd503237f,
+			"autiaz": 0xd503239f, "autiasp": 0xd50323bf, "autibz": 0xd50323df, "autibsp": 0xd50323ff,
+			"xpaclri": 0xd50320ff,
+		}[a.mnemo]
+	case "mrs":
+		a.nops(2)
+		rt := a.gpSF(0, false, 1)
+		return 0xd5300000 | a.sysReg(1)<<5 | rt
+	case "msr":
+		a.nops(2)
+		return 0xd5100000 | a.sysReg(0)<<5 | a.gpSF(1, false, 1)
+
+	// branch
+	case "b":
+		a.nops(1)
+		return 0x14000000 | a.rel(0, 26, 2)
+	case "bl":
+		a.nops(1)
+		return 0x94000000 | a.rel(0, 26, 2)
+	case "br", "blr":
+		a.nops(1)
+		n, _ := a.gp(0, false)
+		return map[string]uint32{"br": 0xd61f0000, "blr": 0xd63f0000}[a.mnemo] | n<<5
+	case "ret":
+		n := uint32(30)
+		if len(a.ops) > 0 {
+			n, _ = a.gp(0, false)
+		}
+		return 0xd65f0000 | n<<5
+	// pointer authentication
+	case "braa", "brab", "blraa", "blrab":
+		a.nops(2)
+		n, _ := a.gp(0, false)
+		m, _ := a.gp(1, true)
+		return map[string]uint32{"braa": 0xd71f0800, "brab": 0xd71f0c00, "blraa": 0xd73f0800, "blrab": 0xd73f0c00}[a.mnemo] | n<<5 | m
+	case "braaz", "brabz", "blraaz", "blrabz":
+		a.nops(1)
+		n, _ := a.gp(0, false)
+		return map[string]uint32{"braaz": 0xd61f081f, "brabz": 0xd61f0c1f, "blraaz": 0xd63f081f, "blrabz": 0xd63f0c1f}[a.mnemo] | n<<5
+	case "retaa", "retab":
+		a.nops(0)
+		return map[string]uint32{"retaa": 0xd65f0bff, "retab": 0xd65f0fff}[a.mnemo]
+	case "cbz", "cbnz":
+		a.nops(2)
+		n, sf := a.gp(0, false)
+		op := map[string]uint32{"cbz": 0x34000000, "cbnz": 0x35000000}[a.mnemo]
+		return sf<<31 | op | a.rel(1, 19, 2)<<5 | n
+	case "tbz", "tbnz":
+		a.nops(3)
+		n, sf := a.gp(0, false)
+		b := a.uimm(1, 6)
+		if sf == 0 && b >= 32 {
+			a64Fail("bit number out of range")
+		}
+		op := map[string]uint32{"tbz": 0x36000000, "tbnz": 0x37000000}[a.mnemo]
+		return (b>>5)<<31 | op | (b&31)<<19 | a.rel(2, 14, 2)<<5 | n
+	case "adr":
+		a.nops(2)
+		n := a.gpSF(0, false, 1)
+		off := a.rel(1, 21, 0)
+		return 0x10000000 | (off&3)<<29 | (off>>2)<<5 | n
+	case "adrp":
+		a.nops(2)
+		n := a.gpSF(0, false, 1)
+		off := (a.imm(1) >> 12) - (a.pc >> 12)
+		if off < -(1<<20) || off >= 1<<20 {
+			a64Fail("target out of range")
+		}
+		return 0x90000000 | (uint32(off)&3)<<29 | (uint32(off)>>2&0x7ffff)<<5 | n
+	}
+
+	// bc.cond is the hinted one
+	if strings.HasPrefix(a.mnemo, "b.") || strings.HasPrefix(a.mnemo, "bc.") {
+		a.nops(1)
+		hint, cond, _ := strings.Cut(a.mnemo, ".")
+		c, ok := a64Conds[cond]
+		if !ok {
+			a64Fail("invalid condition")
+		}
+		if hint == "bc" {
+			c |= 0x10
+		}
+		return 0x54000000 | a.rel(0, 19, 2)<<5 | c
+	}
+
+	if ins, ok := a.encodeCrypto(); ok {
+		return ins
+	}
+	for i := range a.ops {
+		if a.isV(i) || a.ops[i].typ == a64OpList {
+			return a.encodeSIMD()
+		}
+	}
+	if a.isFP(0) && (strings.HasPrefix(a.mnemo, "f") || a.mnemo == "scvtf" || a.mnemo == "ucvtf") ||
+		a.isGP(0) && strings.HasPrefix(a.mnemo, "fcvt") || a.mnemo == "fmov" {
+		if ins, ok := a.encodeFP(); ok {
+			return ins
+		}
+	}
+	if a.isFP(0) && !strings.HasPrefix(a.mnemo, "ld") && !strings.HasPrefix(a.mnemo, "st") {
+		return a.encodeSIMDScalar()
+	}
+
+	switch a.mnemo {
+	case "add", "adds", "sub", "subs":
+		a.nops(3, 4)
+		return a.addSub(a.mnemo[0] == 's', strings.HasSuffix(a.mnemo, "s"), 0, 1, 2)
+	case "cmp", "cmn":
+		a.nops(2, 3)
+		return a.addSub(a.mnemo == "cmp", true, -1, 0, 1)
+	case "neg", "negs":
+		a.nops(2, 3)
+		_, sf := a.gp(0, false)
+		rd := a.gpSF(0, false, sf)
+		rm := a.gpSF(1, false, sf)
+		typ, amt := a.shift(2, "lsl", "lsr", "asr")
+		return a.addSubReg(1, a64Bit(a.mnemo == "negs"), sf, rd, 31, rm, typ, amt)
+	case "adc", "adcs", "sbc", "sbcs":
+		a.nops(3)
+		rd, sf := a.gp(0, false)
+		op := map[string]uint32{"adc": 0x1a000000, "adcs": 0x3a000000, "sbc": 0x5a000000, "sbcs": 0x7a000000}[a.mnemo]
+		return sf<<31 | op | a.gpSF(2, false, sf)<<16 | a.gpSF(1, false, sf)<<5 | rd
+	case "ngc", "ngcs":
+		a.nops(2)
+		rd, sf := a.gp(0, false)
+		op := map[string]uint32{"ngc": 0x5a000000, "ngcs": 0x7a000000}[a.mnemo]
+		return sf<<31 | op | a.gpSF(1, false, sf)<<16 | 31<<5 | rd
+
+	case "mov":
+		return a.mov()
+	case "movz", "movn", "movk":
+		a.nops(2, 3)
+		rd, sf := a.gp(0, false)
+		_, amt := a.shift(2, "lsl")
+		if amt&15 != 0 || amt >= 32<<sf {
+			a64Fail("invalid shift")
+		}
+		op := map[string]uint32{"movn": 0x12800000, "movz": 0x52800000, "movk": 0x72800000}[a.mnemo]
+		return sf<<31 | op | (amt/16)<<21 | a.uimm(1, 16)<<5 | rd
+
+	case "and", "orr", "eor", "ands", "bic", "orn", "eon", "bics":
+		a.nops(3, 4)
+		rd, sf := a.gp(0, a.isImm(2) && a.mnemo != "ands")
+		return a.logical(a.mnemo, sf, rd, a.gpSF(1, false, sf), 2)
+	case "tst":
+		a.nops(2, 3)
+		_, sf := a.gp(0, false)
+		return a.logical("ands", sf, 31, a.gpSF(0, false, sf), 1)
+	case "mvn":
+		a.nops(2, 3)
+		rd, sf := a.gp(0, false)
+		typ, amt := a.shift(2, "lsl", "lsr", "asr", "ror")
+		return a.logicalReg("orn", sf, rd, 31, a.gpSF(1, false, sf), typ, amt)
+
+	case "lsl", "lsr", "asr", "ror":
+		a.nops(3)
+		rd, sf := a.gp(0, false)
+		rn := a.gpSF(1, false, sf)
+		if !a.isImm(2) {
+			op := map[string]uint32{"lsl": 0x1ac02000, "lsr": 0x1ac02400, "asr": 0x1ac02800, "ror": 0x1ac02c00}[a.mnemo]
+			return sf<<31 | op | a.gpSF(2, false, sf)<<16 | rn<<5 | rd
+		}
+		size := uint32(32) << sf
+		sh := a.uimm(2, 6)
+		if sh >= size {
+			a64Fail("shift out of range")
+		}
+		switch a.mnemo {
+		case "lsl":
+			return a.bitfield(0x53000000, sf, rd, rn, (size-sh)%size, size-1-sh)
+		case "lsr":
+			return a.bitfield(0x53000000, sf, rd, rn, sh, size-1)
+		case "asr":
+			return a.bitfield(0x13000000, sf, rd, rn, sh, size-1)
+		default:
+			return sf<<31 | 0x13800000 | sf<<22 | rn<<16 | sh<<10 | rn<<5 | rd
+		}
+	case "lslv", "lsrv", "asrv", "rorv":
+		a.nops(3)
+		rd, sf := a.gp(0, false)
+		op := map[string]uint32{"lslv": 0x1ac02000, "lsrv": 0x1ac02400, "asrv": 0x1ac02800, "rorv": 0x1ac02c00}[a.mnemo]
+		return sf<<31 | op | a.gpSF(2, false, sf)<<16 | a.gpSF(1, false, sf)<<5 | rd
+	case "extr":
+		a.nops(4)
+		rd, sf := a.gp(0, false)
+		return sf<<31 | 0x13800000 | sf<<22 | a.gpSF(2, false, sf)<<16 | a.uimm(3, 5+uint(sf))<<10 | a.gpSF(1, false, sf)<<5 | rd
+	case "ubfm", "sbfm", "bfm":
+		a.nops(4)
+		rd, sf := a.gp(0, false)
+		op := map[string]uint32{"sbfm": 0x13000000, "bfm": 0x33000000, "ubfm": 0x53000000}[a.mnemo]
+		return a.bitfield(op, sf, rd, a.gpSF(1, false, sf), a.uimm(2, 5+uint(sf)), a.uimm(3, 5+uint(sf)))
+	case "ubfx", "sbfx", "bfxil", "ubfiz", "sbfiz", "bfi":
+		a.nops(4)
+		rd, sf := a.gp(0, false)
+		rn := a.gpSF(1, false, sf)
+		size := uint32(32) << sf
+		lsb, width := a.uimm(2, 6), a.uimm(3, 7)
+		if width == 0 || lsb+width > size {
+			a64Fail("bitfield out of range")
+		}
+		op := map[byte]uint32{'s': 0x13000000, 'b': 0x33000000, 'u': 0x53000000}[a.mnemo[0]]
+		if strings.HasSuffix(a.mnemo, "x") || a.mnemo == "bfxil" {
+			return a.bitfield(op, sf, rd, rn, lsb, lsb+width-1)
+		}
+		return a.bitfield(op, sf, rd, rn, (size-lsb)%size, width-1)
+	case "sxtb", "sxth", "sxtw", "uxtb", "uxth":
+		a.nops(2)
+		rd, sf := a.gp(0, false)
+		rn, _ := a.gp(1, false)
+		if a.mnemo[0] == 'u' {
+			sf = 0
+		}
+		imms := map[byte]uint32{'b': 7, 'h': 15, 'w': 31}[a.mnemo[3]]
+		op := map[byte]uint32{'s': 0x13000000, 'u': 0x53000000}[a.mnemo[0]]
+		return a.bitfield(op, sf, rd, rn, 0, imms)
+
+	case "madd", "msub", "smaddl", "smsubl", "umaddl", "umsubl":
+		a.nops(4)
+		rd, sf := a.gp(0, false)
+		op := map[string]uint32{
+			"madd": 0x1b000000, "msub": 0x1b008000,
+			"smaddl": 0x9b200000, "smsubl": 0x9b208000, "umaddl": 0x9ba00000, "umsubl": 0x9ba08000,
+		}[a.mnemo]
+		if op&0x80000000 == 0 {
+			op |= sf << 31
+		}
+		rn, _ := a.gp(1, false)
+		rm, _ := a.gp(2, false)
+		return op | rm<<16 | a.gpSF(3, false, sf)<<10 | rn<<5 | rd
+	case "mul", "mneg", "smull", "smnegl", "umull", "umnegl", "smulh", "umulh":
+		a.nops(3)
+		rd, sf := a.gp(0, false)
+		op := map[string]uint32{
+			"mul": 0x1b000000, "mneg": 0x1b008000,
+			"smull": 0x9b200000, "smnegl": 0x9b208000, "umull": 0x9ba00000, "umnegl": 0x9ba08000,
+			"smulh": 0x9b400000, "umulh": 0x9bc00000,
+		}[a.mnemo]
+		if op&0x80000000 == 0 {
+			op |= sf << 31
+		}
+		rn, _ := a.gp(1, false)
+		rm, _ := a.gp(2, false)
+		return op | rm<<16 | 31<<10 | rn<<5 | rd
+	case "sdiv", "udiv":
+		a.nops(3)
+		rd, sf := a.gp(0, false)
+		op := map[string]uint32{"udiv": 0x1ac00800, "sdiv": 0x1ac00c00}[a.mnemo]
+		return sf<<31 | op | a.gpSF(2, false, sf)<<16 | a.gpSF(1, false, sf)<<5 | rd
+	case "crc32b", "crc32h", "crc32w", "crc32x", "crc32cb", "crc32ch", "crc32cw", "crc32cx":
+		a.nops(3)
+		sz := map[byte]uint32{'b': 0, 'h': 1, 'w': 2, 'x': 3}[a.mnemo[len(a.mnemo)-1]]
+		sf := a64Bit(sz == 3)
+		c := a64Bit(a.mnemo[5] == 'c')
+		return sf<<31 | 0x1ac04000 | a.gpSF(2, false, sf)<<16 | c<<12 | sz<<10 | a.gpSF(1, false, 0)<<5 | a.gpSF(0, false, 0)
+	case "clz", "cls", "rbit", "rev", "rev16", "rev32":
+		a.nops(2)
+		rd, sf := a.gp(0, false)
+		opc := map[string]uint32{"rbit": 0, "rev16": 1, "rev32": 2, "rev": 2 + sf, "clz": 4, "cls": 5}[a.mnemo]
+		if a.mnemo == "rev32" && sf == 0 {
+			a64Fail("rev32 needs 64-bit registers")
+		}
+		return sf<<31 | 0x5ac00000 | opc<<10 | a.gpSF(1, false, sf)<<5 | rd
+
+	case "csel", "csinc", "csinv", "csneg":
+		a.nops(4)
+		rd, sf := a.gp(0, false)
+		op := map[string]uint32{"csel": 0x1a800000, "csinc": 0x1a800400, "csinv": 0x5a800000, "csneg": 0x5a800400}[a.mnemo]
+		return sf<<31 | op | a.gpSF(2, false, sf)<<16 | a.cond(3)<<12 | a.gpSF(1, false, sf)<<5 | rd
+	case "cset", "csetm":
+		a.nops(2)
+		rd, sf := a.gp(0, false)
+		op := map[string]uint32{"cset": 0x1a800400, "csetm": 0x5a800000}[a.mnemo]
+		return sf<<31 | op | 31<<16 | (a.cond(1)^1)<<12 | 31<<5 | rd
+	case "cinc", "cinv", "cneg":
+		a.nops(3)
+		rd, sf := a.gp(0, false)
+		rn := a.gpSF(1, false, sf)
+		op := map[string]uint32{"cinc": 0x1a800400, "cinv": 0x5a800000, "cneg": 0x5a800400}[a.mnemo]
+		return sf<<31 | op | rn<<16 | (a.cond(2)^1)<<12 | rn<<5 | rd
+	case "ccmp", "ccmn":
+		a.nops(4)
+		rn, sf := a.gp(0, false)
+		op := map[string]uint32{"ccmn": 0x3a400000, "ccmp": 0x7a400000}[a.mnemo]
+		if a.isImm(1) {
+			op |= 0x800 | a.uimm(1, 5)<<16
+		} else {
+			op |= a.gpSF(1, false, sf) << 16
+		}
+		return sf<<31 | op | a.cond(3)<<12 | rn<<5 | a.uimm(2, 4)
+
+	case "ldp", "stp", "ldnp", "stnp", "ldpsw":
+		return a.loadStorePair()
+	case "ldxr", "ldxrb", "ldxrh", "ldaxr", "ldaxrb", "ldaxrh", "ldar", "ldarb", "ldarh",
+		"stxr", "stxrb", "stxrh", "stlxr", "stlxrb", "stlxrh", "stlr", "stlrb", "stlrh":
+		return a.loadStoreExclusive()
+	}
+
+	if ins, ok := a.atomic(); ok {
+		return ins
+	}
+	if strings.HasPrefix(a.mnemo, "ld") || strings.HasPrefix(a.mnemo, "st") || strings.HasPrefix(a.mnemo, "prf") {
+		return a.loadStore()
+	}
+
+	a64Fail("unsupported instruction, try -asm llvm-mc")
+	return 0
+}
+
+func a64Bit(b bool) uint32 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func (a *a64) barrier() uint32 {
+	a.nops(1)
+	opts := map[string]uint32{
+		"oshld": 1, "oshst": 2, "osh": 3, "nshld": 5, "nshst": 6, "nsh": 7,
+		"ishld": 9, "ishst": 10, "ish": 11, "ld": 13, "st": 14, "sy": 15,
+	}
+	var crm uint32
+	if op := a.op(0); op.typ == a64OpName {
+		v, ok := opts[op.name]
+		if !ok {
+			a64Fail("invalid barrier option")
+		}
+		crm = v
+	} else {
+		crm = a.uimm(0, 4)
+	}
+	if a.mnemo == "dmb" {
+		return 0xd50330bf | crm<<8
+	}
+	return 0xd503309f | crm<<8
+}
+
+// rd == -1 for cmp/cmn
+func (a *a64) addSub(sub, setFlags bool, rdi, rni, rmi int) uint32 {
+	op, s := a64Bit(sub), a64Bit(setFlags)
+	var rd, sf uint32
+	if rdi >= 0 {
+		rd, sf = a.gp(rdi, !setFlags || a.isReg(rdi, a64SP, a64WSP))
+	} else {
+		rd = 31
+		_, sf = a.gp(rni, a.isReg(rni, a64SP, a64WSP))
+	}
+
+	if a.isImm(rmi) {
+		rn := a.gpSF(rni, true, sf)
+		imm := a.imm(rmi)
+		var sh uint32
+		if rmi+1 < len(a.ops) {
+			_, amt := a.shift(rmi+1, "lsl")
+			if amt != 0 && amt != 12 {
+				a64Fail("invalid shift")
+			}
+			sh = amt / 12
+		}
+		if imm < 0 && sh == 0 {
+			imm, op = -imm, op^1
+		}
+		if sh == 0 && imm >= 1<<12 && imm&0xfff == 0 {
+			imm, sh = imm>>12, 1
+		}
+		if imm < 0 || imm >= 1<<12 {
+			a64Fail("immediate out of range")
+		}
+		return sf<<31 | op<<30 | s<<29 | 0x11000000 | sh<<22 | uint32(imm)<<10 | rn<<5 | rd
+	}
+
+	rnIsSP := a.isReg(rni, a64SP, a64WSP)
+	rdIsSP := rdi >= 0 && a.isReg(rdi, a64SP, a64WSP)
+	rn := a.gpSF(rni, rnIsSP, sf)
+	hasExt := rmi+1 < len(a.ops) && a.ops[rmi+1].typ == a64OpExt
+	if hasExt || rnIsSP || rdIsSP {
+		// extended register
+		rm, rmsf := a.gp(rmi, false)
+		option := 2 + sf
+		var amt uint32
+		if rmi+1 < len(a.ops) {
+			ext := a.ops[rmi+1]
+			switch {
+			case ext.typ == a64OpExt:
+				option = a64Exts[ext.name]
+			case ext.typ == a64OpShift && ext.name == "lsl":
+			default:
+				a64Fail("invalid extend")
+			}
+			if ext.imm < 0 || ext.imm > 4 {
+				a64Fail("extend amount out of range")
+			}
+			amt = uint32(ext.imm)
+		}
+		if option&3 == 3 && rmsf == 0 || option&3 != 3 && rmsf == 1 {
+			a64Fail("register width mismatch")
+		}
+		return sf<<31 | op<<30 | s<<29 | 0x0b200000 | rm<<16 | option<<13 | amt<<10 | rn<<5 | rd
+	}
+
+	rm := a.gpSF(rmi, false, sf)
+	typ, amt := a.shift(rmi+1, "lsl", "lsr", "asr")
+	return a.addSubReg(op, s, sf, rd, rn, rm, typ, amt)
+}
+
+func (a *a64) addSubReg(op, s, sf, rd, rn, rm uint32, typ string, amt uint32) uint32 {
+	sh := map[string]uint32{"lsl": 0, "lsr": 1, "asr": 2}[typ]
+	if amt >= 32<<sf {
+		a64Fail("shift out of range")
+	}
+	return sf<<31 | op<<30 | s<<29 | 0x0b000000 | sh<<22 | rm<<16 | amt<<10 | rn<<5 | rd
+}
+
+func (a *a64) bitfield(op, sf, rd, rn, immr, imms uint32) uint32 {
+	return sf<<31 | op | sf<<22 | immr<<16 | imms<<10 | rn<<5 | rd
+}
+
+var a64LogicalOps = map[string]uint32{
+	"and": 0, "bic": 0, "orr": 1, "orn": 1, "eor": 2, "eon": 2, "ands": 3, "bics": 3,
+}
+
+func (a *a64) logical(mnemo string, sf, rd, rn uint32, rmi int) uint32 {
+	if a.isImm(rmi) {
+		a.nops(rmi + 1)
+		imm := uint64(a.imm(rmi))
+		switch mnemo {
+		case "bic", "orn", "eon", "bics":
+			a64Fail("invalid immediate")
+		}
+		n, immr, imms, ok := encodeBitmask(imm, 32<<sf)
+		if !ok {
+			a64Fail("invalid logical immediate")
+		}
+		return sf<<31 | a64LogicalOps[mnemo]<<29 | 0x12000000 | n<<22 | immr<<16 | imms<<10 | rn<<5 | rd
+	}
+	typ, amt := a.shift(rmi+1, "lsl", "lsr", "asr", "ror")
+	return a.logicalReg(mnemo, sf, rd, rn, a.gpSF(rmi, false, sf), typ, amt)
+}
+
+func (a *a64) logicalReg(mnemo string, sf, rd, rn, rm uint32, typ string, amt uint32) uint32 {
+	sh := map[string]uint32{"lsl": 0, "lsr": 1, "asr": 2, "ror": 3}[typ]
+	var n uint32
+	switch mnemo {
+	case "bic", "orn", "eon", "bics":
+		n = 1
+	}
+	if amt >= 32<<sf {
+		a64Fail("shift out of range")
+	}
+	return sf<<31 | a64LogicalOps[mnemo]<<29 | 0x0a000000 | sh<<22 | n<<21 | rm<<16 | amt<<10 | rn<<5 | rd
+}
+
+func encodeBitmask(imm uint64, width uint) (n, immr, imms uint32, ok bool) {
+	if width == 32 {
+		if imm>>32 != 0 && imm>>32 != 0xffffffff {
+			return
+		}
+		imm &= 0xffffffff
+		imm |= imm << 32
+	}
+	if imm == 0 || imm == ^uint64(0) {
+		return
+	}
+
+	size := uint(64)
+	for size > 2 {
+		half := size / 2
+		mask := uint64(1)<<half - 1
+		if imm&mask != imm>>half&mask {
+			break
+		}
+		size = half
+	}
+	mask := ^uint64(0) >> (64 - size)
+	elem := imm & mask
+	ones := uint(bits.OnesCount64(elem))
+	target := uint64(1)<<ones - 1
+	for r := uint(0); r < size; r++ {
+		rot := (elem>>r | elem<<(size-r)) & mask
+		if rot == target {
+			if size == 64 {
+				n = 1
+			}
+			immr = uint32((size - r) % size)
+			imms = uint32(^(size*2-1))&0x3f | uint32(ones-1)
+			return n, immr, imms, true
+		}
+	}
+	return
+}
+
+func (a *a64) mov() uint32 {
+	a.nops(2)
+	if a.isImm(1) {
+		rd, sf := a.gp(0, false)
+		return a.movImm(sf, rd, uint64(a.imm(1)))
+	}
+	if a.isReg(0, a64SP, a64WSP) || a.isReg(1, a64SP, a64WSP) {
+		rd, sf := a.gp(0, a.isReg(0, a64SP, a64WSP))
+		rn := a.gpSF(1, a.isReg(1, a64SP, a64WSP), sf)
+		return sf<<31 | 0x11000000 | rn<<5 | rd
+	}
+	rd, sf := a.gp(0, false)
+	return a.logicalReg("orr", sf, rd, 31, a.gpSF(1, false, sf), "lsl", 0)
+}
+
+func (a *a64) movImm(sf, rd uint32, imm uint64) uint32 {
+	size := uint(32) << sf
+	if sf == 0 {
+		if imm>>32 != 0 && imm>>31 != 0x1ffffffff {
+			a64Fail("immediate out of range")
+		}
+		imm &= 0xffffffff
+	}
+	mask := ^uint64(0) >> (64 - size)
+	for hw := uint(0); hw < size; hw += 16 {
+		if imm&^(0xffff<<hw) == 0 {
+			return sf<<31 | 0x52800000 | uint32(hw/16)<<21 | uint32(imm>>hw&0xffff)<<5 | rd
+		}
+	}
+	for hw := uint(0); hw < size; hw += 16 {
+		if ^imm&mask&^(0xffff<<hw) == 0 {
+			return sf<<31 | 0x12800000 | uint32(hw/16)<<21 | uint32(^imm>>hw&0xffff)<<5 | rd
+		}
+	}
+	n, immr, imms, ok := encodeBitmask(imm, size)
+	if !ok {
+		a64Fail("immediate can not be encoded")
+	}
+	return sf<<31 | 0x32000000 | n<<22 | immr<<16 | imms<<10 | 31<<5 | rd
+}
+
+////////////////////////
+
+var a64Prefetch = map[string]uint32{"pld": 0, "pli": 1, "pst": 2}
+
+func (a *a64) prfop(i int) uint32 {
+	op := a.op(i)
+	if op.typ == a64OpImm {
+		return a.uimm(i, 5)
+	}
+	// pldl1keep
+	name := op.name
+	if op.typ != a64OpName || len(name) < 8 {
+		a64Fail("invalid prefetch operation")
+	}
+	typ, ok1 := a64Prefetch[name[:3]]
+	target := uint32(name[4] - '1')
+	policy, ok2 := map[string]uint32{"keep": 0, "strm": 1}[name[5:]]
+	if !ok1 || !ok2 || name[3] != 'l' || target > 2 {
+		a64Fail("invalid prefetch operation")
+	}
+	return typ<<3 | target<<1 | policy
+}
+
+func (a *a64) loadStore() uint32 {
+	mnemo := a.mnemo
+	var unscaled bool
+	switch {
+	case mnemo == "prfum":
+		mnemo, unscaled = "prfm", true
+	case strings.HasPrefix(mnemo, "ldur") || strings.HasPrefix(mnemo, "stur"):
+		mnemo, unscaled = mnemo[:2]+"r"+mnemo[4:], true
+	}
+
+	var size, v, opc, rt uint32
+	switch mnemo {
+	case "ldr", "str":
+		opc = a64Bit(mnemo == "ldr")
+		if a.isGP(0) {
+			var sf uint32
+			rt, sf = a.gp(0, false)
+			size = 2 + sf
+		} else {
+			var sz uint32
+			rt, sz = a.fp(0)
+			v = 1
+			if sz == 4 {
+				size, opc = 0, opc+2
+			} else {
+				size = sz
+			}
+		}
+	case "ldrb", "strb", "ldrh", "strh":
+		rt = a.gpSF(0, false, 0)
+		size = a64Bit(mnemo[3] == 'h')
+		opc = a64Bit(mnemo[0] == 'l')
+	case "ldrsb", "ldrsh", "ldrsw":
+		var sf uint32
+		rt, sf = a.gp(0, false)
+		size = map[byte]uint32{'b': 0, 'h': 1, 'w': 2}[mnemo[4]]
+		if size == 2 && sf == 0 {
+			a64Fail("ldrsw needs 64-bit register")
+		}
+		opc = 3 - sf
+	case "prfm":
+		rt = a.prfop(0)
+		size, opc = 3, 2
+	default:
+		a64Fail("unsupported instruction, try -asm llvm-mc")
+	}
+
+	scale := size
+	if v == 1 && opc >= 2 {
+		scale = 4
+	}
+
+	// literal
+	if a.isImm(1) {
+		a.nops(2)
+		if unscaled {
+			a64Fail("invalid operand")
+		}
+		var lop uint32
+		switch {
+		case mnemo == "prfm":
+			lop = 0xd8000000
+		case mnemo == "ldrsw":
+			lop = 0x98000000
+		case mnemo == "ldr" && v == 0:
+			lop = 0x18000000 | (size-2)<<30
+		case mnemo == "ldr" && v == 1 && scale >= 2:
+			lop = 0x1c000000 | (scale-2)<<30
+		default:
+			a64Fail("invalid literal load")
+		}
+		return lop | a.rel(1, 19, 2)<<5 | rt
+	}
+
+	a.nops(2, 3)
+	mem := a.op(1)
+	if mem.typ != a64OpMem {
+		a64Fail("expect memory operand")
+	}
+	rn := mem.base.reg.n
+	if mem.base.reg.kind != a64X && mem.base.reg.kind != a64SP || mem.base.reg.kind == a64X && rn == 31 {
+		a64Fail("invalid base register")
+	}
+	base := size<<30 | 0x38000000 | v<<26 | opc<<22 | rn<<5 | rt
+
+	imm9 := func(off int64) uint32 {
+		if off < -256 || off > 255 {
+			a64Fail("offset out of range")
+		}
+		return uint32(off) & 0x1ff
+	}
+
+	// post index
+	if len(a.ops) == 3 {
+		if mem.index != nil || mem.pre || unscaled {
+			a64Fail("invalid post index")
+		}
+		return base | imm9(a.imm(2))<<12 | 0x400
+	}
+
+	var off int64
+	switch {
+	case mem.index == nil:
+	case mem.index.typ == a64OpImm:
+		off = mem.index.imm
+	case mem.index.typ == a64OpReg && mem.index.reg.isGP():
+		if mem.pre || unscaled {
+			a64Fail("invalid register offset")
+		}
+		rm := mem.index.reg.n
+		option := uint32(3)
+		var s uint32
+		if mem.ext != nil {
+			switch {
+			case mem.ext.typ == a64OpExt:
+				option = a64Exts[mem.ext.name]
+			case mem.ext.typ == a64OpShift && mem.ext.name == "lsl":
+			default:
+				a64Fail("invalid extend")
+			}
+			if mem.ext.hasAmt {
+				if mem.ext.imm != int64(scale) && mem.ext.imm != 0 {
+					a64Fail("invalid shift amount")
+				}
+				s = a64Bit(mem.ext.imm == int64(scale) || scale == 0)
+			}
+		}
+		if option != 2 && option != 3 && option != 6 && option != 7 {
+			a64Fail("invalid extend")
+		}
+		if (option&1 == 1) != (mem.index.reg.kind == a64X) {
+			a64Fail("register width mismatch")
+		}
+		return base | 0x00200800 | rm<<16 | option<<13 | s<<12
+	default:
+		a64Fail("invalid memory operand")
+	}
+
+	if mem.pre {
+		if unscaled {
+			a64Fail("invalid pre index")
+		}
+		return base | imm9(off)<<12 | 0xc00
+	}
+	if !unscaled && off >= 0 && off&(1<<scale-1) == 0 && off>>scale < 4096 {
+		return base | 0x01000000 | uint32(off>>scale)<<10
+	}
+	return base | imm9(off)<<12
+}
+
+func (a *a64) loadStorePair() uint32 {
+	a.nops(3, 4)
+	l := a64Bit(a.mnemo[0] == 'l')
+	var opc, v, scale, rt, rt2 uint32
+	if a.isGP(0) {
+		var sf uint32
+		rt, sf = a.gp(0, false)
+		rt2 = a.gpSF(1, false, sf)
+		opc, scale = sf<<1, 2+sf
+		if a.mnemo == "ldpsw" {
+			if sf == 0 {
+				a64Fail("ldpsw needs 64-bit registers")
+			}
+			opc, scale = 1, 2
+		}
+	} else {
+		var sz uint32
+		rt, sz = a.fp(0)
+		rt2 = a.fpSize(1, sz)
+		if sz < 2 {
+			a64Fail("invalid register")
+		}
+		opc, v, scale = sz-2, 1, sz
+	}
+
+	mem := a.op(2)
+	if mem.typ != a64OpMem || mem.base.reg.kind != a64X && mem.base.reg.kind != a64SP ||
+		mem.base.reg.kind == a64X && mem.base.reg.n == 31 {
+		a64Fail("invalid memory operand")
+	}
+	var off int64
+	var idx uint32 = 2
+	switch {
+	case len(a.ops) == 4:
+		if mem.index != nil || mem.pre {
+			a64Fail("invalid post index")
+		}
+		off, idx = a.imm(3), 1
+	case mem.index != nil:
+		if mem.index.typ != a64OpImm {
+			a64Fail("invalid offset")
+		}
+		off = mem.index.imm
+		if mem.pre {
+			idx = 3
+		}
+	}
+	if strings.HasSuffix(a.mnemo, "np") {
+		if idx != 2 {
+			a64Fail("invalid addressing mode")
+		}
+		idx = 0
+	}
+	if off&(1<<scale-1) != 0 || off>>scale < -64 || off>>scale > 63 {
+		a64Fail("offset out of range")
+	}
+	imm7 := uint32(off>>scale) & 0x7f
+	return opc<<30 | 0x28000000 | v<<26 | idx<<23 | l<<22 | imm7<<15 | rt2<<10 | mem.base.reg.n<<5 | rt
+}
+
+func (a *a64) loadStoreExclusive() uint32 {
+	mnemo := a.mnemo
+	var size uint32
+	switch mnemo[len(mnemo)-1] {
+	case 'b':
+		mnemo = mnemo[:len(mnemo)-1]
+	case 'h':
+		mnemo, size = mnemo[:len(mnemo)-1], 1
+	default:
+		size = 2
+	}
+	op := map[string]uint32{
+		"stxr": 0x08007c00, "ldxr": 0x085f7c00, "stlxr": 0x0800fc00, "ldaxr": 0x085ffc00,
+		"stlr": 0x089ffc00, "ldar": 0x08dffc00,
+	}[mnemo]
+
+	var rs uint32
+	i := 0
+	if mnemo == "stxr" || mnemo == "stlxr" {
+		a.nops(3)
+		rs = a.gpSF(0, false, 0)
+		i = 1
+	} else {
+		a.nops(2)
+	}
+	rt, sf := a.gp(i, false)
+	if size == 2 {
+		size += sf
+	} else if sf == 1 {
+		a64Fail("register width mismatch")
+	}
+	mem := a.op(i + 1)
+	if mem.typ != a64OpMem || mem.index != nil && !(mem.index.typ == a64OpImm && mem.index.imm == 0) {
+		a64Fail("invalid memory operand")
+	}
+	return size<<30 | op | rs<<16 | mem.base.reg.n<<5 | rt
+}
+
+// op0, op1, CRn, CRm, op2 of the system registers read by the compilers
+var a64SysRegs = map[string][5]uint32{
+	"nzcv": {3, 3, 4, 2, 0}, "fpcr": {3, 3, 4, 4, 0}, "fpsr": {3, 3, 4, 4, 1},
+	"tpidr_el0": {3, 3, 13, 0, 2}, "tpidrro_el0": {3, 3, 13, 0, 3},
+	"cntfrq_el0": {3, 3, 14, 0, 0}, "cntpct_el0": {3, 3, 14, 0, 1}, "cntvct_el0": {3, 3, 14, 0, 2},
+	"ctr_el0": {3, 3, 0, 0, 1}, "dczid_el0": {3, 3, 0, 0, 7},
+	"midr_el1": {3, 0, 0, 0, 0}, "mpidr_el1": {3, 0, 0, 0, 5},
+	"id_aa64isar0_el1": {3, 0, 0, 6, 0}, "id_aa64isar1_el1": {3, 0, 0, 6, 1},
+	"id_aa64pfr0_el1": {3, 0, 0, 4, 0}, "id_aa64pfr1_el1": {3, 0, 0, 4, 1},
+}
+
+// sysReg is the o0:op1:CRn:CRm:op2 field of mrs and msr, by the name or
+// s3_3_c13_c0_2.
+func (a *a64) sysReg(i int) uint32 {
+	op := a.op(i)
+	if op.typ != a64OpName {
+		a64Fail("operand %d: expect system register", i+1)
+	}
+	f, ok := a64SysRegs[op.name]
+	if !ok {
+		n, err := fmt.Sscanf(op.name, "s%d_%d_c%d_c%d_%d", &f[0], &f[1], &f[2], &f[3], &f[4])
+		if err != nil || n != 5 || f[0] < 2 || f[0] > 3 || f[1] > 7 || f[2] > 15 || f[3] > 15 || f[4] > 7 {
+			a64Fail("operand %d: unsupported system register: %s", i+1, op.name)
+		}
+	}
+	return (f[0]&1)<<14 | f[1]<<11 | f[2]<<7 | f[3]<<3 | f[4]
+}
+
+// opc of ld<op> and st<op>
+var a64AtomicOps = map[string]uint32{
+	"add": 0, "clr": 1, "eor": 2, "set": 3, "smax": 4, "smin": 5, "umax": 6, "umin": 7,
+}
+
+// atomic encodes the lse atomics, ld<op>, st<op>, swp, cas and casp with
+// the a, l, al orderings and the b, h sizes.
+func (a *a64) atomic() (_ uint32, ok bool) {
+	name, rest := "", ""
+	switch m := a.mnemo; {
+	case strings.HasPrefix(m, "swp"):
+		name, rest = "swp", m[3:]
+	case strings.HasPrefix(m, "casp"):
+		name, rest = "casp", m[4:]
+	case strings.HasPrefix(m, "cas"):
+		name, rest = "cas", m[3:]
+	case strings.HasPrefix(m, "ld"), strings.HasPrefix(m, "st"):
+		for k := range a64AtomicOps {
+			if strings.HasPrefix(m[2:], k) {
+				name, rest = m[:2+len(k)], m[2+len(k):]
+			}
+		}
+	}
+	if name == "" {
+		return
+	}
+
+	size := uint32(2)
+	if name != "casp" && rest != "" {
+		switch rest[len(rest)-1] {
+		case 'b':
+			size, rest = 0, rest[:len(rest)-1]
+		case 'h':
+			size, rest = 1, rest[:len(rest)-1]
+		}
+	}
+	var acq, rel uint32
+	switch {
+	case rest == "":
+	case rest == "l":
+		rel = 1
+	case rest == "a" && !strings.HasPrefix(name, "st"):
+		acq = 1
+	case rest == "al" && !strings.HasPrefix(name, "st"):
+		acq, rel = 1, 1
+	default:
+		return
+	}
+
+	// the registers before the memory operand
+	var regs []uint32
+	n := len(a.ops) - 1
+	if n < 1 {
+		a64Fail("invalid operand count")
+	}
+	_, sf := a.gp(0, false)
+	for i := 0; i < n; i++ {
+		regs = append(regs, a.gpSF(i, false, sf))
+	}
+	if size < 2 && sf == 1 {
+		a64Fail("register width mismatch")
+	}
+	if size == 2 {
+		size += sf
+	}
+	mem := a.op(n)
+	if mem.typ != a64OpMem || mem.pre || mem.index != nil && !(mem.index.typ == a64OpImm && mem.index.imm == 0) {
+		a64Fail("invalid memory operand")
+	}
+	rn := mem.base.reg.n
+
+	switch {
+	case name == "casp":
+		a.nops(5)
+		if regs[0]&1 != 0 || regs[1] != regs[0]+1 || regs[2]&1 != 0 || regs[3] != regs[2]+1 {
+			a64Fail("invalid register pair")
+		}
+		return sf<<30 | 0x08207c00 | acq<<22 | regs[0]<<16 | rel<<15 | rn<<5 | regs[2], true
+	case name == "cas":
+		a.nops(3)
+		return size<<30 | 0x08a07c00 | acq<<22 | regs[0]<<16 | rel<<15 | rn<<5 | regs[1], true
+	case strings.HasPrefix(name, "st"):
+		// st<op> is ld<op> to zr
+		a.nops(2)
+		return size<<30 | 0x38200000 | rel<<22 | regs[0]<<16 | a64AtomicOps[name[2:]]<<12 | rn<<5 | 31, true
+	}
+	a.nops(3)
+	var op uint32
+	if name == "swp" {
+		op = 1 << 15
+	} else {
+		op = a64AtomicOps[name[2:]] << 12
+	}
+	return size<<30 | 0x38200000 | acq<<23 | rel<<22 | regs[0]<<16 | op | rn<<5 | regs[1], true
+}
+
+////////////////////////
+
+// fp type field, 0: s, 1: d, 3: h
+func a64FPType(size uint32) uint32 {
+	switch size {
+	case 1:
+		return 3
+	case 2:
+		return 0
+	case 3:
+		return 1
+	}
+	a64Fail("invalid fp register")
+	return 0
+}
+
+func encodeFPImm(f float64) (uint32, bool) {
+	b := math.Float64bits(f)
+	sign := uint32(b >> 63)
+	exp := b >> 52 & 0x7ff
+	frac := b & (1<<52 - 1)
+	if frac&(1<<48-1) != 0 {
+		return 0, false
+	}
+	// exp must be NOT(b):b:b:b:b:b:b:b:b:c:d
+	hi := exp >> 2
+	var bb uint64
+	switch hi {
+	case 0xff:
+		bb = 1
+	case 0x100:
+		bb = 0
+	default:
+		return 0, false
+	}
+	return sign<<7 | uint32(bb)<<6 | uint32(exp&3)<<4 | uint32(frac>>48), true
+}
+
+var a64FP2Src = map[string]uint32{
+	"fmul": 0, "fdiv": 1, "fadd": 2, "fsub": 3, "fmax": 4, "fmin": 5, "fmaxnm": 6, "fminnm": 7, "fnmul": 8,
+}
+
+var a64FP1Src = map[string]uint32{
+	"fabs": 1, "fneg": 2, "fsqrt": 3,
+	"frintn": 8, "frintp": 9, "frintm": 10, "frintz": 11, "frinta": 12, "frintx": 14, "frinti": 15,
+}
+
+// rmode<<3 | opcode
+var a64FPCvt = map[string]uint32{
+	"fcvtns": 0, "fcvtnu": 1, "scvtf": 2, "ucvtf": 3, "fcvtas": 4, "fcvtau": 5,
+	"fcvtps": 8, "fcvtpu": 9, "fcvtms": 16, "fcvtmu": 17, "fcvtzs": 24, "fcvtzu": 25,
+}
+
+func (a *a64) encodeFP() (uint32, bool) {
+	switch a.mnemo {
+	case "fmov":
+		a.nops(2)
+		switch {
+		case a.isFP(0) && a.isFP(1):
+			rd, sz := a.fp(0)
+			return 0x1e204000 | a64FPType(sz)<<22 | a.fpSize(1, sz)<<5 | rd, true
+		case a.isFP(0) && a.isGP(1):
+			rd, sz := a.fp(0)
+			rn, sf := a.gp(1, false)
+			if sz == 3 && sf == 1 {
+				return 0x9e670000 | rn<<5 | rd, true
+			}
+			if sz == 2 && sf == 0 {
+				return 0x1e270000 | rn<<5 | rd, true
+			}
+			if sz == 1 {
+				return sf<<31 | 0x1ee70000 | rn<<5 | rd, true
+			}
+			a64Fail("register width mismatch")
+		case a.isGP(0) && a.isFP(1):
+			rd, sf := a.gp(0, false)
+			rn, sz := a.fp(1)
+			if sz == 3 && sf == 1 {
+				return 0x9e660000 | rn<<5 | rd, true
+			}
+			if sz == 2 && sf == 0 {
+				return 0x1e260000 | rn<<5 | rd, true
+			}
+			if sz == 1 {
+				return sf<<31 | 0x1ee60000 | rn<<5 | rd, true
+			}
+			a64Fail("register width mismatch")
+		case a.isFP(0):
+			rd, sz := a.fp(0)
+			f := a.fimm(1)
+			if f == 0 && !math.Signbit(f) {
+				// fmov d0, xzr
+				if sz == 3 {
+					return 0x9e6703e0 | rd, true
+				}
+				return 0x1e2703e0 | rd, true
+			}
+			imm8, ok := encodeFPImm(f)
+			if !ok {
+				a64Fail("invalid fp immediate")
+			}
+			return 0x1e201000 | a64FPType(sz)<<22 | imm8<<13 | rd, true
+		}
+		return 0, false
+	case "fcmp", "fcmpe":
+		a.nops(2)
+		rn, sz := a.fp(0)
+		opc := a64Bit(a.mnemo == "fcmpe") << 4
+		if a.isFP(1) {
+			return 0x1e202000 | a64FPType(sz)<<22 | a.fpSize(1, sz)<<16 | rn<<5 | opc, true
+		}
+		if a.fimm(1) != 0 {
+			a64Fail("fcmp only compares with zero")
+		}
+		return 0x1e202000 | a64FPType(sz)<<22 | rn<<5 | opc | 8, true
+	case "fccmp", "fccmpe":
+		a.nops(4)
+		rn, sz := a.fp(0)
+		opc := a64Bit(a.mnemo == "fccmpe") << 4
+		return 0x1e200400 | a64FPType(sz)<<22 | a.fpSize(1, sz)<<16 | a.cond(3)<<12 | rn<<5 | opc | a.uimm(2, 4), true
+	case "fcsel":
+		a.nops(4)
+		rd, sz := a.fp(0)
+		return 0x1e200c00 | a64FPType(sz)<<22 | a.fpSize(2, sz)<<16 | a.cond(3)<<12 | a.fpSize(1, sz)<<5 | rd, true
+	case "fmadd", "fmsub", "fnmadd", "fnmsub":
+		a.nops(4)
+		rd, sz := a.fp(0)
+		op := map[string]uint32{"fmadd": 0, "fmsub": 0x8000, "fnmadd": 0x200000, "fnmsub": 0x208000}[a.mnemo]
+		return 0x1f000000 | a64FPType(sz)<<22 | op | a.fpSize(2, sz)<<16 | a.fpSize(3, sz)<<10 | a.fpSize(1, sz)<<5 | rd, true
+	case "fcvt":
+		a.nops(2)
+		rd, dsz := a.fp(0)
+		rn, ssz := a.fp(1)
+		return 0x1e224000 | a64FPType(ssz)<<22 | a64FPType(dsz)<<15 | rn<<5 | rd, true
+	}
+
+	if op, ok := a64FP2Src[a.mnemo]; ok && a.isFP(0) {
+		a.nops(3)
+		rd, sz := a.fp(0)
+		return 0x1e200800 | a64FPType(sz)<<22 | a.fpSize(2, sz)<<16 | op<<12 | a.fpSize(1, sz)<<5 | rd, true
+	}
+	if op, ok := a64FP1Src[a.mnemo]; ok && a.isFP(0) {
+		a.nops(2)
+		rd, sz := a.fp(0)
+		return 0x1e204000 | a64FPType(sz)<<22 | op<<15 | a.fpSize(1, sz)<<5 | rd, true
+	}
+	if op, ok := a64FPCvt[a.mnemo]; ok && len(a.ops) == 2 && (a.isGP(0) || a.isGP(1)) {
+		var rd, rn, sf, sz uint32
+		if a.isGP(0) {
+			rd, sf = a.gp(0, false)
+			rn, sz = a.fp(1)
+		} else {
+			rd, sz = a.fp(0)
+			rn, sf = a.gp(1, false)
+		}
+		return sf<<31 | 0x1e200000 | a64FPType(sz)<<22 | op<<16 | rn<<5 | rd, true
+	}
+	return 0, false
+}
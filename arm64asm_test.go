@@ -0,0 +1,413 @@
+package main
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// the encodings are from llvm-mc -triple=arm64-apple-darwin, the branch
+// targets are absolute at address 0
+var arm64AsmTests = []struct {
+	mnemo, opers string
+	want         uint32
+}{
+	{"nop", "", 0xd503201f},
+	{"yield", "", 0xd503203f},
+	{"isb", "", 0xd5033fdf},
+	{"dmb", "ish", 0xd5033bbf},
+	{"dsb", "sy", 0xd5033f9f},
+	{"brk", "#0x3e8", 0xd4207d00},
+	{"hint", "#34", 0xd503245f},
+	{"b", "#4096", 0x14000400},
+	{"bl", "#-8", 0x97fffffe},
+	{"br", "x16", 0xd61f0200},
+	{"blr", "x8", 0xd63f0100},
+	{"ret", "", 0xd65f03c0},
+	{"ret", "x1", 0xd65f0020},
+	{"braa", "x0, x1", 0xd71f0801},
+	{"retaa", "", 0xd65f0bff},
+	{"cbz", "w0, #64", 0x34000200},
+	{"cbnz", "x3, #-64", 0xb5fffe03},
+	{"tbz", "w1, #3, #32", 0x36180101},
+	{"tbnz", "x2, #40, #-32", 0xb747ff02},
+	{"adr", "x0, #12", 0x10000060},
+	{"adrp", "x0, #4096", 0xb0000000},
+	{"b.eq", "#16", 0x54000080},
+	{"b.ne", "#-16", 0x54ffff81},
+	{"add", "x0, x1, x2", 0x8b020020},
+	{"add", "w0, w1, #4095", 0x113ffc20},
+	{"add", "x0, sp, #16, lsl #12", 0x914043e0},
+	{"add", "x0, x1, w2, sxtw #2", 0x8b22c820},
+	{"add", "x0, x1, x2, lsl #3", 0x8b020c20},
+	{"adds", "w0, w1, w2", 0x2b020020},
+	{"sub", "sp, sp, #32", 0xd10083ff},
+	{"subs", "x0, x1, #1", 0xf1000420},
+	{"cmp", "x0, #0", 0xf100001f},
+	{"cmn", "w1, w2", 0x2b02003f},
+	{"cmp", "x0, x1, lsl #2", 0xeb01081f},
+	{"neg", "x0, x1", 0xcb0103e0},
+	{"negs", "w0, w1, lsr #3", 0x6b410fe0},
+	{"adc", "x0, x1, x2", 0x9a020020},
+	{"sbcs", "w0, w1, w2", 0x7a020020},
+	{"ngc", "x0, x1", 0xda0103e0},
+	{"mov", "x0, x1", 0xaa0103e0},
+	{"mov", "x0, sp", 0x910003e0},
+	{"mov", "w0, #-1", 0x12800000},
+	{"mov", "x0, #0x10000", 0xd2a00020},
+	{"mov", "x0, #0xff00ff00ff00ff00", 0xb2089fe0},
+	{"movz", "x0, #0x1234, lsl #16", 0xd2a24680},
+	{"movn", "w0, #0", 0x12800000},
+	{"movk", "x0, #0xbeef, lsl #48", 0xf2f7dde0},
+	{"and", "x0, x1, #0xff", 0x92401c20},
+	{"orr", "w0, w1, #0x80000000", 0x32010020},
+	{"eor", "x0, x1, x2, ror #7", 0xcac21c20},
+	{"ands", "w0, w1, w2", 0x6a020020},
+	{"bic", "x0, x1, x2", 0x8a220020},
+	{"orn", "w0, w1, w2", 0x2a220020},
+	{"eon", "x0, x1, x2, lsl #1", 0xca220420},
+	{"bics", "x0, x1, x2", 0xea220020},
+	{"tst", "w0, #1", 0x7200001f},
+	{"tst", "x0, x1", 0xea01001f},
+	{"mvn", "w0, w1", 0x2a2103e0},
+	{"lsl", "x0, x1, #3", 0xd37df020},
+	{"lsr", "w0, w1, #31", 0x531f7c20},
+	{"asr", "x0, x1, x2", 0x9ac22820},
+	{"ror", "w0, w1, #5", 0x13811420},
+	{"lslv", "x0, x1, x2", 0x9ac22020},
+	{"extr", "x0, x1, x2, #17", 0x93c24420},
+	{"ubfm", "x0, x1, #4, #7", 0xd3441c20},
+	{"sbfx", "w0, w1, #2, #5", 0x13021820},
+	{"ubfx", "x0, x1, #60, #4", 0xd37cfc20},
+	{"bfi", "x0, x1, #8, #8", 0xb3781c20},
+	{"ubfiz", "w0, w1, #3, #4", 0x531d0c20},
+	{"bfxil", "w0, w1, #0, #1", 0x33000020},
+	{"sxtb", "x0, w1", 0x93401c20},
+	{"sxth", "w0, w1", 0x13003c20},
+	{"sxtw", "x0, w1", 0x93407c20},
+	{"uxtb", "w0, w1", 0x53001c20},
+	{"uxth", "w0, w1", 0x53003c20},
+	{"madd", "x0, x1, x2, x3", 0x9b020c20},
+	{"msub", "w0, w1, w2, w3", 0x1b028c20},
+	{"smaddl", "x0, w1, w2, x3", 0x9b220c20},
+	{"umsubl", "x0, w1, w2, x3", 0x9ba28c20},
+	{"mul", "w0, w1, w2", 0x1b027c20},
+	{"mneg", "x0, x1, x2", 0x9b02fc20},
+	{"smull", "x0, w1, w2", 0x9b227c20},
+	{"umulh", "x0, x1, x2", 0x9bc27c20},
+	{"sdiv", "x0, x1, x2", 0x9ac20c20},
+	{"udiv", "w0, w1, w2", 0x1ac20820},
+	{"clz", "x0, x1", 0xdac01020},
+	{"cls", "w0, w1", 0x5ac01420},
+	{"rbit", "x0, x1", 0xdac00020},
+	{"rev", "w0, w1", 0x5ac00820},
+	{"rev", "x0, x1", 0xdac00c20},
+	{"rev16", "x0, x1", 0xdac00420},
+	{"rev32", "x0, x1", 0xdac00820},
+	{"csel", "x0, x1, x2, eq", 0x9a820020},
+	{"csinc", "w0, w1, w2, lt", 0x1a82b420},
+	{"csinv", "x0, x1, x2, hs", 0xda822020},
+	{"csneg", "w0, w1, w2, mi", 0x5a824420},
+	{"cset", "w0, ne", 0x1a9f07e0},
+	{"csetm", "x0, gt", 0xda9fd3e0},
+	{"cinc", "x0, x1, le", 0x9a81c420},
+	{"cneg", "w0, w1, vs", 0x5a817420},
+	{"ccmp", "x0, #3, #4, ne", 0xfa431804},
+	{"ccmn", "w0, w1, #0, eq", 0x3a410000},
+	{"ldr", "x0, [x1]", 0xf9400020},
+	{"ldr", "w0, [x1, #4]", 0xb9400420},
+	{"ldr", "x0, [sp, #32]!", 0xf8420fe0},
+	{"ldr", "x0, [x1], #-8", 0xf85f8420},
+	{"ldr", "x0, [x1, x2, lsl #3]", 0xf8627820},
+	{"ldr", "w0, [x1, w2, sxtw #2]", 0xb862d820},
+	{"ldr", "w0, [x1, w2, uxtw]", 0xb8624820},
+	{"ldrb", "w0, [x1, #255]", 0x3943fc20},
+	{"ldrsb", "x0, [x1]", 0x39800020},
+	{"ldrh", "w0, [x1, #2]", 0x79400420},
+	{"ldrsh", "w0, [x1, x2]", 0x78e26820},
+	{"ldrsw", "x0, [x1, #4]", 0xb9800420},
+	{"str", "x0, [x1, #8]", 0xf9000420},
+	{"strb", "w0, [x1], #1", 0x38001420},
+	{"strh", "w0, [sp, #-2]!", 0x781fefe0},
+	{"ldur", "x0, [x1, #-8]", 0xf85f8020},
+	{"stur", "w0, [x1, #3]", 0xb8003020},
+	{"ldr", "q0, [x1, #16]", 0x3dc00420},
+	{"ldr", "d0, [x1, x2, lsl #3]", 0xfc627820},
+	{"str", "s0, [sp, #4]", 0xbd0007e0},
+	{"ldr", "b0, [x0]", 0x3d400000},
+	{"str", "h1, [x2, #2]", 0x7d000441},
+	{"ldur", "q0, [x1, #-16]", 0x3cdf0020},
+	{"prfm", "pldl1keep, [x0, #64]", 0xf9802000},
+	{"prfum", "pstl2strm, [x0, #-1]", 0xf89ff013},
+	{"ldp", "x29, x30, [sp], #16", 0xa8c17bfd},
+	{"stp", "x29, x30, [sp, #-16]!", 0xa9bf7bfd},
+	{"ldp", "w0, w1, [x2, #8]", 0x29410440},
+	{"stnp", "x0, x1, [x2]", 0xa8000440},
+	{"ldpsw", "x0, x1, [x2, #8]", 0x69410440},
+	{"ldp", "q0, q1, [x0, #32]", 0xad410400},
+	{"stp", "d8, d9, [sp, #-16]!", 0x6dbf27e8},
+	{"ldxr", "x0, [x1]", 0xc85f7c20},
+	{"ldaxrb", "w0, [x1]", 0x085ffc20},
+	{"stxr", "w2, x0, [x1]", 0xc8027c20},
+	{"stlxrh", "w2, w0, [x1]", 0x4802fc20},
+	{"stlr", "w0, [x1]", 0x889ffc20},
+	{"ldar", "x0, [x1]", 0xc8dffc20},
+	{"fadd", "s0, s1, s2", 0x1e222820},
+	{"fsub", "d0, d1, d2", 0x1e623820},
+	{"fmul", "h0, h1, h2", 0x1ee20820},
+	{"fdiv", "d0, d1, d2", 0x1e621820},
+	{"fmax", "s0, s1, s2", 0x1e224820},
+	{"fminnm", "d0, d1, d2", 0x1e627820},
+	{"fnmul", "s0, s1, s2", 0x1e228820},
+	{"fmadd", "d0, d1, d2, d3", 0x1f420c20},
+	{"fnmsub", "s0, s1, s2, s3", 0x1f228c20},
+	{"fabs", "d0, d1", 0x1e60c020},
+	{"fneg", "s0, s1", 0x1e214020},
+	{"fsqrt", "d0, d1", 0x1e61c020},
+	{"frintm", "s0, s1", 0x1e254020},
+	{"frintz", "d0, d1", 0x1e65c020},
+	{"fcvt", "d0, s1", 0x1e22c020},
+	{"fcvt", "s0, d1", 0x1e624020},
+	{"fcvt", "h0, s1", 0x1e23c020},
+	{"fcmp", "d0, d1", 0x1e612000},
+	{"fcmp", "s0, #0.0", 0x1e202008},
+	{"fcmpe", "d0, #0.0", 0x1e602018},
+	{"fccmp", "s0, s1, #0, eq", 0x1e210400},
+	{"fcsel", "d0, d1, d2, gt", 0x1e62cc20},
+	{"fmov", "s0, w1", 0x1e270020},
+	{"fmov", "x0, d1", 0x9e660020},
+	{"fmov", "d0, d1", 0x1e604020},
+	{"fmov", "d0, #1.5", 0x1e6f1000},
+	{"fmov", "s0, #-2.0", 0x1e301000},
+	{"fmov", "v0.d[1], x1", 0x9eaf0020},
+	{"scvtf", "d0, x1", 0x9e620020},
+	{"ucvtf", "s0, w1", 0x1e230020},
+	{"fcvtzs", "w0, d1", 0x1e780020},
+	{"fcvtzu", "x0, s1", 0x9e390020},
+	{"fcvtas", "x0, d1", 0x9e640020},
+	{"fcvtms", "w0, s1", 0x1e300020},
+	{"add", "v0.4s, v1.4s, v2.4s", 0x4ea28420},
+	{"add.4s", "v0, v1, v2", 0x4ea28420},
+	{"sub", "v0.2d, v1.2d, v2.2d", 0x6ee28420},
+	{"mul", "v0.8h, v1.8h, v2.8h", 0x4e629c20},
+	{"cmeq", "v0.16b, v1.16b, v2.16b", 0x6e228c20},
+	{"cmhi", "v0.4s, v1.4s, v2.4s", 0x6ea23420},
+	{"cmle", "v0.4s, v1.4s, v2.4s", 0x4ea13c40},
+	{"cmeq", "v0.4s, v1.4s, #0", 0x4ea09820},
+	{"and", "v0.16b, v1.16b, v2.16b", 0x4e221c20},
+	{"orr", "v0.8b, v1.8b, v2.8b", 0x0ea21c20},
+	{"bsl", "v0.16b, v1.16b, v2.16b", 0x6e621c20},
+	{"eor.16b", "v0, v1, v2", 0x6e221c20},
+	{"fadd", "v0.4s, v1.4s, v2.4s", 0x4e22d420},
+	{"fmul", "v0.2d, v1.2d, v2.2d", 0x6e62dc20},
+	{"fmla", "v0.4s, v1.4s, v2.4s", 0x4e22cc20},
+	{"fcmgt", "v0.2d, v1.2d, v2.2d", 0x6ee2e420},
+	{"fcmlt", "v0.4s, v1.4s, v2.4s", 0x6ea1e440},
+	{"rev64", "v0.16b, v1.16b", 0x4e200820},
+	{"cnt", "v0.8b, v1.8b", 0x0e205820},
+	{"abs", "v0.4s, v1.4s", 0x4ea0b820},
+	{"neg", "v0.2d, v1.2d", 0x6ee0b820},
+	{"xtn", "v0.8b, v1.8h", 0x0e212820},
+	{"xtn2", "v0.16b, v1.8h", 0x4e212820},
+	{"sqxtn", "v0.4h, v1.4s", 0x0e614820},
+	{"fabs", "v0.4s, v1.4s", 0x4ea0f820},
+	{"fneg", "v0.2d, v1.2d", 0x6ee0f820},
+	{"fsqrt", "v0.4s, v1.4s", 0x6ea1f820},
+	{"scvtf", "v0.4s, v1.4s", 0x4e21d820},
+	{"fcvtzs", "v0.2d, v1.2d", 0x4ee1b820},
+	{"fcvtl", "v0.2d, v1.2s", 0x0e617820},
+	{"fcvtn", "v0.2s, v1.2d", 0x0e616820},
+	{"addv", "s0, v1.4s", 0x4eb1b820},
+	{"umaxv", "b0, v1.16b", 0x6e30a820},
+	{"fmaxnmv", "s0, v1.4s", 0x6e30c820},
+	{"uaddlv", "h0, v1.16b", 0x6e303820},
+	{"saddl", "v0.8h, v1.8b, v2.8b", 0x0e220020},
+	{"umull", "v0.2d, v1.2s, v2.2s", 0x2ea2c020},
+	{"umull2", "v0.4s, v1.8h, v2.8h", 0x6e62c020},
+	{"pmull", "v0.8h, v1.8b, v2.8b", 0x0e22e020},
+	{"shl", "v0.4s, v1.4s, #3", 0x4f235420},
+	{"ushr", "v0.2d, v1.2d, #63", 0x6f410420},
+	{"sshr", "v0.8h, v1.8h, #1", 0x4f1f0420},
+	{"ushll", "v0.8h, v1.8b, #0", 0x2f08a420},
+	{"shrn", "v0.8b, v1.8h, #4", 0x0f0c8420},
+	{"sri", "v0.4s, v1.4s, #8", 0x6f384420},
+	{"mul", "v0.4s, v1.4s, v2.s[1]", 0x4fa28020},
+	{"fmla", "v0.2d, v1.2d, v2.d[1]", 0x4fc21820},
+	{"fmul", "s0, s1, v2.s[3]", 0x5fa29820},
+	{"zip1", "v0.16b, v1.16b, v2.16b", 0x4e023820},
+	{"uzp2", "v0.4s, v1.4s, v2.4s", 0x4e825820},
+	{"trn1", "v0.2d, v1.2d, v2.2d", 0x4ec22820},
+	{"ext", "v0.16b, v1.16b, v2.16b, #3", 0x6e021820},
+	{"tbl", "v0.16b, {v1.16b}, v2.16b", 0x4e020020},
+	{"tbl", "v0.16b, {v1.16b, v2.16b}, v3.16b", 0x4e032020},
+	{"dup", "v0.4s, w1", 0x4e040c20},
+	{"dup", "v0.2d, v1.d[1]", 0x4e180420},
+	{"dup", "s0, v1.s[2]", 0x5e140420},
+	{"mov", "v0.16b, v1.16b", 0x4ea11c20},
+	{"mov", "v0.s[1], w1", 0x4e0c1c20},
+	{"mov", "v0.s[1], v1.s[3]", 0x6e0c6420},
+	{"mov", "w0, v1.s[1]", 0x0e0c3c20},
+	{"mov", "x0, v1.d[0]", 0x4e083c20},
+	{"umov", "w0, v1.b[15]", 0x0e1f3c20},
+	{"smov", "x0, v1.h[3]", 0x4e0e2c20},
+	{"ins", "v0.d[1], x1", 0x4e181c20},
+	{"movi", "v0.2d, #0", 0x6f00e400},
+	{"movi", "v0.16b, #0xff", 0x4f07e7e0},
+	{"movi", "v0.4s, #1, lsl #8", 0x4f002420},
+	{"mvni", "v0.4s, #0", 0x6f000400},
+	{"movi", "d0, #0xff00ff00ff00ff00", 0x2f05e540},
+	{"fmov", "v0.4s, #1.0", 0x4f03f600},
+	{"ld1", "{v0.16b}, [x0]", 0x4c407000},
+	{"ld1", "{v0.4s, v1.4s}, [x0], #32", 0x4cdfa800},
+	{"st1", "{v0.2d, v1.2d, v2.2d, v3.2d}, [x1]", 0x4c002c20},
+	{"ld1", "{v0.s}[1], [x0]", 0x0d409000},
+	{"ld1r", "{v0.4s}, [x0]", 0x4d40c800},
+	{"st1", "{v0.d}[0], [x0], #8", 0x0d9f8400},
+	{"ld2", "{v0.8h, v1.8h}, [x0]", 0x4c408400},
+	{"st4", "{v0.16b, v1.16b, v2.16b, v3.16b}, [x0], x2", 0x4c820000},
+	{"ld1", "{v0.16b}, [x0], x2", 0x4cc27000},
+	{"add", "d0, d1, d2", 0x5ee28420},
+	{"sub", "d0, d1, d2", 0x7ee28420},
+	{"cmeq", "d0, d1, #0", 0x5ee09820},
+	{"ushr", "d0, d1, #3", 0x7f7d0420},
+	{"addp", "d0, v1.2d", 0x5ef1b820},
+	{"faddp", "s0, v1.2s", 0x7e30d820},
+	{"fabd", "s0, s1, s2", 0x7ea2d420},
+	{"fcmge", "s0, s1, s2", 0x7e22e420},
+	{"fcmgt", "d0, d1, d2", 0x7ee2e420},
+	{"facge", "s3, s4, s5", 0x7e25ec83},
+	{"facgt", "d3, d4, d5", 0x7ee5ec83},
+	{"frecps", "s0, s1, s2", 0x5e22fc20},
+	{"frsqrts", "d0, d1, d2", 0x5ee2fc20},
+	{"fmulx", "s0, s1, s2", 0x5e22dc20},
+	{"fabd", "d7, d8, d9", 0x7ee9d507},
+	{"fcmeq", "s0, s1, #0.0", 0x5ea0d820},
+	{"fcmlt", "d0, d1, #0.0", 0x5ee0e820},
+	{"fcvtas", "s0, s1", 0x5e21c820},
+	{"fcvtzs", "d0, d1", 0x5ee1b820},
+	{"frecpe", "s2, s3", 0x5ea1d862},
+	{"frsqrte", "d2, d3", 0x7ee1d862},
+	{"fmaxnmp", "s0, v1.2s", 0x7e30c820},
+	{"fminp", "d0, v1.2d", 0x7ef0f820},
+
+	// the extensions: bti, pauth, lse, crc, dotprod, aes, sha2, sha3
+	{"bti", "", 0xd503241f},
+	{"bti", "c", 0xd503245f},
+	{"bti", "j", 0xd503249f},
+	{"bti", "jc", 0xd50324df},
+	{"paciasp", "", 0xd503233f},
+	{"autiasp", "", 0xd50323bf},
+	{"pacibsp", "", 0xd503237f},
+	{"autibsp", "", 0xd50323ff},
+	{"paciaz", "", 0xd503231f},
+	{"xpaclri", "", 0xd50320ff},
+	{"mrs", "x0, tpidr_el0", 0xd53bd040},
+	{"mrs", "x1, cntvct_el0", 0xd53be041},
+	{"mrs", "x2, nzcv", 0xd53b4202},
+	{"mrs", "x3, fpcr", 0xd53b4403},
+	{"mrs", "x4, id_aa64isar0_el1", 0xd5380604},
+	{"mrs", "x5, s3_3_c13_c0_2", 0xd53bd045},
+	{"msr", "fpcr, x0", 0xd51b4400},
+	{"msr", "nzcv, x1", 0xd51b4201},
+	{"msr", "tpidr_el0, x2", 0xd51bd042},
+	{"crc32b", "w0, w1, w2", 0x1ac24020},
+	{"crc32h", "w0, w1, w2", 0x1ac24420},
+	{"crc32w", "w0, w1, w2", 0x1ac24820},
+	{"crc32x", "w0, w1, x2", 0x9ac24c20},
+	{"crc32cb", "w0, w1, w2", 0x1ac25020},
+	{"crc32ch", "w0, w1, w2", 0x1ac25420},
+	{"crc32cw", "w0, w1, w2", 0x1ac25820},
+	{"crc32cx", "w0, w1, x2", 0x9ac25c20},
+	{"ldadd", "w0, w1, [x2]", 0xb8200041},
+	{"ldadd", "x0, x1, [sp]", 0xf82003e1},
+	{"ldadda", "x0, x1, [x2]", 0xf8a00041},
+	{"ldaddl", "x0, x1, [x2]", 0xf8600041},
+	{"ldaddal", "x0, x1, [x2]", 0xf8e00041},
+	{"ldaddalb", "w0, w1, [x2]", 0x38e00041},
+	{"ldaddh", "w0, w1, [x2]", 0x78200041},
+	{"ldclral", "x3, x4, [x5]", 0xf8e310a4},
+	{"ldeor", "w3, w4, [x5]", 0xb82320a4},
+	{"ldset", "x3, x4, [x5]", 0xf82330a4},
+	{"ldsmax", "x3, x4, [x5]", 0xf82340a4},
+	{"ldsmin", "w3, w4, [x5]", 0xb82350a4},
+	{"ldumax", "x3, x4, [x5]", 0xf82360a4},
+	{"lduminlh", "w3, w4, [x5]", 0x786370a4},
+	{"stadd", "w0, [x1]", 0xb820003f},
+	{"staddl", "x0, [x1]", 0xf860003f},
+	{"stclrb", "w0, [x1]", 0x3820103f},
+	{"steorlh", "w0, [x1]", 0x7860203f},
+	{"stset", "x0, [x1]", 0xf820303f},
+	{"swp", "w0, w1, [x2]", 0xb8208041},
+	{"swpal", "x0, x1, [x2]", 0xf8e08041},
+	{"swpab", "w0, w1, [x2]", 0x38a08041},
+	{"swplh", "w0, w1, [x2]", 0x78608041},
+	{"cas", "w0, w1, [x2]", 0x88a07c41},
+	{"casal", "x0, x1, [x2]", 0xc8e0fc41},
+	{"casa", "x0, x1, [x2]", 0xc8e07c41},
+	{"casl", "x0, x1, [x2]", 0xc8a0fc41},
+	{"casb", "w0, w1, [x2]", 0x08a07c41},
+	{"casalh", "w0, w1, [x2]", 0x48e0fc41},
+	{"casp", "x0, x1, x2, x3, [x4]", 0x48207c82},
+	{"caspal", "w0, w1, w2, w3, [x4]", 0x0860fc82},
+	{"sdot", "v0.4s, v1.16b, v2.16b", 0x4e829420},
+	{"udot", "v0.2s, v1.8b, v2.8b", 0x2e829420},
+	{"sdot", "v0.4s, v1.16b, v2.4b[3]", 0x4fa2e820},
+	{"udot", "v0.2s, v1.8b, v15.4b[0]", 0x2f8fe020},
+	{"aese", "v0.16b, v1.16b", 0x4e284820},
+	{"aesd", "v0.16b, v1.16b", 0x4e285820},
+	{"aesmc", "v0.16b, v1.16b", 0x4e286820},
+	{"aesimc", "v0.16b, v1.16b", 0x4e287820},
+	{"sha1h", "s0, s1", 0x5e280820},
+	{"sha1su1", "v0.4s, v1.4s", 0x5e281820},
+	{"sha256su0", "v0.4s, v1.4s", 0x5e282820},
+	{"sha512su0", "v0.2d, v1.2d", 0xcec08020},
+	{"sha1c", "q0, s1, v2.4s", 0x5e020020},
+	{"sha1p", "q0, s1, v2.4s", 0x5e021020},
+	{"sha1m", "q0, s1, v2.4s", 0x5e022020},
+	{"sha1su0", "v0.4s, v1.4s, v2.4s", 0x5e023020},
+	{"sha256h", "q0, q1, v2.4s", 0x5e024020},
+	{"sha256h2", "q0, q1, v2.4s", 0x5e025020},
+	{"sha256su1", "v0.4s, v1.4s, v2.4s", 0x5e026020},
+	{"sha512h", "q0, q1, v2.2d", 0xce628020},
+	{"sha512h2", "q0, q1, v2.2d", 0xce628420},
+	{"sha512su1", "v0.2d, v1.2d, v2.2d", 0xce628820},
+	{"rax1", "v0.2d, v1.2d, v2.2d", 0xce628c20},
+	{"eor3", "v0.16b, v1.16b, v2.16b, v3.16b", 0xce020c20},
+	{"bcax", "v0.16b, v1.16b, v2.16b, v3.16b", 0xce220c20},
+	{"xar", "v0.2d, v1.2d, v2.2d, #10", 0xce822820},
+}
+
+func TestArm64Asm(t *testing.T) {
+	for _, v := range arm64AsmTests {
+		data, err := arm64Asm(v.mnemo, v.opers, 0)
+		if err != nil {
+			t.Errorf("%s %s: %v", v.mnemo, v.opers, err)
+			continue
+		}
+		if got := binary.LittleEndian.Uint32(data); got != v.want {
+			t.Errorf("%s %s = %#08x, want %#08x", v.mnemo, v.opers, got, v.want)
+		}
+	}
+}
+
+func TestArm64AsmError(t *testing.T) {
+	for _, v := range []struct{ mnemo, opers string }{
+		{"add", "x0, w1, x2"},
+		{"tbz", "w0, #40, #8"},
+		{"b", "#2"},
+		{"rev32", "w0, w1"},
+		{"ldr", "x0, [x1, #4096]!"},
+		{"frob", "x0"},
+		// fp16 is not encoded
+		{"fcmge", "h2, h7, h9"},
+		{"fabd", "h0, h1, h2"},
+		{"fcmeq", "h0, h1, #0.0"},
+		{"fcvtas", "h0, h1"},
+		{"frecpe", "h0, h1"},
+		{"fmaxnmp", "h0, v1.2h"},
+		{"fadd", "v0.4h, v1.4h, v2.4h"},
+	} {
+		if data, err := arm64Asm(v.mnemo, v.opers, 0); err == nil {
+			t.Errorf("%s %s = %x, want error", v.mnemo, v.opers, data)
+		}
+	}
+}
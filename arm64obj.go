@@ -0,0 +1,246 @@
+package main
+
+import (
+	"debug/elf"
+	"debug/macho"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/arch/arm64/arm64asm"
+)
+
+func signExtend(v uint32, bits uint) int64 {
+	return int64(int32(v<<(32-bits)) >> (32 - bits))
+}
+
+func (aa *archArm64) Decode(ea int64, data []byte) (_ *instrBase, target int64, err error) {
+	if len(data) < 4 {
+		err = errors.New("truncated instruction")
+		return
+	}
+	w := binary.LittleEndian.Uint32(data)
+	ib := &instrBase{
+		kind: InstrKind_Normal,
+		ea:   ea,
+		data: data[:4],
+	}
+	if inst, err1 := arm64asm.Decode(data[:4]); err1 == nil {
+		text := arm64asm.GNUSyntax(inst)
+		if idx := strings.IndexByte(text, ' '); idx != -1 {
+			ib.mnemo, ib.opers = text[:idx], text[idx+1:]
+		} else {
+			ib.mnemo = text
+		}
+	} else {
+		ib.mnemo, ib.opers = ".inst", fmt.Sprintf("0x%08x", w)
+	}
+
+	target = -1
+	switch {
+	case w&0xfc000000 == 0x14000000:
+		// b
+		ib.kind = InstrKind_Jmp
+		target = ea + signExtend(w&0x3ffffff, 26)*4
+	case w&0xfc000000 == 0x94000000:
+		// bl
+		ib.kind = InstrKind_Call
+		target = ea + signExtend(w&0x3ffffff, 26)*4
+	case w&0xff000000 == 0x54000000:
+		// b.cond, bc.cond
+		ib.kind = InstrKind_Cond_Jmp
+		target = ea + signExtend(w>>5&0x7ffff, 19)*4
+	case w&0x7e000000 == 0x34000000:
+		// cbz, cbnz
+		ib.kind = InstrKind_Cond_Jmp
+		target = ea + signExtend(w>>5&0x7ffff, 19)*4
+	case w&0x7e000000 == 0x36000000:
+		// tbz, tbnz
+		ib.kind = InstrKind_Cond_Jmp
+		target = ea + signExtend(w>>5&0x3fff, 14)*4
+	case w&0xfffffc1f == 0xd65f0000, w&0xfffffbff == 0xd65f0bff:
+		// ret, retaa, retab
+		ib.kind = InstrKind_Ret
+	case w&0xfffffc1f == 0xd61f0000, w&0xfefff800 == 0xd61f0800:
+		// br, braa, braaz
+		ib.kind = InstrKind_Indirect_Jmp
+	case w&0xfffffc1f == 0xd63f0000, w&0xfefff800 == 0xd63f0800:
+		// blr, blraa, blraaz
+		ib.kind = InstrKind_Indirect_Call
+	}
+	return ib, target, nil
+}
+
+type arm64Fix int
+
+const (
+	arm64FixBranch26 arm64Fix = iota
+	arm64FixImm19
+	arm64FixImm14
+	arm64FixAdr
+	arm64FixAdrp
+	// add and load/store, scaled by the access size
+	arm64FixLo12
+	// ldr x0, [x0, #off] of the got, relaxed to add x0, x0, #off
+	arm64FixGotLo12
+)
+
+// arm64Patch points the immediate field of the instruction at pc to addr.
+func arm64Patch(data []byte, fix arm64Fix, pc, addr int64) error {
+	if len(data) < 4 {
+		return errors.New("truncated instruction")
+	}
+	w := binary.LittleEndian.Uint32(data)
+	field := func(v int64, bits uint, lo uint) error {
+		if v < -1<<(bits-1) || v >= 1<<(bits-1) {
+			return fmt.Errorf("relocation out of range: %d", v)
+		}
+		mask := uint32(1)<<bits - 1
+		w = w&^(mask<<lo) | uint32(v)&mask<<lo
+		return nil
+	}
+	rel := addr - pc
+	if fix <= arm64FixImm14 && rel&3 != 0 {
+		return fmt.Errorf("misaligned branch: %d", rel)
+	}
+
+	var err error
+	switch fix {
+	case arm64FixBranch26:
+		err = field(rel>>2, 26, 0)
+	case arm64FixImm19:
+		err = field(rel>>2, 19, 5)
+	case arm64FixImm14:
+		err = field(rel>>2, 14, 5)
+	case arm64FixAdr, arm64FixAdrp:
+		if fix == arm64FixAdrp {
+			rel = addr>>12 - pc>>12
+		}
+		if rel < -1<<20 || rel >= 1<<20 {
+			return fmt.Errorf("relocation out of range: %d", rel)
+		}
+		// immhi:immlo
+		v := uint32(rel) & 0x1fffff
+		w = w&^(3<<29|0x7ffff<<5) | (v&3)<<29 | v>>2<<5
+	case arm64FixGotLo12:
+		// there is no got, the symbol is always local
+		if w&0xffc00000 != 0xf9400000 {
+			return fmt.Errorf("unexpected got load: 0x%08x", w)
+		}
+		w = 0x91000000 | w&0x3ff
+		fallthrough
+	case arm64FixLo12:
+		var scale uint
+		// load/store unsigned offset
+		if w&0x3b000000 == 0x39000000 {
+			scale = uint(w >> 30)
+			if w&0x04800000 == 0x04800000 && scale == 0 {
+				// 128-bit
+				scale = 4
+			}
+		}
+		lo := addr & 0xfff
+		if lo&(1<<scale-1) != 0 {
+			return fmt.Errorf("misaligned offset: %d", lo)
+		}
+		w = w&^(0xfff<<10) | uint32(lo>>scale)<<10
+	}
+	if err != nil {
+		return err
+	}
+	binary.LittleEndian.PutUint32(data, w)
+	return nil
+}
+
+func (aa *archArm64) ELFMachine() elf.Machine {
+	return elf.EM_AARCH64
+}
+
+func (aa *archArm64) ELFReloc(typ uint32, data []byte, pc, addr int64) error {
+	switch elf.R_AARCH64(typ) {
+	case elf.R_AARCH64_CALL26, elf.R_AARCH64_JUMP26:
+		return arm64Patch(data, arm64FixBranch26, pc, addr)
+	case elf.R_AARCH64_CONDBR19, elf.R_AARCH64_LD_PREL_LO19:
+		return arm64Patch(data, arm64FixImm19, pc, addr)
+	case elf.R_AARCH64_TSTBR14:
+		return arm64Patch(data, arm64FixImm14, pc, addr)
+	case elf.R_AARCH64_ADR_PREL_LO21:
+		return arm64Patch(data, arm64FixAdr, pc, addr)
+	case elf.R_AARCH64_ADR_PREL_PG_HI21, elf.R_AARCH64_ADR_PREL_PG_HI21_NC:
+		return arm64Patch(data, arm64FixAdrp, pc, addr)
+	case elf.R_AARCH64_ADD_ABS_LO12_NC,
+		elf.R_AARCH64_LDST8_ABS_LO12_NC, elf.R_AARCH64_LDST16_ABS_LO12_NC,
+		elf.R_AARCH64_LDST32_ABS_LO12_NC, elf.R_AARCH64_LDST64_ABS_LO12_NC,
+		elf.R_AARCH64_LDST128_ABS_LO12_NC:
+		return arm64Patch(data, arm64FixLo12, pc, addr)
+	case elf.R_AARCH64_PREL16:
+		return putPrel(binary.LittleEndian, data, 2, addr-pc)
+	case elf.R_AARCH64_PREL32:
+		return putPrel(binary.LittleEndian, data, 4, addr-pc)
+	case elf.R_AARCH64_PREL64:
+		return putPrel(binary.LittleEndian, data, 8, addr-pc)
+	}
+	return fmt.Errorf("unsupported relocation: %s", elf.R_AARCH64(typ))
+}
+
+func (aa *archArm64) MachoCpu() macho.Cpu {
+	return macho.CpuArm64
+}
+
+func (aa *archArm64) MachoReloc(sec *objSection, rels []macho.Reloc, symAddr func(r macho.Reloc) (int64, error)) error {
+	var addend, sub int64
+	var hasSub bool
+	for _, r := range rels {
+		off := int64(r.Addr)
+		if r.Scattered || off+4 > int64(len(sec.data)) {
+			return fmt.Errorf("bad relocation at %d", off)
+		}
+		data, pc := sec.data[off:], sec.addr+off
+
+		typ := macho.RelocTypeARM64(r.Type)
+		if typ == macho.ARM64_RELOC_ADDEND {
+			// for the next one
+			addend = signExtend(r.Value, 24)
+			continue
+		}
+		addr, err := symAddr(r)
+		if err != nil {
+			return fmt.Errorf("+%d: %w", off, err)
+		}
+		addr += addend
+
+		switch typ {
+		case macho.ARM64_RELOC_SUBTRACTOR:
+			sub, hasSub = addr, true
+			continue
+		case macho.ARM64_RELOC_UNSIGNED:
+			// .long LBB0_3-LJTI0_0
+			if !hasSub {
+				return fmt.Errorf("+%d: absolute address is unsupported", off)
+			}
+			var v int64
+			if r.Len == 3 {
+				v = int64(binary.LittleEndian.Uint64(data))
+			} else {
+				v = int64(int32(binary.LittleEndian.Uint32(data)))
+			}
+			err = putPrel(binary.LittleEndian, data, 1<<r.Len, v+addr-sub)
+		case macho.ARM64_RELOC_BRANCH26:
+			err = arm64Patch(data, arm64FixBranch26, pc, addr)
+		case macho.ARM64_RELOC_PAGE21, macho.ARM64_RELOC_GOT_LOAD_PAGE21:
+			err = arm64Patch(data, arm64FixAdrp, pc, addr)
+		case macho.ARM64_RELOC_PAGEOFF12:
+			err = arm64Patch(data, arm64FixLo12, pc, addr)
+		case macho.ARM64_RELOC_GOT_LOAD_PAGEOFF12:
+			err = arm64Patch(data, arm64FixGotLo12, pc, addr)
+		default:
+			err = fmt.Errorf("unsupported relocation: %s", typ)
+		}
+		if err != nil {
+			return fmt.Errorf("+%d: %w", off, err)
+		}
+		addend, hasSub = 0, false
+	}
+	return nil
+}
@@ -0,0 +1,793 @@
+package main
+
+import (
+	"strings"
+)
+
+// advanced simd part of the A64 encoder
+
+// Q, size
+var a64Arrs = map[string][2]uint32{
+	"8b": {0, 0}, "16b": {1, 0}, "4h": {0, 1}, "8h": {1, 1},
+	"2s": {0, 2}, "4s": {1, 2}, "1d": {0, 3}, "2d": {1, 3}, "1q": {0, 4},
+}
+
+var a64Elems = map[string]uint32{"b": 0, "h": 1, "s": 2, "d": 3}
+
+func (a *a64) arr() (q, size uint32) {
+	v, ok := a64Arrs[a.t]
+	if !ok {
+		a64Fail("invalid arrangement: %s", a.t)
+	}
+	return v[0], v[1]
+}
+
+func (a *a64) elemSize() uint32 {
+	if v, ok := a64Elems[a.t]; ok {
+		return v
+	}
+	_, size := a.arr()
+	return size
+}
+
+// fp arrangement, 2s/4s/2d
+func (a *a64) farr() (q, sz uint32) {
+	q, size := a.arr()
+	if size == 1 {
+		a64Fail("unsupported fp16 arrangement: %s, try -asm llvm-mc", a.t)
+	}
+	if size != 2 && size != 3 || a.t == "1d" {
+		a64Fail("invalid arrangement: %s", a.t)
+	}
+	return q, size - 2
+}
+
+// fsz is the sz bit of the scalar fp register size, fp16 is not encoded.
+func fsz(size uint32) uint32 {
+	if size == 1 {
+		a64Fail("unsupported fp16 register, try -asm llvm-mc")
+	}
+	if size != 2 && size != 3 {
+		a64Fail("invalid register")
+	}
+	return size - 2
+}
+
+// U<<5 | opcode
+var a64SIMD3Same = map[string]uint32{
+	"shadd": 0x00, "uhadd": 0x20, "sqadd": 0x01, "uqadd": 0x21, "srhadd": 0x02, "urhadd": 0x22,
+	"shsub": 0x04, "uhsub": 0x24, "sqsub": 0x05, "uqsub": 0x25, "cmgt": 0x06, "cmhi": 0x26,
+	"cmge": 0x07, "cmhs": 0x27, "sshl": 0x08, "ushl": 0x28, "sqshl": 0x09, "uqshl": 0x29,
+	"srshl": 0x0a, "urshl": 0x2a, "sqrshl": 0x0b, "uqrshl": 0x2b, "smax": 0x0c, "umax": 0x2c,
+	"smin": 0x0d, "umin": 0x2d, "sabd": 0x0e, "uabd": 0x2e, "saba": 0x0f, "uaba": 0x2f,
+	"add": 0x10, "sub": 0x30, "cmtst": 0x11, "cmeq": 0x31, "mla": 0x12, "mls": 0x32,
+	"mul": 0x13, "pmul": 0x33, "smaxp": 0x14, "umaxp": 0x34, "sminp": 0x15, "uminp": 0x35,
+	"sqdmulh": 0x16, "sqrdmulh": 0x36, "addp": 0x17,
+}
+
+// U<<2 | size
+var a64SIMDLogical = map[string]uint32{
+	"and": 0, "bic": 1, "orr": 2, "orn": 3, "eor": 4, "bsl": 5, "bit": 6, "bif": 7,
+}
+
+// U<<6 | a<<5 | opcode
+var a64SIMDFP3Same = map[string]uint32{
+	"fmaxnm": 0x18, "fmla": 0x19, "fadd": 0x1a, "fmulx": 0x1b, "fcmeq": 0x1c, "fmax": 0x1e, "frecps": 0x1f,
+	"fminnm": 0x38, "fmls": 0x39, "fsub": 0x3a, "fmin": 0x3e, "frsqrts": 0x3f,
+	"fmaxnmp": 0x58, "faddp": 0x5a, "fmul": 0x5b, "fcmge": 0x5c, "facge": 0x5d, "fmaxp": 0x5e, "fdiv": 0x5f,
+	"fminnmp": 0x78, "fabd": 0x7a, "fcmgt": 0x7c, "facgt": 0x7d, "fminp": 0x7e,
+}
+
+// register compares with swapped operands
+var a64SIMDSwap = map[string]string{
+	"cmle": "cmge", "cmlt": "cmgt", "cmls": "cmhs", "cmlo": "cmhi",
+	"fcmle": "fcmge", "fcmlt": "fcmgt", "facle": "facge", "faclt": "facgt",
+}
+
+// U<<5 | opcode
+var a64SIMD2Misc = map[string]uint32{
+	"rev64": 0x00, "rev32": 0x20, "rev16": 0x01, "saddlp": 0x02, "uaddlp": 0x22,
+	"suqadd": 0x03, "usqadd": 0x23, "cls": 0x04, "clz": 0x24, "cnt": 0x05,
+	"sadalp": 0x06, "uadalp": 0x26, "sqabs": 0x07, "sqneg": 0x27, "abs": 0x0b, "neg": 0x2b,
+	"xtn": 0x12, "sqxtun": 0x32, "sqxtn": 0x14, "uqxtn": 0x34,
+}
+
+// compare with zero, U<<5 | opcode
+var a64SIMDCmp0 = map[string]uint32{
+	"cmgt": 0x08, "cmge": 0x28, "cmeq": 0x09, "cmle": 0x29, "cmlt": 0x0a,
+}
+
+// U<<6 | a<<5 | opcode
+var a64SIMDFP2Misc = map[string]uint32{
+	"frintn": 0x18, "frintm": 0x19, "fcvtns": 0x1a, "fcvtms": 0x1b, "fcvtas": 0x1c, "scvtf": 0x1d,
+	"frintp": 0x38, "frintz": 0x39, "fcvtps": 0x3a, "fcvtzs": 0x3b, "frecpe": 0x3d, "fabs": 0x2f,
+	"frinta": 0x58, "frintx": 0x59, "fcvtnu": 0x5a, "fcvtmu": 0x5b, "fcvtau": 0x5c, "ucvtf": 0x5d,
+	"frinti": 0x79, "fcvtpu": 0x7a, "fcvtzu": 0x7b, "frsqrte": 0x7d, "fneg": 0x6f, "fsqrt": 0x7f,
+}
+
+var a64SIMDFPCmp0 = map[string]uint32{
+	"fcmgt": 0x2c, "fcmeq": 0x2d, "fcmlt": 0x2e, "fcmge": 0x6c, "fcmle": 0x6d,
+}
+
+// U<<5 | opcode
+var a64SIMDAcross = map[string]uint32{
+	"saddlv": 0x03, "uaddlv": 0x23, "smaxv": 0x0a, "umaxv": 0x2a, "sminv": 0x1a, "uminv": 0x3a, "addv": 0x1b,
+}
+
+// U<<6 | a<<5 | opcode
+var a64SIMDFPAcross = map[string]uint32{
+	"fmaxnmv": 0x4c, "fmaxv": 0x4f, "fminnmv": 0x6c, "fminv": 0x6f,
+}
+
+// U<<4 | opcode
+var a64SIMD3Diff = map[string]uint32{
+	"saddl": 0x0, "uaddl": 0x10, "saddw": 0x1, "uaddw": 0x11, "ssubl": 0x2, "usubl": 0x12,
+	"ssubw": 0x3, "usubw": 0x13, "addhn": 0x4, "raddhn": 0x14, "sabal": 0x5, "uabal": 0x15,
+	"subhn": 0x6, "rsubhn": 0x16, "sabdl": 0x7, "uabdl": 0x17, "smlal": 0x8, "umlal": 0x18,
+	"sqdmlal": 0x9, "smlsl": 0xa, "umlsl": 0x1a, "sqdmlsl": 0xb, "smull": 0xc, "umull": 0x1c,
+	"sqdmull": 0xd, "pmull": 0xe,
+}
+
+// U<<5 | opcode
+var a64SIMDShift = map[string]uint32{
+	"sshr": 0x00, "ushr": 0x20, "ssra": 0x02, "usra": 0x22, "srshr": 0x04, "urshr": 0x24,
+	"srsra": 0x06, "ursra": 0x26, "sri": 0x28, "shl": 0x0a, "sli": 0x2a, "sqshlu": 0x2c,
+	"sqshl": 0x0e, "uqshl": 0x2e, "shrn": 0x10, "sqshrun": 0x30, "rshrn": 0x11, "sqrshrun": 0x31,
+	"sqshrn": 0x12, "uqshrn": 0x32, "sqrshrn": 0x13, "uqrshrn": 0x33, "sshll": 0x14, "ushll": 0x34,
+}
+
+// U<<4 | opcode
+var a64SIMDElem = map[string]uint32{
+	"mla": 0x10, "mls": 0x14, "mul": 0x08, "smull": 0x0a, "umull": 0x1a, "smlal": 0x02, "umlal": 0x12,
+	"smlsl": 0x06, "umlsl": 0x16, "sqdmulh": 0x0c, "sqrdmulh": 0x0d, "sqdmull": 0x0b,
+	"sqdmlal": 0x03, "sqdmlsl": 0x07,
+}
+
+var a64SIMDFPElem = map[string]uint32{
+	"fmla": 0x01, "fmls": 0x05, "fmul": 0x09, "fmulx": 0x19,
+}
+
+var a64SIMDPermute = map[string]uint32{
+	"uzp1": 1, "trn1": 2, "zip1": 3, "uzp2": 5, "trn2": 6, "zip2": 7,
+}
+
+// the "2" variants work on the upper half
+var a64SIMDUpper = map[string]bool{
+	"xtn": true, "sqxtn": true, "uqxtn": true, "sqxtun": true, "fcvtn": true, "fcvtl": true,
+	"shrn": true, "rshrn": true, "sqshrn": true, "uqshrn": true, "sqrshrn": true, "uqrshrn": true,
+	"sqshrun": true, "sqrshrun": true, "sshll": true, "ushll": true, "sxtl": true, "uxtl": true,
+}
+
+func (a *a64) upper() (string, uint32) {
+	m := a.mnemo
+	if strings.HasSuffix(m, "2") {
+		base := m[:len(m)-1]
+		if _, ok := a64SIMD3Diff[base]; ok || a64SIMDUpper[base] {
+			return base, 1
+		}
+	}
+	return m, 0
+}
+
+// scalar register of the instruction, if any
+func (a *a64) scalar(i int) (n, size uint32, ok bool) {
+	if !a.isFP(i) {
+		return
+	}
+	n, size = a.fp(i)
+	return n, size, true
+}
+
+func (a *a64) encodeSIMD() uint32 {
+	mnemo, up := a.upper()
+	if m, ok := a64SIMDSwap[mnemo]; ok && len(a.ops) == 3 && a.isV(2) {
+		mnemo = m
+		a.ops[1], a.ops[2] = a.ops[2], a.ops[1]
+	}
+
+	switch mnemo {
+	case "sdot", "udot":
+		return a.simdDot()
+	case "mov":
+		return a.simdMov()
+	case "ins":
+		return a.simdMov()
+	case "umov", "smov":
+		a.nops(2)
+		rd, sf := a.gp(0, false)
+		rn, idx := a.vIdx(1)
+		size := a.elemSize()
+		op := uint32(0x0e003c00)
+		if mnemo == "smov" {
+			op = 0x0e002c00
+		}
+		return sf<<30 | op | a64Imm5(size, idx)<<16 | rn<<5 | rd
+	case "dup":
+		a.nops(2)
+		if n, size, ok := a.scalar(0); ok {
+			rn, idx := a.vIdx(1)
+			return 0x5e000400 | a64Imm5(size, idx)<<16 | rn<<5 | n
+		}
+		rd := a.v(0)
+		q, size := a.arr()
+		if a.isGP(1) {
+			rn, _ := a.gp(1, false)
+			return q<<30 | 0x0e000c00 | a64Imm5(size, 0)<<16 | rn<<5 | rd
+		}
+		rn, idx := a.vIdx(1)
+		return q<<30 | 0x0e000400 | a64Imm5(size, idx)<<16 | rn<<5 | rd
+	case "fmov":
+		a.nops(2)
+		if a.isGP(0) {
+			rd := a.gpSF(0, false, 1)
+			rn, idx := a.vIdx(1)
+			if a.elemSize() != 3 || idx != 1 {
+				a64Fail("invalid element")
+			}
+			return 0x9eae0000 | rn<<5 | rd
+		}
+		rd := a.v(0)
+		if a.isGP(1) {
+			if _, idx := a.vIdx(0); a.elemSize() != 3 || idx != 1 {
+				a64Fail("invalid element")
+			}
+			return 0x9eaf0000 | a.gpSF(1, false, 1)<<5 | rd
+		}
+		q, sz := a.farr()
+		imm8, ok := encodeFPImm(a.fimm(1))
+		if !ok {
+			a64Fail("invalid fp immediate")
+		}
+		return q<<30 | sz<<29 | 0x0f00f400 | (imm8>>5)<<16 | (imm8&31)<<5 | rd
+	case "movi", "mvni":
+		return a.simdMovi(mnemo)
+	case "not", "mvn":
+		a.nops(2)
+		q, _ := a.arr()
+		return q<<30 | 0x2e205800 | a.v(1)<<5 | a.v(0)
+	case "rbit":
+		a.nops(2)
+		q, _ := a.arr()
+		return q<<30 | 0x2e605800 | a.v(1)<<5 | a.v(0)
+	case "ext":
+		a.nops(4)
+		q, _ := a.arr()
+		return q<<30 | 0x2e000000 | a.v(2)<<16 | a.uimm(3, 4)<<11 | a.v(1)<<5 | a.v(0)
+	case "tbl", "tbx":
+		a.nops(3)
+		q, _ := a.arr()
+		list := a.op(1)
+		if list.typ != a64OpList {
+			a64Fail("expect register list")
+		}
+		op := a64Bit(mnemo == "tbx") << 12
+		return q<<30 | 0x0e000000 | a.v(2)<<16 | uint32(len(list.list)-1)<<13 | op | list.list[0].n<<5 | a.v(0)
+	case "sxtl", "uxtl":
+		a.nops(2)
+		a.ops = append(a.ops, a64Op{typ: a64OpImm})
+		mnemo = map[string]string{"sxtl": "sshll", "uxtl": "ushll"}[mnemo]
+	case "fcvtn", "fcvtl":
+		a.nops(2)
+		_, size := a.arr()
+		// the wider side decides sz
+		sz := size - 1
+		op := uint32(0x16)
+		if mnemo == "fcvtl" {
+			op, sz = 0x17, size-2
+		}
+		return up<<30 | 0x0e200800 | sz<<22 | op<<12 | a.v(1)<<5 | a.v(0)
+	case "addp", "faddp", "fmaxp", "fminp", "fmaxnmp", "fminnmp":
+		// scalar pairwise
+		if rd, size, ok := a.scalar(0); ok {
+			a.nops(2)
+			rn := a.v(1)
+			if mnemo == "addp" {
+				if size != 3 {
+					a64Fail("invalid register")
+				}
+				return 0x5ef1b800 | rn<<5 | rd
+			}
+			op := map[string]uint32{
+				"faddp": 0x7e30d800, "fmaxp": 0x7e30f800, "fminp": 0x7eb0f800,
+				"fmaxnmp": 0x7e30c800, "fminnmp": 0x7eb0c800,
+			}[mnemo]
+			return op | fsz(size)<<22 | rn<<5 | rd
+		}
+	}
+
+	if op, ok := a64SIMDPermute[mnemo]; ok {
+		a.nops(3)
+		q, size := a.arr()
+		return q<<30 | 0x0e000800 | size<<22 | a.v(2)<<16 | op<<12 | a.v(1)<<5 | a.v(0)
+	}
+
+	// by element
+	if len(a.ops) == 3 && a.isV(2) && a.ops[2].reg.idx >= 0 {
+		return a.simdElem(mnemo, up)
+	}
+
+	if op, ok := a64SIMDLogical[mnemo]; ok {
+		if len(a.ops) == 3 && a.isV(2) {
+			q, _ := a.arr()
+			return q<<30 | (op>>2)<<29 | 0x0e201c00 | (op&3)<<22 | a.v(2)<<16 | a.v(1)<<5 | a.v(0)
+		}
+		if mnemo == "orr" || mnemo == "bic" {
+			return a.simdMovi(mnemo)
+		}
+	}
+
+	if op, ok := a64SIMDAcross[mnemo]; ok {
+		a.nops(2)
+		rd, _, _ := a.scalar(0)
+		q, size := a.arr()
+		return q<<30 | (op>>5)<<29 | 0x0e300800 | size<<22 | (op&31)<<12 | a.v(1)<<5 | rd
+	}
+	if op, ok := a64SIMDFPAcross[mnemo]; ok {
+		a.nops(2)
+		rd, _, _ := a.scalar(0)
+		q, sz := a.farr()
+		return q<<30 | (op>>6)<<29 | 0x0e300800 | (op>>5&1)<<23 | sz<<22 | (op&31)<<12 | a.v(1)<<5 | rd
+	}
+
+	if op, ok := a64SIMD3Diff[mnemo]; ok {
+		a.nops(3)
+		_, size := a.arr()
+		switch mnemo {
+		case "addhn", "subhn", "raddhn", "rsubhn":
+		default:
+			size--
+		}
+		return up<<30 | (op>>4)<<29 | 0x0e200000 | size<<22 | a.v(2)<<16 | (op&15)<<12 | a.v(1)<<5 | a.v(0)
+	}
+
+	if op, ok := a64SIMDShift[mnemo]; ok && len(a.ops) == 3 && a.isImm(2) {
+		q, size := a.arr()
+		rd, rn := a.v(0), a.v(1)
+		switch mnemo {
+		case "sshll", "ushll":
+			q, size = up, size-1
+		case "shrn", "rshrn", "sqshrn", "uqshrn", "sqrshrn", "uqrshrn", "sqshrun", "sqrshrun":
+			q = up
+		}
+		return q<<30 | a.simdShift(op, size, 2) | rn<<5 | rd
+	}
+
+	if op, ok := a64SIMD3Same[mnemo]; ok && len(a.ops) == 3 && a.isV(2) {
+		q, size := a.arr()
+		return q<<30 | (op>>5)<<29 | 0x0e200400 | size<<22 | a.v(2)<<16 | (op&31)<<11 | a.v(1)<<5 | a.v(0)
+	}
+	if op, ok := a64SIMDFP3Same[mnemo]; ok && len(a.ops) == 3 && a.isV(2) {
+		q, sz := a.farr()
+		return q<<30 | (op>>6)<<29 | 0x0e200400 | (op>>5&1)<<23 | sz<<22 | a.v(2)<<16 | (op&31)<<11 | a.v(1)<<5 | a.v(0)
+	}
+
+	if op, ok := a64SIMDCmp0[mnemo]; ok && len(a.ops) == 3 {
+		q, size := a.arr()
+		if a.imm(2) != 0 {
+			a64Fail("compare with zero only")
+		}
+		return q<<30 | (op>>5)<<29 | 0x0e200800 | size<<22 | (op&31)<<12 | a.v(1)<<5 | a.v(0)
+	}
+	if op, ok := a64SIMDFPCmp0[mnemo]; ok && len(a.ops) == 3 {
+		q, sz := a.farr()
+		if a.fimm(2) != 0 {
+			a64Fail("compare with zero only")
+		}
+		return q<<30 | (op>>6)<<29 | 0x0e200800 | 1<<23 | sz<<22 | (op&31)<<12 | a.v(1)<<5 | a.v(0)
+	}
+
+	if op, ok := a64SIMD2Misc[mnemo]; ok {
+		a.nops(2)
+		q, size := a.arr()
+		switch mnemo {
+		case "xtn", "sqxtn", "uqxtn", "sqxtun":
+			q = up
+		case "saddlp", "uaddlp", "sadalp", "uadalp":
+			size--
+		}
+		return q<<30 | (op>>5)<<29 | 0x0e200800 | size<<22 | (op&31)<<12 | a.v(1)<<5 | a.v(0)
+	}
+	if op, ok := a64SIMDFP2Misc[mnemo]; ok {
+		a.nops(2)
+		q, sz := a.farr()
+		return q<<30 | (op>>6)<<29 | 0x0e200800 | (op>>5&1)<<23 | sz<<22 | (op&31)<<12 | a.v(1)<<5 | a.v(0)
+	}
+
+	if strings.HasPrefix(mnemo, "ld") || strings.HasPrefix(mnemo, "st") {
+		return a.simdLoadStore(mnemo)
+	}
+
+	a64Fail("unsupported instruction, try -asm llvm-mc")
+	return 0
+}
+
+// sdot v0.4s, v1.16b, v2.16b
+// sdot v0.4s, v1.16b, v2.4b[1]
+func (a *a64) simdDot() uint32 {
+	a.nops(3)
+	q, size := a.arr()
+	if size != 2 {
+		a64Fail("invalid arrangement: %s", a.t)
+	}
+	u := a64Bit(a.mnemo == "udot")
+	rd, rn := a.v(0), a.v(1)
+	if a.ops[2].typ == a64OpReg && a.ops[2].reg.idx >= 0 {
+		rm, idx := a.vIdx(2)
+		if idx > 3 {
+			a64Fail("element index out of range")
+		}
+		return q<<30 | u<<29 | 0x0f80e000 | uint32(idx&1)<<21 | rm<<16 | uint32(idx>>1)<<11 | rn<<5 | rd
+	}
+	return q<<30 | u<<29 | 0x0e809400 | a.v(2)<<16 | rn<<5 | rd
+}
+
+// the crypto instructions by the register numbers, their arrangements are
+// fixed
+var (
+	a64Crypto2 = map[string]uint32{
+		"aese": 0x4e284800, "aesd": 0x4e285800, "aesmc": 0x4e286800, "aesimc": 0x4e287800,
+		"sha1h": 0x5e280800, "sha1su1": 0x5e281800, "sha256su0": 0x5e282800, "sha512su0": 0xcec08000,
+	}
+	a64Crypto3 = map[string]uint32{
+		"sha1c": 0x5e000000, "sha1p": 0x5e001000, "sha1m": 0x5e002000, "sha1su0": 0x5e003000,
+		"sha256h": 0x5e004000, "sha256h2": 0x5e005000, "sha256su1": 0x5e006000,
+		"sha512h": 0xce608000, "sha512h2": 0xce608400, "sha512su1": 0xce608800, "rax1": 0xce608c00,
+	}
+	a64Crypto4 = map[string]uint32{"eor3": 0xce000000, "bcax": 0xce200000}
+)
+
+// cryptoReg is the number of the vector or the scalar register.
+func (a *a64) cryptoReg(i int) uint32 {
+	if a.isFP(i) {
+		n, _ := a.fp(i)
+		return n
+	}
+	return a.v(i)
+}
+
+func (a *a64) encodeCrypto() (_ uint32, ok bool) {
+	if op, ok := a64Crypto2[a.mnemo]; ok {
+		a.nops(2)
+		return op | a.cryptoReg(1)<<5 | a.cryptoReg(0), true
+	}
+	if op, ok := a64Crypto3[a.mnemo]; ok {
+		a.nops(3)
+		return op | a.cryptoReg(2)<<16 | a.cryptoReg(1)<<5 | a.cryptoReg(0), true
+	}
+	if op, ok := a64Crypto4[a.mnemo]; ok {
+		a.nops(4)
+		return op | a.v(2)<<16 | a.v(3)<<10 | a.v(1)<<5 | a.v(0), true
+	}
+	if a.mnemo == "xar" {
+		a.nops(4)
+		return 0xce800000 | a.v(2)<<16 | a.uimm(3, 6)<<10 | a.v(1)<<5 | a.v(0), true
+	}
+	return
+}
+
+func a64Imm5(size uint32, idx int) uint32 {
+	if idx < 0 || idx >= 16>>size {
+		a64Fail("element index out of range")
+	}
+	return uint32(idx)<<(size+1) | 1<<size
+}
+
+// immh:immb and opcode for shift by immediate
+func (a *a64) simdShift(op, size uint32, i int) uint32 {
+	esize := int64(8) << size
+	sh := a.imm(i)
+	var immhb int64
+	switch op & 31 {
+	case 0x0a, 0x0c, 0x0e, 0x14:
+		// left
+		if sh < 0 || sh >= esize {
+			a64Fail("shift out of range")
+		}
+		immhb = esize + sh
+	default:
+		if sh < 1 || sh > esize {
+			a64Fail("shift out of range")
+		}
+		immhb = 2*esize - sh
+	}
+	return (op>>5)<<29 | 0x0f000400 | uint32(immhb)<<16 | (op&31)<<11
+}
+
+func (a *a64) simdElem(mnemo string, up uint32) uint32 {
+	rm, idx := a.vIdx(2)
+	var q, size, u, opcode uint32
+	scalar := a.isFP(0)
+	if op, ok := a64SIMDFPElem[mnemo]; ok {
+		var sz uint32
+		if scalar {
+			var fsz uint32
+			_, fsz = a.fp(0)
+			q, sz = 1, fsz-2
+		} else {
+			q, sz = a.farr()
+		}
+		size, u, opcode = 2|sz, op>>4, op&15
+	} else if op, ok := a64SIMDElem[mnemo]; ok {
+		q, size = a.arr()
+		switch mnemo {
+		case "smull", "umull", "smlal", "umlal", "smlsl", "umlsl", "sqdmull", "sqdmlal", "sqdmlsl":
+			q, size = up, size-1
+		}
+		u, opcode = op>>4, op&15
+	} else {
+		a64Fail("unsupported instruction, try -asm llvm-mc")
+	}
+
+	var h, l, m uint32
+	switch size {
+	case 1:
+		if rm >= 16 || idx > 7 {
+			a64Fail("invalid element")
+		}
+		h, l, m = uint32(idx)>>2, uint32(idx)>>1&1, uint32(idx)&1
+	case 2:
+		if idx > 3 {
+			a64Fail("invalid element")
+		}
+		h, l, m = uint32(idx)>>1, uint32(idx)&1, rm>>4
+	case 3:
+		if idx > 1 {
+			a64Fail("invalid element")
+		}
+		h, m = uint32(idx), rm>>4
+	default:
+		a64Fail("invalid element")
+	}
+
+	var rd, rn uint32
+	ins := q<<30 | u<<29 | 0x0f000000 | size<<22 | l<<21 | m<<20 | (rm&15)<<16 | opcode<<12 | h<<11
+	if scalar {
+		rd, _ = a.fp(0)
+		rn, _ = a.fp(1)
+		ins |= 0x10000000
+	} else {
+		rd, rn = a.v(0), a.v(1)
+	}
+	return ins | rn<<5 | rd
+}
+
+func (a *a64) simdMov() uint32 {
+	a.nops(2)
+	switch {
+	case a.isGP(0):
+		// umov
+		rd, sf := a.gp(0, false)
+		rn, idx := a.vIdx(1)
+		size := a.elemSize()
+		if sf != a64Bit(size == 3) {
+			a64Fail("register width mismatch")
+		}
+		return sf<<30 | 0x0e003c00 | a64Imm5(size, idx)<<16 | rn<<5 | rd
+	case a.isFP(0):
+		// mov s0, v1.s[1]
+		rd, size := a.fp(0)
+		rn, idx := a.vIdx(1)
+		return 0x5e000400 | a64Imm5(size, idx)<<16 | rn<<5 | rd
+	}
+
+	rd := a.v(0)
+	if a.ops[0].reg.idx < 0 {
+		// mov v0.16b, v1.16b
+		q, _ := a.arr()
+		rn := a.v(1)
+		return q<<30 | 0x0ea01c00 | rn<<16 | rn<<5 | rd
+	}
+	_, idx := a.vIdx(0)
+	size := a.elemSize()
+	if a.isGP(1) {
+		rn, _ := a.gp(1, false)
+		return 0x4e001c00 | a64Imm5(size, idx)<<16 | rn<<5 | rd
+	}
+	rn, idx2 := a.vIdx(1)
+	return 0x6e000400 | a64Imm5(size, idx)<<16 | uint32(idx2)<<(11+size) | rn<<5 | rd
+}
+
+func (a *a64) simdMovi(mnemo string) uint32 {
+	a.nops(2, 3)
+	var rd, q, size uint32
+	if n, sz, ok := a.scalar(0); ok {
+		if sz != 3 || mnemo != "movi" {
+			a64Fail("invalid register")
+		}
+		rd, q, size = n, 0, 4
+	} else {
+		rd = a.v(0)
+		q, size = a.arr()
+		if a.t == "2d" {
+			size = 4
+		}
+	}
+
+	var op, cmode, imm8 uint32
+	switch {
+	case size == 4:
+		// 64-bit byte mask
+		v := uint64(a.imm(1))
+		for i := uint(0); i < 8; i++ {
+			switch v >> (i * 8) & 0xff {
+			case 0xff:
+				imm8 |= 1 << i
+			case 0:
+			default:
+				a64Fail("invalid immediate")
+			}
+		}
+		op, cmode = 1, 0xe
+	case size == 0:
+		if mnemo != "movi" {
+			a64Fail("invalid arrangement")
+		}
+		imm8, cmode = uint32(a.imm(1))&0xff, 0xe
+	default:
+		imm8 = a.uimm(1, 8)
+		var typ string
+		var amt uint32
+		if len(a.ops) == 3 {
+			typ, amt = a.shift(2, "lsl", "msl")
+		}
+		switch {
+		case size == 1 && typ != "msl" && (amt == 0 || amt == 8):
+			cmode = 0x8 | amt/8<<1
+		case size == 2 && typ != "msl" && amt%8 == 0 && amt <= 24:
+			cmode = amt / 8 << 1
+		case size == 2 && typ == "msl" && (amt == 8 || amt == 16):
+			if mnemo == "orr" || mnemo == "bic" {
+				a64Fail("invalid shift")
+			}
+			cmode = 0xc | amt/16
+		default:
+			a64Fail("invalid shift")
+		}
+		switch mnemo {
+		case "mvni", "bic":
+			op = 1
+		}
+		if mnemo == "orr" || mnemo == "bic" {
+			cmode |= 1
+		}
+	}
+	return q<<30 | op<<29 | 0x0f000400 | (imm8>>5)<<16 | cmode<<12 | (imm8&31)<<5 | rd
+}
+
+func (a *a64) simdLoadStore(mnemo string) uint32 {
+	a.nops(2, 3)
+	l := a64Bit(mnemo[0] == 'l')
+	rep := strings.HasSuffix(mnemo, "r")
+	if rep {
+		mnemo = mnemo[:len(mnemo)-1]
+	}
+	if len(mnemo) != 3 || mnemo[2] < '1' || mnemo[2] > '4' {
+		a64Fail("unsupported instruction, try -asm llvm-mc")
+	}
+	selem := uint32(mnemo[2] - '0')
+
+	list := a.op(0)
+	if list.typ != a64OpList {
+		a64Fail("expect register list")
+	}
+	nregs := uint32(len(list.list))
+	if selem != 1 && nregs != selem {
+		a64Fail("invalid register list")
+	}
+	mem := a.op(1)
+	if mem.typ != a64OpMem || mem.index != nil || mem.pre {
+		a64Fail("invalid memory operand")
+	}
+	rn, rt := mem.base.reg.n, list.list[0].n
+
+	var ins, total uint32
+	switch {
+	case rep:
+		if l == 0 || nregs != selem {
+			a64Fail("invalid register list")
+		}
+		q, size := a.arr()
+		ins = q<<30 | 0x0d40c000 | (selem-1)&1<<21 | ((selem-1)>>1)<<13 | size<<10
+		total = selem << size
+	case list.reg.idx >= 0:
+		size := a.elemSize()
+		idx := uint32(list.reg.idx)
+		if idx >= 16>>size {
+			a64Fail("element index out of range")
+		}
+		var q, s, sz, opcode uint32
+		switch size {
+		case 0:
+			q, s, sz, opcode = idx>>3, idx>>2&1, idx&3, 0
+		case 1:
+			q, s, sz, opcode = idx>>2, idx>>1&1, (idx&1)<<1, 2
+		case 2:
+			q, s, sz, opcode = idx>>1, idx&1, 0, 4
+		case 3:
+			q, s, sz, opcode = idx, 0, 1, 4
+		}
+		opcode |= (selem - 1) >> 1
+		ins = q<<30 | 0x0d000000 | l<<22 | (selem-1)&1<<21 | opcode<<13 | s<<12 | sz<<10
+		total = selem << size
+	default:
+		q, size := a.arr()
+		if size == 4 || q == 0 && size == 3 && selem != 1 {
+			a64Fail("invalid arrangement")
+		}
+		var opcode uint32
+		switch selem {
+		case 1:
+			opcode = map[uint32]uint32{1: 7, 2: 10, 3: 6, 4: 2}[nregs]
+		case 2:
+			opcode = 8
+		case 3:
+			opcode = 4
+		case 4:
+			opcode = 0
+		}
+		ins = q<<30 | 0x0c000000 | l<<22 | opcode<<12 | size<<10
+		total = nregs * (8 << q)
+	}
+
+	if len(a.ops) == 3 {
+		// post index
+		ins |= 0x00800000
+		if a.isImm(2) {
+			if uint32(a.imm(2)) != total {
+				a64Fail("invalid post index")
+			}
+			ins |= 31 << 16
+		} else {
+			rm := a.gpSF(2, false, 1)
+			ins |= rm << 16
+		}
+	}
+	return ins | rn<<5 | rt
+}
+
+// scalar forms of the advanced simd instructions
+func (a *a64) encodeSIMDScalar() uint32 {
+	mnemo := a.mnemo
+	if m, ok := a64SIMDSwap[mnemo]; ok && len(a.ops) == 3 && a.isFP(2) {
+		mnemo = m
+		a.ops[1], a.ops[2] = a.ops[2], a.ops[1]
+	}
+	const scalar = 0x50000000
+
+	switch mnemo {
+	case "movi":
+		return a.simdMovi(mnemo)
+	}
+
+	rd, size := a.fp(0)
+	if op, ok := a64SIMDShift[mnemo]; ok && len(a.ops) == 3 && a.isImm(2) {
+		return scalar | a.simdShift(op, size, 2) | a.fpSize(1, size)<<5 | rd
+	}
+	if op, ok := a64SIMD3Same[mnemo]; ok && len(a.ops) == 3 && a.isFP(2) {
+		return scalar | (op>>5)<<29 | 0x0e200400 | size<<22 | a.fpSize(2, size)<<16 | (op&31)<<11 | a.fpSize(1, size)<<5 | rd
+	}
+	if op, ok := a64SIMDFP3Same[mnemo]; ok && len(a.ops) == 3 && a.isFP(2) {
+		return scalar | (op>>6)<<29 | 0x0e200400 | (op>>5&1)<<23 | fsz(size)<<22 | a.fpSize(2, size)<<16 | (op&31)<<11 | a.fpSize(1, size)<<5 | rd
+	}
+	if op, ok := a64SIMDCmp0[mnemo]; ok && len(a.ops) == 3 {
+		if a.imm(2) != 0 {
+			a64Fail("compare with zero only")
+		}
+		return scalar | (op>>5)<<29 | 0x0e200800 | size<<22 | (op&31)<<12 | a.fpSize(1, size)<<5 | rd
+	}
+	if op, ok := a64SIMDFPCmp0[mnemo]; ok && len(a.ops) == 3 {
+		if a.fimm(2) != 0 {
+			a64Fail("compare with zero only")
+		}
+		return scalar | (op>>6)<<29 | 0x0e200800 | 1<<23 | fsz(size)<<22 | (op&31)<<12 | a.fpSize(1, size)<<5 | rd
+	}
+	if op, ok := a64SIMD2Misc[mnemo]; ok && len(a.ops) == 2 {
+		return scalar | (op>>5)<<29 | 0x0e200800 | size<<22 | (op&31)<<12 | a.fpSize(1, size)<<5 | rd
+	}
+	if op, ok := a64SIMDFP2Misc[mnemo]; ok && len(a.ops) == 2 {
+		return scalar | (op>>6)<<29 | 0x0e200800 | (op>>5&1)<<23 | fsz(size)<<22 | (op&31)<<12 | a.fpSize(1, size)<<5 | rd
+	}
+
+	a64Fail("unsupported instruction, try -asm llvm-mc")
+	return 0
+}
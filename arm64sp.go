@@ -0,0 +1,295 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// arm64Reg is the canonical name of the general register, "zr", "sp", or
+// "" for the others. w is set for the 32-bit ones.
+func arm64Reg(name string) (reg string, w bool) {
+	switch name {
+	case "sp", "wsp":
+		return "sp", name == "wsp"
+	case "xzr", "wzr":
+		return "zr", name == "wzr"
+	case "fp":
+		return "x29", false
+	case "lr":
+		return "x30", false
+	}
+	if len(name) < 2 || name[0] != 'x' && name[0] != 'w' {
+		return "", false
+	}
+	n, err := strconv.Atoi(name[1:])
+	if err != nil || n < 0 || n > 30 {
+		return "", false
+	}
+	return "x" + strconv.Itoa(n), name[0] == 'w'
+}
+
+// arm64Imm parses #16, #-0x20 and the masks like #0xfffffffffffffff0.
+func arm64Imm(s string) (_ int64, ok bool) {
+	if !strings.HasPrefix(s, "#") {
+		return
+	}
+	s = s[1:]
+	if v, err := strconv.ParseInt(s, 0, 64); err == nil {
+		return v, true
+	}
+	if v, err := strconv.ParseUint(s, 0, 64); err == nil {
+		return int64(v), true
+	}
+	return
+}
+
+// arm64Value is the value of the register or the immediate operand.
+func arm64Value(op string, st spState) spValue {
+	if v, ok := arm64Imm(op); ok {
+		return spValue{spConst, v}
+	}
+	reg, w := arm64Reg(op)
+	switch {
+	case reg == "zr":
+		return spValue{spConst, 0}
+	case reg == "":
+		return spValue{}
+	}
+	v := st[reg]
+	if w {
+		v = arm64W(v)
+	}
+	return v
+}
+
+// arm64W is the 32-bit view of v, the stack addresses are lost.
+func arm64W(v spValue) spValue {
+	if v.kind != spConst {
+		return spValue{}
+	}
+	return spValue{spConst, int64(uint32(v.val))}
+}
+
+// arm64Shift applies the shift or the extension of the last operand, like
+// lsl #12, uxtw #2 or sxtx.
+func arm64Shift(v spValue, shift string) spValue {
+	if shift == "" || v.kind == spUnknown {
+		return v
+	}
+	op, amount, _ := strings.Cut(shift, " ")
+	var n int64
+	if amount != "" {
+		var ok bool
+		if n, ok = arm64Imm(amount); !ok || n < 0 || n > 63 {
+			return spValue{}
+		}
+	}
+	if v.kind != spConst {
+		// sp plus the scaled offset
+		if (op == "lsl" || op == "uxtx" || op == "sxtx") && n == 0 {
+			return v
+		}
+		return spValue{}
+	}
+	x := v.val
+	switch op {
+	case "lsl", "uxtx", "sxtx":
+	case "uxtw":
+		x = int64(uint32(x))
+	case "sxtw":
+		x = int64(int32(x))
+	case "uxth":
+		x = int64(uint16(x))
+	case "sxth":
+		x = int64(int16(x))
+	case "uxtb":
+		x = int64(uint8(x))
+	case "sxtb":
+		x = int64(int8(x))
+	default:
+		return spValue{}
+	}
+	return spValue{spConst, x << n}
+}
+
+// arm64AddSub is a+b or a-b, sp only plus or minus a constant.
+func arm64AddSub(a, b spValue, sub bool) spValue {
+	if sub {
+		switch {
+		case a.kind == spRel && b.kind == spRel:
+			return spValue{spConst, a.val - b.val}
+		case b.kind == spConst && a.kind != spUnknown:
+			return spValue{a.kind, a.val - b.val}
+		}
+		return spValue{}
+	}
+	switch {
+	case a.kind == spConst && b.kind != spUnknown:
+		return spValue{b.kind, a.val + b.val}
+	case b.kind == spConst && a.kind != spUnknown:
+		return spValue{a.kind, a.val + b.val}
+	}
+	return spValue{}
+}
+
+// arm64MemEval writes back the base of the memory operand ops[i] and
+// clobbers the loaded registers:
+//
+//	stp x29, x30, [sp, #-32]!
+//	ldr x19, [sp], #16
+func arm64MemEval(mnemo string, ops []string, i int, st spState) {
+	mem := ops[i]
+	inner := mem[1:strings.LastIndexByte(mem, ']')]
+	parts := splitOperands(inner)
+	base, w := arm64Reg(parts[0])
+	if base != "" && base != "zr" && !w {
+		var off spValue
+		writeback := true
+		switch {
+		case strings.HasSuffix(mem, "!") && len(parts) > 1:
+			off = arm64Value(parts[1], st)
+		case i+1 < len(ops):
+			off = arm64Value(ops[i+1], st)
+		default:
+			writeback = false
+		}
+		if writeback {
+			// the base moves by an unknown offset, like ldr x0, [x1], x2
+			if v := arm64AddSub(st[base], off, false); v.kind == spUnknown {
+				delete(st, base)
+			} else {
+				st[base] = v
+			}
+		}
+	}
+
+	var dsts []string
+	switch {
+	case strings.HasPrefix(mnemo, "st"):
+		// the status of stxr, stlxp
+		if strings.Contains(mnemo, "xr") || strings.Contains(mnemo, "xp") {
+			dsts = ops[:1]
+		}
+	case mnemo == "prfm", mnemo == "prfum":
+	default:
+		// loads, swp, cas, ldadd
+		dsts = ops[:i]
+	}
+	for _, v := range dsts {
+		if reg, _ := arm64Reg(v); reg != "" && reg != "zr" {
+			delete(st, reg)
+		}
+	}
+}
+
+// SPEval tracks sp and the registers copied from it, the constants are
+// kept for the register amounts.
+func (aa *archArm64) SPEval(ins Instr, st spState) error {
+	switch ins.Kind() {
+	case InstrKind_Call, InstrKind_Indirect_Call:
+		// the caller saved registers
+		for i := 0; i <= 18; i++ {
+			delete(st, "x"+strconv.Itoa(i))
+		}
+		delete(st, "x30")
+		return nil
+	case InstrKind_Normal:
+	default:
+		return nil
+	}
+
+	mnemo := ins.Mnemonic()
+	ops := splitOperands(ins.Operands())
+	for i, v := range ops {
+		if strings.HasPrefix(v, "[") {
+			arm64MemEval(mnemo, ops, i, st)
+			return nil
+		}
+	}
+	if len(ops) == 0 || !arm64WritesFirst(mnemo) {
+		return nil
+	}
+	dst, w := arm64Reg(ops[0])
+	if dst == "" || dst == "zr" {
+		return nil
+	}
+
+	var shift string
+	if n := len(ops); n > 2 && !strings.HasPrefix(ops[n-1], "#") && strings.Contains(ops[n-1], " ") ||
+		n > 2 && arm64IsExtend(ops[n-1]) {
+		shift, ops = ops[n-1], ops[:n-1]
+	}
+
+	var val spValue
+	switch mnemo {
+	case "mov":
+		if len(ops) == 2 {
+			val = arm64Shift(arm64Value(ops[1], st), shift)
+		}
+	case "movz", "movn":
+		if len(ops) == 2 {
+			val = arm64Shift(arm64Value(ops[1], st), shift)
+			if mnemo == "movn" && val.kind == spConst {
+				val.val = ^val.val
+			}
+		}
+	case "movk":
+		imm := arm64Shift(arm64Value(ops[1], st), shift)
+		n := int64(0)
+		if shift != "" {
+			n, _ = arm64Imm(strings.TrimPrefix(shift, "lsl "))
+		}
+		if old := st[dst]; old.kind == spConst && imm.kind == spConst && len(ops) == 2 {
+			val = spValue{spConst, old.val&^(0xffff<<n) | imm.val}
+		}
+	case "add", "adds", "sub", "subs":
+		if len(ops) == 3 {
+			sub := strings.HasPrefix(mnemo, "sub")
+			val = arm64AddSub(arm64Value(ops[1], st), arm64Shift(arm64Value(ops[2], st), shift), sub)
+		}
+	case "orr":
+		// mov x0, #imm
+		if len(ops) == 3 && shift == "" {
+			if a, b := arm64Value(ops[1], st), arm64Value(ops[2], st); a.kind == spConst && b.kind == spConst {
+				val = spValue{spConst, a.val | b.val}
+			}
+		}
+	case "and":
+		// and sp, x9, #-64 realigns the stack, the entry sp is 16 aligned
+		// so at worst 64-16 bytes are skipped
+		if len(ops) == 3 && shift == "" {
+			a, b := arm64Value(ops[1], st), arm64Value(ops[2], st)
+			switch {
+			case a.kind == spConst && b.kind == spConst:
+				val = spValue{spConst, a.val & b.val}
+			case a.kind == spRel && b.kind == spConst && b.val < 0 && -b.val&(-b.val-1) == 0:
+				val = spValue{spRel, a.val &^ 15}
+				if align := -b.val; align > 16 {
+					val.val -= align - 16
+				}
+			}
+		}
+	}
+	if w {
+		val = arm64W(val)
+	}
+	if val.kind == spUnknown {
+		delete(st, dst)
+	} else {
+		st[dst] = val
+	}
+	if dst == "sp" && val.kind != spRel {
+		return fmt.Errorf("unsupported sp update: %s %s", mnemo, ins.Operands())
+	}
+	return nil
+}
+
+// arm64IsExtend reports whether op is an extension without the amount.
+func arm64IsExtend(op string) bool {
+	switch op {
+	case "uxtb", "uxth", "uxtw", "uxtx", "sxtb", "sxth", "sxtw", "sxtx":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestArm64MemEval(t *testing.T) {
+	for _, v := range []struct {
+		mnemo, opers string
+		want         spState
+	}{
+		{"stp", "x29, x30, [sp, #-32]!", spState{"sp": {spRel, -48}, "x1": {spRel, -8}, "x2": {spConst, 8}}},
+		{"ldr", "x19, [sp], #16", spState{"sp": {spRel, 0}, "x1": {spRel, -8}, "x2": {spConst, 8}}},
+		{"ldr", "x3, [x1], x2", spState{"sp": {spRel, -16}, "x1": {spRel, 0}, "x2": {spConst, 8}}},
+		{"ldr", "x2, [sp, #8]", spState{"sp": {spRel, -16}, "x1": {spRel, -8}}},
+		{"str", "x0, [x1, #8]", spState{"sp": {spRel, -16}, "x1": {spRel, -8}, "x2": {spConst, 8}}},
+		// the offset is unknown
+		{"st1", "{v0.16b}, [sp], x4", spState{"x1": {spRel, -8}, "x2": {spConst, 8}}},
+		{"ld1", "{v0.16b}, [x1], x4", spState{"sp": {spRel, -16}, "x2": {spConst, 8}}},
+	} {
+		st := spState{"sp": {spRel, -16}, "x1": {spRel, -8}, "x2": {spConst, 8}}
+		ops := splitOperands(v.opers)
+		for i, op := range ops {
+			if op[0] == '[' {
+				arm64MemEval(v.mnemo, ops, i, st)
+				break
+			}
+		}
+		if !reflect.DeepEqual(st, v.want) {
+			t.Errorf("%s %s = %v, want %v", v.mnemo, v.opers, st, v.want)
+		}
+	}
+}
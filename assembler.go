@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Assembler encodes one instruction at address, label operands are
+// already replaced by their absolute addresses.
+type Assembler interface {
+	Asm(mnemo, opers string, address int64) ([]byte, error)
+	Close()
+}
+
+type asmInput struct {
+	mnemo, opers string
+	address      int64
+}
+
+// batchAssembler is implemented by the assemblers that are much faster
+// when they see many instructions at once, the results are cached for
+// the following Asm calls.
+type batchAssembler interface {
+	Prefetch(ins []asmInput)
+}
+
+// keystoneArchs is set when built with keystone.
+var keystoneArchs map[string]bool
+
+var assemblers = map[string]func(goarch string) (Assembler, error){
+	"go":      newGoAssembler,
+	"llvm-mc": newLLVMMC,
+}
+
+func assemblerNames() string {
+	var names []string
+	for k := range assemblers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func defaultAssembler(goarch string) string {
+	if _, ok := goEncoders[goarch]; ok {
+		return "go"
+	}
+	if keystoneArchs[goarch] {
+		return "keystone"
+	}
+	return "llvm-mc"
+}
+
+func newAssembler(name, goarch string) (_ Assembler, err error) {
+	if name == "" {
+		name = defaultAssembler(goarch)
+	}
+	fn, ok := assemblers[name]
+	if !ok {
+		err = fmt.Errorf("unsupported assembler: %s (%s)", name, assemblerNames())
+		return
+	}
+	return fn(goarch)
+}
+
+////////////////////////
+
+var goEncoders = map[string]asmfunc{
+	"arm64":   arm64Asm,
+	"loong64": loong64Asm,
+}
+
+type goAssembler asmfunc
+
+func newGoAssembler(goarch string) (_ Assembler, err error) {
+	fn, ok := goEncoders[goarch]
+	if !ok {
+		err = fmt.Errorf("go assembler: unsupported arch: %s", goarch)
+		return
+	}
+	return goAssembler(fn), nil
+}
+
+func (fn goAssembler) Asm(mnemo, opers string, address int64) ([]byte, error) {
+	return fn(mnemo, opers, address)
+}
+
+func (fn goAssembler) Close() {
+}
+
+////////////////////////
+
+// asmArch is embedded by the archs, it owns the Assembler.
+type asmArch struct {
+	as Assembler
+}
+
+func (aa asmArch) asm(mnemo, opers string, address int64) ([]byte, error) {
+	return aa.as.Asm(mnemo, opers, address)
+}
+
+func (aa asmArch) Close() {
+	aa.as.Close()
+}
+
+func (aa asmArch) Prefetch(ins []asmInput) {
+	if ba, ok := aa.as.(batchAssembler); ok {
+		ba.Prefetch(ins)
+	}
+}
@@ -12,6 +12,8 @@ type instrBase struct {
 	los          []LabelOperand
 	data         []byte
 	sp           int64
+	dynsp        bool
+	pos          srcPos
 }
 
 func (ins *instrBase) Kind() InstrKind {
@@ -41,6 +43,10 @@ func (ins *instrBase) LabelOperand() *LabelOperand {
 	return nil
 }
 
+func (ins *instrBase) LabelOperands() []LabelOperand {
+	return ins.los
+}
+
 func (ins *instrBase) LabelNames() string {
 	var buf strings.Builder
 	for i, v := range ins.los {
@@ -68,6 +74,18 @@ func (ins *instrBase) SPDiff() int64 {
 	return ins.sp
 }
 
+func (ins *instrBase) DynSP() bool {
+	return ins.dynsp
+}
+
+func (ins *instrBase) Pos() srcPos {
+	return ins.pos
+}
+
+func (ins *instrBase) SetPos(pos srcPos) {
+	ins.pos = pos
+}
+
 ////////////////////////
 
 type asmfunc func(mnemo, opers string, address int64) (data []byte, err error)
@@ -122,3 +140,30 @@ func (ins instrLabel) Rebuild() (dif int64, err error) {
 	dif = ins.Size() - old
 	return
 }
+
+func buildEntryBlock(asm asmfunc, op ...string) (bb *BasicBlock, err error) {
+	bb = &BasicBlock{}
+	var ea int64
+	addInstr := func(mnemo, opers string) (err error) {
+		data, err := asm(mnemo, opers, ea)
+		if err != nil {
+			return
+		}
+		bb.Instrs = append(bb.Instrs, &instrBase{
+			kind:  InstrKind_Normal,
+			ea:    ea,
+			mnemo: mnemo,
+			opers: opers,
+			data:  data,
+		})
+		ea += int64(len(data))
+		return
+	}
+
+	for i := 0; i < len(op)/2; i++ {
+		if err = addInstr(op[i*2], op[i*2+1]); err != nil {
+			return
+		}
+	}
+	return
+}
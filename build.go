@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+var clangPath = "clang"
+
+// clangFlags are the flags of every target, there is no unwind info and no
+// stack protector.
+var clangFlags = []string{
+	"-S", "-O3",
+	"-fno-asynchronous-unwind-tables", "-fno-unwind-tables", "-fno-exceptions",
+	"-fno-stack-protector",
+}
+
+// clangTargets are the flags of [goos_]goarch, the code is position
+// independent without the got. the jump tables are only recovered on amd64
+// and arm64.
+var clangTargets = map[string][]string{
+	"amd64":         {"--target=x86_64-linux-gnu", "-mno-red-zone", "-fPIC", "-fvisibility=hidden"},
+	"darwin_amd64":  {"--target=x86_64-apple-macos10.13", "-mno-red-zone"},
+	"windows_amd64": {"--target=x86_64-pc-windows-msvc", "-mno-stack-arg-probe"},
+	"arm64":         {"--target=aarch64-linux-gnu", "-fPIC", "-fvisibility=hidden"},
+	"darwin_arm64":  {"--target=arm64-apple-macos11"},
+	"windows_arm64": {"--target=aarch64-pc-windows-msvc", "-mno-stack-arg-probe"},
+	"riscv64":       {"--target=riscv64-linux-gnu", "-march=rv64imafd", "-mabi=lp64d", "-mcmodel=medany", "-mno-relax", "-fno-pic", "-fno-jump-tables"},
+	"ppc64le":       {"--target=powerpc64le-linux-gnu", "-mcpu=power8", "-fno-jump-tables"},
+	"loong64":       {"--target=loongarch64-linux-gnu", "-fPIC", "-fvisibility=hidden", "-fno-jump-tables"},
+	"s390x":         {"--target=s390x-linux-gnu", "-march=z13", "-fno-jump-tables"},
+	"arm":           {"--target=armv7a-linux-gnueabihf", "-mfloat-abi=hard", "-mfpu=vfpv3-d16", "-fPIC", "-fvisibility=hidden", "-fno-jump-tables"},
+}
+
+// clangTargetFlags falls back to the flags of the linux for the unix-like os.
+func clangTargetFlags(t multiTarget) (_ []string, err error) {
+	if flags, ok := clangTargets[t.String()]; ok {
+		return flags, nil
+	}
+	if t.goos != "windows" && t.goos != "darwin" && t.goos != "ios" {
+		if flags, ok := clangTargets[t.goarch]; ok {
+			return flags, nil
+		}
+	}
+	err = fmt.Errorf("clang: unsupported target: %s", t)
+	return
+}
+
+// asmUnit is the text assembly of one c file, the lines of the
+// diagnostics are in it.
+type asmUnit struct {
+	name string
+	open func() (io.ReadCloser, error)
+}
+
+func (u asmUnit) lex(commentTokens []string) (lines []asmLine, err error) {
+	r, err := u.open()
+	if err != nil {
+		return
+	}
+	lines, err = asmLex(r, commentTokens)
+	// the failure of clang comes first
+	if err1 := r.Close(); err1 != nil {
+		err = err1
+	}
+	return
+}
+
+// unitsParse links the units in one program, the units call each other.
+func unitsParse(units []asmUnit, arch Arch) (p *Prog, err error) {
+	if p, err = newProg(arch); err != nil {
+		return
+	}
+	all := make([][]asmLine, len(units))
+	p.syms = make(map[string]bool)
+	for i, v := range units {
+		if all[i], err = v.lex(arch.CommentTokens()); err != nil {
+			return
+		}
+		for _, line := range all[i] {
+			if line.label != "" {
+				p.syms[line.label] = true
+			}
+		}
+	}
+	for i, v := range units {
+		if err = p.parseLines(all[i], v.name, ""); err != nil {
+			return
+		}
+	}
+	if err = p.link(); err != nil {
+		return
+	}
+	err = p.Rebuild()
+	return
+}
+
+// clangOutput is the stdout of clang, Close waits for it.
+type clangOutput struct {
+	io.ReadCloser
+	cmd    *exec.Cmd
+	stderr bytes.Buffer
+}
+
+func (o *clangOutput) Close() error {
+	// drain, or clang may block on the pipe
+	io.Copy(io.Discard, o.ReadCloser)
+	if err := o.cmd.Wait(); err != nil {
+		return fmt.Errorf("%s: %w\n%s", strings.Join(o.cmd.Args, " "), err, strings.TrimSpace(o.stderr.String()))
+	}
+	return nil
+}
+
+// clangUnit runs clang in dir, cfile is relative to it.
+func clangUnit(clang, dir, cfile, target string, args []string) asmUnit {
+	return asmUnit{
+		name: fmt.Sprintf("%s.%s.s", filepath.Join(dir, cfile), target),
+		open: func() (_ io.ReadCloser, err error) {
+			o := &clangOutput{cmd: exec.Command(clang, args...)}
+			o.cmd.Dir = dir
+			o.cmd.Stderr = &o.stderr
+			if o.ReadCloser, err = o.cmd.StdoutPipe(); err != nil {
+				return
+			}
+			if err = o.cmd.Start(); err != nil {
+				return
+			}
+			return o, nil
+		},
+	}
+}
+
+// clangVersion is the first line of clang --version.
+func clangVersion(clang string) (_ string, err error) {
+	out, err := exec.Command(clang, "--version").Output()
+	if err != nil {
+		err = fmt.Errorf("%s --version: %w", clang, err)
+		return
+	}
+	return strings.TrimSpace(strings.SplitN(string(out), "\n", 2)[0]), nil
+}
+
+// buildFlags are the flags of the build command.
+func buildFlags(fs *flag.FlagSet) func() error {
+	gfile := fs.String("proto", "", "go prototypes, the outputs are next to it, foo_amd64.s")
+	targets := fs.String("targets", "", "comma separated [goos_]goarch")
+	cflags := fs.String("cflags", "", "extra clang flags, split by spaces")
+	check := fs.Bool("check", false, "compare with the outputs instead of writing, fail with the diff")
+	asmName := fs.String("asm", "", "assembler backend: "+assemblerNames()+" (default go if the arch has one)")
+	fs.StringVar(&clangPath, "clang", clangPath, "clang executable")
+	fs.StringVar(&llvmMCPath, "llvm-mc", llvmMCPath, "llvm-mc executable used by -asm llvm-mc")
+	fs.BoolVar(&jsonDiags, "json", false, "print the diagnostics as json lines to stdout")
+
+	return func() (err error) {
+		if *gfile == "" || *targets == "" || fs.NArg() == 0 {
+			fs.Usage()
+			return fmt.Errorf("need the prototypes, the targets and the c files")
+		}
+		var ts []multiTarget
+		for _, v := range strings.Split(*targets, ",") {
+			t, err := parseTarget(strings.TrimSpace(v))
+			if err != nil {
+				return err
+			}
+			ts = append(ts, t)
+		}
+		return build(*gfile, ts, fs.Args(), strings.Fields(*cflags), *asmName, *check)
+	}
+}
+
+// build compiles the c files of every target by clang, the outputs of one
+// target are linked together.
+func build(gfile string, targets []multiTarget, cfiles, cflags []string, asmName string, check bool) (err error) {
+	for _, v := range cfiles {
+		if filepath.Ext(v) != ".c" {
+			return fmt.Errorf("not a c file: %s", v)
+		}
+	}
+	version, err := clangVersion(clangPath)
+	if err != nil {
+		return
+	}
+
+	return translateTargets(gfile, targets, func(j *job, t multiTarget) error {
+		j.clang = clangPath
+		j.asmName = asmName
+		j.check = check
+		return j.clangUnits(t, "", cfiles, cflags, version)
+	})
+}
+
+// clangUnits takes the c files of the target as the input, they are
+// relative to dir. version and the commands go to the note.
+func (j *job) clangUnits(t multiTarget, dir string, cfiles, cflags []string, version string) error {
+	flags, err := clangTargetFlags(t)
+	if err != nil {
+		return err
+	}
+
+	notes := []string{version}
+	for _, v := range cfiles {
+		args := append(append(append(append([]string{}, clangFlags...), flags...), cflags...), "-o", "-", v)
+		j.units = append(j.units, clangUnit(j.clang, dir, v, t.String(), args))
+		notes = append(notes, j.clang+" "+strings.Join(args, " "))
+	}
+	j.note = strings.Join(notes, "\n")
+	return nil
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type command struct {
+	usage string
+	// flags adds the flags to fs, run is called after the parsing
+	flags func(fs *flag.FlagSet) (run func() error)
+}
+
+var commands = map[string]command{
+	"translate": {"[flags] <clang-asm> [feature[+feature]=clang-asm] ...", jobCommand(runTranslate)},
+	"check":     {"[flags] <clang-asm> [feature[+feature]=clang-asm] ...", jobCommand(runCheck)},
+	"inspect":   {"[flags] <clang-asm>", jobCommand(runInspect)},
+	"stack":     {"[flags] <clang-asm> [feature[+feature]=clang-asm] ...", jobCommand(runStack)},
+	"verify":    {"[flags]", jobCommand(runVerify)},
+	"build":     {"[flags] -proto <prototypes> -targets <[goos_]goarch,...> <c-file> ...", buildFlags},
+	"generate":  {"[flags] [dir | dir/...] ...", generateFlags},
+}
+
+func commandNames() string {
+	var names []string
+	for k := range commands {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// jobFlags are the flags shared by the commands.
+func jobFlags(fs *flag.FlagSet) *job {
+	j := &job{}
+	fs.StringVar(&j.ofile, "o", "", "output go assembly, the target is taken from the name, foo_amd64.s")
+	fs.StringVar(&j.gfile, "proto", "", "go prototypes (default the output with .go)")
+	fs.StringVar(&j.pkg, "pkg", "", "package of the subr file (default the package of the prototypes)")
+	fs.StringVar(&j.goarch, "arch", "", "target arch (default from the output name, or arm64)")
+	fs.StringVar(&j.goos, "goos", "", "target os (default from the output name)")
+	fs.StringVar(&j.subrFile, "subr-out", "", "output subr file (default foo_subr_amd64.go next to the output)")
+	fs.StringVar(&j.tags, "tags", "", "extra build constraint, comma separated tags are and-ed")
+	fs.StringVar(&j.asmName, "asm", "", "assembler backend: "+assemblerNames()+" (default go if the arch has one)")
+	fs.StringVar(&llvmMCPath, "llvm-mc", llvmMCPath, "llvm-mc executable used by -asm llvm-mc")
+	fs.BoolVar(&jsonDiags, "json", false, "print the diagnostics as json lines to stdout")
+	return j
+}
+
+// jobCommand is the command of one translation.
+func jobCommand(run func(fs *flag.FlagSet, j *job) error) func(fs *flag.FlagSet) func() error {
+	return func(fs *flag.FlagSet) func() error {
+		j := jobFlags(fs)
+		return func() error {
+			return run(fs, j)
+		}
+	}
+}
+
+// runCommand parses args of the command name.
+func runCommand(name string, args []string) error {
+	cmd := commands[name]
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "* usage: %s %s %s\n", os.Args[0], name, cmd.usage)
+		fs.PrintDefaults()
+	}
+	run := cmd.flags(fs)
+	fs.Parse(args)
+	return run()
+}
+
+// inputArgs takes the clang output and the variants.
+func (j *job) inputArgs(fs *flag.FlagSet, variants bool) error {
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return fmt.Errorf("need the clang output")
+	}
+	j.ifile = fs.Arg(0)
+	if !variants && fs.NArg() > 1 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args()[1:], " "))
+	}
+	for _, v := range fs.Args()[1:] {
+		variant, err := parseVariant(v)
+		if err != nil {
+			return err
+		}
+		j.variants = append(j.variants, variant)
+	}
+	return nil
+}
+
+func runTranslate(fs *flag.FlagSet, j *job) (err error) {
+	if err = j.inputArgs(fs, true); err != nil {
+		return
+	}
+	_, err = translate(j)
+	return
+}
+
+// runCheck compares the outputs with the files, or does all but writing
+// without -o.
+func runCheck(fs *flag.FlagSet, j *job) (err error) {
+	if err = j.inputArgs(fs, true); err != nil {
+		return
+	}
+	if j.ofile != "" {
+		j.check = true
+		_, err = translate(j)
+		return
+	}
+	if err = j.fill(); err != nil {
+		return
+	}
+	p, arch, funcs, pkg, err := j.load()
+	if err != nil {
+		return
+	}
+	defer arch.Close()
+
+	return j.render(io.Discard, io.Discard, p, arch, funcs, pkg)
+}
+
+// runInspect lists the functions and the basic blocks.
+func runInspect(fs *flag.FlagSet, j *job) (err error) {
+	if err = j.inputArgs(fs, false); err != nil {
+		return
+	}
+	if err = j.fill(); err != nil {
+		return
+	}
+	p, arch, funcs, _, err := j.load()
+	if err != nil {
+		return
+	}
+	defer arch.Close()
+
+	// the blocks without label are named by the address
+	name := func(bb *BasicBlock) string {
+		if bb.ID != "" {
+			return bb.ID
+		}
+		return fmt.Sprintf("@%d", bb.EA())
+	}
+
+	w := os.Stdout
+	if j.goos != "" {
+		fmt.Fprintf(w, "target %s/%s\n", j.goos, j.goarch)
+	} else {
+		fmt.Fprintf(w, "target %s\n", j.goarch)
+	}
+	sizes, err := p.StackSizes(funcs)
+	if err != nil {
+		return
+	}
+	for _, f := range funcs {
+		bb, _ := p.FuncBB(f)
+		fmt.Fprintf(w, "func %s %s ea=%d stack=%d\n", f.Name, f.CName(), bb.EA(), sizes[f])
+	}
+	for _, bb := range p.bbs {
+		fmt.Fprintf(w, "\n%s:", name(bb))
+		for _, v := range bb.Succs {
+			fmt.Fprintf(w, " -> %s", name(v))
+		}
+		fmt.Fprintln(w)
+		for _, v := range bb.Instrs {
+			line := fmt.Sprintf("\t%d\t%d\t%s\t%s", v.EA(), v.Size(), v.Mnemonic(), v.Operands())
+			if lbl := v.LabelNames(); lbl != "" {
+				line += " // " + lbl
+			}
+			if pos := v.Pos(); pos.line > 0 {
+				line += fmt.Sprintf(" (%d)", pos.line)
+			}
+			fmt.Fprintln(w, line)
+		}
+	}
+	return
+}
+
+// runStack prints the stack size of every function.
+func runStack(fs *flag.FlagSet, j *job) (err error) {
+	if err = j.inputArgs(fs, true); err != nil {
+		return
+	}
+	if err = j.fill(); err != nil {
+		return
+	}
+	p, arch, funcs, _, err := j.load()
+	if err != nil {
+		return
+	}
+	defer arch.Close()
+
+	sizes, err := p.StackSizes(funcs)
+	if err != nil {
+		return
+	}
+	for _, f := range funcs {
+		fmt.Printf("%s\t%d\n", f.Name, sizes[f])
+	}
+	return
+}
+
+// runVerify vets the package of the output for the target, the frame
+// sizes and the argument offsets are checked by asmdecl.
+func runVerify(fs *flag.FlagSet, j *job) (err error) {
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	if j.ofile == "" {
+		return fmt.Errorf("need the output file")
+	}
+	if err = j.fill(); err != nil {
+		return
+	}
+
+	cmd := exec.Command("go", "vet", ".")
+	cmd.Dir = filepath.Dir(j.ofile)
+	cmd.Env = append(os.Environ(), "GOARCH="+j.goarch)
+	if j.goos != "" {
+		cmd.Env = append(cmd.Env, "GOOS="+j.goos)
+	}
+	cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+	if err = cmd.Run(); err != nil {
+		err = fmt.Errorf("go vet %s/%s: %w", j.goos, j.goarch, err)
+	}
+	return
+}
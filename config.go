@@ -0,0 +1,422 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// configName is the project configuration of the package.
+const configName = "nocgo.json"
+
+// config reproduces the build of a package, the paths are relative to the
+// file.
+type config struct {
+	// the prototypes shared by the targets
+	Proto   string   `json:"proto"`
+	Package string   `json:"package"`
+	Targets []string `json:"targets"`
+	// the c files compiled for every target, or the clang output of the
+	// targets
+	Sources []string          `json:"sources"`
+	Inputs  map[string]string `json:"inputs"`
+	Cflags  []string          `json:"cflags"`
+	// the extra clang flags of goarch or [goos_]goarch
+	ArchCflags map[string][]string `json:"arch_cflags"`
+	Clang      string              `json:"clang"`
+	Asm        string              `json:"asm"`
+	Symbols    []symbolRule        `json:"symbols"`
+	Stack      map[string]int64    `json:"stack"`
+	// the functions run on the native stacks
+	NativeStack []string `json:"native_stack"`
+	Forbid      []string `json:"forbid"`
+	// the names of the outputs, {target} is [goos_]goarch
+	Output     string `json:"output"`
+	SubrOutput string `json:"subr_output"`
+
+	fpath string
+	// the positions of the keys
+	pos     map[string]srcPos
+	targets []multiTarget
+}
+
+// symbolRule maps the Go names to the C names, * matches any and is
+// replaced in C.
+type symbolRule struct {
+	Go string `json:"go"`
+	C  string `json:"c"`
+}
+
+func (r symbolRule) match(name string) (_ string, ok bool) {
+	i := strings.IndexByte(r.Go, '*')
+	if i == -1 {
+		return r.C, name == r.Go
+	}
+	prefix, suffix := r.Go[:i], r.Go[i+1:]
+	if len(name) < len(prefix)+len(suffix) || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+		return
+	}
+	return strings.Replace(r.C, "*", name[len(prefix):len(name)-len(suffix)], 1), true
+}
+
+// offsetPos is the srcPos of the offset off in data.
+func offsetPos(file string, data []byte, off int) srcPos {
+	line := 1 + bytes.Count(data[:off], []byte{'\n'})
+	start := bytes.LastIndexByte(data[:off], '\n') + 1
+	end := bytes.IndexByte(data[off:], '\n')
+	if end == -1 {
+		end = len(data) - off
+	}
+	return srcPos{file: file, line: line, col: off - start + 1, text: strings.TrimSpace(string(data[start : off+end]))}
+}
+
+// loadConfig decodes fpath, all the unknown keys are reported.
+func loadConfig(fpath string) (c *config, err error) {
+	data, err := os.ReadFile(fpath)
+	if err != nil {
+		return
+	}
+	c = &config{fpath: fpath, pos: make(map[string]srcPos)}
+
+	fail := func(off int64, err error) error {
+		return newDiag(DiagKind_Error, offsetPos(fpath, data, int(off)), err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil {
+		return nil, fail(dec.InputOffset(), err)
+	} else if tok != json.Delim('{') {
+		return nil, fail(0, errors.New("want a json object"))
+	}
+
+	// the fields by the json names
+	fields := make(map[string]interface{})
+	for _, v := range []struct {
+		name string
+		ptr  interface{}
+	}{
+		{"proto", &c.Proto}, {"package", &c.Package}, {"targets", &c.Targets},
+		{"sources", &c.Sources}, {"inputs", &c.Inputs}, {"cflags", &c.Cflags},
+		{"arch_cflags", &c.ArchCflags}, {"clang", &c.Clang}, {"asm", &c.Asm},
+		{"symbols", &c.Symbols}, {"stack", &c.Stack}, {"native_stack", &c.NativeStack}, {"forbid", &c.Forbid},
+		{"output", &c.Output}, {"subr_output", &c.SubrOutput},
+	} {
+		fields[v.name] = v.ptr
+	}
+
+	var diags Diagnostics
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, fail(dec.InputOffset(), err)
+		}
+		key := tok.(string)
+		// the opening quote of the key
+		off := bytes.LastIndexByte(data[:dec.InputOffset()-1], '"')
+		pos := offsetPos(fpath, data, off)
+
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, fail(dec.InputOffset(), err)
+		}
+		ptr, ok := fields[key]
+		if !ok {
+			diags = append(diags, newDiag(DiagKind_Error, pos, fmt.Errorf("unknown key: %s", key)))
+			continue
+		}
+		if _, ok := c.pos[key]; ok {
+			diags = append(diags, newDiag(DiagKind_Error, pos, fmt.Errorf("duplicate key: %s", key)))
+			continue
+		}
+		c.pos[key] = pos
+
+		vdec := json.NewDecoder(bytes.NewReader(raw))
+		vdec.DisallowUnknownFields()
+		if err := vdec.Decode(ptr); err != nil {
+			diags = append(diags, newDiag(DiagKind_Error, pos, fmt.Errorf("%s: %w", key, err)))
+		}
+	}
+	if err = diags.err(); err != nil {
+		return nil, err
+	}
+	if _, err = dec.Token(); err != nil {
+		return nil, fail(dec.InputOffset(), err)
+	}
+	if _, err = dec.Token(); err != io.EOF {
+		return nil, fail(dec.InputOffset(), errors.New("extra data after the object"))
+	}
+
+	if err = c.validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// path is relative to the config file.
+func (c *config) path(name string) string {
+	return filepath.Join(filepath.Dir(c.fpath), name)
+}
+
+// validate checks the keys with each other and with the prototypes.
+func (c *config) validate() error {
+	var diags Diagnostics
+	report := func(key string, format string, args ...interface{}) {
+		pos, ok := c.pos[key]
+		if !ok {
+			pos = srcPos{file: c.fpath}
+		}
+		diags = append(diags, newDiag(DiagKind_Error, pos, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...))))
+	}
+
+	if c.Proto == "" {
+		report("proto", "need the prototypes")
+	}
+	if len(c.Targets) == 0 {
+		report("targets", "need the targets")
+	}
+	seen := make(map[string]bool)
+	for _, v := range c.Targets {
+		t, err := parseTarget(v)
+		if err != nil {
+			report("targets", "%v", err)
+			continue
+		}
+		c.targets = append(c.targets, t)
+		seen[t.String()] = true
+	}
+
+	switch {
+	case len(c.Sources) > 0 && len(c.Inputs) > 0:
+		report("inputs", "conflicts with sources")
+	case len(c.Sources) > 0:
+		for _, v := range c.Sources {
+			if filepath.Ext(v) != ".c" {
+				report("sources", "not a c file: %s", v)
+			}
+		}
+	case len(c.Inputs) > 0:
+		for _, t := range c.targets {
+			if c.Inputs[t.String()] == "" {
+				report("inputs", "need the clang output of %s", t)
+			}
+		}
+		for k := range c.Inputs {
+			if !seen[k] {
+				report("inputs", "not a target: %s", k)
+			}
+		}
+	default:
+		report("sources", "need the c files or the inputs")
+	}
+
+	if len(c.Sources) == 0 && (len(c.Cflags) > 0 || len(c.ArchCflags) > 0) {
+		report("cflags", "need the c files")
+	}
+	for k := range c.ArchCflags {
+		t, err := parseTarget(k)
+		if err != nil {
+			report("arch_cflags", "%v", err)
+			continue
+		}
+		var has bool
+		for _, v := range c.targets {
+			has = has || v.String() == k || (t.goos == "" && v.goarch == t.goarch)
+		}
+		if !has {
+			report("arch_cflags", "not a target: %s", k)
+		}
+	}
+
+	if c.Asm != "" {
+		if _, ok := assemblers[c.Asm]; !ok {
+			report("asm", "unknown assembler: %s, use %s", c.Asm, assemblerNames())
+		}
+	}
+	for _, v := range []struct{ key, name string }{
+		{"output", c.Output},
+		{"subr_output", c.SubrOutput},
+	} {
+		if v.name != "" && !strings.Contains(v.name, "{target}") {
+			report(v.key, "need {target} in %s", v.name)
+		}
+	}
+	for _, v := range c.Forbid {
+		if strings.TrimSpace(v) == "" {
+			report("forbid", "empty mnemonic")
+		}
+	}
+	for _, v := range c.Symbols {
+		if v.Go == "" || v.C == "" {
+			report("symbols", "need go and c")
+		} else if strings.Count(v.Go, "*") > 1 || strings.Contains(v.C, "*") && !strings.Contains(v.Go, "*") {
+			report("symbols", "bad rule: %s -> %s", v.Go, v.C)
+		}
+	}
+	for k, v := range c.Stack {
+		if v <= 0 {
+			report("stack", "bad size of %s: %d", k, v)
+		}
+	}
+	if err := sortDiags(diags).err(); err != nil {
+		return err
+	}
+
+	// the names are the same on all the targets
+	funcs, _, err := protoParse(c.path(c.Proto), 8)
+	if err != nil {
+		return err
+	}
+	names := make(map[string]bool)
+	for _, f := range funcs {
+		names[f.Name] = true
+	}
+	for _, v := range c.Symbols {
+		var has bool
+		for _, f := range funcs {
+			if _, ok := v.match(f.Name); ok {
+				has = true
+				break
+			}
+		}
+		if !has {
+			report("symbols", "%s matches no prototype", v.Go)
+		}
+	}
+	for k := range c.Stack {
+		if !names[k] {
+			report("stack", "no prototype: %s", k)
+		}
+	}
+	for _, v := range c.NativeStack {
+		if !names[v] {
+			report("native_stack", "no prototype: %s", v)
+		}
+	}
+	return sortDiags(diags).err()
+}
+
+// sortDiags orders ds by the lines, the keys of the maps come in any order.
+func sortDiags(ds Diagnostics) Diagnostics {
+	sort.SliceStable(ds, func(i, j int) bool {
+		if ds[i].Pos.line != ds[j].Pos.line {
+			return ds[i].Pos.line < ds[j].Pos.line
+		}
+		return ds[i].Err.Error() < ds[j].Err.Error()
+	})
+	return ds
+}
+
+// cflags are the clang flags of t, goarch first.
+func (c *config) cflags(t multiTarget) []string {
+	ret := append([]string{}, c.Cflags...)
+	ret = append(ret, c.ArchCflags[t.goarch]...)
+	if t.goos != "" {
+		ret = append(ret, c.ArchCflags[t.String()]...)
+	}
+	return ret
+}
+
+// outputName expands {target} of name.
+func (c *config) outputName(name string, t multiTarget) string {
+	return c.path(strings.ReplaceAll(name, "{target}", t.String()))
+}
+
+// run translates all the targets, or compares the outputs in check.
+func (c *config) run(check bool) (err error) {
+	clang := clangPath
+	if c.Clang != "" {
+		clang = c.Clang
+	}
+	var version string
+	if len(c.Sources) > 0 {
+		if version, err = clangVersion(clang); err != nil {
+			return
+		}
+	}
+
+	dir := filepath.Dir(c.fpath)
+	return translateTargets(c.path(c.Proto), c.targets, func(j *job, t multiTarget) error {
+		j.goos, j.goarch = t.goos, t.goarch
+		if c.Output != "" {
+			j.ofile = c.outputName(c.Output, t)
+		}
+		if c.SubrOutput != "" {
+			j.subrFile = c.outputName(c.SubrOutput, t)
+		}
+		j.pkg = c.Package
+		j.clang = clang
+		j.asmName = c.Asm
+		j.check = check
+		j.symbols = c.Symbols
+		j.stacks = c.Stack
+		j.nativeStacks = c.NativeStack
+		j.forbid = c.Forbid
+		if len(c.Sources) > 0 {
+			return j.clangUnits(t, dir, c.Sources, c.cflags(t), version)
+		}
+		j.ifile = c.path(c.Inputs[t.String()])
+		return nil
+	})
+}
+
+// runConfig runs the configuration file.
+func runConfig(fpath string, check bool) error {
+	c, err := loadConfig(fpath)
+	if err != nil {
+		return err
+	}
+	return c.run(check)
+}
+
+// applyFuncs sets the C names, the stack sizes and the native stacks of the
+// prototypes.
+func (j *job) applyFuncs(funcs Functions) {
+	for _, f := range funcs {
+		for _, v := range j.symbols {
+			if name, ok := v.match(f.Name); ok {
+				f.Symbol = name
+				break
+			}
+		}
+		if n, ok := j.stacks[f.Name]; ok {
+			f.Stack = n
+		}
+		for _, v := range j.nativeStacks {
+			if v == f.Name {
+				f.NativeStack = true
+			}
+		}
+	}
+}
+
+// checkForbidden reports every forbidden instruction, the entry block is
+// ours.
+func (j *job) checkForbidden(p *Prog) error {
+	if len(j.forbid) == 0 {
+		return nil
+	}
+	forbid := make(map[string]bool)
+	for _, v := range j.forbid {
+		forbid[strings.ToLower(strings.TrimSpace(v))] = true
+	}
+
+	var diags Diagnostics
+	for _, bb := range p.bbs[1:] {
+		for _, v := range bb.Instrs {
+			if v.Kind() == InstrKind_Data || !forbid[strings.ToLower(v.Mnemonic())] {
+				continue
+			}
+			pos := v.Pos()
+			if pos.file == "" {
+				pos.file = j.ifile
+			}
+			diags = append(diags, newDiag(DiagKind_Forbidden, pos, fmt.Errorf("forbidden instruction: %s", v.Mnemonic())))
+		}
+	}
+	return diags.err()
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var errUnknownDirective = errors.New("unknown directive")
+
+// asmDirective encodes the data and alignment directives that every
+// assembler backend has to understand in the same way.
+func asmDirective(mnemo, opers string, address int64, order binary.ByteOrder, nop []byte) (data []byte, err error) {
+	putInt := func(sz int) (err error) {
+		for _, v := range splitOperands(opers) {
+			x, err1 := evalExpr(v)
+			if err1 != nil {
+				return err1
+			}
+			// signed or unsigned, the jump tables overflow after the relaxation
+			if bits := uint(sz * 8); bits < 64 && (x < -1<<(bits-1) || x >= 1<<bits) {
+				return fmt.Errorf("value out of range: %d", x)
+			}
+			b := make([]byte, 8)
+			switch sz {
+			case 1:
+				b[0] = byte(x)
+			case 2:
+				order.PutUint16(b, uint16(x))
+			case 4:
+				order.PutUint32(b, uint32(x))
+			case 8:
+				order.PutUint64(b, uint64(x))
+			}
+			data = append(data, b[:sz]...)
+		}
+		return
+	}
+
+	switch mnemo {
+	case ".byte":
+		err = putInt(1)
+	case ".short", ".hword", ".half", ".2byte":
+		err = putInt(2)
+	case ".long", ".word", ".4byte", ".int":
+		err = putInt(4)
+	case ".quad", ".xword", ".dword", ".8byte":
+		err = putInt(8)
+	case ".space", ".zero", ".skip":
+		args := splitOperands(opers)
+		var n, fill int64
+		if n, err = evalExpr(args[0]); err != nil {
+			return
+		}
+		if len(args) > 1 {
+			if fill, err = evalExpr(args[1]); err != nil {
+				return
+			}
+		}
+		for i := int64(0); i < n; i++ {
+			data = append(data, byte(fill))
+		}
+	case ".ascii", ".asciz", ".string":
+		for _, v := range splitOperands(opers) {
+			s, err1 := unquoteC(v)
+			if err1 != nil {
+				return nil, err1
+			}
+			data = append(data, s...)
+			if mnemo != ".ascii" {
+				data = append(data, 0)
+			}
+		}
+	case ".p2align", ".align", ".balign":
+		args := splitOperands(opers)
+		var n int64
+		if n, err = evalExpr(args[0]); err != nil {
+			return
+		}
+		if mnemo != ".balign" {
+			n = 1 << n
+		}
+		pad := (n - address%n) % n
+		if len(args) > 1 && args[1] != "" {
+			var fill int64
+			if fill, err = evalExpr(args[1]); err != nil {
+				return
+			}
+			for i := int64(0); i < pad; i++ {
+				data = append(data, byte(fill))
+			}
+			return
+		}
+		for ; pad > 0 && pad%int64(len(nop)) != 0; pad-- {
+			data = append(data, 0)
+		}
+		for ; pad > 0; pad -= int64(len(nop)) {
+			data = append(data, nop...)
+		}
+	default:
+		err = fmt.Errorf("%w: %s", errUnknownDirective, mnemo)
+	}
+	if err != nil {
+		err = fmt.Errorf("[%d] %s %s, %w", address, mnemo, opers, err)
+	}
+	return
+}
+
+// splitOperands splits opers at the top level commas.
+func splitOperands(opers string) (ret []string) {
+	var depth int
+	var quote bool
+	last := 0
+	for i := 0; i < len(opers); i++ {
+		switch c := opers[i]; {
+		case quote:
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				quote = false
+			}
+		case c == '"':
+			quote = true
+		case c == '[' || c == '{' || c == '(':
+			depth++
+		case c == ']' || c == '}' || c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			ret = append(ret, strings.TrimSpace(opers[last:i]))
+			last = i + 1
+		}
+	}
+	if s := strings.TrimSpace(opers[last:]); s != "" || len(ret) > 0 {
+		ret = append(ret, s)
+	}
+	return
+}
+
+func unquoteC(s string) (ret []byte, err error) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return nil, fmt.Errorf("invalid string: %s", s)
+	}
+	s = s[1 : len(s)-1]
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			ret = append(ret, s[i])
+			continue
+		}
+		i++
+		if i == len(s) {
+			return nil, errors.New("invalid escape")
+		}
+		switch c := s[i]; c {
+		case 'n':
+			ret = append(ret, '\n')
+		case 't':
+			ret = append(ret, '\t')
+		case 'r':
+			ret = append(ret, '\r')
+		case 'b':
+			ret = append(ret, '\b')
+		case 'f':
+			ret = append(ret, '\f')
+		case 'x':
+			j := i + 1
+			for j < len(s) && j < i+3 && strings.IndexByte("0123456789abcdefABCDEF", s[j]) != -1 {
+				j++
+			}
+			v, err1 := strconv.ParseUint(s[i+1:j], 16, 8)
+			if err1 != nil {
+				return nil, err1
+			}
+			ret = append(ret, byte(v))
+			i = j - 1
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			j := i
+			for j < len(s) && j < i+3 && s[j] >= '0' && s[j] <= '7' {
+				j++
+			}
+			v, err1 := strconv.ParseUint(s[i:j], 8, 16)
+			if err1 != nil {
+				return nil, err1
+			}
+			ret = append(ret, byte(v))
+			i = j - 1
+		default:
+			ret = append(ret, c)
+		}
+	}
+	return
+}
+
+// evalExpr evaluates the integer expressions left after label
+// substitution, like "1024-512" or "(8*4)".
+func evalExpr(s string) (int64, error) {
+	e := exprParser{s: strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(s), "#"))}
+	v, err := e.binary(0)
+	if err != nil {
+		return 0, err
+	}
+	if e.skip(); e.pos != len(e.s) {
+		return 0, fmt.Errorf("invalid expression: %s", s)
+	}
+	return v, nil
+}
+
+type exprParser struct {
+	s   string
+	pos int
+}
+
+var exprPrec = map[string]int{
+	"|": 1, "^": 2, "&": 3,
+	"<<": 4, ">>": 4,
+	"+": 5, "-": 5,
+	"*": 6, "/": 6, "%": 6,
+}
+
+func (e *exprParser) skip() {
+	for e.pos < len(e.s) && (e.s[e.pos] == ' ' || e.s[e.pos] == '\t') {
+		e.pos++
+	}
+}
+
+func (e *exprParser) op() string {
+	e.skip()
+	for _, v := range []string{"<<", ">>", "|", "^", "&", "+", "-", "*", "/", "%"} {
+		if strings.HasPrefix(e.s[e.pos:], v) {
+			return v
+		}
+	}
+	return ""
+}
+
+func (e *exprParser) binary(prec int) (x int64, err error) {
+	if x, err = e.unary(); err != nil {
+		return
+	}
+	for {
+		op := e.op()
+		if op == "" || exprPrec[op] <= prec {
+			return
+		}
+		e.pos += len(op)
+		var y int64
+		if y, err = e.binary(exprPrec[op]); err != nil {
+			return
+		}
+		switch op {
+		case "|":
+			x |= y
+		case "^":
+			x ^= y
+		case "&":
+			x &= y
+		case "<<":
+			x <<= uint64(y)
+		case ">>":
+			x >>= uint64(y)
+		case "+":
+			x += y
+		case "-":
+			x -= y
+		case "*":
+			x *= y
+		case "/", "%":
+			if y == 0 {
+				return 0, errors.New("division by zero")
+			}
+			if op == "/" {
+				x /= y
+			} else {
+				x %= y
+			}
+		}
+	}
+}
+
+func (e *exprParser) unary() (x int64, err error) {
+	e.skip()
+	if e.pos == len(e.s) {
+		return 0, fmt.Errorf("invalid expression: %s", e.s)
+	}
+	switch e.s[e.pos] {
+	case '-':
+		e.pos++
+		x, err = e.unary()
+		return -x, err
+	case '+':
+		e.pos++
+		return e.unary()
+	case '~':
+		e.pos++
+		x, err = e.unary()
+		return ^x, err
+	case '(':
+		e.pos++
+		if x, err = e.binary(0); err != nil {
+			return
+		}
+		if e.skip(); e.pos == len(e.s) || e.s[e.pos] != ')' {
+			return 0, fmt.Errorf("invalid expression: %s", e.s)
+		}
+		e.pos++
+		return
+	}
+
+	end := e.pos
+	for end < len(e.s) && (e.s[end] >= '0' && e.s[end] <= '9' || e.s[end] >= 'a' && e.s[end] <= 'z' ||
+		e.s[end] >= 'A' && e.s[end] <= 'Z') {
+		end++
+	}
+	u, err := strconv.ParseUint(e.s[e.pos:end], 0, 64)
+	if err != nil {
+		return
+	}
+	e.pos = end
+	return int64(u), nil
+}
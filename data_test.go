@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestEvalExpr(t *testing.T) {
+	for _, v := range []struct {
+		s    string
+		want int64
+	}{
+		{"1024-512", 512},
+		{"(8*4)", 32},
+		{"#16", 16},
+		{"1+2*3", 7},
+		{"(1+2)*3", 9},
+		{"10-4-3", 3},
+		{"1<<4|1", 17},
+		{"0x10+010", 24},
+		{"-8", -8},
+		{"~0", -1},
+		{"0xffffffffffffffff", -1},
+		{"7/2", 3},
+		{"7%4", 3},
+		{"6&3^1", 3},
+		{" 4 * ( 2 + 1 ) ", 12},
+	} {
+		got, err := evalExpr(v.s)
+		if err != nil {
+			t.Errorf("%q: %v", v.s, err)
+		} else if got != v.want {
+			t.Errorf("%q = %d, want %d", v.s, got, v.want)
+		}
+	}
+
+	for _, s := range []string{"", "1/0", "(1+2", "1+", "abc", "1 2"} {
+		if got, err := evalExpr(s); err == nil {
+			t.Errorf("%q = %d, want error", s, got)
+		}
+	}
+}
+
+func TestSplitOperands(t *testing.T) {
+	for _, v := range []struct {
+		s    string
+		want []string
+	}{
+		{"", nil},
+		{"x0", []string{"x0"}},
+		{"x0, [x1, #8]", []string{"x0", "[x1, #8]"}},
+		{"{v0.4s, v1.4s}, [x0]", []string{"{v0.4s, v1.4s}", "[x0]"}},
+		{`"a,b", 1`, []string{`"a,b"`, "1"}},
+		{`"a\",b"`, []string{`"a\",b"`}},
+		{"a,", []string{"a", ""}},
+	} {
+		if got := splitOperands(v.s); !reflect.DeepEqual(got, v.want) {
+			t.Errorf("%q = %q, want %q", v.s, got, v.want)
+		}
+	}
+}
+
+func TestUnquoteC(t *testing.T) {
+	got, err := unquoteC(`"a\n\x41\101\"b"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "a\nAA\"b"; string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	for _, s := range []string{`abc`, `"abc\"`, `"`} {
+		if _, err := unquoteC(s); err == nil {
+			t.Errorf("%s: want error", s)
+		}
+	}
+}
+
+func TestAsmDirective(t *testing.T) {
+	for _, v := range []struct {
+		mnemo, opers string
+		address      int64
+		want         []byte
+	}{
+		{".byte", "1, -1, 255", 0, []byte{1, 0xff, 0xff}},
+		{".short", "0x1234", 0, []byte{0x34, 0x12}},
+		{".long", "1-2", 0, []byte{0xff, 0xff, 0xff, 0xff}},
+		{".quad", "1", 0, []byte{1, 0, 0, 0, 0, 0, 0, 0}},
+		{".asciz", `"hi"`, 0, []byte{'h', 'i', 0}},
+		{".ascii", `"a", "b"`, 0, []byte{'a', 'b'}},
+		{".space", "3, 0x90", 0, []byte{0x90, 0x90, 0x90}},
+		// the nops are aligned, the rest is zero
+		{".p2align", "3", 4, arm64Nop},
+		{".p2align", "2", 2, []byte{0, 0}},
+		{".p2align", "2", 8, nil},
+		{".balign", "8, 0xcc", 5, []byte{0xcc, 0xcc, 0xcc}},
+	} {
+		got, err := asmDirective(v.mnemo, v.opers, v.address, binary.LittleEndian, arm64Nop)
+		if err != nil {
+			t.Errorf("%s %s: %v", v.mnemo, v.opers, err)
+		} else if !bytes.Equal(got, v.want) {
+			t.Errorf("%s %s = %x, want %x", v.mnemo, v.opers, got, v.want)
+		}
+	}
+
+	if _, err := asmDirective(".byte", "256", 0, binary.LittleEndian, arm64Nop); err == nil {
+		t.Error(".byte 256: want error")
+	}
+	if _, err := asmDirective(".foo", "", 0, binary.LittleEndian, arm64Nop); !errors.Is(err, errUnknownDirective) {
+		t.Errorf(".foo: got %v, want %v", err, errUnknownDirective)
+	}
+}
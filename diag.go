@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// srcPos is the position in the input, line and col start at 1.
+type srcPos struct {
+	file      string
+	line, col int
+	// the line as it is
+	text string
+}
+
+func (pos srcPos) String() string {
+	switch {
+	case pos.line > 0:
+		return fmt.Sprintf("%s:%d:%d", pos.file, pos.line, pos.col)
+	case pos.file != "":
+		return pos.file
+	}
+	return ""
+}
+
+type DiagKind string
+
+const (
+	DiagKind_Error            DiagKind = "error"
+	DiagKind_UnknownDirective DiagKind = "unknown-directive"
+	DiagKind_UnresolvedLabel  DiagKind = "unresolved-label"
+	DiagKind_Encoding         DiagKind = "encoding"
+	DiagKind_UnsupportedParam DiagKind = "unsupported-parameter"
+	DiagKind_Stale            DiagKind = "stale"
+	DiagKind_Forbidden        DiagKind = "forbidden-instruction"
+	DiagKind_Stack            DiagKind = "stack"
+)
+
+// Diagnostic is an error at pos, printed as file.s:123:5: message.
+type Diagnostic struct {
+	Kind DiagKind
+	Pos  srcPos
+	Err  error
+}
+
+func newDiag(kind DiagKind, pos srcPos, err error) *Diagnostic {
+	return &Diagnostic{Kind: kind, Pos: pos, Err: err}
+}
+
+func (d *Diagnostic) Error() string {
+	if s := d.Pos.String(); s != "" {
+		return s + ": " + d.Err.Error()
+	}
+	return d.Err.Error()
+}
+
+func (d *Diagnostic) Unwrap() error {
+	return d.Err
+}
+
+// Diagnostics are the errors of many lines.
+type Diagnostics []*Diagnostic
+
+func (ds Diagnostics) Error() string {
+	arr := make([]string, len(ds))
+	for i, v := range ds {
+		arr[i] = v.Error()
+	}
+	return strings.Join(arr, "\n")
+}
+
+// err is nil when there is nothing.
+func (ds Diagnostics) err() error {
+	if len(ds) == 0 {
+		return nil
+	}
+	return ds
+}
+
+// diagFile gives the errors without position the file.
+func diagFile(err error, file string) error {
+	if err == nil {
+		return nil
+	}
+	ds := diagsOf(err)
+	for _, v := range ds {
+		if v.Pos.file == "" {
+			v.Pos.file = file
+		}
+	}
+	if len(ds) == 1 {
+		return ds[0]
+	}
+	return ds
+}
+
+// diagsOf flattens err, the plain errors have no position.
+func diagsOf(err error) Diagnostics {
+	var ds Diagnostics
+	if errors.As(err, &ds) {
+		return ds
+	}
+	var d *Diagnostic
+	if errors.As(err, &d) {
+		return Diagnostics{d}
+	}
+	return Diagnostics{newDiag(DiagKind_Error, srcPos{}, err)}
+}
+
+type jsonDiag struct {
+	File    string   `json:"file,omitempty"`
+	Line    int      `json:"line,omitempty"`
+	Col     int      `json:"col,omitempty"`
+	Kind    DiagKind `json:"kind"`
+	Message string   `json:"message"`
+	Text    string   `json:"text,omitempty"`
+}
+
+// writeDiags writes err as text, or as json lines for the editors and ci.
+func writeDiags(w io.Writer, err error, asJSON bool) error {
+	if !asJSON {
+		_, err = fmt.Fprintln(w, err)
+		return err
+	}
+	enc := json.NewEncoder(w)
+	for _, v := range diagsOf(err) {
+		if err := enc.Encode(jsonDiag{
+			File:    v.Pos.file,
+			Line:    v.Pos.line,
+			Col:     v.Pos.col,
+			Kind:    v.Kind,
+			Message: v.Err.Error(),
+			Text:    v.Pos.text,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// checkOutput compares the generated data with the file at fpath, it is
+// nil when they are the same.
+func checkOutput(fpath string, data []byte) *Diagnostic {
+	old, err := os.ReadFile(fpath)
+	if errors.Is(err, os.ErrNotExist) {
+		return newDiag(DiagKind_Stale, srcPos{file: fpath}, errors.New("missing, regenerate it"))
+	} else if err != nil {
+		return newDiag(DiagKind_Error, srcPos{file: fpath}, err)
+	}
+	if bytes.Equal(old, data) {
+		return nil
+	}
+
+	// the first different line
+	line := 1 + bytes.Count(old[:commonPrefix(old, data)], []byte{'\n'})
+	diff := unifiedDiff(fpath, fpath+" (generated)", string(old), string(data))
+	return newDiag(DiagKind_Stale, srcPos{file: fpath, line: line, col: 1},
+		fmt.Errorf("stale, regenerate it\n%s", strings.TrimSuffix(diff, "\n")))
+}
+
+func commonPrefix(a, b []byte) (n int) {
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return
+}
+
+// unifiedDiff is the diff -u of the lines of a and b, empty when they are
+// the same.
+func unifiedDiff(aName, bName, a, b string) string {
+	x, y := splitLines(a), splitLines(b)
+	ops := diffLines(x, y)
+
+	const context = 3
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "--- %s\n+++ %s\n", aName, bName)
+	var hunks int
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+		// the hunk ends at the context between two changes
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			n := end
+			for n < len(ops) && ops[n].kind == ' ' {
+				n++
+			}
+			if n == len(ops) || n-end > 2*context {
+				end += context
+				if end > len(ops) {
+					end = len(ops)
+				}
+				break
+			}
+			end = n
+		}
+
+		var ai, an, bi, bn int
+		ai, bi = ops[start].ai+1, ops[start].bi+1
+		var body strings.Builder
+		for _, v := range ops[start:end] {
+			switch v.kind {
+			case ' ':
+				an++
+				bn++
+				body.WriteString(" " + x[v.ai])
+			case '-':
+				an++
+				body.WriteString("-" + x[v.ai])
+			case '+':
+				bn++
+				body.WriteString("+" + y[v.bi])
+			}
+		}
+		if an == 0 {
+			ai--
+		}
+		if bn == 0 {
+			bi--
+		}
+		fmt.Fprintf(&buf, "@@ -%d,%d +%d,%d @@\n%s", ai, an, bi, bn, body.String())
+		hunks++
+		i = end
+	}
+	if hunks == 0 {
+		return ""
+	}
+	return buf.String()
+}
+
+// splitLines keeps the newlines, the last line gets one.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	} else {
+		lines[len(lines)-1] += "\n\\ No newline at end of file\n"
+	}
+	return lines
+}
+
+type diffOp struct {
+	kind   byte
+	ai, bi int
+}
+
+// diffLines is the shortest edit script of myers, ai and bi are the
+// indexes of the lines before the op. the far too different lines are
+// replaced as a whole.
+func diffLines(x, y []string) (ops []diffOp) {
+	const maxD = 2048
+	n, m := len(x), len(y)
+	max := n + m
+	v := make([]int, 2*max+2)
+	// the diagonals -d..d before the step d
+	var trace [][]int
+	for d := 0; d <= max && d <= maxD; d++ {
+		trace = append(trace, append([]int(nil), v[max-d:max+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var i int
+			if k == -d || k != d && v[max+k-1] < v[max+k+1] {
+				i = v[max+k+1]
+			} else {
+				i = v[max+k-1] + 1
+			}
+			j := i - k
+			for i < n && j < m && x[i] == y[j] {
+				i++
+				j++
+			}
+			v[max+k] = i
+			if i >= n && j >= m {
+				return backtrack(trace, n, m)
+			}
+		}
+	}
+
+	for i := range x {
+		ops = append(ops, diffOp{'-', i, 0})
+	}
+	for j := range y {
+		ops = append(ops, diffOp{'+', n, j})
+	}
+	return
+}
+
+func backtrack(trace [][]int, n, m int) []diffOp {
+	var ops []diffOp
+	i, j := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := func(k int) int { return trace[d][k+d] }
+		k := i - j
+		var pk int
+		if k == -d || k != d && v(k-1) < v(k+1) {
+			pk = k + 1
+		} else {
+			pk = k - 1
+		}
+		var pi int
+		if d > 0 {
+			pi = v(pk)
+		}
+		pj := pi - pk
+		for i > pi && j > pj {
+			i--
+			j--
+			ops = append(ops, diffOp{' ', i, j})
+		}
+		if d > 0 {
+			if i == pi {
+				j--
+				ops = append(ops, diffOp{'+', i, j})
+			} else {
+				i--
+				ops = append(ops, diffOp{'-', i, j})
+			}
+		}
+	}
+	for l, r := 0, len(ops)-1; l < r; l, r = l+1, r-1 {
+		ops[l], ops[r] = ops[r], ops[l]
+	}
+	return ops
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+func elfParse(r io.ReaderAt, arch Arch) (p *Prog, err error) {
+	oa, ok := arch.(objArch)
+	if !ok {
+		err = fmt.Errorf("object file is unsupported by arch")
+		return
+	}
+
+	f, err := elf.NewFile(r)
+	if err != nil {
+		return
+	}
+	if f.Type != elf.ET_REL {
+		err = fmt.Errorf("not a relocatable object: %s", f.Type)
+		return
+	}
+	if f.Machine != oa.ELFMachine() {
+		err = fmt.Errorf("unexpected machine: %s", f.Machine)
+		return
+	}
+
+	var secs []*objSection
+	idx := make(map[elf.SectionIndex]*objSection)
+	for i, v := range f.Sections {
+		if v.Flags&elf.SHF_ALLOC == 0 || v.Size == 0 {
+			continue
+		}
+		switch {
+		case v.Name == ".text" || strings.HasPrefix(v.Name, ".text."),
+			v.Name == ".rodata" || strings.HasPrefix(v.Name, ".rodata."):
+		case v.Flags&elf.SHF_WRITE != 0 || v.Type == elf.SHT_NOBITS:
+			err = fmt.Errorf("unsupported section: %s", v.Name)
+			return
+		default:
+			// .eh_frame
+			continue
+		}
+
+		sec := &objSection{
+			name:  v.Name,
+			code:  v.Flags&elf.SHF_EXECINSTR != 0,
+			align: int64(v.Addralign),
+		}
+		if sec.data, err = v.Data(); err != nil {
+			return
+		}
+		secs = append(secs, sec)
+		idx[elf.SectionIndex(i)] = sec
+	}
+
+	entry, err := arch.EntryBlock()
+	if err != nil {
+		return
+	}
+	objLayout(entry.Size(), secs)
+
+	esyms, err := f.Symbols()
+	if err != nil {
+		return
+	}
+	var syms []objSymbol
+	for _, v := range esyms {
+		switch elf.ST_TYPE(v.Info) {
+		case elf.STT_FUNC, elf.STT_OBJECT, elf.STT_NOTYPE:
+		default:
+			continue
+		}
+		// mapping symbols, $x $d
+		if sec := idx[v.Section]; sec != nil && v.Name != "" && v.Name[0] != '$' {
+			syms = append(syms, objSymbol{name: v.Name, sec: sec, off: int64(v.Value), global: elf.ST_BIND(v.Info) == elf.STB_GLOBAL})
+		}
+	}
+
+	for _, v := range f.Sections {
+		if v.Type == elf.SHT_REL {
+			err = fmt.Errorf("unsupported relocation section: %s", v.Name)
+			return
+		}
+		sec := idx[elf.SectionIndex(v.Info)]
+		if v.Type != elf.SHT_RELA || sec == nil {
+			continue
+		}
+		var data []byte
+		if data, err = v.Data(); err != nil {
+			return
+		}
+		for ; len(data) >= 24; data = data[24:] {
+			off := int64(f.ByteOrder.Uint64(data))
+			info := f.ByteOrder.Uint64(data[8:])
+			addend := int64(f.ByteOrder.Uint64(data[16:]))
+
+			si := int(elf.R_SYM64(info))
+			if si == 0 || si > len(esyms) {
+				err = fmt.Errorf("%s+%d: bad symbol index: %d", sec.name, off, si)
+				return
+			}
+			sym := esyms[si-1]
+			ssec := idx[sym.Section]
+			if ssec == nil {
+				if sym.Section == elf.SHN_UNDEF {
+					err = fmt.Errorf("undefined symbol: %s", sym.Name)
+				} else {
+					err = fmt.Errorf("symbol in unsupported section: %s", sym.Name)
+				}
+				return
+			}
+
+			if err = oa.ELFReloc(elf.R_TYPE64(info), sec.data[off:], sec.addr+off,
+				ssec.addr+int64(sym.Value)+addend); err != nil {
+				err = fmt.Errorf("%s+%d: %w", sec.name, off, err)
+				return
+			}
+		}
+	}
+
+	return objProg(oa, entry, secs, syms, "")
+}
+
+func putPrel(order binary.ByteOrder, data []byte, sz int, v int64) error {
+	switch sz {
+	case 2:
+		if v != int64(int16(v)) {
+			return fmt.Errorf("relocation out of range: %d", v)
+		}
+		order.PutUint16(data, uint16(v))
+	case 4:
+		if v != int64(int32(v)) {
+			return fmt.Errorf("relocation out of range: %d", v)
+		}
+		order.PutUint32(data, uint32(v))
+	case 8:
+		order.PutUint64(data, uint64(v))
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	gobuild "go/build"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// pkgProto is a prototype file of one target and its clang source.
+type pkgProto struct {
+	gfile, src string
+	target     multiTarget
+}
+
+// generateFlags are the flags of the generate command.
+func generateFlags(fs *flag.FlagSet) func() error {
+	cflags := fs.String("cflags", "", "extra clang flags of the c sources, split by spaces")
+	check := fs.Bool("check", false, "compare with the outputs instead of writing, fail with the diff")
+	asmName := fs.String("asm", "", "assembler backend: "+assemblerNames()+" (default go if the arch has one)")
+	fs.StringVar(&clangPath, "clang", clangPath, "clang executable")
+	fs.StringVar(&llvmMCPath, "llvm-mc", llvmMCPath, "llvm-mc executable used by -asm llvm-mc")
+	fs.BoolVar(&jsonDiags, "json", false, "print the diagnostics as json lines to stdout")
+
+	return func() error {
+		patterns := fs.Args()
+		if len(patterns) == 0 {
+			patterns = []string{"."}
+		}
+		return generate(patterns, strings.Fields(*cflags), *asmName, *check)
+	}
+}
+
+// isPkgPattern reports whether arg is a directory or dir/...
+func isPkgPattern(arg string) bool {
+	if arg == "..." || strings.HasSuffix(arg, "/...") {
+		return true
+	}
+	fi, err := os.Stat(arg)
+	return err == nil && fi.IsDir()
+}
+
+func allPkgPatterns(args []string) bool {
+	for _, v := range args {
+		if !isPkgPattern(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func fileExists(fpath string) bool {
+	fi, err := os.Stat(fpath)
+	return err == nil && fi.Mode().IsRegular()
+}
+
+// pkgDirs expands the patterns like go, dir/... is dir and its sub
+// directories but testdata, vendor, the names starting with _ or . and the
+// nested modules.
+func pkgDirs(patterns []string) (dirs []string, err error) {
+	seen := make(map[string]bool)
+	add := func(dir string) {
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	for _, v := range patterns {
+		root, all := v, false
+		if v == "..." {
+			root, all = ".", true
+		} else if strings.HasSuffix(v, "/...") {
+			root, all = strings.TrimSuffix(v, "/..."), true
+		}
+		root = filepath.Clean(root)
+		fi, err1 := os.Stat(root)
+		if err1 != nil {
+			return nil, err1
+		}
+		if !fi.IsDir() {
+			return nil, fmt.Errorf("%s: not a directory", root)
+		}
+		if !all {
+			add(root)
+			continue
+		}
+
+		err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || !d.IsDir() {
+				return err
+			}
+			if path != root {
+				name := d.Name()
+				if strings.HasPrefix(name, "_") || strings.HasPrefix(name, ".") || name == "testdata" || name == "vendor" {
+					return filepath.SkipDir
+				}
+				if _, err := os.Stat(filepath.Join(path, "go.mod")); err == nil {
+					return filepath.SkipDir
+				}
+			}
+			add(path)
+			return nil
+		})
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// pkgSources are the clang sources of foo_amd64.go in order, the names
+// starting with _ are ignored by go.
+func pkgSources(stem string, t multiTarget) []string {
+	return []string{
+		"_" + stem + "_" + t.String() + ".s",
+		"_" + stem + "_" + t.String() + ".c",
+		"_" + stem + ".c",
+	}
+}
+
+// pkgProtos finds the prototype files in dir, they are named by the target
+// and built on it, and have the clang source next to them.
+func pkgProtos(dir string) (protos []pkgProto, err error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, v := range entries {
+		name := v.Name()
+		if !v.Type().IsRegular() || filepath.Ext(name) != ".go" || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		arr, idx := splitFileName(name)
+		goos, goarch := fileNameTarget(name)
+		// the subr files are the outputs
+		if goarch == "" || idx == 0 || arr[idx-1] == "subr" {
+			continue
+		}
+
+		// skip the ignored ones
+		ctxt := gobuild.Default
+		ctxt.GOOS, ctxt.GOARCH = goos, goarch
+		if goos == "" {
+			ctxt.GOOS = "linux"
+		}
+		ok, err1 := ctxt.MatchFile(dir, name)
+		if err1 != nil {
+			return nil, err1
+		}
+		if !ok {
+			continue
+		}
+
+		t := multiTarget{goos: goos, goarch: goarch}
+		for _, src := range pkgSources(strings.Join(arr[:idx], "_"), t) {
+			if _, err1 := os.Stat(filepath.Join(dir, src)); err1 == nil {
+				protos = append(protos, pkgProto{filepath.Join(dir, name), src, t})
+				break
+			} else if !errors.Is(err1, fs.ErrNotExist) {
+				return nil, err1
+			}
+		}
+	}
+	return
+}
+
+// generate runs the nocgo.json of the packages and translates the prototype
+// files from their clang sources, the .c ones are compiled like build.
+func generate(patterns, cflags []string, asmName string, check bool) (err error) {
+	dirs, err := pkgDirs(patterns)
+	if err != nil {
+		return
+	}
+	var protos []pkgProto
+	var configs []string
+	for _, dir := range dirs {
+		if cfg := filepath.Join(dir, configName); fileExists(cfg) {
+			configs = append(configs, cfg)
+		}
+		ps, err := pkgProtos(dir)
+		if err != nil {
+			return err
+		}
+		protos = append(protos, ps...)
+	}
+	if len(protos) == 0 && len(configs) == 0 {
+		return fmt.Errorf("no %s or prototypes with the clang sources in %s", configName, strings.Join(patterns, " "))
+	}
+
+	var version string
+	var stale Diagnostics
+	for _, v := range configs {
+		if err1 := runConfig(v, check); err1 != nil {
+			if ds := diagsOf(err1); check && isStale(ds) {
+				stale = append(stale, ds...)
+			} else {
+				return err1
+			}
+		}
+	}
+	for _, v := range protos {
+		dir := filepath.Dir(v.gfile)
+		j := &job{
+			ofile:   strings.TrimSuffix(v.gfile, ".go") + ".s",
+			gfile:   v.gfile,
+			clang:   clangPath,
+			asmName: asmName,
+			check:   check,
+		}
+		if filepath.Ext(v.src) == ".c" {
+			if version == "" {
+				if version, err = clangVersion(clangPath); err != nil {
+					return
+				}
+			}
+			if err = j.clangUnits(v.target, dir, []string{v.src}, cflags, version); err != nil {
+				return
+			}
+		} else {
+			j.ifile = filepath.Join(dir, v.src)
+		}
+
+		if _, err1 := translate(j); err1 != nil {
+			if ds := diagsOf(err1); check && isStale(ds) {
+				stale = append(stale, ds...)
+			} else if ds[0].Pos.file != "" {
+				return err1
+			} else {
+				return fmt.Errorf("%s: %w", v.gfile, err1)
+			}
+		}
+	}
+	return stale.err()
+}
@@ -2,4 +2,6 @@ module nocgo
 
 go 1.18
 
-require github.com/keystone-engine/keystone v0.0.0-20220303013648-18569351000c // indirect
+require github.com/keystone-engine/keystone v0.0.0-20220303013648-18569351000c
+
+require golang.org/x/arch v0.8.0
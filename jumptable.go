@@ -0,0 +1,116 @@
+package main
+
+// jumpTable returns the targets of bb when it is a jump table, the entries
+// are the differences of the labels:
+//
+//	.long	.LBB0_3-.LJTI0_0
+//	.byte	(.LBB0_3-.LBB0_2)>>2
+func jumpTable(bb *BasicBlock) (targets []*BasicBlock) {
+	seen := make(map[*BasicBlock]bool)
+	for _, v := range bb.Instrs {
+		if v.Kind() == InstrKind_P2Align {
+			continue
+		}
+		los := v.LabelOperands()
+		if v.Kind() != InstrKind_Data || len(los) < 2 {
+			return nil
+		}
+		for _, lo := range los {
+			dst := lo.BB()
+			if dst == nil {
+				return nil
+			}
+			// the base
+			if dst == bb || seen[dst] {
+				continue
+			}
+			if dst.Instrs[0].Kind() == InstrKind_Data {
+				return nil
+			}
+			seen[dst] = true
+			targets = append(targets, dst)
+		}
+	}
+	return
+}
+
+// linkJumpTables gives the indirect jumps the targets of the jump tables
+// they load. the address of the table is taken before the jump, it may be
+// hoisted out of the loop, so the predecessors are searched till the
+// reference on every path. the jumps without tables are the tail calls.
+func (p *Prog) linkJumpTables() {
+	tables := make(map[*BasicBlock][]*BasicBlock)
+	for _, bb := range p.bbs {
+		if targets := jumpTable(bb); len(targets) > 0 {
+			tables[bb] = targets
+		}
+	}
+	if len(tables) == 0 {
+		return
+	}
+
+	// a table found through the targets of the others is in the next round
+	for changed := true; changed; {
+		changed = false
+
+		preds := make(map[*BasicBlock][]*BasicBlock)
+		for _, bb := range p.bbs {
+			for _, v := range bb.Succs {
+				preds[v] = append(preds[v], bb)
+			}
+		}
+
+		for _, bb := range p.bbs {
+			if bb.Last().Kind() != InstrKind_Indirect_Jmp {
+				continue
+			}
+			var found []*BasicBlock
+			visited := map[*BasicBlock]bool{bb: true}
+			queue := []*BasicBlock{bb}
+			for len(queue) > 0 {
+				cur := queue[0]
+				queue = queue[1:]
+
+				var has bool
+				for _, v := range cur.Instrs {
+					for _, lo := range v.LabelOperands() {
+						if t := lo.BB(); t != nil && tables[t] != nil {
+							found = appendBBs(found, t)
+							has = true
+						}
+					}
+				}
+				if has {
+					continue
+				}
+				for _, v := range preds[cur] {
+					if !visited[v] {
+						visited[v] = true
+						queue = append(queue, v)
+					}
+				}
+			}
+
+			var succs []*BasicBlock
+			for _, t := range found {
+				for _, v := range tables[t] {
+					succs = appendBBs(succs, v)
+				}
+			}
+			if len(succs) != len(bb.Succs) {
+				bb.Succs = succs
+				changed = true
+			}
+		}
+	}
+}
+
+// appendBBs appends bb once.
+func appendBBs(bbs []*BasicBlock, bb *BasicBlock) []*BasicBlock {
+	for _, v := range bbs {
+		if v == bb {
+			return bbs
+		}
+	}
+	return append(bbs, bb)
+}
@@ -0,0 +1,54 @@
+//go:build keystone
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/keystone-engine/keystone/bindings/go/keystone"
+)
+
+func init() {
+	assemblers["keystone"] = newKeystone
+	keystoneArchs = map[string]bool{"arm64": true, "amd64": true}
+}
+
+type ksAssembler struct {
+	ks *keystone.Keystone
+}
+
+func newKeystone(goarch string) (_ Assembler, err error) {
+	var ks *keystone.Keystone
+	switch goarch {
+	case "arm64":
+		if ks, err = keystone.New(keystone.ARCH_ARM64, keystone.MODE_LITTLE_ENDIAN); err != nil {
+			return
+		}
+	case "amd64":
+		if ks, err = keystone.New(keystone.ARCH_X86, keystone.MODE_64); err != nil {
+			return
+		}
+		if err = ks.Option(keystone.OPT_SYNTAX, keystone.OPT_SYNTAX_ATT); err != nil {
+			ks.Close()
+			return
+		}
+	default:
+		err = fmt.Errorf("keystone: unsupported arch: %s", goarch)
+		return
+	}
+	return &ksAssembler{ks: ks}, nil
+}
+
+func (ka *ksAssembler) Close() {
+	ka.ks.Close()
+}
+
+func (ka *ksAssembler) Asm(mnemo, opers string, address int64) (data []byte, err error) {
+	data, _, ok := ka.ks.Assemble(fmt.Sprintf("%s %s", mnemo, opers), uint64(address))
+	if !ok {
+		if err = ka.ks.LastError(); err == nil {
+			err = fmt.Errorf("[%d] %s %s, asm failed", address, mnemo, opers)
+		}
+	}
+	return
+}
@@ -0,0 +1,425 @@
+package main
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var llvmMCPath = "llvm-mc"
+
+type llvmTarget struct {
+	triple string
+	args   []string
+	order  binary.ByteOrder
+	nop    []byte
+	// rel rewrites the absolute label addresses to the pc relative
+	// operands llvm-mc takes, the pseudo instructions may be expanded
+	rel func(mnemo, opers string, address int64) (string, string)
+}
+
+var llvmTargets = map[string]llvmTarget{
+	// the extensions of the variants, the instructions are already picked by clang
+	"arm64": {"aarch64-linux-gnu", []string{"-mattr=+v8.6a,+sve2,+sha3,+sm4,+aes,+fp16fml,+hbc"}, binary.LittleEndian, arm64Nop, arm64LLVMRel},
+	"amd64": {"x86_64-linux-gnu", nil, binary.LittleEndian, []byte{0x90}, amd64LLVMRel},
+	// no compressed instructions, the sizes are fixed
+	"riscv64": {"riscv64-linux-gnu", []string{"-mattr=+m,+a,+f,+d"}, binary.LittleEndian, riscv64Nop, riscv64LLVMRel},
+	"ppc64le": {"powerpc64le-linux-gnu", []string{"-mcpu=pwr9"}, binary.LittleEndian, ppc64leNop, ppc64leLLVMRel},
+	"loong64": {"loongarch64-linux-gnu", nil, binary.LittleEndian, loong64Nop, loong64LLVMRel},
+	// the newest cpu, the instructions are already picked by clang
+	"s390x": {"s390x-linux-gnu", []string{"-mcpu=arch14"}, binary.BigEndian, s390xNop, s390xLLVMRel},
+	"arm":   {"armv7a-linux-gnueabihf", armLLVMAttrs, binary.LittleEndian, armNop, armLLVMRel},
+}
+
+// the extensions of cortex-a, the instructions are already picked by clang
+var armLLVMAttrs = []string{"-mattr=+neon,+vfp4,+hwdiv,+hwdiv-arm"}
+
+// llvmThumbTarget is the thumb mode of arm, the conditional instructions
+// get their own it, see archArm.
+var llvmThumbTarget = llvmTarget{
+	"thumbv7a-linux-gnueabihf", append([]string{"-arm-implicit-it=always"}, armLLVMAttrs...),
+	binary.LittleEndian, thumbNop, thumbLLVMRel,
+}
+
+// llvmMC runs llvm-mc as a subprocess, it is always in sync with the
+// clang that generates the input.
+type llvmMC struct {
+	llvmTarget
+	cache map[string][]byte
+}
+
+func newLLVMMC(goarch string) (_ Assembler, err error) {
+	t, ok := llvmTargets[goarch]
+	if !ok {
+		err = fmt.Errorf("llvm-mc: unsupported arch: %s", goarch)
+		return
+	}
+	if _, err = exec.LookPath(llvmMCPath); err != nil {
+		return
+	}
+	return &llvmMC{llvmTarget: t, cache: make(map[string][]byte)}, nil
+}
+
+func (lm *llvmMC) Close() {
+}
+
+func (lm *llvmMC) Thumb() (_ Assembler, err error) {
+	if lm.triple != llvmTargets["arm"].triple {
+		err = fmt.Errorf("llvm-mc: thumb is unsupported: %s", lm.triple)
+		return
+	}
+	return &llvmMC{llvmTarget: llvmThumbTarget, cache: make(map[string][]byte)}, nil
+}
+
+func (lm *llvmMC) line(mnemo, opers string, address int64) string {
+	mnemo, opers = lm.rel(mnemo, opers, address)
+	return strings.TrimSpace(mnemo + " " + opers)
+}
+
+func (lm *llvmMC) Asm(mnemo, opers string, address int64) (data []byte, err error) {
+	if strings.HasPrefix(mnemo, ".") {
+		return asmDirective(mnemo, opers, address, lm.order, lm.nop)
+	}
+
+	line := lm.line(mnemo, opers, address)
+	if data, ok := lm.cache[line]; ok {
+		return data, nil
+	}
+	res, err := lm.run([]string{line})
+	if err != nil {
+		err = fmt.Errorf("[%d] %s %s, %w", address, mnemo, opers, err)
+		return
+	}
+	lm.cache[line] = res[0]
+	return res[0], nil
+}
+
+func (lm *llvmMC) Prefetch(ins []asmInput) {
+	var lines []string
+	seen := make(map[string]bool)
+	for _, v := range ins {
+		if strings.HasPrefix(v.mnemo, ".") {
+			continue
+		}
+		line := lm.line(v.mnemo, v.opers, v.address)
+		if _, ok := lm.cache[line]; ok || seen[line] {
+			continue
+		}
+		seen[line] = true
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return
+	}
+
+	// one bad line fails the whole batch, Asm reports it later
+	res, err := lm.run(lines)
+	if err != nil {
+		return
+	}
+	for i, v := range lines {
+		lm.cache[v] = res[i]
+	}
+}
+
+// run puts every line into its own section, so the encodings can be
+// told apart in the object file.
+func (lm *llvmMC) run(lines []string) (ret [][]byte, err error) {
+	var in bytes.Buffer
+	for i, v := range lines {
+		fmt.Fprintf(&in, ".section .text.%d,\"ax\"\n%s\n", i, v)
+	}
+
+	var stderr bytes.Buffer
+	args := append([]string{"-triple=" + lm.triple, "-filetype=obj", "-o", "-"}, lm.args...)
+	cmd := exec.Command(llvmMCPath, args...)
+	cmd.Stdin = &in
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := stderr.String(); msg != "" {
+			// <stdin>:2:1: error: invalid instruction mnemonic 'foo'
+			msg = strings.SplitN(msg, "\n", 2)[0]
+			if idx := strings.Index(msg, "error: "); idx != -1 {
+				msg = msg[idx+7:]
+			}
+			err = errors.New(msg)
+		}
+		return
+	}
+
+	f, err := elf.NewFile(bytes.NewReader(out))
+	if err != nil {
+		return
+	}
+	for _, v := range f.Sections {
+		if v.Type == elf.SHT_REL || v.Type == elf.SHT_RELA {
+			err = fmt.Errorf("unresolved relocation: %s", v.Name)
+			return
+		}
+	}
+	for i := range lines {
+		sec := f.Section(fmt.Sprintf(".text.%d", i))
+		if sec == nil {
+			err = fmt.Errorf("missing section: %s", lines[i])
+			return
+		}
+		var data []byte
+		if data, err = sec.Data(); err != nil {
+			return
+		}
+		ret = append(ret, data)
+	}
+	return
+}
+
+////////////////////////
+
+func replaceLastOperand(args []string, s string) string {
+	args[len(args)-1] = s
+	return strings.Join(args, ", ")
+}
+
+func arm64LLVMRel(mnemo, opers string, address int64) (string, string) {
+	args := splitOperands(opers)
+	if len(args) == 0 {
+		return mnemo, opers
+	}
+	switch mnemo {
+	case "b", "bl", "cbz", "cbnz", "tbz", "tbnz", "adr", "adrp":
+	case "ldr", "ldrsw", "prfm":
+		// literal
+		if len(args) != 2 {
+			return mnemo, opers
+		}
+	default:
+		if !strings.HasPrefix(mnemo, "b.") && !strings.HasPrefix(mnemo, "bc.") {
+			return mnemo, opers
+		}
+	}
+
+	v, err := evalExpr(args[len(args)-1])
+	if err != nil {
+		return mnemo, opers
+	}
+	if mnemo == "adrp" {
+		return mnemo, replaceLastOperand(args, fmt.Sprintf("#%d", (v>>12-address>>12)<<12))
+	}
+	return mnemo, replaceLastOperand(args, fmt.Sprintf("#%d", v-address))
+}
+
+func amd64LLVMRel(mnemo, opers string, address int64) (string, string) {
+	switch {
+	case mnemo == "call" || mnemo == "callq" || mnemo == "jmp" || mnemo == "jmpq" || isAmd64Jcc(mnemo):
+	default:
+		return mnemo, opers
+	}
+
+	v, err := evalExpr(opers)
+	if err != nil {
+		return mnemo, opers
+	}
+	return mnemo, fmt.Sprintf(".%+d", v-address)
+}
+
+var reRiscv64PcrelHi = regexp.MustCompile(`^%(?:got_)?pcrel_hi\((.+)\)$`)
+
+func riscv64LLVMRel(mnemo, opers string, address int64) (string, string) {
+	args := splitOperands(opers)
+	if len(args) == 0 {
+		return mnemo, opers
+	}
+	last := args[len(args)-1]
+	switch {
+	case mnemo == "auipc":
+		// auipc a0, %pcrel_hi(sym)
+		res := reRiscv64PcrelHi.FindStringSubmatch(last)
+		if len(res) == 0 {
+			return mnemo, opers
+		}
+		last = res[1]
+	case mnemo == "call", mnemo == "tail", mnemo == "lla":
+	case mnemo == "j", mnemo == "jal", isRiscv64Branch(mnemo):
+	default:
+		return mnemo, opers
+	}
+
+	v, err := evalExpr(last)
+	if err != nil {
+		return mnemo, opers
+	}
+	off := v - address
+	hi, lo := riscv64Split(off)
+	switch mnemo {
+	case "auipc":
+		return mnemo, replaceLastOperand(args, strconv.FormatInt(hi&0xfffff, 10))
+	// the pseudo instructions take symbols only, expand them
+	case "call":
+		return "auipc", fmt.Sprintf("ra, %d; jalr ra, %d(ra)", hi&0xfffff, lo)
+	case "tail":
+		return "auipc", fmt.Sprintf("t1, %d; jr %d(t1)", hi&0xfffff, lo)
+	case "lla":
+		return "auipc", fmt.Sprintf("%s, %d; addi %s, %s, %d", args[0], hi&0xfffff, args[0], args[0], lo)
+	}
+	return mnemo, replaceLastOperand(args, strconv.FormatInt(off, 10))
+}
+
+var rePpc64leMod = regexp.MustCompile(`^(.+)@(ha|h|l)(\(.+\))?$`)
+
+func ppc64leLLVMRel(mnemo, opers string, address int64) (string, string) {
+	args := splitOperands(opers)
+	if len(args) == 0 {
+		return mnemo, opers
+	}
+
+	// addis 3, 2, 1234@ha
+	// ld 3, 1234@l(3)
+	var mod bool
+	for i, v := range args {
+		res := rePpc64leMod.FindStringSubmatch(v)
+		if len(res) == 0 {
+			continue
+		}
+		x, err := evalExpr(res[1])
+		if err != nil {
+			continue
+		}
+		switch res[2] {
+		case "ha":
+			x = (x + 0x8000) >> 16
+		case "h":
+			x >>= 16
+		}
+		args[i] = strconv.FormatInt(int64(int16(x)), 10) + res[3]
+		mod = true
+	}
+	if mod {
+		return mnemo, strings.Join(args, ", ")
+	}
+
+	// the numbers are absolute, and the negative ones are taken as
+	// the branch hints, bdnz- 4
+	if !ppc64leHasTarget(mnemo) {
+		return mnemo, opers
+	}
+	v, err := evalExpr(args[len(args)-1])
+	if err != nil {
+		return mnemo, opers
+	}
+	return mnemo, replaceLastOperand(args, fmt.Sprintf(".%+d", v-address))
+}
+
+var reLoong64PcRel = regexp.MustCompile(`^%pc_(hi20|lo12)\((.+)\)$`)
+
+func loong64LLVMRel(mnemo, opers string, address int64) (string, string) {
+	args := splitOperands(opers)
+	if len(args) == 0 {
+		return mnemo, opers
+	}
+	last := args[len(args)-1]
+
+	// pcalau12i $a0, %pc_hi20(sym)
+	// addi.d $a0, $a0, %pc_lo12(sym)
+	if res := reLoong64PcRel.FindStringSubmatch(last); len(res) > 0 {
+		v, err := evalExpr(res[2])
+		if err != nil {
+			return mnemo, opers
+		}
+		hi, lo := la64Split(v, address)
+		if res[1] == "hi20" {
+			return mnemo, replaceLastOperand(args, strconv.FormatInt(hi, 10))
+		}
+		return mnemo, replaceLastOperand(args, strconv.FormatInt(lo, 10))
+	}
+
+	switch {
+	case mnemo == "b", mnemo == "bl", mnemo == "call36", mnemo == "tail36", isLoong64Branch(mnemo):
+	default:
+		return mnemo, opers
+	}
+	v, err := evalExpr(last)
+	if err != nil {
+		return mnemo, opers
+	}
+	return mnemo, replaceLastOperand(args, strconv.FormatInt(v-address, 10))
+}
+
+// s390xLLVMRel takes the immediate as the offset from the instruction,
+// the same as the gnu assembler.
+func s390xLLVMRel(mnemo, opers string, address int64) (string, string) {
+	args := splitOperands(opers)
+	if len(args) == 0 || !isS390xPcRel(mnemo) {
+		return mnemo, opers
+	}
+	v, err := evalExpr(args[len(args)-1])
+	if err != nil {
+		return mnemo, opers
+	}
+	return mnemo, replaceLastOperand(args, strconv.FormatInt(v-address, 10))
+}
+
+var reArmHalf = regexp.MustCompile(`^#?:(lower|upper)16:(.+)$`)
+
+func armLLVMRel(mnemo, opers string, address int64) (string, string) {
+	return armRel(mnemo, opers, address, false)
+}
+
+func thumbLLVMRel(mnemo, opers string, address int64) (string, string) {
+	return armRel(mnemo, opers, address, true)
+}
+
+// armRel takes the pc, 8 bytes ahead in arm and 4 in thumb, the literal
+// loads are from the word aligned pc.
+func armRel(mnemo, opers string, address int64, thumb bool) (string, string) {
+	args := splitOperands(opers)
+	if len(args) == 0 {
+		return mnemo, opers
+	}
+	last := args[len(args)-1]
+
+	// movw r0, :lower16:(tab-(.LPC0_0+8))
+	if res := reArmHalf.FindStringSubmatch(last); len(res) > 0 {
+		v, err := evalExpr(res[2])
+		if err != nil {
+			return mnemo, opers
+		}
+		if res[1] == "upper" {
+			v >>= 16
+		}
+		return mnemo, replaceLastOperand(args, fmt.Sprintf("#%d", v&0xffff))
+	}
+
+	pc := address + 8
+	if thumb {
+		pc = address + 4
+	}
+	base, _ := armSplitCond(mnemo)
+	_, target := armBranch(mnemo, opers)
+	switch {
+	case target:
+		// blx switches to arm
+		if thumb && base == "blx" {
+			pc &^= 3
+		}
+	case armLiteral[base]:
+		pc &^= 3
+	default:
+		return mnemo, opers
+	}
+
+	v, err := evalExpr(last)
+	if err != nil {
+		return mnemo, opers
+	}
+	if armLiteral[base] && base != "adr" {
+		// ldr r0, .LCPI0_0
+		return mnemo, replaceLastOperand(args, fmt.Sprintf("[pc, #%d]", v-pc))
+	}
+	return mnemo, replaceLastOperand(args, fmt.Sprintf("#%d", v-pc))
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os/exec"
+	"testing"
+)
+
+func needLLVMMC(t *testing.T) {
+	if _, err := exec.LookPath(llvmMCPath); err != nil {
+		t.Skip("llvm-mc is not found")
+	}
+}
+
+// TestLLVMMCArm64 checks the relocations of the labels by the encodings of
+// the go encoder, they are batched like the archs do.
+func TestLLVMMCArm64(t *testing.T) {
+	needLLVMMC(t)
+	as, err := newLLVMMC("arm64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer as.Close()
+
+	ins := make([]asmInput, len(arm64AsmTests))
+	for i, v := range arm64AsmTests {
+		ins[i] = asmInput{v.mnemo, v.opers, 0}
+	}
+	as.(batchAssembler).Prefetch(ins)
+	for _, v := range arm64AsmTests {
+		data, err := as.Asm(v.mnemo, v.opers, 0)
+		if err != nil {
+			t.Errorf("%s %s: %v", v.mnemo, v.opers, err)
+			continue
+		}
+		if got := binary.LittleEndian.Uint32(data); got != v.want {
+			t.Errorf("%s %s = %#08x, want %#08x", v.mnemo, v.opers, got, v.want)
+		}
+	}
+}
+
+// the label operands are the absolute addresses
+func TestLLVMMCRel(t *testing.T) {
+	needLLVMMC(t)
+	for _, v := range []struct {
+		goarch, mnemo, opers string
+		address              int64
+		want                 []byte
+	}{
+		{"amd64", "jmp", "0x20", 0x10, []byte{0xeb, 0x0e}},
+		{"amd64", "callq", "0x100", 0x10, []byte{0xe8, 0xeb, 0x00, 0x00, 0x00}},
+		{"arm64", "bl", "0x100", 0x10, []byte{0x3c, 0x00, 0x00, 0x94}},
+		{"arm64", "adrp", "x0, 0x3000", 0x1ff0, []byte{0x00, 0x00, 0x00, 0xd0}},
+	} {
+		as, err := newLLVMMC(v.goarch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := as.Asm(v.mnemo, v.opers, v.address)
+		as.Close()
+		if err != nil {
+			t.Errorf("%s: %s %s: %v", v.goarch, v.mnemo, v.opers, err)
+		} else if !bytes.Equal(data, v.want) {
+			t.Errorf("%s: %s %s = %x, want %x", v.goarch, v.mnemo, v.opers, data, v.want)
+		}
+	}
+}
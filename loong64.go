@@ -0,0 +1,287 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type archLoong64 struct {
+	asmArch
+	wordWriter
+}
+
+func newLoong64(as Assembler) (_ *archLoong64, err error) {
+	return &archLoong64{asmArch: asmArch{as: as}, wordWriter: wordWriter{binary.LittleEndian}}, nil
+}
+
+func (aa *archLoong64) CommentTokens() []string {
+	return []string{"#"}
+}
+
+var reLoong64Sp = regexp.MustCompile(`^\$sp, \$sp, (-?\d+)$`)
+
+func isLoong64Branch(mnemo string) bool {
+	switch mnemo {
+	case "beq", "bne", "blt", "bge", "bltu", "bgeu", "beqz", "bnez", "bceqz", "bcnez",
+		// pseudo
+		"bgt", "ble", "bgtu", "bleu", "bltz", "bgez", "blez", "bgtz":
+		return true
+	}
+	return false
+}
+
+func (aa *archLoong64) Instr(ea int64, mnemo string, opers string, los []LabelOperand) (_ Instr, err error) {
+	if len(los) > 0 {
+		// bl %plt(sym)
+		opers = strings.ReplaceAll(opers, "%%plt(%d)", "%d")
+
+		// pcalau12i $a0, %got_pc_hi20(sym)
+		// ld.d $a0, $a0, %got_pc_lo12(sym)
+		// there is no got, the symbol is always local, use the address directly
+		if strings.Contains(opers, "%%got_pc_lo12(") {
+			if mnemo != "ld.d" {
+				err = fmt.Errorf("unexpected got load: %s %s", mnemo, opers)
+				return
+			}
+			mnemo = "addi.d"
+		}
+		opers = strings.ReplaceAll(opers, "%%got_pc_", "%%pc_")
+
+		// lu12i.w $a0, %abs_hi20(sym)
+		if strings.Contains(opers, "%%abs_") {
+			err = fmt.Errorf("absolute address is unsupported, use -fPIC: %s %s", mnemo, opers)
+			return
+		}
+	}
+
+	ib := &instrBase{
+		kind:  InstrKind_Normal,
+		ea:    ea,
+		mnemo: mnemo,
+		opers: opers,
+		los:   los,
+	}
+
+	if len(los) == 0 {
+		if ib.data, err = aa.asm(mnemo, opers, ea); err != nil {
+			return
+		}
+	}
+
+	// addi.d $sp, $sp, -32
+	// addi.d $sp, $sp, 32
+	if mnemo == "addi.d" {
+		if res := reLoong64Sp.FindStringSubmatch(opers); len(res) > 0 {
+			if ib.sp, err = strconv.ParseInt(res[1], 10, 64); err != nil {
+				return
+			}
+			return ib, nil
+		}
+	}
+
+	// sub.d $sp, $sp, $a0
+	// move $sp, $a0
+	switch mnemo {
+	case "add.d", "sub.d", "move", "or", "addi.d":
+		ib.dynsp = isDynSP(splitOperands(opers), 0, "$sp", "$fp", "$s9")
+	}
+
+	if mnemo == ".p2align" {
+		// the functions are aligned to 32 bytes for the speed
+		if ib.opers = p2alignClamp(opers, 4); ib.opers != opers {
+			if ib.data, err = aa.asm(mnemo, ib.opers, ea); err != nil {
+				return
+			}
+		}
+		ib.kind = InstrKind_P2Align
+		return instrRebuild{instrBase: ib, asm: aa.asm}, nil
+	}
+
+	switch {
+	case isData(mnemo):
+		ib.kind = InstrKind_Data
+	case mnemo == "ret", mnemo == "jr" && opers == "$ra":
+		ib.kind = InstrKind_Ret
+	case mnemo == "bl", mnemo == "call36":
+		ib.kind = InstrKind_Call
+	case mnemo == "jirl":
+		// jirl $zero, $a0, 0
+		if strings.HasPrefix(opers, "$zero,") {
+			ib.kind = InstrKind_Indirect_Jmp
+		} else {
+			ib.kind = InstrKind_Indirect_Call
+		}
+	case mnemo == "jr":
+		ib.kind = InstrKind_Indirect_Jmp
+	case mnemo == "b", mnemo == "tail36":
+		ib.kind = InstrKind_Jmp
+	case isLoong64Branch(mnemo):
+		ib.kind = InstrKind_Cond_Jmp
+	}
+
+	if len(los) > 0 {
+		var stub int64
+		if ib.kind == InstrKind_Call || ib.kind == InstrKind_Jmp || ib.kind == InstrKind_Cond_Jmp {
+			stub = ea
+		}
+		il := instrLabel{instrBase: ib, asm: aa.asm, stub: stub}
+		if ib.data, err = aa.asm(mnemo, il.Operands(), ea); err != nil {
+			return
+		}
+		return il, nil
+	}
+
+	return ib, nil
+}
+
+func (aa *archLoong64) WriteProg(w io.Writer, p *Prog) error {
+	// TEXT symbols are only 16 byte aligned, see .p2align
+	_, err := aa.writeProg(w, p)
+	return err
+}
+
+func (aa *archLoong64) EntryBlock() (*BasicBlock, error) {
+	return buildEntryBlock(aa.asm,
+		"pcaddi", "$a0, 0",
+		"st.d", "$a0, $sp, 8",
+		"ret", "",
+	)
+}
+
+func (aa *archLoong64) WriteHead(w io.Writer) (err error) {
+	return
+}
+
+func (aa *archLoong64) WriteFunc(w io.Writer, f *Function, spsize, fpos int64) (err error) {
+	if spsize != 0 {
+		// stack realignment
+		if _, err = fmt.Fprintf(w, `
+_entry:
+	MOVV 16(g), R19
+	ADDV $-%d, R3, R20
+	BGEU R19, R20, _stack_grow
+`, spsize+16); err != nil {
+			return
+		}
+	}
+
+	if _, err = fmt.Fprintf(w, "\n%s:\n", f.Name[1:]); err != nil {
+		return
+	}
+
+	// a0-a7, fa0-fa7
+	getReg := func(idx int, fp bool) string {
+		if fp {
+			return "F" + strconv.Itoa(idx)
+		}
+		return "R" + strconv.Itoa(idx+4)
+	}
+
+	// the narrow integers are extended by the sign of the type in the
+	// psABI, but 32-bit ones are sign extended, unsigned or not
+	getOp := func(sz int, fp, signed, load bool) string {
+		switch sz {
+		case 1:
+			if load && !signed {
+				return "MOVBU"
+			}
+			return "MOVB"
+		case 2:
+			if load && !signed {
+				return "MOVHU"
+			}
+			return "MOVH"
+		case 4:
+			if fp {
+				return "MOVF"
+			}
+			return "MOVW"
+		case 8:
+			if fp {
+				return "MOVD"
+			}
+			return "MOVV"
+		default:
+			panic(fmt.Sprintf("unexpected parameter size: %d", sz))
+		}
+	}
+
+	var ri, fi, soff int
+	nextOff := func(sz int) (r int) {
+		r = (soff + sz - 1) &^ (sz - 1)
+		soff = r + sz
+		return
+	}
+	nextReg := func(fp bool) (string, error) {
+		if fp {
+			if fi == 8 {
+				return "", errors.New("too many float arguments")
+			}
+			fi++
+			return getReg(fi-1, true), nil
+		}
+		if ri == 8 {
+			return "", errors.New("too many integer arguments")
+		}
+		ri++
+		return getReg(ri-1, false), nil
+	}
+	for _, v := range f.Args {
+		reg, err1 := nextReg(v.IsFloat)
+		if err1 != nil {
+			return newDiag(DiagKind_UnsupportedParam, f.Pos, fmt.Errorf("%s: %w", f.Name, err1))
+		}
+		if _, err = fmt.Fprintf(w, "\t%s %s+%d(FP), %s\n",
+			getOp(v.Size, v.IsFloat, v.Signed, true),
+			v.Name, nextOff(v.Size), reg,
+		); err != nil {
+			return
+		}
+	}
+
+	// R23 and R24 are callee-saved in the psABI
+	if _, err = fmt.Fprintf(w, `	MOVV ·_subr%s(SB), R20
+	MOVV R1, R24
+	MOVV R3, R23
+	AND $-16, R3
+	JAL (R20)
+	MOVV R23, R3
+	MOVV R24, R1
+`, f.Name); err != nil {
+		return
+	}
+
+	soff = nextOff(8)
+	if f.Ret != nil {
+		if _, err = fmt.Fprintf(w, "\t%s %s, %s+%d(FP)\n",
+			getOp(f.Ret.Size, f.Ret.IsFloat, f.Ret.Signed, false), getReg(0, f.Ret.IsFloat),
+			f.Ret.Name, nextOff(f.Ret.Size)); err != nil {
+			return
+		}
+	}
+	if _, err = fmt.Fprint(w, "\tRET\n"); err != nil {
+		return
+	}
+
+	if spsize != 0 {
+		// morestack takes the return address in R31
+		if _, err = fmt.Fprintf(w, `
+_stack_grow:
+	MOVV R1, R31
+	JAL runtime·morestack_noctxt<>(SB)
+	JMP _entry
+`); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (aa *archLoong64) SubrEntry(w io.Writer) (string, error) {
+	return "", nil
+}
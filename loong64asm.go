@@ -0,0 +1,691 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// pure go encoder for the LoongArch64 base instructions, in the syntax of
+// the llvm assembler (addi.d $a0, $a0, 1).
+
+var loong64Nop = []byte{0x00, 0x00, 0x40, 0x03}
+
+func loong64Asm(mnemo, opers string, address int64) (data []byte, err error) {
+	if strings.HasPrefix(mnemo, ".") {
+		return asmDirective(mnemo, opers, address, binary.LittleEndian, loong64Nop)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(la64Error)
+			if !ok {
+				panic(r)
+			}
+			err = fmt.Errorf("[%d] %s %s, %s", address, mnemo, opers, string(e))
+		}
+	}()
+
+	codes := la64Encode(strings.ToLower(mnemo), splitOperands(opers), address)
+	data = make([]byte, 4*len(codes))
+	for i, v := range codes {
+		binary.LittleEndian.PutUint32(data[i*4:], v)
+	}
+	return
+}
+
+type la64Error string
+
+func la64Fail(format string, args ...interface{}) {
+	panic(la64Error(fmt.Sprintf(format, args...)))
+}
+
+type la64Arg int
+
+const (
+	la64Rd la64Arg = iota
+	la64Rj
+	la64Rk
+	la64Fd
+	la64Fj
+	la64Fk
+	la64Fa
+	la64Cd
+	la64Cj
+	la64Ca
+	la64FcsrD
+	la64FcsrJ
+	la64Ui5
+	la64Ui6
+	la64Ui12
+	la64Si12
+	la64Si14
+	la64Si16
+	la64Si20
+	la64Sa2
+	la64Sa2p1 // alsl, 1-4
+	la64Sa3
+	la64Msbw
+	la64Lsbw
+	la64Msbd
+	la64Lsbd
+	la64Hint5
+	la64Hint15
+	la64Code15
+	la64Off16
+	la64Off21
+	la64Off26
+)
+
+type la64Op struct {
+	code uint32
+	args []la64Arg
+}
+
+var la64RegNames = map[string]uint32{
+	"zero": 0, "ra": 1, "tp": 2, "sp": 3,
+	"a0": 4, "a1": 5, "a2": 6, "a3": 7, "a4": 8, "a5": 9, "a6": 10, "a7": 11,
+	"v0": 4, "v1": 5,
+	"t0": 12, "t1": 13, "t2": 14, "t3": 15, "t4": 16, "t5": 17, "t6": 18, "t7": 19, "t8": 20,
+	"fp": 22, "s9": 22,
+	"s0": 23, "s1": 24, "s2": 25, "s3": 26, "s4": 27, "s5": 28, "s6": 29, "s7": 30, "s8": 31,
+}
+
+var la64FRegNames = map[string]uint32{
+	"fa0": 0, "fa1": 1, "fa2": 2, "fa3": 3, "fa4": 4, "fa5": 5, "fa6": 6, "fa7": 7,
+	"fv0": 0, "fv1": 1,
+	"ft0": 8, "ft1": 9, "ft2": 10, "ft3": 11, "ft4": 12, "ft5": 13, "ft6": 14, "ft7": 15,
+	"ft8": 16, "ft9": 17, "ft10": 18, "ft11": 19, "ft12": 20, "ft13": 21, "ft14": 22, "ft15": 23,
+	"fs0": 24, "fs1": 25, "fs2": 26, "fs3": 27, "fs4": 28, "fs5": 29, "fs6": 30, "fs7": 31,
+}
+
+// la64Reg parses $r0-$r31, $f0-$f31, $fcc0-$fcc7, $fcsr0-$fcsr3 and the
+// abi names, the prefix is the numbered form.
+func la64Reg(s, prefix string, max uint32) uint32 {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "$")
+	if strings.HasPrefix(s, prefix) {
+		if n, err := strconv.ParseUint(s[len(prefix):], 10, 32); err == nil && uint32(n) < max {
+			return uint32(n)
+		}
+	}
+	switch prefix {
+	case "r":
+		if n, ok := la64RegNames[s]; ok {
+			return n
+		}
+	case "f":
+		if n, ok := la64FRegNames[s]; ok {
+			return n
+		}
+	}
+	la64Fail("invalid register: %s", s)
+	return 0
+}
+
+// la64Imm evaluates the immediate, the %pc_hi20 and %pc_lo12 operators
+// take the absolute address.
+func la64Imm(s string, pc int64) int64 {
+	s = strings.TrimSpace(s)
+	for _, v := range []string{"%pc_hi20(", "%pc_lo12("} {
+		if !strings.HasPrefix(s, v) || !strings.HasSuffix(s, ")") {
+			continue
+		}
+		x, err := evalExpr(s[len(v) : len(s)-1])
+		if err != nil {
+			la64Fail("%s", err)
+		}
+		if v == "%pc_hi20(" {
+			hi, _ := la64Split(x, pc)
+			return hi
+		}
+		_, lo := la64Split(x, pc)
+		return lo
+	}
+	x, err := evalExpr(s)
+	if err != nil {
+		la64Fail("%s", err)
+	}
+	return x
+}
+
+// la64Split splits the address for pcalau12i and the 12-bit signed
+// immediate after it, pcalau12i takes the page of the pc.
+func la64Split(x, pc int64) (hi, lo int64) {
+	hi = (x+0x800)>>12 - pc>>12
+	lo = x & 0xfff
+	if lo >= 0x800 {
+		lo -= 0x1000
+	}
+	return
+}
+
+func la64SImm(s string, pc int64, bits uint) uint32 {
+	x := la64Imm(s, pc)
+	if x < -(1<<(bits-1)) || x >= 1<<(bits-1) {
+		la64Fail("immediate out of range: %s", s)
+	}
+	return uint32(x) & (1<<bits - 1)
+}
+
+func la64UImm(s string, pc int64, bits uint) uint32 {
+	x := la64Imm(s, pc)
+	if x < 0 || x >= 1<<bits {
+		la64Fail("immediate out of range: %s", s)
+	}
+	return uint32(x)
+}
+
+// la64Rel is the pc relative offset in words.
+func la64Rel(s string, pc int64, bits uint) uint32 {
+	x := la64Imm(s, pc) - pc
+	if x&3 != 0 {
+		la64Fail("unaligned target: %s", s)
+	}
+	x >>= 2
+	if x < -(1<<(bits-1)) || x >= 1<<(bits-1) {
+		la64Fail("target out of range: %s", s)
+	}
+	return uint32(x) & (1<<bits - 1)
+}
+
+func la64Encode(mnemo string, args []string, pc int64) []uint32 {
+	nargs := func(n int) {
+		if len(args) != n {
+			la64Fail("invalid operands")
+		}
+	}
+
+	// aliases
+	switch mnemo {
+	case "nop":
+		mnemo, args = "andi", []string{"$zero", "$zero", "0"}
+	case "move":
+		nargs(2)
+		mnemo, args = "or", append(args, "$zero")
+	case "ret":
+		mnemo, args = "jirl", []string{"$zero", "$ra", "0"}
+	case "jr":
+		nargs(1)
+		mnemo, args = "jirl", []string{"$zero", args[0], "0"}
+	case "bgt", "ble", "bgtu", "bleu":
+		nargs(3)
+		args[0], args[1] = args[1], args[0]
+		mnemo = map[string]string{"bgt": "blt", "ble": "bge", "bgtu": "bltu", "bleu": "bgeu"}[mnemo]
+	case "bltz", "bgez":
+		nargs(2)
+		mnemo, args = mnemo[:3], append([]string{args[0], "$zero"}, args[1:]...)
+	case "bgtz", "blez":
+		nargs(2)
+		mnemo = map[string]string{"bgtz": "blt", "blez": "bge"}[mnemo]
+		args = append([]string{"$zero"}, args...)
+	case "call36", "tail36":
+		// pcaddu18i $ra, %call36(sym)
+		// jirl $ra, $ra, 0
+		rd, rj := "$ra", "$ra"
+		if mnemo == "tail36" {
+			nargs(2)
+			rd, rj, args = "$zero", args[0], args[1:]
+		}
+		nargs(1)
+		off := la64Imm(args[0], pc) - pc
+		hi := (off + 0x20000) >> 18
+		lo := off - hi<<18
+		if hi < -(1<<19) || hi >= 1<<19 {
+			la64Fail("target out of range: %s", args[0])
+		}
+		return []uint32{
+			la64Encode("pcaddu18i", []string{rj, strconv.FormatInt(hi, 10)}, pc)[0],
+			la64Encode("jirl", []string{rd, rj, strconv.FormatInt(lo, 10)}, pc+4)[0],
+		}
+	}
+
+	op, ok := la64Ops[mnemo]
+	if !ok {
+		la64Fail("unsupported instruction, try -asm llvm-mc")
+	}
+	nargs(len(op.args))
+
+	code := op.code
+	for i, v := range op.args {
+		s := args[i]
+		switch v {
+		case la64Rd:
+			code |= la64Reg(s, "r", 32)
+		case la64Rj:
+			code |= la64Reg(s, "r", 32) << 5
+		case la64Rk:
+			code |= la64Reg(s, "r", 32) << 10
+		case la64Fd:
+			code |= la64Reg(s, "f", 32)
+		case la64Fj:
+			code |= la64Reg(s, "f", 32) << 5
+		case la64Fk:
+			code |= la64Reg(s, "f", 32) << 10
+		case la64Fa:
+			code |= la64Reg(s, "f", 32) << 15
+		case la64Cd:
+			code |= la64Reg(s, "fcc", 8)
+		case la64Cj:
+			code |= la64Reg(s, "fcc", 8) << 5
+		case la64Ca:
+			code |= la64Reg(s, "fcc", 8) << 15
+		case la64FcsrD:
+			code |= la64Reg(s, "fcsr", 4)
+		case la64FcsrJ:
+			code |= la64Reg(s, "fcsr", 4) << 5
+		case la64Ui5, la64Lsbw:
+			code |= la64UImm(s, pc, 5) << 10
+		case la64Ui6, la64Lsbd:
+			code |= la64UImm(s, pc, 6) << 10
+		case la64Msbw:
+			code |= la64UImm(s, pc, 5) << 16
+		case la64Msbd:
+			code |= la64UImm(s, pc, 6) << 16
+		case la64Ui12:
+			code |= la64UImm(s, pc, 12) << 10
+		case la64Si12:
+			code |= la64SImm(s, pc, 12) << 10
+		case la64Si14:
+			// byte offset, multiple of 4
+			x := la64Imm(s, pc)
+			if x&3 != 0 {
+				la64Fail("unaligned offset: %s", s)
+			}
+			code |= la64SImm(strconv.FormatInt(x>>2, 10), pc, 14) << 10
+		case la64Si16:
+			code |= la64SImm(s, pc, 16) << 10
+		case la64Si20:
+			code |= la64SImm(s, pc, 20) << 5
+		case la64Sa2:
+			code |= la64UImm(s, pc, 2) << 15
+		case la64Sa2p1:
+			x := la64Imm(s, pc)
+			if x < 1 || x > 4 {
+				la64Fail("immediate out of range: %s", s)
+			}
+			code |= uint32(x-1) << 15
+		case la64Sa3:
+			code |= la64UImm(s, pc, 3) << 15
+		case la64Hint5:
+			code |= la64UImm(s, pc, 5)
+		case la64Hint15, la64Code15:
+			code |= la64UImm(s, pc, 15)
+		case la64Off16:
+			// jirl takes the offset from rj, not a target
+			if mnemo == "jirl" {
+				x := la64Imm(s, pc)
+				if x&3 != 0 {
+					la64Fail("unaligned offset: %s", s)
+				}
+				code |= la64SImm(strconv.FormatInt(x>>2, 10), pc, 16) << 10
+				break
+			}
+			code |= la64Rel(s, pc, 16) << 10
+		case la64Off21:
+			x := la64Rel(s, pc, 21)
+			code |= x&0xffff<<10 | x>>16
+		case la64Off26:
+			x := la64Rel(s, pc, 26)
+			code |= x&0xffff<<10 | x>>16
+		}
+	}
+	return []uint32{code}
+}
+
+var (
+	la64FmtRdRjSi12     = []la64Arg{la64Rd, la64Rj, la64Si12}
+	la64FmtRdRjSi16     = []la64Arg{la64Rd, la64Rj, la64Si16}
+	la64FmtRdRjRk       = []la64Arg{la64Rd, la64Rj, la64Rk}
+	la64FmtRdRjRkSa2p1  = []la64Arg{la64Rd, la64Rj, la64Rk, la64Sa2p1}
+	la64FmtRdRkRj       = []la64Arg{la64Rd, la64Rk, la64Rj}
+	la64FmtRdRjUi12     = []la64Arg{la64Rd, la64Rj, la64Ui12}
+	la64FmtOff26        = []la64Arg{la64Off26}
+	la64FmtCjOff21      = []la64Arg{la64Cj, la64Off21}
+	la64FmtRjRdOff16    = []la64Arg{la64Rj, la64Rd, la64Off16}
+	la64FmtRjOff21      = []la64Arg{la64Rj, la64Off21}
+	la64FmtRdRj         = []la64Arg{la64Rd, la64Rj}
+	la64FmtCode15       = []la64Arg{la64Code15}
+	la64FmtRdRjMsbdLsbd = []la64Arg{la64Rd, la64Rj, la64Msbd, la64Lsbd}
+	la64FmtRdRjMsbwLsbw = []la64Arg{la64Rd, la64Rj, la64Msbw, la64Lsbw}
+	la64FmtRdRjRkSa3    = []la64Arg{la64Rd, la64Rj, la64Rk, la64Sa3}
+	la64FmtRdRjRkSa2    = []la64Arg{la64Rd, la64Rj, la64Rk, la64Sa2}
+	la64FmtHint15       = []la64Arg{la64Hint15}
+	la64FmtFdFj         = []la64Arg{la64Fd, la64Fj}
+	la64FmtFdFjFk       = []la64Arg{la64Fd, la64Fj, la64Fk}
+	la64FmtCdFjFk       = []la64Arg{la64Cd, la64Fj, la64Fk}
+	la64FmtFdRjRk       = []la64Arg{la64Fd, la64Rj, la64Rk}
+	la64FmtFdRjSi12     = []la64Arg{la64Fd, la64Rj, la64Si12}
+	la64FmtFdFjFkFa     = []la64Arg{la64Fd, la64Fj, la64Fk, la64Fa}
+	la64FmtFdFjFkCa     = []la64Arg{la64Fd, la64Fj, la64Fk, la64Ca}
+	la64FmtRdRjOff16    = []la64Arg{la64Rd, la64Rj, la64Off16}
+	la64FmtRdRjSi14     = []la64Arg{la64Rd, la64Rj, la64Si14}
+	la64FmtRdSi20       = []la64Arg{la64Rd, la64Si20}
+	la64FmtFdCj         = []la64Arg{la64Fd, la64Cj}
+	la64FmtRdCj         = []la64Arg{la64Rd, la64Cj}
+	la64FmtRdFcsrJ      = []la64Arg{la64Rd, la64FcsrJ}
+	la64FmtCdFj         = []la64Arg{la64Cd, la64Fj}
+	la64FmtRdFj         = []la64Arg{la64Rd, la64Fj}
+	la64FmtCdRj         = []la64Arg{la64Cd, la64Rj}
+	la64FmtFcsrDRj      = []la64Arg{la64FcsrD, la64Rj}
+	la64FmtFdRj         = []la64Arg{la64Fd, la64Rj}
+	la64FmtHint5RjSi12  = []la64Arg{la64Hint5, la64Rj, la64Si12}
+	la64FmtHint5RjRk    = []la64Arg{la64Hint5, la64Rj, la64Rk}
+	la64FmtRdRjUi6      = []la64Arg{la64Rd, la64Rj, la64Ui6}
+	la64FmtRdRjUi5      = []la64Arg{la64Rd, la64Rj, la64Ui5}
+)
+
+var la64Ops = map[string]la64Op{
+	"addi.d":       {0x02c00000, la64FmtRdRjSi12},
+	"addi.w":       {0x02800000, la64FmtRdRjSi12},
+	"ld.b":         {0x28000000, la64FmtRdRjSi12},
+	"ld.bu":        {0x2a000000, la64FmtRdRjSi12},
+	"ld.d":         {0x28c00000, la64FmtRdRjSi12},
+	"ld.h":         {0x28400000, la64FmtRdRjSi12},
+	"ld.hu":        {0x2a400000, la64FmtRdRjSi12},
+	"ld.w":         {0x28800000, la64FmtRdRjSi12},
+	"ld.wu":        {0x2a800000, la64FmtRdRjSi12},
+	"lu52i.d":      {0x03000000, la64FmtRdRjSi12},
+	"slti":         {0x02000000, la64FmtRdRjSi12},
+	"sltui":        {0x02400000, la64FmtRdRjSi12},
+	"st.b":         {0x29000000, la64FmtRdRjSi12},
+	"st.d":         {0x29c00000, la64FmtRdRjSi12},
+	"st.h":         {0x29400000, la64FmtRdRjSi12},
+	"st.w":         {0x29800000, la64FmtRdRjSi12},
+	"addu16i.d":    {0x10000000, la64FmtRdRjSi16},
+	"add.d":        {0x00108000, la64FmtRdRjRk},
+	"add.w":        {0x00100000, la64FmtRdRjRk},
+	"and":          {0x00148000, la64FmtRdRjRk},
+	"andn":         {0x00168000, la64FmtRdRjRk},
+	"div.d":        {0x00220000, la64FmtRdRjRk},
+	"div.du":       {0x00230000, la64FmtRdRjRk},
+	"div.w":        {0x00200000, la64FmtRdRjRk},
+	"div.wu":       {0x00210000, la64FmtRdRjRk},
+	"ldx.b":        {0x38000000, la64FmtRdRjRk},
+	"ldx.bu":       {0x38200000, la64FmtRdRjRk},
+	"ldx.d":        {0x380c0000, la64FmtRdRjRk},
+	"ldx.h":        {0x38040000, la64FmtRdRjRk},
+	"ldx.hu":       {0x38240000, la64FmtRdRjRk},
+	"ldx.w":        {0x38080000, la64FmtRdRjRk},
+	"ldx.wu":       {0x38280000, la64FmtRdRjRk},
+	"maskeqz":      {0x00130000, la64FmtRdRjRk},
+	"masknez":      {0x00138000, la64FmtRdRjRk},
+	"mod.d":        {0x00228000, la64FmtRdRjRk},
+	"mod.du":       {0x00238000, la64FmtRdRjRk},
+	"mod.w":        {0x00208000, la64FmtRdRjRk},
+	"mod.wu":       {0x00218000, la64FmtRdRjRk},
+	"mulh.d":       {0x001e0000, la64FmtRdRjRk},
+	"mulh.du":      {0x001e8000, la64FmtRdRjRk},
+	"mulh.w":       {0x001c8000, la64FmtRdRjRk},
+	"mulh.wu":      {0x001d0000, la64FmtRdRjRk},
+	"mulw.d.w":     {0x001f0000, la64FmtRdRjRk},
+	"mulw.d.wu":    {0x001f8000, la64FmtRdRjRk},
+	"mul.d":        {0x001d8000, la64FmtRdRjRk},
+	"mul.w":        {0x001c0000, la64FmtRdRjRk},
+	"nor":          {0x00140000, la64FmtRdRjRk},
+	"or":           {0x00150000, la64FmtRdRjRk},
+	"orn":          {0x00160000, la64FmtRdRjRk},
+	"rotr.d":       {0x001b8000, la64FmtRdRjRk},
+	"rotr.w":       {0x001b0000, la64FmtRdRjRk},
+	"sll.d":        {0x00188000, la64FmtRdRjRk},
+	"sll.w":        {0x00170000, la64FmtRdRjRk},
+	"slt":          {0x00120000, la64FmtRdRjRk},
+	"sltu":         {0x00128000, la64FmtRdRjRk},
+	"sra.d":        {0x00198000, la64FmtRdRjRk},
+	"sra.w":        {0x00180000, la64FmtRdRjRk},
+	"srl.d":        {0x00190000, la64FmtRdRjRk},
+	"srl.w":        {0x00178000, la64FmtRdRjRk},
+	"stx.b":        {0x38100000, la64FmtRdRjRk},
+	"stx.d":        {0x381c0000, la64FmtRdRjRk},
+	"stx.h":        {0x38140000, la64FmtRdRjRk},
+	"stx.w":        {0x38180000, la64FmtRdRjRk},
+	"sub.d":        {0x00118000, la64FmtRdRjRk},
+	"sub.w":        {0x00110000, la64FmtRdRjRk},
+	"xor":          {0x00158000, la64FmtRdRjRk},
+	"alsl.d":       {0x002c0000, la64FmtRdRjRkSa2p1},
+	"alsl.w":       {0x00040000, la64FmtRdRjRkSa2p1},
+	"alsl.wu":      {0x00060000, la64FmtRdRjRkSa2p1},
+	"amadd.d":      {0x38618000, la64FmtRdRkRj},
+	"amadd_db.d":   {0x386a8000, la64FmtRdRkRj},
+	"amadd_db.w":   {0x386a0000, la64FmtRdRkRj},
+	"amadd.w":      {0x38610000, la64FmtRdRkRj},
+	"amand.d":      {0x38628000, la64FmtRdRkRj},
+	"amand_db.d":   {0x386b8000, la64FmtRdRkRj},
+	"amand_db.w":   {0x386b0000, la64FmtRdRkRj},
+	"amand.w":      {0x38620000, la64FmtRdRkRj},
+	"ammax.d":      {0x38658000, la64FmtRdRkRj},
+	"ammax_db.d":   {0x386e8000, la64FmtRdRkRj},
+	"ammax_db.du":  {0x38708000, la64FmtRdRkRj},
+	"ammax_db.w":   {0x386e0000, la64FmtRdRkRj},
+	"ammax_db.wu":  {0x38700000, la64FmtRdRkRj},
+	"ammax.du":     {0x38678000, la64FmtRdRkRj},
+	"ammax.w":      {0x38650000, la64FmtRdRkRj},
+	"ammax.wu":     {0x38670000, la64FmtRdRkRj},
+	"ammin.d":      {0x38668000, la64FmtRdRkRj},
+	"ammin_db.d":   {0x386f8000, la64FmtRdRkRj},
+	"ammin_db.du":  {0x38718000, la64FmtRdRkRj},
+	"ammin_db.w":   {0x386f0000, la64FmtRdRkRj},
+	"ammin_db.wu":  {0x38710000, la64FmtRdRkRj},
+	"ammin.du":     {0x38688000, la64FmtRdRkRj},
+	"ammin.w":      {0x38660000, la64FmtRdRkRj},
+	"ammin.wu":     {0x38680000, la64FmtRdRkRj},
+	"amor.d":       {0x38638000, la64FmtRdRkRj},
+	"amor_db.d":    {0x386c8000, la64FmtRdRkRj},
+	"amor_db.w":    {0x386c0000, la64FmtRdRkRj},
+	"amor.w":       {0x38630000, la64FmtRdRkRj},
+	"amswap.d":     {0x38608000, la64FmtRdRkRj},
+	"amswap_db.d":  {0x38698000, la64FmtRdRkRj},
+	"amswap_db.w":  {0x38690000, la64FmtRdRkRj},
+	"amswap.w":     {0x38600000, la64FmtRdRkRj},
+	"amxor.d":      {0x38648000, la64FmtRdRkRj},
+	"amxor_db.d":   {0x386d8000, la64FmtRdRkRj},
+	"amxor_db.w":   {0x386d0000, la64FmtRdRkRj},
+	"amxor.w":      {0x38640000, la64FmtRdRkRj},
+	"andi":         {0x03400000, la64FmtRdRjUi12},
+	"ori":          {0x03800000, la64FmtRdRjUi12},
+	"xori":         {0x03c00000, la64FmtRdRjUi12},
+	"b":            {0x50000000, la64FmtOff26},
+	"bl":           {0x54000000, la64FmtOff26},
+	"bceqz":        {0x48000000, la64FmtCjOff21},
+	"bcnez":        {0x48000100, la64FmtCjOff21},
+	"beq":          {0x58000000, la64FmtRjRdOff16},
+	"bge":          {0x64000000, la64FmtRjRdOff16},
+	"bgeu":         {0x6c000000, la64FmtRjRdOff16},
+	"blt":          {0x60000000, la64FmtRjRdOff16},
+	"bltu":         {0x68000000, la64FmtRjRdOff16},
+	"bne":          {0x5c000000, la64FmtRjRdOff16},
+	"beqz":         {0x40000000, la64FmtRjOff21},
+	"bnez":         {0x44000000, la64FmtRjOff21},
+	"bitrev.4b":    {0x00004800, la64FmtRdRj},
+	"bitrev.8b":    {0x00004c00, la64FmtRdRj},
+	"bitrev.d":     {0x00005400, la64FmtRdRj},
+	"bitrev.w":     {0x00005000, la64FmtRdRj},
+	"clo.d":        {0x00002000, la64FmtRdRj},
+	"clo.w":        {0x00001000, la64FmtRdRj},
+	"clz.d":        {0x00002400, la64FmtRdRj},
+	"clz.w":        {0x00001400, la64FmtRdRj},
+	"cto.d":        {0x00002800, la64FmtRdRj},
+	"cto.w":        {0x00001800, la64FmtRdRj},
+	"ctz.d":        {0x00002c00, la64FmtRdRj},
+	"ctz.w":        {0x00001c00, la64FmtRdRj},
+	"ext.w.b":      {0x00005c00, la64FmtRdRj},
+	"ext.w.h":      {0x00005800, la64FmtRdRj},
+	"revb.2h":      {0x00003000, la64FmtRdRj},
+	"revb.2w":      {0x00003800, la64FmtRdRj},
+	"revb.4h":      {0x00003400, la64FmtRdRj},
+	"revb.d":       {0x00003c00, la64FmtRdRj},
+	"revh.2w":      {0x00004000, la64FmtRdRj},
+	"revh.d":       {0x00004400, la64FmtRdRj},
+	"break":        {0x002a0000, la64FmtCode15},
+	"syscall":      {0x002b0000, la64FmtCode15},
+	"bstrins.d":    {0x00800000, la64FmtRdRjMsbdLsbd},
+	"bstrpick.d":   {0x00c00000, la64FmtRdRjMsbdLsbd},
+	"bstrins.w":    {0x00600000, la64FmtRdRjMsbwLsbw},
+	"bstrpick.w":   {0x00608000, la64FmtRdRjMsbwLsbw},
+	"bytepick.d":   {0x000c0000, la64FmtRdRjRkSa3},
+	"bytepick.w":   {0x00080000, la64FmtRdRjRkSa2},
+	"dbar":         {0x38720000, la64FmtHint15},
+	"ibar":         {0x38728000, la64FmtHint15},
+	"fabs.d":       {0x01140800, la64FmtFdFj},
+	"fabs.s":       {0x01140400, la64FmtFdFj},
+	"fclass.d":     {0x01143800, la64FmtFdFj},
+	"fclass.s":     {0x01143400, la64FmtFdFj},
+	"fcvt.d.s":     {0x01192400, la64FmtFdFj},
+	"fcvt.s.d":     {0x01191800, la64FmtFdFj},
+	"ffint.d.l":    {0x011d2800, la64FmtFdFj},
+	"ffint.d.w":    {0x011d2000, la64FmtFdFj},
+	"ffint.s.l":    {0x011d1800, la64FmtFdFj},
+	"ffint.s.w":    {0x011d1000, la64FmtFdFj},
+	"flogb.d":      {0x01142800, la64FmtFdFj},
+	"flogb.s":      {0x01142400, la64FmtFdFj},
+	"fmov.d":       {0x01149800, la64FmtFdFj},
+	"fmov.s":       {0x01149400, la64FmtFdFj},
+	"fneg.d":       {0x01141800, la64FmtFdFj},
+	"fneg.s":       {0x01141400, la64FmtFdFj},
+	"frecip.d":     {0x01145800, la64FmtFdFj},
+	"frecip.s":     {0x01145400, la64FmtFdFj},
+	"frint.d":      {0x011e4800, la64FmtFdFj},
+	"frint.s":      {0x011e4400, la64FmtFdFj},
+	"frsqrt.d":     {0x01146800, la64FmtFdFj},
+	"frsqrt.s":     {0x01146400, la64FmtFdFj},
+	"fsqrt.d":      {0x01144800, la64FmtFdFj},
+	"fsqrt.s":      {0x01144400, la64FmtFdFj},
+	"ftintrm.l.d":  {0x011a2800, la64FmtFdFj},
+	"ftintrm.l.s":  {0x011a2400, la64FmtFdFj},
+	"ftintrm.w.d":  {0x011a0800, la64FmtFdFj},
+	"ftintrm.w.s":  {0x011a0400, la64FmtFdFj},
+	"ftintrne.l.d": {0x011ae800, la64FmtFdFj},
+	"ftintrne.l.s": {0x011ae400, la64FmtFdFj},
+	"ftintrne.w.d": {0x011ac800, la64FmtFdFj},
+	"ftintrne.w.s": {0x011ac400, la64FmtFdFj},
+	"ftintrp.l.d":  {0x011a6800, la64FmtFdFj},
+	"ftintrp.l.s":  {0x011a6400, la64FmtFdFj},
+	"ftintrp.w.d":  {0x011a4800, la64FmtFdFj},
+	"ftintrp.w.s":  {0x011a4400, la64FmtFdFj},
+	"ftintrz.l.d":  {0x011aa800, la64FmtFdFj},
+	"ftintrz.l.s":  {0x011aa400, la64FmtFdFj},
+	"ftintrz.w.d":  {0x011a8800, la64FmtFdFj},
+	"ftintrz.w.s":  {0x011a8400, la64FmtFdFj},
+	"ftint.l.d":    {0x011b2800, la64FmtFdFj},
+	"ftint.l.s":    {0x011b2400, la64FmtFdFj},
+	"ftint.w.d":    {0x011b0800, la64FmtFdFj},
+	"ftint.w.s":    {0x011b0400, la64FmtFdFj},
+	"fadd.d":       {0x01010000, la64FmtFdFjFk},
+	"fadd.s":       {0x01008000, la64FmtFdFjFk},
+	"fcopysign.d":  {0x01130000, la64FmtFdFjFk},
+	"fcopysign.s":  {0x01128000, la64FmtFdFjFk},
+	"fdiv.d":       {0x01070000, la64FmtFdFjFk},
+	"fdiv.s":       {0x01068000, la64FmtFdFjFk},
+	"fmaxa.d":      {0x010d0000, la64FmtFdFjFk},
+	"fmaxa.s":      {0x010c8000, la64FmtFdFjFk},
+	"fmax.d":       {0x01090000, la64FmtFdFjFk},
+	"fmax.s":       {0x01088000, la64FmtFdFjFk},
+	"fmina.d":      {0x010f0000, la64FmtFdFjFk},
+	"fmina.s":      {0x010e8000, la64FmtFdFjFk},
+	"fmin.d":       {0x010b0000, la64FmtFdFjFk},
+	"fmin.s":       {0x010a8000, la64FmtFdFjFk},
+	"fmul.d":       {0x01050000, la64FmtFdFjFk},
+	"fmul.s":       {0x01048000, la64FmtFdFjFk},
+	"fscaleb.d":    {0x01110000, la64FmtFdFjFk},
+	"fscaleb.s":    {0x01108000, la64FmtFdFjFk},
+	"fsub.d":       {0x01030000, la64FmtFdFjFk},
+	"fsub.s":       {0x01028000, la64FmtFdFjFk},
+	"fcmp.caf.d":   {0x0c200000, la64FmtCdFjFk},
+	"fcmp.caf.s":   {0x0c100000, la64FmtCdFjFk},
+	"fcmp.ceq.d":   {0x0c220000, la64FmtCdFjFk},
+	"fcmp.ceq.s":   {0x0c120000, la64FmtCdFjFk},
+	"fcmp.cle.d":   {0x0c230000, la64FmtCdFjFk},
+	"fcmp.cle.s":   {0x0c130000, la64FmtCdFjFk},
+	"fcmp.clt.d":   {0x0c210000, la64FmtCdFjFk},
+	"fcmp.clt.s":   {0x0c110000, la64FmtCdFjFk},
+	"fcmp.cne.d":   {0x0c280000, la64FmtCdFjFk},
+	"fcmp.cne.s":   {0x0c180000, la64FmtCdFjFk},
+	"fcmp.cor.d":   {0x0c2a0000, la64FmtCdFjFk},
+	"fcmp.cor.s":   {0x0c1a0000, la64FmtCdFjFk},
+	"fcmp.cueq.d":  {0x0c260000, la64FmtCdFjFk},
+	"fcmp.cueq.s":  {0x0c160000, la64FmtCdFjFk},
+	"fcmp.cule.d":  {0x0c270000, la64FmtCdFjFk},
+	"fcmp.cule.s":  {0x0c170000, la64FmtCdFjFk},
+	"fcmp.cult.d":  {0x0c250000, la64FmtCdFjFk},
+	"fcmp.cult.s":  {0x0c150000, la64FmtCdFjFk},
+	"fcmp.cune.d":  {0x0c2c0000, la64FmtCdFjFk},
+	"fcmp.cune.s":  {0x0c1c0000, la64FmtCdFjFk},
+	"fcmp.cun.d":   {0x0c240000, la64FmtCdFjFk},
+	"fcmp.cun.s":   {0x0c140000, la64FmtCdFjFk},
+	"fcmp.saf.d":   {0x0c208000, la64FmtCdFjFk},
+	"fcmp.saf.s":   {0x0c108000, la64FmtCdFjFk},
+	"fcmp.seq.d":   {0x0c228000, la64FmtCdFjFk},
+	"fcmp.seq.s":   {0x0c128000, la64FmtCdFjFk},
+	"fcmp.sle.d":   {0x0c238000, la64FmtCdFjFk},
+	"fcmp.sle.s":   {0x0c138000, la64FmtCdFjFk},
+	"fcmp.slt.d":   {0x0c218000, la64FmtCdFjFk},
+	"fcmp.slt.s":   {0x0c118000, la64FmtCdFjFk},
+	"fcmp.sne.d":   {0x0c288000, la64FmtCdFjFk},
+	"fcmp.sne.s":   {0x0c188000, la64FmtCdFjFk},
+	"fcmp.sor.d":   {0x0c2a8000, la64FmtCdFjFk},
+	"fcmp.sor.s":   {0x0c1a8000, la64FmtCdFjFk},
+	"fcmp.sueq.d":  {0x0c268000, la64FmtCdFjFk},
+	"fcmp.sueq.s":  {0x0c168000, la64FmtCdFjFk},
+	"fcmp.sule.d":  {0x0c278000, la64FmtCdFjFk},
+	"fcmp.sule.s":  {0x0c178000, la64FmtCdFjFk},
+	"fcmp.sult.d":  {0x0c258000, la64FmtCdFjFk},
+	"fcmp.sult.s":  {0x0c158000, la64FmtCdFjFk},
+	"fcmp.sune.d":  {0x0c2c8000, la64FmtCdFjFk},
+	"fcmp.sune.s":  {0x0c1c8000, la64FmtCdFjFk},
+	"fcmp.sun.d":   {0x0c248000, la64FmtCdFjFk},
+	"fcmp.sun.s":   {0x0c148000, la64FmtCdFjFk},
+	"fldx.d":       {0x38340000, la64FmtFdRjRk},
+	"fldx.s":       {0x38300000, la64FmtFdRjRk},
+	"fstx.d":       {0x383c0000, la64FmtFdRjRk},
+	"fstx.s":       {0x38380000, la64FmtFdRjRk},
+	"fld.d":        {0x2b800000, la64FmtFdRjSi12},
+	"fld.s":        {0x2b000000, la64FmtFdRjSi12},
+	"fst.d":        {0x2bc00000, la64FmtFdRjSi12},
+	"fst.s":        {0x2b400000, la64FmtFdRjSi12},
+	"fmadd.d":      {0x08200000, la64FmtFdFjFkFa},
+	"fmadd.s":      {0x08100000, la64FmtFdFjFkFa},
+	"fmsub.d":      {0x08600000, la64FmtFdFjFkFa},
+	"fmsub.s":      {0x08500000, la64FmtFdFjFkFa},
+	"fnmadd.d":     {0x08a00000, la64FmtFdFjFkFa},
+	"fnmadd.s":     {0x08900000, la64FmtFdFjFkFa},
+	"fnmsub.d":     {0x08e00000, la64FmtFdFjFkFa},
+	"fnmsub.s":     {0x08d00000, la64FmtFdFjFkFa},
+	"fsel":         {0x0d000000, la64FmtFdFjFkCa},
+	"jirl":         {0x4c000000, la64FmtRdRjOff16},
+	"ldptr.d":      {0x26000000, la64FmtRdRjSi14},
+	"ldptr.w":      {0x24000000, la64FmtRdRjSi14},
+	"ll.d":         {0x22000000, la64FmtRdRjSi14},
+	"ll.w":         {0x20000000, la64FmtRdRjSi14},
+	"sc.d":         {0x23000000, la64FmtRdRjSi14},
+	"sc.w":         {0x21000000, la64FmtRdRjSi14},
+	"stptr.d":      {0x27000000, la64FmtRdRjSi14},
+	"stptr.w":      {0x25000000, la64FmtRdRjSi14},
+	"lu12i.w":      {0x14000000, la64FmtRdSi20},
+	"lu32i.d":      {0x16000000, la64FmtRdSi20},
+	"pcaddi":       {0x18000000, la64FmtRdSi20},
+	"pcaddu12i":    {0x1c000000, la64FmtRdSi20},
+	"pcaddu18i":    {0x1e000000, la64FmtRdSi20},
+	"pcalau12i":    {0x1a000000, la64FmtRdSi20},
+	"movcf2fr":     {0x0114d400, la64FmtFdCj},
+	"movcf2gr":     {0x0114dc00, la64FmtRdCj},
+	"movfcsr2gr":   {0x0114c800, la64FmtRdFcsrJ},
+	"movfr2cf":     {0x0114d000, la64FmtCdFj},
+	"movfr2gr.d":   {0x0114b800, la64FmtRdFj},
+	"movfr2gr.s":   {0x0114b400, la64FmtRdFj},
+	"movfrh2gr.s":  {0x0114bc00, la64FmtRdFj},
+	"movgr2cf":     {0x0114d800, la64FmtCdRj},
+	"movgr2fcsr":   {0x0114c000, la64FmtFcsrDRj},
+	"movgr2frh.w":  {0x0114ac00, la64FmtFdRj},
+	"movgr2fr.d":   {0x0114a800, la64FmtFdRj},
+	"movgr2fr.w":   {0x0114a400, la64FmtFdRj},
+	"preld":        {0x2ac00000, la64FmtHint5RjSi12},
+	"preldx":       {0x382c0000, la64FmtHint5RjRk},
+	"rotri.d":      {0x004d0000, la64FmtRdRjUi6},
+	"slli.d":       {0x00410000, la64FmtRdRjUi6},
+	"srai.d":       {0x00490000, la64FmtRdRjUi6},
+	"srli.d":       {0x00450000, la64FmtRdRjUi6},
+	"rotri.w":      {0x004c8000, la64FmtRdRjUi5},
+	"slli.w":       {0x00408000, la64FmtRdRjUi5},
+	"srai.w":       {0x00488000, la64FmtRdRjUi5},
+	"srli.w":       {0x00448000, la64FmtRdRjUi5},
+}
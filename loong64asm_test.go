@@ -0,0 +1,246 @@
+package main
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// llvm-mc 14 has no loongarch, the encodings are from the go assembler
+// tests in cmd/asm/internal/asm/testdata/loong64enc*.s. the branch targets
+// are absolute at address 0.
+var loong64AsmTests = []struct {
+	mnemo, opers string
+	want         uint32
+}{
+	{"nop", "", 0x03400000},
+	{"andi", "$zero, $zero, 0", 0x03400000},
+	{"move", "$r5, $r4", 0x00150085},
+	{"or", "$a1, $a0, $zero", 0x00150085},
+	{"syscall", "0", 0x002b0000},
+	{"break", "0", 0x002a0000},
+	{"dbar", "0", 0x38720000},
+
+	// the integer operations
+	{"add.w", "$r6, $r5, $r4", 0x001010a6},
+	{"add.d", "$r6, $r5, $r4", 0x001090a6},
+	{"sub.w", "$r6, $r5, $r4", 0x001110a6},
+	{"sub.d", "$r6, $r5, $r4", 0x001190a6},
+	{"sub.d", "$r5, $zero, $r4", 0x00119005},
+	{"and", "$r6, $r5, $r4", 0x001490a6},
+	{"andn", "$r6, $r5, $r4", 0x001690a6},
+	{"orn", "$r6, $r5, $r4", 0x001610a6},
+	{"sll.w", "$r6, $r5, $r4", 0x001710a6},
+	{"sll.w", "$r5, $r4, $zero", 0x00170085},
+	{"srl.w", "$r6, $r5, $r4", 0x001790a6},
+	{"sra.w", "$r6, $r5, $r4", 0x001810a6},
+	{"sll.d", "$r6, $r5, $r4", 0x001890a6},
+	{"srl.d", "$r6, $r5, $r4", 0x001910a6},
+	{"rotr.w", "$r6, $r5, $r4", 0x001b10a6},
+	{"rotr.d", "$r6, $r5, $r4", 0x001b90a6},
+	{"maskeqz", "$r6, $r5, $r4", 0x001310a6},
+	{"masknez", "$r6, $r5, $r4", 0x001390a6},
+	{"mul.w", "$r6, $r5, $r4", 0x001c10a6},
+	{"mulh.w", "$r6, $r5, $r4", 0x001c90a6},
+	{"mulh.wu", "$r6, $r5, $r4", 0x001d10a6},
+	{"mul.d", "$r6, $r5, $r4", 0x001d90a6},
+	{"mulh.d", "$r6, $r5, $r4", 0x001e10a6},
+	{"div.w", "$r6, $r5, $r4", 0x002010a6},
+	{"mod.w", "$r6, $r5, $r4", 0x002090a6},
+	{"mod.wu", "$r6, $r5, $r4", 0x002190a6},
+	{"mod.d", "$r6, $r5, $r4", 0x002290a6},
+
+	{"clo.w", "$r5, $r4", 0x00001085},
+	{"clz.w", "$r5, $r4", 0x00001485},
+	{"cto.w", "$r5, $r4", 0x00001885},
+	{"ctz.w", "$r5, $r4", 0x00001c85},
+	{"clo.d", "$r5, $r4", 0x00002085},
+	{"clz.d", "$r5, $r4", 0x00002485},
+	{"cto.d", "$r5, $r4", 0x00002885},
+	{"ctz.d", "$r5, $r4", 0x00002c85},
+	{"revb.2h", "$r5, $r4", 0x00003085},
+	{"revb.4h", "$r5, $r4", 0x00003485},
+	{"revb.2w", "$r5, $r4", 0x00003885},
+	{"revb.d", "$r5, $r4", 0x00003c85},
+	{"revh.2w", "$r5, $r4", 0x00004085},
+	{"revh.d", "$r5, $r4", 0x00004485},
+	{"bitrev.4b", "$r5, $r4", 0x00004885},
+	{"bitrev.8b", "$r5, $r4", 0x00004c85},
+	{"bitrev.w", "$r5, $r4", 0x00005085},
+	{"bitrev.d", "$r5, $r4", 0x00005485},
+	{"ext.w.h", "$r5, $r4", 0x00005885},
+	{"ext.w.b", "$r5, $r4", 0x00005c85},
+	{"bstrpick.d", "$r5, $r4, 31, 0", 0x00df0085},
+
+	// the immediates
+	{"addi.w", "$r5, $r4, -1", 0x02bffc85},
+	{"addi.d", "$r5, $r4, -1", 0x02fffc85},
+	{"addi.d", "$r5, $r4, 4", 0x02c01085},
+	{"addi.d", "$a0, $zero, -2015", 0x02e08404},
+	{"andi", "$r5, $r4, 1", 0x03400485},
+	{"andi", "$r5, $r4, 255", 0x0343fc85},
+	{"ori", "$r4, $zero, 1", 0x03800404},
+	{"ori", "$r4, $zero, 0x821", 0x03a08404},
+	{"slli.w", "$r5, $r4, 4", 0x00409085},
+	{"srli.w", "$r5, $r4, 4", 0x00449085},
+	{"srai.w", "$r5, $r4, 4", 0x00489085},
+	{"rotri.w", "$r5, $r4, 4", 0x004c9085},
+	{"slli.d", "$r5, $r4, 4", 0x00411085},
+	{"srli.d", "$r5, $r4, 4", 0x00451085},
+	{"srli.d", "$r5, $r4, 32", 0x00458085},
+	{"rotri.d", "$r5, $r4, 4", 0x004d1085},
+	{"addu16i.d", "$r5, $r4, -32768", 0x12000085},
+	{"addu16i.d", "$r5, $r4, 8", 0x10002085},
+	{"addu16i.d", "$r5, $r4, 32767", 0x11fffc85},
+	{"lu12i.w", "$r4, 1", 0x14000024},
+	{"lu12i.w", "$r4, 16", 0x14000204},
+	{"lu12i.w", "$r4, 0x54321", 0x14a86424},
+	{"lu12i.w", "$r4, -507089", 0x150865e4},
+	{"lu32i.d", "$r4, 0x12345", 0x162468a4},
+	{"lu32i.d", "$r4, -524288", 0x17000004},
+	{"lu52i.d", "$r4, $zero, 0x7a9", 0x031ea404},
+	{"lu52i.d", "$r4, $r4, 0x273", 0x0309cc84},
+
+	// the memory
+	{"ld.b", "$r4, $r5, 1", 0x280004a4},
+	{"ld.w", "$r4, $r5, 1", 0x288004a4},
+	{"ld.d", "$r4, $r5, 1", 0x28c004a4},
+	{"ld.bu", "$r4, $r5, 1", 0x2a0004a4},
+	{"ld.wu", "$r4, $r5, 1", 0x2a8004a4},
+	{"st.b", "$r4, $r5, 1", 0x290004a4},
+	{"st.w", "$r4, $r5, 1", 0x298004a4},
+	{"st.d", "$r4, $r5, 1", 0x29c004a4},
+	{"ld.d", "$a0, $sp, 24", 0x28c06064},
+	{"st.d", "$a0, $sp, 32", 0x29c08064},
+	{"fld.s", "$f4, $r5, 1", 0x2b0004a4},
+	{"fld.d", "$f4, $r5, 1", 0x2b8004a4},
+	{"fst.s", "$f4, $r5, 1", 0x2b4004a4},
+	{"fst.d", "$f4, $r5, 1", 0x2bc004a4},
+	{"ll.w", "$r4, $r5, 4096", 0x201000a4},
+	{"sc.w", "$r4, $r5, 4096", 0x211000a4},
+	{"ll.d", "$r4, $r5, 4096", 0x221000a4},
+	{"sc.d", "$r4, $r5, 4096", 0x231000a4},
+	{"amswap.w", "$r12, $r14, $r13", 0x386039ac},
+	{"amswap.d", "$r12, $r14, $r13", 0x3860b9ac},
+	{"amadd.w", "$r12, $r14, $r13", 0x386139ac},
+	{"amadd.d", "$r12, $r14, $r13", 0x3861b9ac},
+	{"amand.w", "$r12, $r14, $r13", 0x386239ac},
+	{"amor.d", "$r12, $r14, $r13", 0x3863b9ac},
+	{"amxor.w", "$r12, $r14, $r13", 0x386439ac},
+	{"ammax.w", "$r12, $r14, $r13", 0x386539ac},
+	{"ammin.d", "$r12, $r14, $r13", 0x3866b9ac},
+	{"ammax.wu", "$r12, $r14, $r13", 0x386739ac},
+	{"ammin.wu", "$r12, $r14, $r13", 0x386839ac},
+
+	// the branches
+	{"b", "4", 0x50000400},
+	{"jirl", "$zero, $r4, 0", 0x4c000080},
+	{"jr", "$r4", 0x4c000080},
+	{"beq", "$r4, $r5, 4", 0x58000485},
+	{"bne", "$r4, $r5, 4", 0x5c000485},
+	{"bltu", "$r4, $zero, 4", 0x68000480},
+	{"beqz", "$r4, 4", 0x40000480},
+	{"bnez", "$r4, 4", 0x44000480},
+	{"bceqz", "$fcc0, 4", 0x48000400},
+	{"bcnez", "$fcc0, 4", 0x48000500},
+
+	// the floats
+	{"fadd.s", "$f6, $f5, $f4", 0x010090a6},
+	{"fmax.s", "$f6, $f5, $f4", 0x010890a6},
+	{"fmax.d", "$f6, $f5, $f4", 0x010910a6},
+	{"fmin.s", "$f6, $f5, $f4", 0x010a90a6},
+	{"fmin.d", "$f6, $f5, $f4", 0x010b10a6},
+	{"fmaxa.s", "$f6, $f5, $f4", 0x010c90a6},
+	{"fmina.d", "$f6, $f5, $f4", 0x010f10a6},
+	{"fcopysign.s", "$f6, $f5, $f4", 0x011290a6},
+	{"fcopysign.d", "$f6, $f5, $f4", 0x011310a6},
+	{"fabs.s", "$f5, $f4", 0x01140485},
+	{"fabs.d", "$f5, $f4", 0x01140885},
+	{"fneg.s", "$f5, $f4", 0x01141485},
+	{"fneg.d", "$f5, $f4", 0x01141885},
+	{"fclass.s", "$f5, $f4", 0x01143485},
+	{"fclass.d", "$f5, $f4", 0x01143885},
+	{"fsqrt.s", "$f5, $f4", 0x01144485},
+	{"fsqrt.d", "$f5, $f4", 0x01144885},
+	{"fmov.s", "$f5, $f4", 0x01149485},
+	{"fmov.d", "$f5, $f4", 0x01149885},
+	{"movgr2fr.w", "$f5, $r4", 0x0114a485},
+	{"movgr2fr.d", "$f5, $r4", 0x0114a885},
+	{"movfr2gr.s", "$r5, $f4", 0x0114b485},
+	{"movfr2gr.d", "$r5, $f4", 0x0114b885},
+	{"ftintrm.w.s", "$f2, $f0", 0x011a0402},
+	{"ftintrp.w.d", "$f2, $f0", 0x011a4802},
+	{"ftintrz.w.s", "$f5, $f4", 0x011a8485},
+	{"ftintrz.w.d", "$f5, $f4", 0x011a8885},
+	{"ftintrne.w.d", "$f2, $f0", 0x011ac802},
+	{"ftint.w.s", "$f1, $f0", 0x011b0401},
+	{"ftint.w.d", "$f1, $f0", 0x011b0801},
+	{"ffint.s.w", "$f1, $f0", 0x011d1001},
+	{"ffint.s.l", "$f1, $f0", 0x011d1801},
+	{"ffint.d.w", "$f1, $f0", 0x011d2001},
+	{"ffint.d.l", "$f1, $f0", 0x011d2801},
+	{"frint.s", "$f1, $f0", 0x011e4401},
+	{"frint.d", "$f1, $f0", 0x011e4801},
+	{"fmadd.s", "$f16, $f9, $f14, $f2", 0x08113930},
+	{"fmadd.d", "$f12, $f23, $f20, $f11", 0x0825d2ec},
+	{"fmsub.s", "$f22, $f31, $f11, $f3", 0x0851aff6},
+	{"fmsub.d", "$f15, $f9, $f30, $f13", 0x0866f92f},
+	{"fnmadd.s", "$f21, $f5, $f11, $f27", 0x089dacb5},
+	{"fnmadd.d", "$f6, $f27, $f14, $f29", 0x08aebb66},
+	{"fnmsub.s", "$f8, $f12, $f8, $f17", 0x08d8a188},
+	{"fnmsub.d", "$f17, $f3, $f21, $f29", 0x08eed471},
+	{"fcmp.ceq.s", "$fcc0, $f5, $f4", 0x0c1210a0},
+	{"fcmp.slt.s", "$fcc1, $f5, $f4", 0x0c1190a1},
+	{"fcmp.slt.d", "$fcc2, $f5, $f4", 0x0c2190a2},
+	{"fcmp.sle.s", "$fcc3, $f5, $f4", 0x0c1390a3},
+	{"fcmp.sle.d", "$fcc4, $f5, $f4", 0x0c2390a4},
+	{"fcmp.ceq.d", "$fcc5, $f5, $f4", 0x0c2210a5},
+	{"fsel", "$f3, $f2, $f1, $fcc0", 0x0d000443},
+	{"fsel", "$f2, $f2, $f1, $fcc1", 0x0d008442},
+}
+
+func TestLoong64Asm(t *testing.T) {
+	for _, v := range loong64AsmTests {
+		data, err := loong64Asm(v.mnemo, v.opers, 0)
+		if err != nil {
+			t.Errorf("%s %s: %v", v.mnemo, v.opers, err)
+			continue
+		}
+		if len(data) != 4 {
+			t.Errorf("%s %s = %x, want one instruction", v.mnemo, v.opers, data)
+			continue
+		}
+		if got := binary.LittleEndian.Uint32(data); got != v.want {
+			t.Errorf("%s %s = %#08x, want %#08x", v.mnemo, v.opers, got, v.want)
+		}
+	}
+}
+
+func TestLoong64AsmCall36(t *testing.T) {
+	// pcaddu18i $ra, 1; jirl $ra, $ra, -4
+	data, err := loong64Asm("call36", "0x40ffc", 0x1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []uint32{0x1e000021, 0x4ffffc21}
+	for i, v := range want {
+		if got := binary.LittleEndian.Uint32(data[i*4:]); got != v {
+			t.Errorf("%d = %#08x, want %#08x", i, got, v)
+		}
+	}
+}
+
+func TestLoong64AsmError(t *testing.T) {
+	for _, v := range []struct{ mnemo, opers string }{
+		{"addi.d", "$r5, $r4, 2048"},
+		{"add.d", "$r6, $r5"},
+		{"add.d", "$r6, $r5, $r32"},
+		{"ld.d", "$f4, $r5, 1"},
+		{"b", "2"},
+		{"ll.w", "$r4, $r5, 2"},
+		{"frob", "$r4"},
+	} {
+		if data, err := loong64Asm(v.mnemo, v.opers, 0); err == nil {
+			t.Errorf("%s %s = %x, want error", v.mnemo, v.opers, data)
+		}
+	}
+}
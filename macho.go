@@ -0,0 +1,119 @@
+package main
+
+import (
+	"debug/macho"
+	"fmt"
+	"io"
+	"strings"
+)
+
+func machoParse(r io.ReaderAt, arch Arch) (p *Prog, err error) {
+	oa, ok := arch.(objArch)
+	if !ok {
+		err = fmt.Errorf("object file is unsupported by arch")
+		return
+	}
+
+	f, err := macho.NewFile(r)
+	if err != nil {
+		return
+	}
+	if f.Type != macho.TypeObj {
+		err = fmt.Errorf("not a relocatable object: %s", f.Type)
+		return
+	}
+	if f.Cpu != oa.MachoCpu() {
+		err = fmt.Errorf("unexpected cpu: %s", f.Cpu)
+		return
+	}
+
+	var secs []*objSection
+	// section number starts from 1
+	idx := make(map[uint8]*objSection)
+	orig := make(map[*objSection]int64)
+	for i, v := range f.Sections {
+		if v.Size == 0 {
+			continue
+		}
+		switch {
+		case v.Seg == "__TEXT" && (v.Name == "__text" || v.Name == "__const" || v.Name == "__cstring" ||
+			strings.HasPrefix(v.Name, "__literal")):
+		case strings.HasPrefix(v.Seg, "__DATA"):
+			err = fmt.Errorf("unsupported section: %s,%s", v.Seg, v.Name)
+			return
+		default:
+			// __LD,__compact_unwind
+			continue
+		}
+
+		sec := &objSection{
+			name:  v.Seg + "," + v.Name,
+			code:  v.Name == "__text",
+			align: 1 << v.Align,
+		}
+		if sec.data, err = v.Data(); err != nil {
+			return
+		}
+		secs = append(secs, sec)
+		idx[uint8(i+1)] = sec
+		orig[sec] = int64(v.Addr)
+	}
+
+	entry, err := arch.EntryBlock()
+	if err != nil {
+		return
+	}
+	objLayout(entry.Size(), secs)
+
+	var msyms []macho.Symbol
+	if f.Symtab != nil {
+		msyms = f.Symtab.Syms
+	}
+	var syms, tmps []objSymbol
+	for _, v := range msyms {
+		// N_STAB, N_SECT
+		if v.Type&0xe0 != 0 || v.Type&0x0e != 0x0e {
+			continue
+		}
+		if sec := idx[v.Sect]; sec != nil {
+			// N_EXT
+			sym := objSymbol{name: v.Name, sec: sec, off: int64(v.Value) - orig[sec], global: v.Type&0x01 != 0}
+			// the section symbols, bind first to leave the name to the others
+			if strings.HasPrefix(v.Name, "ltmp") {
+				tmps = append(tmps, sym)
+			} else {
+				syms = append(syms, sym)
+			}
+		}
+	}
+	syms = append(tmps, syms...)
+
+	symAddr := func(r macho.Reloc) (_ int64, err error) {
+		if !r.Extern || int(r.Value) >= len(msyms) {
+			err = fmt.Errorf("unsupported relocation: %d", r.Value)
+			return
+		}
+		sym := msyms[r.Value]
+		sec := idx[sym.Sect]
+		if sec == nil {
+			if sym.Type&0x0e == 0 {
+				err = fmt.Errorf("undefined symbol: %s", sym.Name)
+			} else {
+				err = fmt.Errorf("symbol in unsupported section: %s", sym.Name)
+			}
+			return
+		}
+		return sec.addr + int64(sym.Value) - orig[sec], nil
+	}
+
+	for i, v := range f.Sections {
+		if sec := idx[uint8(i+1)]; sec != nil {
+			if err = oa.MachoReloc(sec, v.Relocs, symAddr); err != nil {
+				err = fmt.Errorf("%s: %w", sec.name, err)
+				return
+			}
+		}
+	}
+
+	return objProg(oa, entry, secs, syms, "_")
+}
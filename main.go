@@ -1,9 +1,12 @@
 package main
 
 import (
+	"bytes"
+	"flag"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
 )
 
@@ -18,8 +21,16 @@ const (
 	InstrKind_Ret
 	InstrKind_Data
 	InstrKind_P2Align
+	// the target is in a register
+	InstrKind_Indirect_Jmp
+	InstrKind_Indirect_Call
 )
 
+// endsBB reports whether the instruction ends the basic block.
+func (k InstrKind) endsBB() bool {
+	return k == InstrKind_Jmp || k == InstrKind_Cond_Jmp || k == InstrKind_Ret || k == InstrKind_Indirect_Jmp
+}
+
 type Instr interface {
 	Kind() InstrKind
 
@@ -29,12 +40,19 @@ type Instr interface {
 	Operands() string
 
 	LabelOperand() *LabelOperand // first
+	LabelOperands() []LabelOperand
 	LabelNames() string
 
 	Byte() []byte
 	Size() int64
 	Rebuild() (int64, error) // return size diff
 	SPDiff() int64
+	// sp is set by a register amount, alloca
+	DynSP() bool
+
+	// the source line, empty in the object files
+	Pos() srcPos
+	SetPos(pos srcPos)
 }
 
 type BasicBlock struct {
@@ -61,6 +79,8 @@ func (bb *BasicBlock) Size() (ret int64) {
 type Label struct {
 	ID string
 	BB *BasicBlock
+	// the first use
+	ref srcPos
 }
 
 func (lbl *Label) Bind(bb *BasicBlock) {
@@ -71,23 +91,40 @@ func (lbl *Label) Bind(bb *BasicBlock) {
 type LabelOperand struct {
 	lbl  *Label
 	oper func(int64) int64
+	// load from the got, there is no got, the address is used directly
+	got bool
 }
 
 func newLabelOperand(name string, getLabel func(name string) *Label) LabelOperand {
+	page := func(x int64) int64 { return x &^ 4095 }
+	pageOff := func(x int64) int64 { return x & 4095 }
+
 	var oper func(int64) int64
+	var got bool
 	switch {
+	// darwin
 	case strings.HasSuffix(name, "@PAGE"):
-		name = name[:len(name)-5]
-		oper = func(x int64) int64 { return x &^ 4095 }
+		name, oper = name[:len(name)-5], page
 	case strings.HasSuffix(name, "@PAGEOFF"):
-		name = name[:len(name)-8]
-		oper = func(x int64) int64 { return x & 4095 }
+		name, oper = name[:len(name)-8], pageOff
+	case strings.HasSuffix(name, "@GOTPAGE"):
+		name, oper = name[:len(name)-8], page
+	case strings.HasSuffix(name, "@GOTPAGEOFF"):
+		name, oper, got = name[:len(name)-11], pageOff, true
+	// gnu
+	case strings.HasPrefix(name, ":lo12:"):
+		name, oper = name[6:], pageOff
+	case strings.HasPrefix(name, ":got:"):
+		name, oper = name[5:], page
+	case strings.HasPrefix(name, ":got_lo12:"):
+		name, oper, got = name[10:], pageOff, true
 	default:
 		oper = func(x int64) int64 { return x }
 	}
 	return LabelOperand{
 		lbl:  getLabel(name),
 		oper: oper,
+		got:  got,
 	}
 }
 
@@ -107,9 +144,10 @@ func (o LabelOperand) ID() string {
 }
 
 type Arch interface {
-	CommentToken() string
+	CommentTokens() []string
 	Instr(ea int64, mnemo, opers string, los []LabelOperand) (Instr, error)
 	Close()
+	Prefetch(ins []asmInput)
 	EntryBlock() (*BasicBlock, error)
 	WriteProg(w io.Writer, p *Prog) error
 	WriteFunc(w io.Writer, f *Function, spsize, fpos int64) error
@@ -117,46 +155,296 @@ type Arch interface {
 	SubrEntry(w io.Writer) (string, error)
 }
 
-func fatalError(err error) {
+var archs = map[string]func(as Assembler, goos string) (Arch, error){
+	"arm64":   func(as Assembler, goos string) (Arch, error) { return newArm64(as, goos) },
+	"amd64":   func(as Assembler, goos string) (Arch, error) { return newAmd64(as, goos) },
+	"riscv64": func(as Assembler, goos string) (Arch, error) { return newRiscv64(as) },
+	"ppc64le": func(as Assembler, goos string) (Arch, error) { return newPpc64le(as) },
+	"loong64": func(as Assembler, goos string) (Arch, error) { return newLoong64(as) },
+	"s390x":   func(as Assembler, goos string) (Arch, error) { return newS390x(as) },
+	"arm":     func(as Assembler, goos string) (Arch, error) { return newArm(as) },
+}
+
+// windowsArchs follow the windows abi, the others are the same on all the
+// unix-like os.
+var windowsArchs = map[string]bool{
+	"amd64": true,
+	"arm64": true,
+}
+
+func newArch(goos, goarch, asmName string) (arch Arch, err error) {
+	// compatible with old naming
+	if goarch == "" {
+		goarch = "arm64"
+	}
+	fn, ok := archs[goarch]
+	if !ok {
+		err = fmt.Errorf("unsupported arch: %s", goarch)
+		return
+	}
+	if goos == "windows" && !windowsArchs[goarch] {
+		err = fmt.Errorf("unsupported arch on windows: %s", goarch)
+		return
+	}
+	as, err := newAssembler(asmName, goarch)
 	if err != nil {
-		panic(err)
+		return
 	}
+	if arch, err = fn(as, goos); err != nil {
+		as.Close()
+	}
+	return
 }
 
-func main() {
-	if len(os.Args) < 3 {
-		fmt.Fprintf(os.Stderr, "* usage: %s <output-file> <clang-asm> ...\n", os.Args[0])
+// jsonDiags prints the errors as json lines to stdout.
+var jsonDiags bool
+
+func fatalError(err error) {
+	if err == nil {
 		return
 	}
-	ofile, ifile := os.Args[1], os.Args[2]
+	if jsonDiags {
+		writeDiags(os.Stdout, err, true)
+	} else {
+		writeDiags(os.Stderr, err, false)
+	}
+	os.Exit(1)
+}
 
-	ifp, err := os.Open(ifile)
-	fatalError(err)
-	defer ifp.Close()
+// job is one translation, the empty fields are taken from the file names.
+type job struct {
+	ofile, subrFile string
+	ifile, gfile    string
+	goos, goarch    string
+	// override the package of the prototypes
+	pkg string
+	// the extra build constraint
+	tags    string
+	asmName string
+	// the clang executable of units
+	clang string
+	// picked at runtime by the cpu features
+	variants []asmVariant
+	// the text assembly instead of ifile, see build
+	units []asmUnit
+	// the comment in the header
+	note string
+	// compare with the outputs instead of writing
+	check bool
+	// from the configuration
+	symbols      []symbolRule
+	stacks       map[string]int64
+	nativeStacks []string
+	forbid       []string
+}
 
-	arch, err := newArm64()
-	fatalError(err)
+// fill takes the defaults from ofile, the target not in the file name goes
+// to the tags.
+func (j *job) fill() error {
+	if j.gfile == "" {
+		if j.ofile == "" {
+			return fmt.Errorf("need the output file or the prototypes")
+		}
+		j.gfile = strings.TrimSuffix(j.ofile, filepath.Ext(j.ofile)) + ".go"
+	}
+	if j.ofile != "" && j.subrFile == "" {
+		j.subrFile = subrFileName(j.ofile)
+	}
+
+	goos, goarch := fileNameTarget(j.ofile)
+	var tags []string
+	for _, v := range []struct{ flag, name, fname string }{
+		{"goos", j.goos, goos},
+		{"arch", j.goarch, goarch},
+	} {
+		switch {
+		case v.name == "":
+		case v.fname == "":
+			tags = append(tags, v.name)
+		case v.fname != v.name:
+			return fmt.Errorf("-%s %s conflicts with the file name: %s", v.flag, v.name, v.fname)
+		}
+	}
+	if j.goos == "" {
+		j.goos = goos
+	}
+	if j.goarch == "" {
+		j.goarch = goarch
+	}
+	// compatible with old naming
+	if j.goarch == "" {
+		j.goarch = "arm64"
+	}
+	if j.tags != "" {
+		tags = append(tags, j.tags)
+	}
+	j.tags = strings.Join(tags, ",")
+	return nil
+}
+
+// load parses the input and the prototypes, arch must be closed.
+func (j *job) load() (p *Prog, arch Arch, funcs Functions, pkg string, err error) {
+	seen := make(map[string]bool)
+	for i, v := range j.variants {
+		if seen[v.Name()] {
+			err = fmt.Errorf("duplicate variant: %s", v)
+			return
+		}
+		seen[v.Name()] = true
+		if j.variants[i].cond, err = v.goCond(j.goarch); err != nil {
+			return
+		}
+	}
+
+	if arch, err = newArch(j.goos, j.goarch, j.asmName); err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			arch.Close()
+		}
+	}()
+
+	if len(j.units) > 0 {
+		if len(j.variants) > 0 {
+			err = fmt.Errorf("variants need the clang output files")
+			return
+		}
+		p, err = unitsParse(j.units, arch)
+	} else {
+		p, err = fileParse(j.ifile, arch, j.variants)
+	}
+	if err != nil {
+		return
+	}
+	if err = j.checkForbidden(p); err != nil {
+		return
+	}
+	if funcs, pkg, err = protoParse(j.gfile, archPtrSize(j.goarch)); err != nil {
+		return
+	}
+	j.applyFuncs(funcs)
+	for _, f := range funcs {
+		if f.NativeStack && j.goarch != "amd64" && j.goarch != "arm64" {
+			err = newDiag(DiagKind_Error, f.Pos, fmt.Errorf("native stack is unsupported on %s: %s", j.goarch, f.Name))
+			return
+		}
+	}
+	if j.pkg != "" {
+		pkg = j.pkg
+	}
+	return
+}
+
+// render writes the go assembly to w and the subr to sw.
+func (j *job) render(w, sw io.Writer, p *Prog, arch Arch, funcs Functions, pkg string) (err error) {
+	if err = writeGoASM(w, p, funcs, arch, j.tags, j.note); err != nil {
+		return
+	}
+	return writeSubr(sw, p, funcs, pkg, arch, j.tags, j.note)
+}
+
+// translate writes the output file and its subr file from the clang output,
+// or compares them in check.
+func translate(j *job) (p *Prog, err error) {
+	if err = j.fill(); err != nil {
+		return
+	}
+	if j.ofile == "" {
+		err = fmt.Errorf("need the output file")
+		return
+	}
+	p, arch, funcs, pkg, err := j.load()
+	if err != nil {
+		return
+	}
 	defer arch.Close()
 
-	p, err := asmParse(ifp, arch)
-	fatalError(err)
-	fatalError(p.Rebuild())
+	var obuf, sbuf bytes.Buffer
+	if err = j.render(&obuf, &sbuf, p, arch, funcs, pkg); err != nil {
+		return
+	}
 
-	idx := strings.LastIndexByte(ofile, '.')
-	gfile := ofile[:idx+1] + "go"
+	if j.check {
+		var diags Diagnostics
+		for _, v := range []struct {
+			fpath string
+			data  []byte
+		}{
+			{j.ofile, obuf.Bytes()},
+			{j.subrFile, sbuf.Bytes()},
+		} {
+			if d := checkOutput(v.fpath, v.data); d != nil {
+				diags = append(diags, d)
+			}
+		}
+		err = diags.err()
+		return
+	}
 
-	funcs, pkg, err := protoParse(gfile)
-	fatalError(err)
-	ofp, err := os.Create(ofile)
-	fatalError(err)
-	defer ofp.Close()
+	if err = os.WriteFile(j.ofile, obuf.Bytes(), 0666); err != nil {
+		return
+	}
+	err = os.WriteFile(j.subrFile, sbuf.Bytes(), 0666)
+	return
+}
+
+func main() {
+	if len(os.Args) > 1 {
+		if _, ok := commands[os.Args[1]]; ok {
+			fatalError(runCommand(os.Args[1], os.Args[2:]))
+			return
+		}
+	}
 
-	fatalError(writeGoASM(ofp, p, funcs, arch))
+	asmName := flag.String("asm", "", "assembler backend: "+assemblerNames()+" (default go if the arch has one)")
+	flag.StringVar(&llvmMCPath, "llvm-mc", llvmMCPath, "llvm-mc executable used by -asm llvm-mc")
+	multi := flag.Bool("multi", false, "translate the clang outputs of many targets with the same prototypes")
+	check := flag.Bool("check", false, "compare with the outputs instead of writing, fail with the diff")
+	cfg := flag.String("config", "", "project configuration (default "+configName+" without arguments)")
+	flag.BoolVar(&jsonDiags, "json", false, "print the diagnostics as json lines to stdout")
+	flag.Parse()
 
-	subr := subrFileName(ofile)
-	sfp, err := os.Create(subr)
-	fatalError(err)
-	defer sfp.Close()
+	// nocgo alone reproduces the build of nocgo.json
+	if *cfg == "" && flag.NArg() == 0 {
+		if fileExists(configName) {
+			*cfg = configName
+		}
+	}
+	if *cfg != "" {
+		if flag.NArg() > 0 {
+			fatalError(fmt.Errorf("unexpected arguments with -config: %s", strings.Join(flag.Args(), " ")))
+		}
+		fatalError(runConfig(*cfg, *check))
+		return
+	}
 
-	fatalError(writeSubr(sfp, p, funcs, pkg, arch))
+	// go:generate nocgo ./...
+	if flag.NArg() > 0 && allPkgPatterns(flag.Args()) {
+		fatalError(generate(flag.Args(), nil, *asmName, *check))
+		return
+	}
+
+	if flag.NArg() < 2 {
+		fmt.Fprintf(os.Stderr, "* usage: %s <command> [flags] ..., the commands are %s\n", os.Args[0], commandNames())
+		fmt.Fprintf(os.Stderr, "         %s [-asm name] <output-file> <clang-asm> [feature[+feature]=clang-asm] ...\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "         %s [-asm name] -multi <prototypes> <[goos_]goarch=clang-asm> ...\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "         %s [-asm name] <dir | dir/...> ...\n", os.Args[0])
+		return
+	}
+
+	if *multi {
+		fatalError(multiTranslate(flag.Arg(0), flag.Args()[1:], *asmName, *check))
+		return
+	}
+
+	j := &job{ofile: flag.Arg(0), ifile: flag.Arg(1), asmName: *asmName, check: *check}
+	for _, v := range flag.Args()[2:] {
+		variant, err := parseVariant(v)
+		fatalError(err)
+		j.variants = append(j.variants, variant)
+	}
+
+	_, err := translate(j)
+	fatalError(err)
 }
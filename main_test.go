@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden outputs of testdata")
+
+// TestTranslate translates testdata/translate/<target>/foo.s, the llc -O2
+// output of foo.ll, and compares with foo_<target>.s and its subr.
+func TestTranslate(t *testing.T) {
+	inputs, err := filepath.Glob("testdata/translate/*/foo.s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range inputs {
+		dir := filepath.Dir(v)
+		goarch := filepath.Base(dir)
+		t.Run(goarch, func(t *testing.T) {
+			if defaultAssembler(goarch) == "llvm-mc" {
+				if _, err := exec.LookPath(llvmMCPath); err != nil {
+					t.Skipf("%s needs llvm-mc", goarch)
+				}
+			}
+			j := &job{
+				ofile: filepath.Join(dir, "foo_"+goarch+".s"),
+				ifile: v,
+				check: !*update,
+			}
+			if _, err := translate(j); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// TestNativeStackUnsupported marks the first prototype by
+// //nocgo:nativestack on the archs without the native stacks, translate
+// must fail on it.
+func TestNativeStackUnsupported(t *testing.T) {
+	for _, goarch := range []string{"arm", "loong64", "ppc64le", "riscv64", "s390x"} {
+		t.Run(goarch, func(t *testing.T) {
+			if defaultAssembler(goarch) == "llvm-mc" {
+				if _, err := exec.LookPath(llvmMCPath); err != nil {
+					t.Skipf("%s needs llvm-mc", goarch)
+				}
+			}
+			dir := filepath.Join("testdata/translate", goarch)
+			proto, err := os.ReadFile(filepath.Join(dir, "foo_"+goarch+".go"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			proto = bytes.Replace(proto, []byte("\nfunc "), []byte("\n//nocgo:nativestack\nfunc "), 1)
+			gfile := filepath.Join(t.TempDir(), "foo.go")
+			if err = os.WriteFile(gfile, proto, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			j := &job{
+				ofile: filepath.Join(dir, "foo_"+goarch+".s"),
+				ifile: filepath.Join(dir, "foo.s"),
+				gfile: gfile,
+				check: true,
+			}
+			if _, err = translate(j); err == nil {
+				t.Fatal("translated the native stack")
+			}
+			ds := diagsOf(err)
+			if len(ds) != 1 || ds[0].Kind != DiagKind_Error || !strings.Contains(ds[0].Err.Error(), "native stack is unsupported") {
+				t.Fatalf("got %v, want the native stack error", err)
+			}
+		})
+	}
+}
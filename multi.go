@@ -0,0 +1,265 @@
+package main
+
+import (
+	"fmt"
+	gobuild "go/build"
+	"go/build/constraint"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// multiTarget is a clang output of one target, goos is empty for all the
+// unix-like os.
+type multiTarget struct {
+	goos, goarch string
+	ifile        string
+}
+
+func (t multiTarget) String() string {
+	if t.goos == "" {
+		return t.goarch
+	}
+	return t.goos + "_" + t.goarch
+}
+
+// expr is the build constraint of the target.
+func (t multiTarget) expr() string {
+	if t.goos == "" {
+		return t.goarch
+	}
+	return fmt.Sprintf("(%s && %s)", t.goos, t.goarch)
+}
+
+// parseTarget takes [goos_]goarch.
+func parseTarget(target string) (t multiTarget, err error) {
+	if i := strings.IndexByte(target, '_'); i != -1 {
+		t.goos, t.goarch = target[:i], target[i+1:]
+	} else {
+		t.goarch = target
+	}
+	if (t.goos != "" && !KnownOS[t.goos]) || !KnownArch[t.goarch] {
+		err = fmt.Errorf("unknown target: %s, use [goos_]goarch", target)
+	}
+	return
+}
+
+// parseMultiTarget takes [goos_]goarch=file, or the target in the file name.
+func parseMultiTarget(arg string) (t multiTarget, err error) {
+	var target string
+	if idx := strings.IndexByte(arg, '='); idx != -1 {
+		target, t.ifile = arg[:idx], arg[idx+1:]
+		if i := strings.IndexByte(target, '_'); i != -1 {
+			t.goos, t.goarch = target[:i], target[i+1:]
+		} else {
+			t.goarch = target
+		}
+	} else {
+		t.ifile = arg
+		t.goos, t.goarch = fileNameTarget(arg)
+	}
+
+	if (t.goos != "" && !KnownOS[t.goos]) || !KnownArch[t.goarch] {
+		err = fmt.Errorf("unknown target of %s, use [goos_]goarch=file", arg)
+	}
+	return
+}
+
+// unixOS are the goos satisfying the unix tag, like go/build.
+var unixOS = map[string]bool{
+	"aix":       true,
+	"android":   true,
+	"darwin":    true,
+	"dragonfly": true,
+	"freebsd":   true,
+	"hurd":      true,
+	"illumos":   true,
+	"ios":       true,
+	"linux":     true,
+	"netbsd":    true,
+	"openbsd":   true,
+	"solaris":   true,
+}
+
+// buildTag reports whether tag is satisfied on goos/goarch, it mirrors the
+// matching of go/build without cgo, the cross builds have none.
+func buildTag(goos, goarch, tag string) bool {
+	switch {
+	case tag == goos, tag == goarch, tag == "gc":
+		return true
+	case tag == "unix":
+		return unixOS[goos]
+	case goos == "android" && tag == "linux":
+		return true
+	case goos == "ios" && tag == "darwin":
+		return true
+	case goos == "illumos" && tag == "solaris":
+		return true
+	}
+	for _, tags := range [][]string{gobuild.Default.BuildTags, gobuild.Default.ToolTags, gobuild.Default.ReleaseTags} {
+		for _, v := range tags {
+			if v == tag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkProtoBuild checks the prototypes are only built on the targets, the
+// others have no asm for them.
+func checkProtoBuild(gfile string, targets []multiTarget) (err error) {
+	var exprs []string
+	for _, v := range targets {
+		exprs = append(exprs, v.expr())
+	}
+	want := strings.Join(exprs, " || ")
+
+	data, err := os.ReadFile(gfile)
+	if err != nil {
+		return
+	}
+	var expr constraint.Expr
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "package ") {
+			break
+		}
+		if constraint.IsGoBuild(line) {
+			if expr, err = constraint.Parse(line); err != nil {
+				return fmt.Errorf("%s: %w", gfile, err)
+			}
+		}
+	}
+	if expr == nil {
+		return fmt.Errorf("%s: need //go:build %s", gfile, want)
+	}
+
+	// sorted, the first mismatch is reported
+	for _, goos := range sortedKeys(KnownOS) {
+		for _, goarch := range sortedKeys(KnownArch) {
+			var has bool
+			for _, v := range targets {
+				if v.goarch == goarch && (v.goos == "" || v.goos == goos) {
+					has = true
+					break
+				}
+			}
+			ok := expr.Eval(func(tag string) bool {
+				return buildTag(goos, goarch, tag)
+			})
+			if has != ok {
+				not := ""
+				if has {
+					not = "not "
+				}
+				return fmt.Errorf("%s: //go:build %s is %sbuilt on %s/%s, want //go:build %s", gfile, expr, not, goos, goarch, want)
+			}
+		}
+	}
+	return
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// multiTranslate writes the files of every target next to gfile, they
+// must export the same functions.
+func multiTranslate(gfile string, args []string, asmName string, check bool) (err error) {
+	var targets []multiTarget
+	for _, v := range args {
+		t, err1 := parseMultiTarget(v)
+		if err1 != nil {
+			return err1
+		}
+		targets = append(targets, t)
+	}
+	return translateTargets(gfile, targets, func(j *job, t multiTarget) error {
+		j.ifile = t.ifile
+		j.asmName = asmName
+		j.check = check
+		return nil
+	})
+}
+
+// translateTargets is multiTranslate, setup gives the input of the target.
+func translateTargets(gfile string, targets []multiTarget, setup func(j *job, t multiTarget) error) (err error) {
+	if goos, goarch := fileNameTarget(gfile); goos != "" || goarch != "" {
+		return fmt.Errorf("%s: the prototypes are shared by the targets, drop the suffix", gfile)
+	}
+
+	seen := make(map[string]bool)
+	for _, t := range targets {
+		if seen[t.String()] {
+			return fmt.Errorf("duplicate target: %s", t)
+		}
+		seen[t.String()] = true
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i].String() < targets[j].String()
+	})
+
+	if err = checkProtoBuild(gfile, targets); err != nil {
+		return
+	}
+
+	base := strings.TrimSuffix(gfile, filepath.Ext(gfile))
+	var first []string
+	var stale Diagnostics
+	for i, v := range targets {
+		// foo_amd64.s is also built on windows, leave it to foo_windows_amd64.s
+		var tags []string
+		if v.goos == "" {
+			for _, v2 := range targets {
+				if v2.goarch == v.goarch && v2.goos != "" {
+					tags = append(tags, "!"+v2.goos)
+				}
+			}
+		}
+
+		j := &job{
+			ofile: base + "_" + v.String() + ".s",
+			gfile: gfile,
+			tags:  strings.Join(tags, ","),
+		}
+		if err = setup(j, v); err != nil {
+			return
+		}
+		p, err1 := translate(j)
+		if err1 != nil {
+			// the stale outputs of all the targets are reported
+			if ds := diagsOf(err1); j.check && isStale(ds) {
+				stale = append(stale, ds...)
+			} else if ds[0].Pos.file != "" {
+				// the diagnostics have the file already
+				return err1
+			} else {
+				return fmt.Errorf("%s: %w", v, err1)
+			}
+		}
+
+		exports := p.Exports()
+		if i == 0 {
+			first = exports
+		} else if strings.Join(exports, ",") != strings.Join(first, ",") {
+			return fmt.Errorf("%s exports %v, but %s exports %v", v, exports, targets[0], first)
+		}
+	}
+	return stale.err()
+}
+
+func isStale(ds Diagnostics) bool {
+	for _, v := range ds {
+		if v.Kind != DiagKind_Stale {
+			return false
+		}
+	}
+	return true
+}
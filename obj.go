@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"debug/elf"
+	"debug/macho"
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// objArch is implemented by the archs that take relocatable object files,
+// the bytes are used as they are, no re-assembly at all.
+type objArch interface {
+	Arch
+	// Decode classifies the instruction at the start of data, target is
+	// the branch destination or -1.
+	Decode(ea int64, data []byte) (ins *instrBase, target int64, err error)
+	ELFMachine() elf.Machine
+	// ELFReloc patches data at pc to refer addr (symbol + addend).
+	ELFReloc(typ uint32, data []byte, pc, addr int64) error
+	MachoCpu() macho.Cpu
+	// MachoReloc applies the relocations of sec, which come in pairs.
+	MachoReloc(sec *objSection, rels []macho.Reloc, symAddr func(r macho.Reloc) (int64, error)) error
+}
+
+type objSection struct {
+	name  string
+	code  bool
+	align int64
+	addr  int64
+	data  []byte
+}
+
+type objSymbol struct {
+	name   string
+	sec    *objSection
+	off    int64
+	global bool
+}
+
+func (s objSymbol) EA() int64 {
+	return s.sec.addr + s.off
+}
+
+func fileParse(fpath string, arch Arch, variants []asmVariant) (p *Prog, err error) {
+	f, err := os.Open(fpath)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	return progParse(f, arch, variants)
+}
+
+// progParse takes the relocatable object files or the text assembly, the
+// variants are text assembly only.
+func progParse(f *os.File, arch Arch, variants []asmVariant) (p *Prog, err error) {
+	defer func() {
+		err = diagFile(err, f.Name())
+	}()
+
+	magic := make([]byte, 4)
+	if n, _ := f.ReadAt(magic, 0); n == len(magic) {
+		var obj bool
+		switch {
+		case bytes.Equal(magic, []byte(elf.ELFMAG)):
+			obj = true
+			p, err = elfParse(f, arch)
+		case binary.LittleEndian.Uint32(magic) == macho.Magic64:
+			obj = true
+			p, err = machoParse(f, arch)
+		}
+		if obj {
+			if err == nil && len(variants) > 0 {
+				err = fmt.Errorf("variants need the text assembly: %s", f.Name())
+			}
+			return
+		}
+	}
+
+	if p, err = asmParse(f, f.Name(), arch); err != nil {
+		return
+	}
+	for _, v := range variants {
+		if err = p.parseVariant(v); err != nil {
+			return
+		}
+	}
+	if len(variants) > 0 {
+		if err = p.link(); err != nil {
+			return
+		}
+	}
+	err = p.Rebuild()
+	return
+}
+
+// objLayout places the sections after the entry block, code first.
+func objLayout(ea int64, secs []*objSection) {
+	sort.SliceStable(secs, func(i, j int) bool {
+		return secs[i].code && !secs[j].code
+	})
+	for _, v := range secs {
+		if !v.code {
+			// arm64 writes data by WORD
+			for len(v.data)&3 != 0 {
+				v.data = append(v.data, 0)
+			}
+		}
+		if v.align > 1 {
+			ea = (ea + v.align - 1) &^ (v.align - 1)
+		}
+		v.addr = ea
+		ea += int64(len(v.data))
+	}
+}
+
+// objProg splits the laid out sections into basic blocks.
+func objProg(arch objArch, entry *BasicBlock, secs []*objSection, syms []objSymbol, symPrefix string) (p *Prog, err error) {
+	p = &Prog{
+		lbls:      make(map[string]*Label),
+		arch:      arch,
+		bbs:       []*BasicBlock{entry},
+		symPrefix: symPrefix,
+		globals:   make(map[string]bool),
+	}
+
+	lbls := make(map[int64][]*Label)
+	for _, v := range syms {
+		lbls[v.EA()] = append(lbls[v.EA()], p.getLabel(v.name))
+		if v.global {
+			p.globals[v.name] = true
+		}
+	}
+
+	// decode first for the branch targets
+	type objInstr struct {
+		ins    *instrBase
+		target int64
+	}
+	code := make(map[*objSection][]objInstr)
+	for _, sec := range secs {
+		if !sec.code {
+			continue
+		}
+		for off := int64(0); off < int64(len(sec.data)); {
+			ins, target, err1 := arch.Decode(sec.addr+off, sec.data[off:])
+			if err1 != nil {
+				err = fmt.Errorf("%s+%d: %w", sec.name, off, err1)
+				return
+			}
+			if target != -1 && len(lbls[target]) == 0 {
+				lbls[target] = []*Label{p.getLabel("Ltmp" + strconv.FormatInt(target, 10))}
+			}
+			code[sec] = append(code[sec], objInstr{ins, target})
+			off += ins.Size()
+		}
+	}
+
+	var lastBB *BasicBlock
+	ea := entry.Size()
+	addInstr := func(instr Instr) {
+		if l := lbls[instr.EA()]; len(l) > 0 || lastBB == nil {
+			lastBB = &BasicBlock{}
+			p.bbs = append(p.bbs, lastBB)
+			for _, v := range l {
+				v.Bind(lastBB)
+			}
+		}
+		lastBB.Instrs = append(lastBB.Instrs, instr)
+		ea += instr.Size()
+
+		if instr.Kind().endsBB() {
+			lastBB = nil
+		}
+	}
+
+	for _, sec := range secs {
+		if ea != sec.addr {
+			var instr Instr
+			if instr, err = arch.Instr(ea, ".p2align", strconv.Itoa(bits.TrailingZeros64(uint64(sec.align))), nil); err != nil {
+				return
+			}
+			addInstr(instr)
+			if ea != sec.addr {
+				err = fmt.Errorf("%s: bad alignment", sec.name)
+				return
+			}
+		}
+
+		if sec.code {
+			for _, v := range code[sec] {
+				if v.target != -1 {
+					lbl := lbls[v.target][0]
+					v.ins.los = []LabelOperand{newLabelOperand(lbl.ID, p.getLabel)}
+				}
+				addInstr(v.ins)
+			}
+			continue
+		}
+
+		// data, split at the labels
+		var cuts []int64
+		for i := range sec.data {
+			if off := int64(i); off != 0 && len(lbls[sec.addr+off]) > 0 {
+				cuts = append(cuts, off)
+			}
+		}
+		cuts = append(cuts, int64(len(sec.data)))
+		var last int64
+		for _, v := range cuts {
+			addInstr(&instrBase{
+				kind:  InstrKind_Data,
+				ea:    sec.addr + last,
+				mnemo: ".byte",
+				data:  sec.data[last:v],
+			})
+			last = v
+		}
+	}
+
+	err = p.link()
+	return
+}
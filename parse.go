@@ -2,9 +2,12 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
+	"os"
 	"regexp"
+	"sort"
 	"strings"
 	"unicode"
 )
@@ -17,20 +20,124 @@ var ignoreMnemo = map[string]bool{
 	".end_data_region":         true,
 	".loh":                     true,
 	".subsections_via_symbols": true,
+	// gnu
+	".text":        true,
+	".file":        true,
+	".type":        true,
+	".size":        true,
+	".ident":       true,
+	".hidden":      true,
+	".addrsig":     true,
+	".addrsig_sym": true,
+	".attribute":   true,
+	".option":      true,
+	".abiversion":  true,
+	// arm
+	".syntax":         true,
+	".eabi_attribute": true,
+	".fpu":            true,
+	".arch":           true,
+	".arch_extension": true,
+	".cpu":            true,
+	".fnstart":        true,
+	".fnend":          true,
+	".save":           true,
+	".vsave":          true,
+	".setfp":          true,
+	".pad":            true,
+	".cantunwind":     true,
+	".personality":    true,
+	".handlerdata":    true,
+	// coff
+	".def":   true,
+	".scl":   true,
+	".endef": true,
 }
 
-var reLabel = regexp.MustCompile(`\b([lL](BB|JTI|CPI)\d+_\d+|_[\w.]+)(@PAGE|@PAGEOFF)?\b`)
+var reLabel = regexp.MustCompile(`\b([lL](BB|JTI|CPI)\d+_\d+|_[\w.]+)(@PAGE|@PAGEOFF|@GOTPAGE|@GOTPAGEOFF)?\b`)
+
+// gnu syntax has no special mark on the symbols, check the names
+// coff has the constants like __real@4000000000000000
+var reGNULabel = regexp.MustCompile(`(?:^|[^\w.$@])((?::lo12:|:got:|:got_lo12:)?([A-Za-z_.$][\w.$@]*))`)
 
 type Prog struct {
 	arch Arch
 	lbls map[string]*Label
 	bbs  []*BasicBlock
+	// darwin symbols have a leading underscore
+	symPrefix string
+	// .globl
+	globals map[string]bool
+	// the best first
+	variants []asmVariant
+	// the text assembly parsed
+	units int
+	// the labels of all the units
+	syms map[string]bool
+	// the stack depths of the functions analyzed, and the ones in progress
+	depths  map[*BasicBlock]int64
+	inDepth map[*BasicBlock]bool
+}
+
+// asmSyntax is the darwin syntax, or the gnu one used by linux.
+type asmSyntax struct {
+	gnu  bool
+	syms map[string]bool
+}
+
+func newAsmSyntax(lines []asmLine) *asmSyntax {
+	s := &asmSyntax{syms: make(map[string]bool)}
+	for _, v := range lines {
+		if v.mnemo == ".type" || strings.HasPrefix(v.label, ".L") {
+			s.gnu = true
+		}
+		if v.label != "" {
+			s.syms[v.label] = true
+		}
+	}
+	return s
 }
 
-func asmParse(r io.Reader, arch Arch) (p *Prog, err error) {
+// replaceLabels replaces the label operands with the results of fn.
+func (s *asmSyntax) replaceLabels(opers string, fn func(old string) string) string {
+	if !s.gnu {
+		return reLabel.ReplaceAllStringFunc(opers, fn)
+	}
+
+	var buf strings.Builder
+	var last int
+	for _, v := range reGNULabel.FindAllStringSubmatchIndex(opers, -1) {
+		name := opers[v[4]:v[5]]
+		// sq@PLT
+		if i := strings.IndexByte(name, '@'); i != -1 && !s.syms[name] {
+			name = name[:i]
+			v[3] = v[4] + i
+		}
+		if !s.syms[name] && !strings.HasPrefix(name, ".L") {
+			continue
+		}
+		buf.WriteString(opers[last:v[2]])
+		buf.WriteString(fn(opers[v[2]:v[3]]))
+		last = v[3]
+	}
+	buf.WriteString(opers[last:])
+	return buf.String()
+}
+
+func (s *asmSyntax) hasLabel(opers string) (ret bool) {
+	s.replaceLabels(opers, func(old string) string {
+		ret = true
+		return old
+	})
+	return
+}
+
+// newProg makes an empty program with the entry block.
+func newProg(arch Arch) (p *Prog, err error) {
 	p = &Prog{
-		lbls: make(map[string]*Label),
-		arch: arch,
+		lbls:    make(map[string]*Label),
+		arch:    arch,
+		globals: make(map[string]bool),
 	}
 
 	entry, err := arch.EntryBlock()
@@ -38,66 +145,113 @@ func asmParse(r io.Reader, arch Arch) (p *Prog, err error) {
 		return
 	}
 	p.bbs = append(p.bbs, entry)
+	return
+}
+
+func asmParse(r io.Reader, file string, arch Arch) (p *Prog, err error) {
+	if p, err = newProg(arch); err != nil {
+		return
+	}
+	if err = p.parse(r, file, ""); err != nil {
+		return
+	}
+	err = p.link()
+	return
+}
+
+// parse appends the text assembly to p.
+func (p *Prog) parse(r io.Reader, file, variant string) (err error) {
+	lines, err := asmLex(r, p.arch.CommentTokens())
+	if err != nil {
+		return
+	}
+	return p.parseLines(lines, file, variant)
+}
+
+// parseLines appends lines to p, the labels of a variant have its name as
+// the suffix. the bad lines are skipped and reported together.
+func (p *Prog) parseLines(lines []asmLine, file, variant string) (err error) {
+	arch := p.arch
+	p.units++
+	unit := p.units
 
 	var lastBB *BasicBlock
-	var lastLabel *Label
+	var lastLabels []*Label
 	sets := make(map[string]string)
+	var diags Diagnostics
 
-	getLabel := func(name string) *Label {
-		if r, ok := p.lbls[name]; ok {
-			return r
-		}
-		r := &Label{ID: name}
-		p.lbls[name] = r
-		return r
+	syntax := newAsmSyntax(lines)
+	if !syntax.gnu {
+		p.symPrefix = "_"
+	}
+	// defined by the other units
+	for k := range p.syms {
+		syntax.syms[k] = true
 	}
 
-	ea := entry.Size()
-	scan := bufio.NewScanner(r)
-	for scan.Scan() {
-		line := strings.TrimSpace(scan.Text())
-
-		// drop comment
-		if !strings.HasPrefix(line, ".asci") {
-			if idx := strings.Index(line, arch.CommentToken()); idx != -1 {
-				line = line[:idx]
+	// the labels not .globl are local to the unit, like the static
+	// functions of the c files
+	local := make(map[string]bool)
+	if unit > 1 && variant == "" {
+		for _, v := range lines {
+			if v.label != "" {
+				local[v.label] = true
 			}
 		}
-		// trim
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
+		for _, v := range lines {
+			if v.mnemo == ".globl" {
+				delete(local, v.opers)
+			}
+		}
+	}
+	getLabel := func(name string) *Label {
+		switch {
+		case variant != "":
+			name += "." + variant
+		case local[name]:
+			name = fmt.Sprintf("%s#%d", name, unit)
+		}
+		return p.getLabel(name)
+	}
+
+	// let the assembler see the label free instructions at once
+	var pre []asmInput
+	for _, v := range lines {
+		if v.mnemo != "" && !ignoreMnemo[v.mnemo] && v.mnemo != ".set" && !syntax.hasLabel(v.opers) {
+			pre = append(pre, asmInput{mnemo: v.mnemo, opers: v.opers})
 		}
+	}
+	arch.Prefetch(pre)
+
+	var ea int64
+	for _, bb := range p.bbs {
+		ea += bb.Size()
+	}
+	for _, line := range lines {
+		pos := line.pos
+		pos.file = file
 		// label
-		if line[len(line)-1] == ':' {
-			// skip Lloh
-			if strings.HasPrefix(line, "Lloh") {
+		if name := line.label; name != "" {
+			// skip Lloh, and the function bounds only used by .size
+			if strings.HasPrefix(name, "Lloh") || strings.HasPrefix(name, ".Lfunc_begin") || strings.HasPrefix(name, ".Lfunc_end") {
 				continue
 			}
-			name := line[:len(line)-1]
 			// check
-			if reLabel.FindString(name) != name {
-				err = fmt.Errorf("invalid label: %s", name)
-				return
-			}
-			if lastLabel != nil {
-				err = fmt.Errorf("continuous label: %s", name)
-				return
+			if !syntax.gnu && reLabel.FindString(name) != name {
+				diags = append(diags, newDiag(DiagKind_Error, pos, fmt.Errorf("invalid label: %s", name)))
+				continue
 			}
-			lastLabel = getLabel(name)
+			lastLabels = append(lastLabels, getLabel(name))
 
 			lastBB = nil
 			continue
 		}
-		// split
-		var mnemo, opers string
-		if idx := strings.IndexFunc(line, unicode.IsSpace); idx != -1 {
-			mnemo, opers = line[:idx], strings.TrimSpace(line[idx+1:])
-		} else {
-			mnemo = line
+		mnemo, opers := line.mnemo, line.opers
+		if mnemo == ".globl" && variant == "" {
+			p.globals[opers] = true
 		}
 		// ignore
-		if ignoreMnemo[mnemo] {
+		if ignoreMnemo[mnemo] || strings.HasPrefix(mnemo, ".cfi_") || strings.HasPrefix(mnemo, ".seh_") {
 			continue
 		}
 
@@ -114,47 +268,96 @@ func asmParse(r io.Reader, arch Arch) (p *Prog, err error) {
 		}
 
 		var los []LabelOperand
-		opers = reLabel.ReplaceAllStringFunc(opers, func(old string) string {
-			los = append(los, newLabelOperand(old, getLabel))
+		// opers becomes a format string, e.g. %rip
+		if syntax.hasLabel(opers) {
+			opers = strings.ReplaceAll(opers, "%", "%%")
+		}
+		opers = syntax.replaceLabels(opers, func(old string) string {
+			lo := newLabelOperand(old, getLabel)
+			if lo.lbl.ref.line == 0 {
+				lo.lbl.ref = pos
+			}
+			los = append(los, lo)
 			return "%d"
 		})
-		var instr Instr
-		if instr, err = arch.Instr(ea, mnemo, opers, los); err != nil {
-			return
+		instr, err1 := arch.Instr(ea, mnemo, opers, los)
+		if err1 != nil {
+			kind := DiagKind_Encoding
+			if errors.Is(err1, errUnknownDirective) {
+				kind = DiagKind_UnknownDirective
+			}
+			diags = append(diags, newDiag(kind, pos, err1))
+			continue
 		}
+		instr.SetPos(pos)
 		ea += instr.Size()
 
 		if lastBB == nil {
 			lastBB = &BasicBlock{}
 			p.bbs = append(p.bbs, lastBB)
-			if lastLabel != nil {
-				lastLabel.Bind(lastBB)
-				lastLabel = nil
+			for _, v := range lastLabels {
+				v.Bind(lastBB)
 			}
+			lastLabels = nil
 		}
 
 		lastBB.Instrs = append(lastBB.Instrs, instr)
 
-		if instr.Kind() == InstrKind_Jmp || instr.Kind() == InstrKind_Cond_Jmp || instr.Kind() == InstrKind_Ret {
+		if instr.Kind().endsBB() {
 			lastBB = nil
 		}
 	}
-	if err = scan.Err(); err != nil {
+	return diags.err()
+}
+
+func (p *Prog) getLabel(name string) *Label {
+	if r, ok := p.lbls[name]; ok {
+		return r
+	}
+	r := &Label{ID: name}
+	p.lbls[name] = r
+	return r
+}
+
+func (p *Prog) parseVariant(v asmVariant) (err error) {
+	f, err := os.Open(v.ifile)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	if err = p.parse(f, v.ifile, v.Name()); err != nil {
 		return
 	}
+	p.variants = append(p.variants, v)
+	return
+}
 
+// link checks the labels and fills the succs.
+func (p *Prog) link() error {
 	// check label
-	for k, v := range p.lbls {
+	var diags Diagnostics
+	for _, v := range p.lbls {
 		if v.BB == nil {
-			err = fmt.Errorf("nil label: %s", k)
-			return
+			diags = append(diags, newDiag(DiagKind_UnresolvedLabel, v.ref, fmt.Errorf("unresolved label: %s", v.ID)))
 		}
 	}
+	if len(diags) > 0 {
+		sort.Slice(diags, func(i, j int) bool {
+			a, b := diags[i].Pos, diags[j].Pos
+			if a.file != b.file {
+				return a.file < b.file
+			}
+			return a.line < b.line
+		})
+		return diags
+	}
 
 	// fill succs
 	for i, v := range p.bbs {
+		v.Succs = nil
 		switch ins := v.Last(); ins.Kind() {
-		case InstrKind_Ret:
+		case InstrKind_Ret, InstrKind_Indirect_Jmp:
 		case InstrKind_Jmp, InstrKind_Cond_Jmp:
 			if o := ins.LabelOperand(); o != nil {
 				v.Succs = append(v.Succs, o.BB())
@@ -169,13 +372,69 @@ func asmParse(r io.Reader, arch Arch) (p *Prog, err error) {
 			}
 		}
 	}
+	p.linkJumpTables()
+	return nil
+}
+
+type asmLine struct {
+	label        string
+	mnemo, opers string
+	pos          srcPos
+}
+
+// asmLex drops the comments and splits the lines into labels and
+// instructions.
+func asmLex(r io.Reader, commentTokens []string) (lines []asmLine, err error) {
+	scan := bufio.NewScanner(r)
+	var n int
+	for scan.Scan() {
+		n++
+		raw := scan.Text()
+		line := strings.TrimSpace(raw)
+		pos := srcPos{
+			line: n,
+			col:  len(raw) - len(strings.TrimLeftFunc(raw, unicode.IsSpace)) + 1,
+			text: line,
+		}
 
+		// drop comment
+		if !strings.HasPrefix(line, ".asci") {
+			for _, v := range commentTokens {
+				if idx := strings.Index(line, v); idx != -1 {
+					line = line[:idx]
+				}
+			}
+		}
+		// trim
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		// label
+		if line[len(line)-1] == ':' {
+			lines = append(lines, asmLine{label: line[:len(line)-1], pos: pos})
+			continue
+		}
+		// split
+		var mnemo, opers string
+		if idx := strings.IndexFunc(line, unicode.IsSpace); idx != -1 {
+			mnemo, opers = line[:idx], strings.TrimSpace(line[idx+1:])
+		} else {
+			mnemo = line
+		}
+		lines = append(lines, asmLine{mnemo: mnemo, opers: opers, pos: pos})
+	}
+	err = scan.Err()
 	return
 }
 
 func isData(mnemo string) bool {
 	switch mnemo {
-	case ".quad", ".long", ".short", ".byte", ".space", ".ascii", ".asciz":
+	case ".quad", ".long", ".short", ".byte", ".space", ".ascii", ".asciz",
+		// gnu
+		".xword", ".word", ".hword", ".zero", ".string",
+		// loongarch
+		".dword", ".half":
 		return true
 	}
 	return false
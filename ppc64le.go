@@ -0,0 +1,381 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type archPpc64le struct {
+	asmArch
+	wordWriter
+	// the local entries by .localentry, the global ones set up r2 from r12
+	entries map[string]LabelOperand
+}
+
+func newPpc64le(as Assembler) (_ *archPpc64le, err error) {
+	return &archPpc64le{
+		asmArch:    asmArch{as: as},
+		wordWriter: wordWriter{binary.LittleEndian},
+		entries:    make(map[string]LabelOperand),
+	}, nil
+}
+
+var ppc64leNop = []byte{0x00, 0x00, 0x00, 0x60}
+
+func (aa *archPpc64le) CommentTokens() []string {
+	return []string{"#"}
+}
+
+var (
+	rePpc64leStdu = regexp.MustCompile(`^r?1, (-\d+)\(r?1\)$`)
+	rePpc64leAddi = regexp.MustCompile(`^r?1, r?1, (-?\d+)$`)
+)
+
+var ppc64leConds = []string{
+	"eq", "ne", "lt", "gt", "le", "ge", "so", "ns", "un", "nu", "nl", "ng",
+	"dnz", "dz", "dnzt", "dnzf", "dzt", "dzf", "t", "f", "c",
+}
+
+// ppc64leBranch returns the kind of the branch mnemonic, target is false
+// for the ones by lr or ctr.
+func ppc64leBranch(mnemo string) (kind InstrKind, target bool) {
+	// the static prediction hints, beq+ 0, .LBB0_1
+	mnemo = strings.TrimRight(mnemo, "+-")
+
+	switch mnemo {
+	case "b", "ba":
+		return InstrKind_Jmp, true
+	case "bctr":
+		return InstrKind_Indirect_Jmp, false
+	case "bl", "bla":
+		return InstrKind_Call, true
+	case "bctrl", "blrl":
+		return InstrKind_Indirect_Call, false
+	case "blr":
+		return InstrKind_Ret, false
+	}
+	if !strings.HasPrefix(mnemo, "b") {
+		return InstrKind_Normal, false
+	}
+
+	for _, v := range ppc64leConds {
+		if !strings.HasPrefix(mnemo[1:], v) {
+			continue
+		}
+		switch mnemo[1+len(v):] {
+		case "", "a":
+			return InstrKind_Cond_Jmp, true
+		case "lr", "ctr":
+			return InstrKind_Cond_Jmp, false
+		case "l", "la":
+			return InstrKind_Call, true
+		case "lrl", "ctrl":
+			return InstrKind_Indirect_Call, false
+		}
+	}
+	return InstrKind_Normal, false
+}
+
+func ppc64leHasTarget(mnemo string) bool {
+	_, target := ppc64leBranch(mnemo)
+	return target
+}
+
+// instrPpc64leBranch is the branch to a label, the calls of the functions
+// go to the local entries, r2 is always the same.
+type instrPpc64leBranch struct {
+	*instrBase
+	asm     asmfunc
+	entries map[string]LabelOperand
+}
+
+func (ins instrPpc64leBranch) Operands() string {
+	arr := make([]interface{}, len(ins.los))
+	for i, v := range ins.los {
+		if lo, ok := ins.entries[v.ID()]; ok {
+			v = lo
+		}
+		if v.EA() == -1 {
+			arr[i] = ins.ea
+		} else {
+			arr[i] = v.EA()
+		}
+	}
+	return fmt.Sprintf(ins.opers, arr...)
+}
+
+func (ins instrPpc64leBranch) Rebuild() (dif int64, err error) {
+	for _, v := range ins.los {
+		if v.EA() == -1 {
+			err = fmt.Errorf("nil label: %s", v.ID())
+			return
+		}
+	}
+
+	old := ins.Size()
+	if ins.data, err = ins.asm(ins.mnemo, ins.Operands(), ins.ea); err != nil {
+		return
+	}
+	dif = ins.Size() - old
+	return
+}
+
+func (aa *archPpc64le) Instr(ea int64, mnemo string, opers string, los []LabelOperand) (_ Instr, err error) {
+	ib := &instrBase{
+		kind:  InstrKind_Normal,
+		ea:    ea,
+		mnemo: mnemo,
+		opers: opers,
+		los:   los,
+	}
+
+	// .localentry foo, .Lfunc_lep0-.Lfunc_gep0
+	if mnemo == ".localentry" {
+		if len(los) == 3 {
+			aa.entries[los[0].ID()] = los[1]
+		}
+		ib.los = nil
+		return ib, nil
+	}
+
+	if len(los) == 0 {
+		if ib.data, err = aa.asm(mnemo, opers, ea); err != nil {
+			return
+		}
+	}
+
+	// stdu 1, -32(1)
+	// addi 1, 1, 32
+	if mnemo == "stdu" || mnemo == "addi" {
+		re := rePpc64leStdu
+		if mnemo == "addi" {
+			re = rePpc64leAddi
+		}
+		if res := re.FindStringSubmatch(opers); len(res) > 0 {
+			if ib.sp, err = strconv.ParseInt(res[1], 10, 64); err != nil {
+				return
+			}
+			return ib, nil
+		}
+	}
+
+	// stdux 1, 1, 3
+	// mr 1, 3
+	// r1 is 1, and r31 is the frame pointer
+	switch mnemo {
+	case "stdux":
+		ib.dynsp = isDynSP(splitOperands(strings.ReplaceAll(opers, "r", "")), 1, "1", "31")
+	case "add", "subf", "mr", "addi":
+		ib.dynsp = isDynSP(splitOperands(strings.ReplaceAll(opers, "r", "")), 0, "1", "31")
+	}
+
+	if mnemo == ".p2align" {
+		// the loops are aligned to 32 bytes for the speed, nothing needs
+		// more than 16 bytes for the correctness
+		if ib.opers = p2alignClamp(opers, 4); ib.opers != opers {
+			if ib.data, err = aa.asm(mnemo, ib.opers, ea); err != nil {
+				return
+			}
+		}
+		ib.kind = InstrKind_P2Align
+		return instrRebuild{instrBase: ib, asm: aa.asm}, nil
+	}
+
+	var target bool
+	if isData(mnemo) {
+		ib.kind = InstrKind_Data
+	} else {
+		ib.kind, target = ppc64leBranch(mnemo)
+	}
+
+	if len(los) > 0 {
+		// addis 2, 12, .TOC.-.Lfunc_gep0@ha
+		// addis 3, 2, .LCPI0_0@toc@ha
+		// the toc base is the start of the code, the offsets are the addresses
+		if strings.Contains(opers, ".TOC.") || strings.Contains(opers, "@toc") {
+			opers = strings.ReplaceAll(opers, ".TOC.", "0")
+			opers = strings.ReplaceAll(opers, "@toc@", "@")
+			ib.opers = strings.ReplaceAll(opers, "@toc", "")
+		}
+
+		if target {
+			ins := instrPpc64leBranch{instrBase: ib, asm: aa.asm, entries: aa.entries}
+			if ib.data, err = aa.asm(mnemo, ins.Operands(), ea); err != nil {
+				return
+			}
+			return ins, nil
+		}
+
+		il := instrLabel{instrBase: ib, asm: aa.asm}
+		if ib.data, err = aa.asm(mnemo, il.Operands(), ea); err != nil {
+			return
+		}
+		return il, nil
+	}
+
+	return ib, nil
+}
+
+func (aa *archPpc64le) WriteProg(w io.Writer, p *Prog) error {
+	// TEXT symbols are only 16 byte aligned, see .p2align
+	_, err := aa.writeProg(w, p)
+	return err
+}
+
+func (aa *archPpc64le) EntryBlock() (*BasicBlock, error) {
+	return buildEntryBlock(aa.asm,
+		"mflr", "4",
+		"bcl", "20, 31, .+4",
+		"mflr", "3",
+		"mtlr", "4",
+		"addi", "3, 3, -8",
+		"std", "3, 32(1)",
+		"blr", "",
+	)
+}
+
+func (aa *archPpc64le) WriteHead(w io.Writer) (err error) {
+	return
+}
+
+func (aa *archPpc64le) WriteFunc(w io.Writer, f *Function, spsize, fpos int64) (err error) {
+	if spsize != 0 {
+		// the 288 bytes under r1 are used without stdu, and the frame of
+		// the stack realignment
+		if _, err = fmt.Fprintf(w, `
+_entry:
+	MOVD 16(g), R22
+	ADD $-%d, R1, R23
+	CMPU R22, R23
+	BGE _stack_grow
+`, spsize+288+48); err != nil {
+			return
+		}
+	}
+
+	if _, err = fmt.Fprintf(w, "\n%s:\n", f.Name[1:]); err != nil {
+		return
+	}
+
+	// r3-r10, f1-f13
+	getReg := func(idx int, fp bool) string {
+		if fp {
+			return "F" + strconv.Itoa(idx+1)
+		}
+		return "R" + strconv.Itoa(idx+3)
+	}
+
+	// the integers are extended to 64-bit by the sign of the type in the abi
+	getOp := func(sz int, fp, signed, load bool) string {
+		switch sz {
+		case 1:
+			if load && !signed {
+				return "MOVBZ"
+			}
+			return "MOVB"
+		case 2:
+			if load && !signed {
+				return "MOVHZ"
+			}
+			return "MOVH"
+		case 4:
+			if fp {
+				return "FMOVS"
+			}
+			if load && !signed {
+				return "MOVWZ"
+			}
+			return "MOVW"
+		case 8:
+			if fp {
+				return "FMOVD"
+			}
+			return "MOVD"
+		default:
+			panic(fmt.Sprintf("unexpected parameter size: %d", sz))
+		}
+	}
+
+	// every argument takes a doubleword of the parameter save area, the
+	// floats skip the integer registers
+	var slot, fi, soff int
+	nextOff := func(sz int) (r int) {
+		r = (soff + sz - 1) &^ (sz - 1)
+		soff = r + sz
+		return
+	}
+	nextReg := func(fp bool) (reg string, err error) {
+		if slot == 8 {
+			return "", errors.New("too many arguments")
+		}
+		slot++
+		if fp {
+			fi++
+			return getReg(fi-1, true), nil
+		}
+		return getReg(slot-1, false), nil
+	}
+	for _, v := range f.Args {
+		reg, err1 := nextReg(v.IsFloat)
+		if err1 != nil {
+			return newDiag(DiagKind_UnsupportedParam, f.Pos, fmt.Errorf("%s: %w", f.Name, err1))
+		}
+		if _, err = fmt.Fprintf(w, "\t%s %s+%d(FP), %s\n",
+			getOp(v.Size, v.IsFloat, v.Signed, true),
+			v.Name, nextOff(v.Size), reg,
+		); err != nil {
+			return
+		}
+	}
+
+	// r12 is the global entry, r14-r16 are nonvolatile, r0 is zero in go
+	if _, err = fmt.Fprintf(w, `	MOVD ·_subr%s(SB), R12
+	MOVD LR, R14
+	MOVD R2, R16
+	MOVD R1, R15
+	SUB $32, R1, R10
+	RLDICR $0, R10, $59, R1
+	MOVD R0, 0(R1)
+	MOVD R12, CTR
+	BL (CTR)
+	XOR R0, R0
+	MOVD R15, R1
+	MOVD R16, R2
+	MOVD R14, LR
+`, f.Name); err != nil {
+		return
+	}
+
+	soff = nextOff(8)
+	if f.Ret != nil {
+		if _, err = fmt.Fprintf(w, "\t%s %s, %s+%d(FP)\n",
+			getOp(f.Ret.Size, f.Ret.IsFloat, f.Ret.Signed, false), getReg(0, f.Ret.IsFloat),
+			f.Ret.Name, nextOff(f.Ret.Size)); err != nil {
+			return
+		}
+	}
+	if _, err = fmt.Fprint(w, "\tRET\n"); err != nil {
+		return
+	}
+
+	if spsize != 0 {
+		if _, err = fmt.Fprintf(w, `
+_stack_grow:
+	MOVD LR, R5
+	CALL runtime·morestack_noctxt<>(SB)
+	JMP _entry
+`); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (aa *archPpc64le) SubrEntry(w io.Writer) (string, error) {
+	return "", nil
+}
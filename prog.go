@@ -1,5 +1,12 @@
 package main
 
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
 type Iter struct {
 	p      *Prog
 	bi, ii int
@@ -35,10 +42,18 @@ func (p *Prog) Rebuild() error {
 	for {
 		var flag bool
 
+		var pre []asmInput
+		for _, bb := range p.bbs {
+			for _, v := range bb.Instrs {
+				pre = append(pre, asmInput{mnemo: v.Mnemonic(), opers: v.Operands(), address: v.EA()})
+			}
+		}
+		p.arch.Prefetch(pre)
+
 		it := p.Iter()
 		for it.Next() {
 			if dif, err := it.Instr().Rebuild(); err != nil {
-				return err
+				return newDiag(DiagKind_Encoding, it.Instr().Pos(), err)
 			} else if dif != 0 {
 				flag = true
 
@@ -56,40 +71,146 @@ func (p *Prog) Rebuild() error {
 	return nil
 }
 
-func (p *Prog) GetBB(id string) *BasicBlock {
-	return p.lbls[id].BB
+// FuncBB returns the entry block of the C function f.
+func (p *Prog) FuncBB(f *Function) (*BasicBlock, error) {
+	name := p.symPrefix + f.CName()
+	if lbl := p.lbls[name]; lbl != nil && lbl.BB != nil {
+		return lbl.BB, nil
+	}
+	return nil, fmt.Errorf("function not found: %s", name)
 }
 
-func int64min(a int64, b int64) int64 {
-	if a < b {
-		return a
+// VariantBB returns the entry block of f in the variant v, it is nil when
+// v has no f.
+func (p *Prog) VariantBB(f *Function, v asmVariant) *BasicBlock {
+	if lbl := p.lbls[p.symPrefix+f.CName()+"."+v.Name()]; lbl != nil {
+		return lbl.BB
 	}
-	return b
+	return nil
 }
 
-func (p *Prog) SPDetect(bb *BasicBlock, redup map[*BasicBlock]bool) int64 {
-	if redup[bb] {
-		return 0
+// StackSize is the max stack size of f over the variants, or the one set
+// by f.
+func (p *Prog) StackSize(f *Function) (_ int64, err error) {
+	bb, err := p.FuncBB(f)
+	if err != nil {
+		return
 	}
-	redup[bb] = true
-
-	var minsp, sp int64
-	for _, v := range bb.Instrs {
-		if v.Kind() == InstrKind_Call {
-			var dstsp int64
-			if lo := v.LabelOperand(); lo != nil && lo.BB() != nil {
-				dstsp = p.SPDetect(lo.BB(), redup)
+	if f.Stack > 0 {
+		return f.Stack, nil
+	}
+	if err = p.checkBounded(bb); err != nil {
+		return 0, stackErr(f, err)
+	}
+	ret, err := p.depth(bb)
+	if err != nil {
+		return 0, stackErr(f, err)
+	}
+	for _, v := range p.variants {
+		if bb := p.VariantBB(f, v); bb != nil {
+			if err := p.checkBounded(bb); err != nil {
+				return 0, stackErr(f, err)
 			}
+			sp, err := p.depth(bb)
+			if err != nil {
+				return 0, stackErr(f, err)
+			}
+			if sp > ret {
+				ret = sp
+			}
+		}
+	}
+	return ret, nil
+}
 
-			minsp = int64min(minsp, sp+v.SPDiff()+dstsp)
+// StackSizes are the stack sizes of funcs, the errors of all of them are
+// reported together.
+func (p *Prog) StackSizes(funcs Functions) (_ map[*Function]int64, err error) {
+	ret := make(map[*Function]int64, len(funcs))
+	var ds Diagnostics
+	for _, f := range funcs {
+		if _, err := p.FuncBB(f); err != nil {
+			ds = append(ds, newDiag(DiagKind_UnresolvedLabel, f.Pos, err))
 			continue
 		}
-		sp += v.SPDiff()
-		minsp = int64min(minsp, sp)
+		spsize, err := p.StackSize(f)
+		if err != nil {
+			ds = append(ds, diagsOf(err)...)
+			continue
+		}
+		ret[f] = spsize
+	}
+	if err = ds.err(); err != nil {
+		return
 	}
+	return ret, nil
+}
 
-	for _, v := range bb.Succs {
-		minsp = int64min(minsp, sp+p.SPDetect(v, redup))
+// stackErr names f in the errors of the objects, they have no position,
+// and tells the ways to set the stack size.
+func stackErr(f *Function, err error) error {
+	d, ok := err.(*Diagnostic)
+	if !ok {
+		return err
+	}
+	pos, err := d.Pos, d.Err
+	if pos.String() == "" {
+		pos, err = f.Pos, fmt.Errorf("%s: %w", f.CName(), err)
+	}
+	return newDiag(d.Kind, pos, fmt.Errorf("%w, set the stack size of %s by //nocgo:stack N or the stack of %s", err, f.Name, configName))
+}
+
+// depth is the stack depth of the function at bb, tracked over the cfg if
+// the arch interprets sp.
+func (p *Prog) depth(bb *BasicBlock) (int64, error) {
+	if sa, ok := p.arch.(spArch); ok {
+		return p.spDepth(bb, sa)
+	}
+	return p.sumDepth(bb)
+}
+
+// Exports are the C names of the global functions, the global data are
+// not counted.
+func (p *Prog) Exports() (ret []string) {
+	for k := range p.globals {
+		if lbl := p.lbls[k]; lbl != nil && lbl.BB != nil && lbl.BB.Instrs[0].Kind() != InstrKind_Data {
+			ret = append(ret, strings.TrimPrefix(k, p.symPrefix))
+		}
+	}
+	sort.Strings(ret)
+	return
+}
+
+// checkAlign fails on the alignments over 1<<max, the TEXT symbols are
+// not aligned that much.
+func (p *Prog) checkAlign(max int) error {
+	for _, bb := range p.bbs {
+		for _, v := range bb.Instrs {
+			if v.Kind() != InstrKind_P2Align {
+				continue
+			}
+			n, err := strconv.Atoi(strings.TrimSpace(strings.SplitN(v.Operands(), ",", 2)[0]))
+			if err != nil {
+				return err
+			}
+			if n > max {
+				return fmt.Errorf("unsupported alignment: %s", v.Operands())
+			}
+		}
+	}
+	return nil
+}
+
+// p2alignClamp lowers the .p2align over 1<<max, for the archs that only
+// align the code for the speed.
+func p2alignClamp(opers string, max int) string {
+	args := splitOperands(opers)
+	if len(args) == 0 {
+		return opers
+	}
+	if n, err := strconv.Atoi(args[0]); err != nil || n <= max {
+		return opers
 	}
-	return minsp
+	args[0] = strconv.Itoa(max)
+	return strings.Join(args, ", ")
 }
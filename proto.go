@@ -5,98 +5,213 @@ import (
 	"fmt"
 	"go/ast"
 	"go/parser"
+	"go/scanner"
 	"go/token"
+	"go/types"
 	"sort"
+	"strconv"
+	"strings"
 )
 
 type Parameter struct {
 	Name    string
 	Size    int
 	IsFloat bool
+	// the integers narrower than a register are extended by the sign
+	Signed bool
+	// the 8 byte values are only 4 byte aligned on the 32-bit archs
+	Align int
+	// the go wrappers of the native stacks keep it alive
+	Pointer bool
 }
 
 type Function struct {
-	Name string
-	Args []*Parameter
-	Ret  *Parameter
+	Name    string
+	Args    []*Parameter
+	Ret     *Parameter
+	PtrSize int
+	// the declaration in the prototypes
+	Pos srcPos
+	// the C name instead of the one from Name
+	Symbol string
+	// the stack size instead of the detected one
+	Stack int64
+	// runs on a native stack out of the goroutine
+	NativeStack bool
 }
 
 func (f *Function) ArgsSize() (ret int) {
-	add := func(sz int, align bool) {
-		ret = (ret + sz - 1) &^ (sz - 1)
-		if !align {
-			ret += sz
-		}
+	add := func(sz, align int) {
+		ret = (ret+align-1)&^(align-1) + sz
 	}
 	for _, v := range f.Args {
-		add(v.Size, false)
+		add(v.Size, v.Align)
 	}
-	add(8, true)
+	add(0, f.PtrSize)
 	if f.Ret != nil {
-		add(f.Ret.Size, false)
+		add(f.Ret.Size, f.Ret.Align)
 	}
-	add(8, true)
+	add(0, f.PtrSize)
 	return
 }
 
+// nativeArgs are the arguments of the native stack call of f, the stack
+// top is the last.
+func (f *Function) nativeArgs() []*Parameter {
+	return append(append([]*Parameter(nil), f.Args...), &Parameter{Name: "nstack", Size: f.PtrSize, Align: f.PtrSize})
+}
+
+// goType is the go type of v in the generated wrappers, the layout is the
+// same as the prototype.
+func (v *Parameter) goType() string {
+	bits := strconv.Itoa(v.Size * 8)
+	switch {
+	case v.Pointer:
+		return "unsafe.Pointer"
+	case v.IsFloat:
+		return "float" + bits
+	case v.Signed:
+		return "int" + bits
+	}
+	return "uint" + bits
+}
+
+// archPtrSize is the size of the pointers and int of goarch.
+func archPtrSize(goarch string) int {
+	switch goarch {
+	case "386", "arm", "mips", "mipsle":
+		return 4
+	}
+	return 8
+}
+
+// CName is the C function name, the Go one has two leading underscores.
+func (f *Function) CName() string {
+	if f.Symbol != "" {
+		return f.Symbol
+	}
+	return strings.TrimPrefix(f.Name[1:], "_")
+}
+
 type Functions []*Function
 
-func paramParse(args *ast.FieldList) (ret []*Parameter, err error) {
+// goPos is the srcPos of node.
+func goPos(fset *token.FileSet, node ast.Node) srcPos {
+	pos := fset.Position(node.Pos())
+	return srcPos{file: pos.Filename, line: pos.Line, col: pos.Column}
+}
+
+func paramParse(fset *token.FileSet, args *ast.FieldList, ptrSize int) (ret []*Parameter, err error) {
 	// void result only
 	if args == nil {
 		return []*Parameter{nil}, nil
 	}
 	for _, v := range args.List {
 		if len(v.Names) == 0 {
-			err = errors.New("need parameter name")
+			err = newDiag(DiagKind_UnsupportedParam, goPos(fset, v), errors.New("need parameter name"))
 			return
 		}
 
 		var sz int
-		var fp bool
+		var fp, signed, ptr bool
 		switch t := v.Type.(type) {
 		case *ast.StarExpr:
 			// pointer
-			sz = 8
+			sz, ptr = ptrSize, true
 		case *ast.SelectorExpr:
 			// unsafe.Pointer
 			if n, ok := t.X.(*ast.Ident); ok && n.Name == "unsafe" && t.Sel.Name == "Pointer" {
-				sz = 8
+				sz, ptr = ptrSize, true
 			}
 		case *ast.Ident:
 			switch t.Name {
-			case "int8", "uint8", "byte", "bool":
+			case "int8":
+				sz, signed = 1, true
+			case "uint8", "byte", "bool":
 				sz = 1
-			case "int16", "uint16":
+			case "int16":
+				sz, signed = 2, true
+			case "uint16":
 				sz = 2
 			case "float32":
 				sz, fp = 4, true
-			case "int32", "uint32", "rune":
+			case "int32", "rune":
+				sz, signed = 4, true
+			case "uint32":
 				sz = 4
 			case "float64":
 				sz, fp = 8, true
-			case "int64", "uint64", "uintptr", "int", "Pointer":
+			case "int64":
+				sz, signed = 8, true
+			case "uint64":
 				sz = 8
+			case "int":
+				sz, signed = ptrSize, true
+			case "uintptr":
+				sz = ptrSize
+			case "Pointer":
+				sz, ptr = ptrSize, true
 			}
 		}
 		if sz == 0 {
-			err = fmt.Errorf("unsupport parameter: %v", v.Type)
+			err = newDiag(DiagKind_UnsupportedParam, goPos(fset, v.Type), fmt.Errorf("unsupported parameter type: %s", types.ExprString(v.Type)))
 			return
 		}
+		align := sz
+		if align > ptrSize {
+			align = ptrSize
+		}
 		for _, name := range v.Names {
 			ret = append(ret, &Parameter{
 				Name:    name.Name,
 				Size:    sz,
 				IsFloat: fp,
+				Signed:  signed,
+				Align:   align,
+				Pointer: ptr,
 			})
 		}
 	}
 	return
 }
 
-func protoParse(fpath string) (ret Functions, pkg string, err error) {
+const (
+	stackPrefix       = "//nocgo:stack "
+	nativeStackPrefix = "//nocgo:nativestack"
+)
+
+// parseDirectives sets the stack size by //nocgo:stack N and the native
+// stack by //nocgo:nativestack in the doc of f.
+func parseDirectives(fset *token.FileSet, doc *ast.CommentGroup, f *Function) error {
+	if doc == nil {
+		return nil
+	}
+	for _, v := range doc.List {
+		switch {
+		case strings.HasPrefix(v.Text, stackPrefix):
+			n, err := strconv.ParseInt(strings.TrimSpace(v.Text[len(stackPrefix):]), 0, 64)
+			if err != nil || n <= 0 {
+				return newDiag(DiagKind_Error, goPos(fset, v), fmt.Errorf("bad stack size: %s", v.Text))
+			}
+			f.Stack = n
+		case strings.TrimSpace(v.Text) == nativeStackPrefix:
+			f.NativeStack = true
+		}
+	}
+	return nil
+}
+
+func protoParse(fpath string, ptrSize int) (ret Functions, pkg string, err error) {
 	fset := token.NewFileSet()
-	f, err := parser.ParseFile(fset, fpath, nil, 0)
+	f, err := parser.ParseFile(fset, fpath, nil, parser.ParseComments)
+	if list, ok := err.(scanner.ErrorList); ok {
+		var diags Diagnostics
+		for _, v := range list {
+			pos := srcPos{file: v.Pos.Filename, line: v.Pos.Line, col: v.Pos.Column}
+			diags = append(diags, newDiag(DiagKind_Error, pos, errors.New(v.Msg)))
+		}
+		err = diags
+	}
 	if err != nil {
 		return
 	}
@@ -109,28 +224,34 @@ func protoParse(fpath string) (ret Functions, pkg string, err error) {
 			continue
 		}
 		if fd.Recv != nil {
-			err = fmt.Errorf("not support method: %s", fd.Name.Name)
+			err = newDiag(DiagKind_Error, goPos(fset, fd), fmt.Errorf("methods are unsupported: %s", fd.Name.Name))
 			return
 		}
 		if fd.Type.Results.NumFields() > 1 {
-			err = fmt.Errorf("not support multi results: %s", fd.Name.Name)
+			err = newDiag(DiagKind_UnsupportedParam, goPos(fset, fd.Type.Results), fmt.Errorf("multiple results are unsupported: %s", fd.Name.Name))
 			return
 		}
-		args, err1 := paramParse(fd.Type.Params)
+		args, err1 := paramParse(fset, fd.Type.Params, ptrSize)
 		if err1 != nil {
 			err = err1
 			return
 		}
-		res, err1 := paramParse(fd.Type.Results)
+		res, err1 := paramParse(fset, fd.Type.Results, ptrSize)
 		if err1 != nil {
 			err = err1
 			return
 		}
-		ret = append(ret, &Function{
-			Name: fd.Name.Name,
-			Args: args,
-			Ret:  res[0],
-		})
+		f := &Function{
+			Name:    fd.Name.Name,
+			Args:    args,
+			Ret:     res[0],
+			PtrSize: ptrSize,
+			Pos:     goPos(fset, fd),
+		}
+		if err = parseDirectives(fset, fd.Doc, f); err != nil {
+			return
+		}
+		ret = append(ret, f)
 	}
 
 	sort.Slice(ret, func(i, j int) bool {
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type archRiscv64 struct {
+	asmArch
+	wordWriter
+}
+
+func newRiscv64(as Assembler) (_ *archRiscv64, err error) {
+	return &archRiscv64{asmArch: asmArch{as: as}, wordWriter: wordWriter{binary.LittleEndian}}, nil
+}
+
+var riscv64Nop = []byte{0x13, 0x00, 0x00, 0x00}
+
+func (aa *archRiscv64) CommentTokens() []string {
+	return []string{"#"}
+}
+
+var reRiscv64Sp = regexp.MustCompile(`^sp, sp, (-?\d+)$`)
+
+var reRiscv64Load = regexp.MustCompile(`^(\w+), %d\((\w+)\)$`)
+
+func isRiscv64Branch(mnemo string) bool {
+	switch mnemo {
+	case "beq", "bne", "blt", "bge", "bltu", "bgeu",
+		// pseudo
+		"beqz", "bnez", "blez", "bgez", "bltz", "bgtz", "bgt", "ble", "bgtu", "bleu":
+		return true
+	}
+	return false
+}
+
+// riscv64Split splits the pc relative offset for auipc and the 12-bit
+// signed immediate after it.
+func riscv64Split(off int64) (hi, lo int64) {
+	hi = (off + 0x800) >> 12
+	lo = off - hi<<12
+	return
+}
+
+// instrPcrelLo is the instruction with %pcrel_lo, it takes the label of
+// the auipc with %pcrel_hi, and the offset is also from there.
+type instrPcrelLo struct {
+	*instrBase
+	asm asmfunc
+	hi  Instr
+}
+
+func (ins instrPcrelLo) Operands() string {
+	var lo int64
+	if ea := ins.hi.LabelOperand().EA(); ea != -1 {
+		_, lo = riscv64Split(ea - ins.hi.EA())
+	}
+	return fmt.Sprintf(ins.opers, lo)
+}
+
+func (ins instrPcrelLo) Rebuild() (dif int64, err error) {
+	if lo := ins.hi.LabelOperand(); lo.EA() == -1 {
+		err = fmt.Errorf("nil label: %s", lo.ID())
+		return
+	}
+
+	old := ins.Size()
+	if ins.data, err = ins.asm(ins.mnemo, ins.Operands(), ins.ea); err != nil {
+		return
+	}
+	dif = ins.Size() - old
+	return
+}
+
+func (aa *archRiscv64) pcrelLo(ib *instrBase) (_ Instr, err error) {
+	if len(ib.los) != 1 || strings.Count(ib.opers, "%%pcrel_lo(%d)") != 1 {
+		err = fmt.Errorf("unsupported operands: %s %s", ib.mnemo, ib.opers)
+		return
+	}
+	bb := ib.los[0].BB()
+	if bb == nil || bb.Instrs[0].Mnemonic() != "auipc" || bb.Instrs[0].LabelOperand() == nil {
+		err = fmt.Errorf("%%pcrel_lo without auipc: %s", ib.los[0].ID())
+		return
+	}
+	hi := bb.Instrs[0]
+	ib.opers = strings.Replace(ib.opers, "%%pcrel_lo(%d)", "%d", 1)
+
+	// auipc a0, %got_pcrel_hi(sym)
+	// ld a0, %pcrel_lo(.Lpcrel_hi0)(a0)
+	// there is no got, the symbol is always local, use the address directly
+	if strings.Contains(hi.Operands(), "%got_pcrel_hi(") {
+		if ib.mnemo != "ld" || !reRiscv64Load.MatchString(ib.opers) {
+			err = fmt.Errorf("unexpected got load: %s %s", ib.mnemo, ib.opers)
+			return
+		}
+		ib.mnemo, ib.opers = "addi", reRiscv64Load.ReplaceAllString(ib.opers, "$1, $2, %d")
+	}
+
+	ins := instrPcrelLo{instrBase: ib, asm: aa.asm, hi: hi}
+	if ib.data, err = aa.asm(ib.mnemo, ins.Operands(), ib.ea); err != nil {
+		return
+	}
+	return ins, nil
+}
+
+func (aa *archRiscv64) Instr(ea int64, mnemo string, opers string, los []LabelOperand) (_ Instr, err error) {
+	ib := &instrBase{
+		kind:  InstrKind_Normal,
+		ea:    ea,
+		mnemo: mnemo,
+		opers: opers,
+		los:   los,
+	}
+
+	if len(los) == 0 {
+		if ib.data, err = aa.asm(mnemo, opers, ea); err != nil {
+			return
+		}
+	}
+
+	// addi sp, sp, -32
+	// addi sp, sp, 32
+	if mnemo == "addi" {
+		if res := reRiscv64Sp.FindStringSubmatch(opers); len(res) > 0 {
+			if ib.sp, err = strconv.ParseInt(res[1], 10, 64); err != nil {
+				return
+			}
+			return ib, nil
+		}
+	}
+
+	// sub sp, sp, a0
+	// mv sp, a0
+	switch mnemo {
+	case "add", "sub", "mv", "addi":
+		ib.dynsp = isDynSP(splitOperands(opers), 0, "sp", "s0", "fp")
+	}
+
+	if mnemo == ".p2align" {
+		ib.kind = InstrKind_P2Align
+		return instrRebuild{instrBase: ib, asm: aa.asm}, nil
+	}
+
+	switch {
+	case isData(mnemo):
+		ib.kind = InstrKind_Data
+	case mnemo == "ret", mnemo == "jr" && opers == "ra":
+		ib.kind = InstrKind_Ret
+	case mnemo == "call":
+		ib.kind = InstrKind_Call
+	case mnemo == "jalr":
+		// jalr a0
+		ib.kind = InstrKind_Call
+		if len(los) == 0 {
+			ib.kind = InstrKind_Indirect_Call
+		}
+	case mnemo == "jal":
+		// jal zero, .LBB0_1
+		if strings.HasPrefix(opers, "zero,") {
+			ib.kind = InstrKind_Jmp
+		} else {
+			ib.kind = InstrKind_Call
+		}
+	case mnemo == "jr":
+		ib.kind = InstrKind_Indirect_Jmp
+	case mnemo == "j", mnemo == "tail":
+		ib.kind = InstrKind_Jmp
+	case isRiscv64Branch(mnemo):
+		ib.kind = InstrKind_Cond_Jmp
+	}
+
+	if len(los) > 0 {
+		// lui a0, %hi(sym)
+		if strings.Contains(opers, "%%hi(") || strings.Contains(opers, "%%lo(") {
+			err = fmt.Errorf("absolute address is unsupported, use -mcmodel=medany: %s %s", mnemo, opers)
+			return
+		}
+		// fld ft0, %pcrel_lo(.Lpcrel_hi0)(a0)
+		if strings.Contains(opers, "%%pcrel_lo(") {
+			return aa.pcrelLo(ib)
+		}
+
+		var stub int64
+		if ib.kind == InstrKind_Call || ib.kind == InstrKind_Jmp || ib.kind == InstrKind_Cond_Jmp {
+			stub = ea
+		}
+		il := instrLabel{instrBase: ib, asm: aa.asm, stub: stub}
+		if ib.data, err = aa.asm(mnemo, il.Operands(), ea); err != nil {
+			return
+		}
+		return il, nil
+	}
+
+	return ib, nil
+}
+
+func (aa *archRiscv64) WriteProg(w io.Writer, p *Prog) error {
+	// TEXT symbols are only 8 byte aligned
+	if err := p.checkAlign(3); err != nil {
+		return err
+	}
+	_, err := aa.writeProg(w, p)
+	return err
+}
+
+func (aa *archRiscv64) EntryBlock() (*BasicBlock, error) {
+	return buildEntryBlock(aa.asm,
+		"auipc", "a0, 0",
+		"sd", "a0, 8(sp)",
+		"ret", "",
+	)
+}
+
+func (aa *archRiscv64) WriteHead(w io.Writer) (err error) {
+	return
+}
+
+func (aa *archRiscv64) WriteFunc(w io.Writer, f *Function, spsize, fpos int64) (err error) {
+	if spsize != 0 {
+		// stack realignment
+		if _, err = fmt.Fprintf(w, `
+_entry:
+	MOV 16(g), X6
+	ADD $-%d, X2, X7
+	BGEU X6, X7, _stack_grow
+`, spsize+16); err != nil {
+			return
+		}
+	}
+
+	if _, err = fmt.Fprintf(w, "\n%s:\n", f.Name[1:]); err != nil {
+		return
+	}
+
+	// a0-a7, fa0-fa7
+	getReg := func(idx int, fp bool) string {
+		idxs := strconv.Itoa(idx + 10)
+		if fp {
+			return "F" + idxs
+		}
+		return "X" + idxs
+	}
+
+	// the narrow integers are extended by the sign of the type in the
+	// psABI, but 32-bit ones are sign extended, unsigned or not
+	getOp := func(sz int, fp, signed, load bool) string {
+		switch sz {
+		case 1:
+			if load && !signed {
+				return "MOVBU"
+			}
+			return "MOVB"
+		case 2:
+			if load && !signed {
+				return "MOVHU"
+			}
+			return "MOVH"
+		case 4:
+			if fp {
+				return "MOVF"
+			}
+			return "MOVW"
+		case 8:
+			if fp {
+				return "MOVD"
+			}
+			return "MOV"
+		default:
+			panic(fmt.Sprintf("unexpected parameter size: %d", sz))
+		}
+	}
+
+	var ri, fi, soff int
+	nextOff := func(sz int) (r int) {
+		r = (soff + sz - 1) &^ (sz - 1)
+		soff = r + sz
+		return
+	}
+	nextReg := func(fp bool) (string, error) {
+		if fp {
+			if fi == 8 {
+				return "", errors.New("too many float arguments")
+			}
+			fi++
+			return getReg(fi-1, true), nil
+		}
+		if ri == 8 {
+			return "", errors.New("too many integer arguments")
+		}
+		ri++
+		return getReg(ri-1, false), nil
+	}
+	for _, v := range f.Args {
+		reg, err1 := nextReg(v.IsFloat)
+		if err1 != nil {
+			return newDiag(DiagKind_UnsupportedParam, f.Pos, fmt.Errorf("%s: %w", f.Name, err1))
+		}
+		if _, err = fmt.Fprintf(w, "\t%s %s+%d(FP), %s\n",
+			getOp(v.Size, v.IsFloat, v.Signed, true),
+			v.Name, nextOff(v.Size), reg,
+		); err != nil {
+			return
+		}
+	}
+
+	// X8 and X9 are callee-saved in the psABI
+	if _, err = fmt.Fprintf(w, `	MOV ·_subr%s(SB), X5
+	MOV X1, X9
+	MOV X2, X8
+	AND $-16, X2
+	JALR X1, (X5)
+	MOV X8, X2
+	MOV X9, X1
+`, f.Name); err != nil {
+		return
+	}
+
+	soff = nextOff(8)
+	if f.Ret != nil {
+		if _, err = fmt.Fprintf(w, "\t%s %s, %s+%d(FP)\n",
+			getOp(f.Ret.Size, f.Ret.IsFloat, f.Ret.Signed, false), getReg(0, f.Ret.IsFloat),
+			f.Ret.Name, nextOff(f.Ret.Size)); err != nil {
+			return
+		}
+	}
+	if _, err = fmt.Fprint(w, "\tRET\n"); err != nil {
+		return
+	}
+
+	if spsize != 0 {
+		// morestack takes the return address in X5, and X1 is kept
+		if _, err = fmt.Fprintf(w, `
+_stack_grow:
+	JAL X5, _morestack
+	JMP _entry
+
+_morestack:
+	JMP runtime·morestack_noctxt<>(SB)
+`); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (aa *archRiscv64) SubrEntry(w io.Writer) (string, error) {
+	return "", nil
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type archS390x struct {
+	asmArch
+	byteWriter
+}
+
+func newS390x(as Assembler) (_ *archS390x, err error) {
+	return &archS390x{
+		asmArch: asmArch{as: as},
+		// the instructions are 2, 4 or 6 bytes, big endian
+		byteWriter: byteWriter{binary.BigEndian, [4]string{"", "WORD", "", "BYTE"}},
+	}, nil
+}
+
+// nopr
+var s390xNop = []byte{0x07, 0x00}
+
+func (aa *archS390x) CommentTokens() []string {
+	return []string{"#"}
+}
+
+var (
+	reS390xAghi = regexp.MustCompile(`^%r15, (-?\d+)$`)
+	reS390xLay  = regexp.MustCompile(`^%r15, (-?\d+)\(%r15\)$`)
+	reS390xLmg  = regexp.MustCompile(`^%r(\d+), %r15, (\d+)\(%r15\)$`)
+)
+
+var s390xConds = []string{
+	"o", "h", "p", "nle", "l", "m", "nhe", "lh", "ne", "nz", "e", "z", "nlh", "he", "nl", "nm", "le", "nh", "np", "no",
+}
+
+func isS390xCond(s string) bool {
+	for _, v := range s390xConds {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// the compare and branch, the ones to the label or to the address
+var s390xCmpBranches = map[string]bool{
+	"crj": true, "cgrj": true, "clrj": true, "clgrj": true, "cij": true, "cgij": true, "clij": true, "clgij": true,
+	"crb": false, "cgrb": false, "clrb": false, "clgrb": false, "cib": false, "cgib": false, "clib": false, "clgib": false,
+}
+
+// s390xBranch returns the kind of the branch mnemonic, target is false
+// for the ones by register or address.
+func s390xBranch(mnemo string) (kind InstrKind, target bool) {
+	switch mnemo {
+	case "j", "jg":
+		return InstrKind_Jmp, true
+	case "br", "b", "bi":
+		return InstrKind_Indirect_Jmp, false
+	case "brasl", "bras":
+		return InstrKind_Call, true
+	case "basr", "balr":
+		return InstrKind_Indirect_Call, false
+	case "brc", "brcl", "brct", "brctg", "brcth", "brxh", "brxle", "brxhg", "brxlg":
+		return InstrKind_Cond_Jmp, true
+	}
+
+	for k, v := range s390xCmpBranches {
+		if strings.HasPrefix(mnemo, k) && (len(mnemo) == len(k) || isS390xCond(mnemo[len(k):])) {
+			return InstrKind_Cond_Jmp, v
+		}
+	}
+
+	switch {
+	// jgne .LBB0_1
+	case strings.HasPrefix(mnemo, "jg") && isS390xCond(mnemo[2:]):
+		return InstrKind_Cond_Jmp, true
+	// jne .LBB0_1
+	case strings.HasPrefix(mnemo, "j") && isS390xCond(mnemo[1:]):
+		return InstrKind_Cond_Jmp, true
+	// ber %r14
+	case len(mnemo) > 2 && mnemo[0] == 'b' && mnemo[len(mnemo)-1] == 'r' && isS390xCond(mnemo[1:len(mnemo)-1]):
+		return InstrKind_Cond_Jmp, false
+	}
+	return InstrKind_Normal, false
+}
+
+// the relative long instructions, the last operand is the pc relative
+// address as the branches
+var s390xRelLong = map[string]bool{
+	"larl": true, "lrl": true, "lgrl": true, "lgfrl": true, "llgfrl": true,
+	"lhrl": true, "lghrl": true, "llhrl": true, "llghrl": true,
+	"strl": true, "stgrl": true, "sthrl": true,
+	"crl": true, "cgrl": true, "cgfrl": true, "chrl": true, "cghrl": true,
+	"clrl": true, "clgrl": true, "clgfrl": true, "clhrl": true, "clghrl": true,
+	"pfdrl": true, "exrl": true,
+}
+
+func isS390xPcRel(mnemo string) bool {
+	if s390xRelLong[mnemo] {
+		return true
+	}
+	_, target := s390xBranch(mnemo)
+	return target
+}
+
+func (aa *archS390x) Instr(ea int64, mnemo string, opers string, los []LabelOperand) (_ Instr, err error) {
+	if len(los) > 0 {
+		// brasl %r14, sq@PLT
+		opers = strings.ReplaceAll(opers, "%d@PLT", "%d")
+
+		// lgrl %r1, sym@GOT
+		// there is no got, the symbol is always local, use the address directly
+		if strings.HasSuffix(opers, "%d@GOT") {
+			if mnemo != "lgrl" {
+				err = fmt.Errorf("unexpected got load: %s %s", mnemo, opers)
+				return
+			}
+			mnemo, opers = "larl", strings.TrimSuffix(opers, "@GOT")
+		}
+
+		// larl %r1, sym@GOTENT
+		if strings.Contains(opers, "%d@") {
+			err = fmt.Errorf("unsupported relocation: %s %s", mnemo, opers)
+			return
+		}
+	}
+
+	ib := &instrBase{
+		kind:  InstrKind_Normal,
+		ea:    ea,
+		mnemo: mnemo,
+		opers: opers,
+		los:   los,
+	}
+
+	if len(los) == 0 {
+		if ib.data, err = aa.asm(mnemo, opers, ea); err != nil {
+			return
+		}
+	}
+
+	// aghi %r15, -160
+	// lay %r15, -4096(%r15)
+	// lmg %r14, %r15, 272(%r15)
+	// the registers are saved at 8*N of the frame of the caller
+	var res []string
+	switch mnemo {
+	case "aghi", "agfi":
+		res = reS390xAghi.FindStringSubmatch(opers)
+	case "lay":
+		res = reS390xLay.FindStringSubmatch(opers)
+	case "lmg":
+		if res = reS390xLmg.FindStringSubmatch(opers); len(res) > 0 {
+			var r, off int64
+			if r, err = strconv.ParseInt(res[1], 10, 64); err != nil {
+				return
+			}
+			if off, err = strconv.ParseInt(res[2], 10, 64); err != nil {
+				return
+			}
+			ib.sp = off - r*8
+			return ib, nil
+		}
+	}
+	if len(res) > 0 {
+		if ib.sp, err = strconv.ParseInt(res[1], 10, 64); err != nil {
+			return
+		}
+		return ib, nil
+	}
+
+	// sgr %r15, %r1
+	// lgr %r15, %r1
+	switch mnemo {
+	case "agr", "sgr", "lgr", "agrk", "sgrk":
+		ib.dynsp = isDynSP(splitOperands(opers), 0, "%r15", "%r11")
+	}
+
+	if mnemo == ".p2align" {
+		ib.kind = InstrKind_P2Align
+		return instrRebuild{instrBase: ib, asm: aa.asm}, nil
+	}
+
+	switch {
+	case isData(mnemo):
+		ib.kind = InstrKind_Data
+	case mnemo == "br" && opers == "%r14":
+		ib.kind = InstrKind_Ret
+	default:
+		ib.kind, _ = s390xBranch(mnemo)
+	}
+
+	if len(los) > 0 {
+		var stub int64
+		if ib.kind == InstrKind_Call || ib.kind == InstrKind_Jmp || ib.kind == InstrKind_Cond_Jmp {
+			stub = ea
+		}
+		il := instrLabel{instrBase: ib, asm: aa.asm, stub: stub}
+		if ib.data, err = aa.asm(mnemo, il.Operands(), ea); err != nil {
+			return
+		}
+		return il, nil
+	}
+
+	return ib, nil
+}
+
+func (aa *archS390x) WriteProg(w io.Writer, p *Prog) error {
+	// TEXT symbols are only 16 byte aligned
+	if err := p.checkAlign(4); err != nil {
+		return err
+	}
+	return aa.writeProg(w, p)
+}
+
+func (aa *archS390x) EntryBlock() (*BasicBlock, error) {
+	return buildEntryBlock(aa.asm,
+		"larl", "%r2, 0",
+		"stg", "%r2, 8(%r15)",
+		"br", "%r14",
+	)
+}
+
+func (aa *archS390x) WriteHead(w io.Writer) (err error) {
+	return
+}
+
+func (aa *archS390x) WriteFunc(w io.Writer, f *Function, spsize, fpos int64) (err error) {
+	if spsize != 0 {
+		// the register save area for the callee
+		if _, err = fmt.Fprintf(w, `
+_entry:
+	MOVD 16(g), R10
+	MOVD $-%d(R15), R11
+	CMPUBGE R10, R11, _stack_grow
+`, spsize+160); err != nil {
+			return
+		}
+	}
+
+	if _, err = fmt.Fprintf(w, "\n%s:\n", f.Name[1:]); err != nil {
+		return
+	}
+
+	// r2-r6, f0, f2, f4, f6
+	getReg := func(idx int, fp bool) string {
+		if fp {
+			return "F" + strconv.Itoa(idx*2)
+		}
+		return "R" + strconv.Itoa(idx+2)
+	}
+
+	// the integers are extended to 64-bit by the sign of the type in the abi
+	getOp := func(sz int, fp, signed, load bool) string {
+		switch sz {
+		case 1:
+			if load && !signed {
+				return "MOVBZ"
+			}
+			return "MOVB"
+		case 2:
+			if load && !signed {
+				return "MOVHZ"
+			}
+			return "MOVH"
+		case 4:
+			if fp {
+				return "FMOVS"
+			}
+			if load && !signed {
+				return "MOVWZ"
+			}
+			return "MOVW"
+		case 8:
+			if fp {
+				return "FMOVD"
+			}
+			return "MOVD"
+		default:
+			panic(fmt.Sprintf("unexpected parameter size: %d", sz))
+		}
+	}
+
+	var ri, fi, soff int
+	nextOff := func(sz int) (r int) {
+		r = (soff + sz - 1) &^ (sz - 1)
+		soff = r + sz
+		return
+	}
+	nextReg := func(fp bool) (string, error) {
+		if fp {
+			if fi == 4 {
+				return "", errors.New("too many float arguments")
+			}
+			fi++
+			return getReg(fi-1, true), nil
+		}
+		if ri == 5 {
+			return "", errors.New("too many integer arguments")
+		}
+		ri++
+		return getReg(ri-1, false), nil
+	}
+	for _, v := range f.Args {
+		reg, err1 := nextReg(v.IsFloat)
+		if err1 != nil {
+			return newDiag(DiagKind_UnsupportedParam, f.Pos, fmt.Errorf("%s: %w", f.Name, err1))
+		}
+		if _, err = fmt.Fprintf(w, "\t%s %s+%d(FP), %s\n",
+			getOp(v.Size, v.IsFloat, v.Signed, true),
+			v.Name, nextOff(v.Size), reg,
+		); err != nil {
+			return
+		}
+	}
+
+	// r7 and r15 are callee-saved, the caller allocates the 160 bytes of
+	// the register save area, r0 is zero in go
+	if _, err = fmt.Fprintf(w, `	MOVD ·_subr%s(SB), R1
+	MOVD R14, R7
+	SUB $160, R15
+	BL R1
+	ADD $160, R15
+	XOR R0, R0
+	MOVD R7, R14
+`, f.Name); err != nil {
+		return
+	}
+
+	soff = nextOff(8)
+	if f.Ret != nil {
+		if _, err = fmt.Fprintf(w, "\t%s %s, %s+%d(FP)\n",
+			getOp(f.Ret.Size, f.Ret.IsFloat, f.Ret.Signed, false), getReg(0, f.Ret.IsFloat),
+			f.Ret.Name, nextOff(f.Ret.Size)); err != nil {
+			return
+		}
+	}
+	if _, err = fmt.Fprint(w, "\tRET\n"); err != nil {
+		return
+	}
+
+	if spsize != 0 {
+		if _, err = fmt.Fprintf(w, `
+_stack_grow:
+	MOVD LR, R5
+	CALL runtime·morestack_noctxt<>(SB)
+	JMP _entry
+`); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (aa *archS390x) SubrEntry(w io.Writer) (string, error) {
+	return "", nil
+}
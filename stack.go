@@ -0,0 +1,323 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// spKind is the kind of the abstract value of a register.
+type spKind int
+
+const (
+	spUnknown spKind = iota
+	spConst
+	// an offset from sp at the function entry
+	spRel
+)
+
+type spValue struct {
+	kind spKind
+	val  int64
+}
+
+// spState are the values of the registers, the missing ones are unknown.
+// sp is "sp".
+type spState map[string]spValue
+
+func (st spState) clone() spState {
+	ret := make(spState, len(st))
+	for k, v := range st {
+		ret[k] = v
+	}
+	return ret
+}
+
+// join keeps the values that st and o agree on.
+func (st spState) join(o spState) (changed bool) {
+	for k, v := range st {
+		if o[k] != v {
+			delete(st, k)
+			changed = true
+		}
+	}
+	return
+}
+
+// spArch interprets the instructions on the registers holding the stack
+// addresses and the constants, the archs without it count SPDiff.
+type spArch interface {
+	SPEval(ins Instr, st spState) error
+}
+
+// bbName is the label of bb, or its address.
+func bbName(bb *BasicBlock) string {
+	if bb.ID != "" {
+		return bb.ID
+	}
+	return fmt.Sprintf("@%d", bb.EA())
+}
+
+// spDepth is the max stack depth of the function at entry, the sp of all
+// the paths must agree at the merge points and be restored at the returns.
+// the local functions called count theirs.
+func (p *Prog) spDepth(entry *BasicBlock, sa spArch) (depth int64, err error) {
+	if d, ok := p.depths[entry]; ok {
+		return d, nil
+	}
+	if p.depths == nil {
+		p.depths = make(map[*BasicBlock]int64)
+		p.inDepth = make(map[*BasicBlock]bool)
+	}
+	if p.inDepth[entry] {
+		// the recursion is not counted
+		return 0, nil
+	}
+	p.inDepth[entry] = true
+	defer delete(p.inDepth, entry)
+
+	in := map[*BasicBlock]spState{entry: {"sp": {spRel, 0}}}
+	queue := []*BasicBlock{entry}
+	queued := map[*BasicBlock]bool{entry: true}
+	for len(queue) > 0 {
+		bb := queue[0]
+		queue = queue[1:]
+		queued[bb] = false
+
+		st := in[bb].clone()
+		for _, v := range bb.Instrs {
+			if v.Kind() == InstrKind_Call {
+				if lo := v.LabelOperand(); lo != nil && lo.BB() != nil {
+					callee, err := p.spDepth(lo.BB(), sa)
+					if err != nil {
+						return 0, err
+					}
+					if d := callee - st["sp"].val; d > depth {
+						depth = d
+					}
+				}
+			}
+			if err = sa.SPEval(v, st); err != nil {
+				return 0, newDiag(DiagKind_Stack, v.Pos(), err)
+			}
+			sp := st["sp"]
+			if sp.kind != spRel {
+				return 0, newDiag(DiagKind_Stack, v.Pos(), fmt.Errorf("unknown sp after %s %s", v.Mnemonic(), v.Operands()))
+			}
+			if -sp.val > depth {
+				depth = -sp.val
+			}
+		}
+
+		// the returns and the tail calls
+		last := bb.Last()
+		if k := last.Kind(); k == InstrKind_Ret || k == InstrKind_Indirect_Jmp && len(bb.Succs) == 0 {
+			if sp := st["sp"].val; sp != 0 {
+				return 0, newDiag(DiagKind_Stack, last.Pos(), fmt.Errorf("sp is not restored at %s: %d", last.Mnemonic(), sp))
+			}
+		}
+
+		for _, v := range bb.Succs {
+			old, ok := in[v]
+			if !ok {
+				in[v] = st.clone()
+			} else if old["sp"] != st["sp"] {
+				return 0, newDiag(DiagKind_Stack, v.Instrs[0].Pos(), fmt.Errorf("the paths reach %s with sp %d and %d", bbName(v), old["sp"].val, st["sp"].val))
+			} else if !old.join(st) {
+				continue
+			}
+			if !queued[v] {
+				queued[v] = true
+				queue = append(queue, v)
+			}
+		}
+	}
+	p.depths[entry] = depth
+	return
+}
+
+// sumDepth is the max stack depth of the function at entry by the sum of
+// the sp adjustments, a block reached by the paths of different sp takes
+// the deepest. the local functions called count theirs.
+func (p *Prog) sumDepth(entry *BasicBlock) (depth int64, err error) {
+	if d, ok := p.depths[entry]; ok {
+		return d, nil
+	}
+	if p.depths == nil {
+		p.depths = make(map[*BasicBlock]int64)
+		p.inDepth = make(map[*BasicBlock]bool)
+	}
+	if p.inDepth[entry] {
+		// the recursion is not counted
+		return 0, nil
+	}
+	p.inDepth[entry] = true
+	defer delete(p.inDepth, entry)
+
+	in := map[*BasicBlock]int64{entry: 0}
+	updates := make(map[*BasicBlock]int)
+	queue := []*BasicBlock{entry}
+	queued := map[*BasicBlock]bool{entry: true}
+	for len(queue) > 0 {
+		bb := queue[0]
+		queue = queue[1:]
+		queued[bb] = false
+
+		sp := in[bb]
+		for _, v := range bb.Instrs {
+			if k := v.Kind(); k == InstrKind_Call || k == InstrKind_Indirect_Call {
+				var callee int64
+				if lo := v.LabelOperand(); lo != nil && lo.BB() != nil {
+					if callee, err = p.sumDepth(lo.BB()); err != nil {
+						return
+					}
+				}
+				if d := callee - (sp + v.SPDiff()); d > depth {
+					depth = d
+				}
+				continue
+			}
+			sp += v.SPDiff()
+			if -sp > depth {
+				depth = -sp
+			}
+		}
+
+		for _, v := range bb.Succs {
+			if old, ok := in[v]; ok && old <= sp {
+				continue
+			}
+			in[v] = sp
+			// sp is lowered more times than the blocks only by a loop
+			if updates[v]++; updates[v] > len(p.bbs) {
+				return 0, newDiag(DiagKind_Stack, v.Instrs[0].Pos(), fmt.Errorf("sp grows in the loop at %s", bbName(v)))
+			}
+			if !queued[v] {
+				queued[v] = true
+				queue = append(queue, v)
+			}
+		}
+	}
+	p.depths[entry] = depth
+	return
+}
+
+// isDynSP reports whether the instruction sets sp by a register, ops[dst]
+// is the destination. the restores from the frame pointers fps are not, the
+// constant adjustments are matched before.
+func isDynSP(ops []string, dst int, sp string, fps ...string) bool {
+	if dst < 0 || dst >= len(ops) || ops[dst] != sp {
+		return false
+	}
+	for i, v := range ops {
+		if i == dst {
+			continue
+		}
+		for _, tok := range strings.FieldsFunc(v, func(c rune) bool { return strings.ContainsRune("(), ", c) }) {
+			for _, fp := range fps {
+				if tok == fp {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// callees are the local functions called by the function at entry, the
+// tail calls are in its blocks.
+func (p *Prog) callees(entry *BasicBlock) (ret []*BasicBlock) {
+	visited := map[*BasicBlock]bool{entry: true}
+	queue := []*BasicBlock{entry}
+	for len(queue) > 0 {
+		bb := queue[0]
+		queue = queue[1:]
+		for _, v := range bb.Instrs {
+			if v.Kind() != InstrKind_Call {
+				continue
+			}
+			if lo := v.LabelOperand(); lo != nil && lo.BB() != nil {
+				ret = appendBBs(ret, lo.BB())
+			}
+		}
+		for _, v := range bb.Succs {
+			if !visited[v] {
+				visited[v] = true
+				queue = append(queue, v)
+			}
+		}
+	}
+	return
+}
+
+// recursion is a cycle of the calls from the function at entry.
+func (p *Prog) recursion(entry *BasicBlock) []*BasicBlock {
+	// 1 is on the path, 2 is done
+	state := make(map[*BasicBlock]int)
+	var path []*BasicBlock
+	var visit func(fn *BasicBlock) []*BasicBlock
+	visit = func(fn *BasicBlock) []*BasicBlock {
+		state[fn] = 1
+		path = append(path, fn)
+		for _, v := range p.callees(fn) {
+			switch state[v] {
+			case 1:
+				for i, o := range path {
+					if o == v {
+						return append(append([]*BasicBlock{}, path[i:]...), v)
+					}
+				}
+			case 0:
+				if cycle := visit(v); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[fn] = 2
+		return nil
+	}
+	return visit(entry)
+}
+
+// checkBounded fails on the recursion, the sp set by a register and the
+// indirect calls in the function at entry and its callees, their stacks
+// have no static bound.
+func (p *Prog) checkBounded(entry *BasicBlock) error {
+	if cycle := p.recursion(entry); cycle != nil {
+		names := make([]string, len(cycle))
+		for i, v := range cycle {
+			names[i] = strings.TrimPrefix(bbName(v), p.symPrefix)
+		}
+		return newDiag(DiagKind_Stack, cycle[0].Instrs[0].Pos(), fmt.Errorf("recursion %s", strings.Join(names, " -> ")))
+	}
+	// arm64 tracks the registers
+	_, tracked := p.arch.(spArch)
+
+	visited := map[*BasicBlock]bool{entry: true}
+	queue := []*BasicBlock{entry}
+	for len(queue) > 0 {
+		bb := queue[0]
+		queue = queue[1:]
+		next := append([]*BasicBlock{}, bb.Succs...)
+		for _, v := range bb.Instrs {
+			switch {
+			case !tracked && v.DynSP():
+				return newDiag(DiagKind_Stack, v.Pos(), fmt.Errorf("sp is set by a register: %s %s", v.Mnemonic(), v.Operands()))
+			case v.Kind() == InstrKind_Indirect_Call:
+				return newDiag(DiagKind_Stack, v.Pos(), fmt.Errorf("the stack of the indirect call is unknown: %s", strings.TrimSpace(v.Mnemonic()+" "+v.Operands())))
+			case v.Kind() == InstrKind_Indirect_Jmp && len(bb.Succs) == 0:
+				return newDiag(DiagKind_Stack, v.Pos(), fmt.Errorf("the stack of the indirect tail call is unknown: %s", strings.TrimSpace(v.Mnemonic()+" "+v.Operands())))
+			}
+			if lo := v.LabelOperand(); v.Kind() == InstrKind_Call && lo != nil && lo.BB() != nil {
+				next = append(next, lo.BB())
+			}
+		}
+		for _, v := range next {
+			if !visited[v] {
+				visited[v] = true
+				queue = append(queue, v)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os/exec"
+	"testing"
+)
+
+func TestStackSizesIndirect(t *testing.T) {
+	j := &job{ofile: "testdata/stack/foo_arm64.s", ifile: "testdata/stack/foo.s"}
+	if err := j.fill(); err != nil {
+		t.Fatal(err)
+	}
+	p, arch, funcs, _, err := j.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer arch.Close()
+
+	// every unbounded function is reported, __bounded has its size
+	_, err = p.StackSizes(funcs)
+	ds := diagsOf(err)
+	if len(ds) != 2 {
+		t.Fatalf("got %v, want 2 diagnostics", err)
+	}
+	for i, line := range []int{10, 21} {
+		if ds[i].Kind != DiagKind_Stack || ds[i].Pos.line != line {
+			t.Errorf("got %s %v, want stack at line %d", ds[i].Kind, ds[i], line)
+		}
+	}
+
+	for _, f := range funcs {
+		if f.Name != "__bounded" {
+			continue
+		}
+		if n, err := p.StackSize(f); err != nil || n != 4096 {
+			t.Errorf("__bounded = %d, %v, want 4096", n, err)
+		}
+	}
+}
+
+// the second call of helper is deeper by the pushes, the first one must
+// not hide it
+func TestStackSizeCalledTwice(t *testing.T) {
+	if _, err := exec.LookPath(llvmMCPath); err != nil {
+		t.Skip("amd64 needs llvm-mc")
+	}
+	j := &job{ofile: "testdata/stack/twice_amd64.s", ifile: "testdata/stack/twice.s"}
+	if err := j.fill(); err != nil {
+		t.Fatal(err)
+	}
+	p, arch, funcs, _, err := j.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer arch.Close()
+
+	if n, err := p.StackSize(funcs[0]); err != nil || n != 4136 {
+		t.Errorf("__twice = %d, %v, want 4136", n, err)
+	}
+}
@@ -0,0 +1,31 @@
+	.text
+	.globl	apply
+	.p2align	2
+	.type	apply,@function
+apply:
+	stp	x29, x30, [sp, #-16]!
+	mov	x29, sp
+	mov	x8, x0
+	mov	x0, x1
+	blr	x8
+	ldp	x29, x30, [sp], #16
+	ret
+	.size	apply, .-apply
+
+	.globl	jump
+	.p2align	2
+	.type	jump,@function
+jump:
+	mov	x8, x0
+	mov	x0, x1
+	br	x8
+	.size	jump, .-jump
+
+	.globl	bounded
+	.p2align	2
+	.type	bounded,@function
+bounded:
+	mov	x8, x0
+	mov	x0, x1
+	br	x8
+	.size	bounded, .-bounded
@@ -0,0 +1,10 @@
+package foo
+
+import "unsafe"
+
+func __apply(fn unsafe.Pointer, x int64) (ret int64)
+
+func __jump(fn unsafe.Pointer, x int64) (ret int64)
+
+//nocgo:stack 4096
+func __bounded(fn unsafe.Pointer, x int64) (ret int64)
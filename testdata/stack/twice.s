@@ -0,0 +1,29 @@
+	.text
+	.p2align	4, 0x90
+	.type	helper,@function
+helper:
+	subq	$4096, %rsp
+	movq	$0, (%rsp)
+	addq	$4096, %rsp
+	retq
+.Lfunc_end0:
+	.size	helper, .Lfunc_end0-helper
+
+	.globl	twice
+	.p2align	4, 0x90
+	.type	twice,@function
+twice:
+	callq	helper
+	pushq	%rbx
+	pushq	%r12
+	pushq	%r13
+	pushq	%r14
+	callq	helper
+	popq	%r14
+	popq	%r13
+	popq	%r12
+	popq	%rbx
+	retq
+.Lfunc_end1:
+	.size	twice, .Lfunc_end1-twice
+	.section	".note.GNU-stack","",@progbits
@@ -0,0 +1,75 @@
+	.text
+	.file	"foo.ll"
+	.globl	sum                             # -- Begin function sum
+	.p2align	4, 0x90
+	.type	sum,@function
+sum:                                    # @sum
+	.cfi_startproc
+# %bb.0:                                # %entry
+	xorl	%eax, %eax
+	testq	%rsi, %rsi
+	jle	.LBB0_2
+	.p2align	4, 0x90
+.LBB0_1:                                # %loop
+                                        # =>This Inner Loop Header: Depth=1
+	addq	(%rdi), %rax
+	addq	$8, %rdi
+	decq	%rsi
+	jne	.LBB0_1
+.LBB0_2:                                # %done
+	retq
+.Lfunc_end0:
+	.size	sum, .Lfunc_end0-sum
+	.cfi_endproc
+                                        # -- End function
+	.p2align	4, 0x90                         # -- Begin function sq
+	.type	sq,@function
+sq:                                     # @sq
+	.cfi_startproc
+# %bb.0:
+	mulsd	%xmm0, %xmm0
+	retq
+.Lfunc_end1:
+	.size	sq, .Lfunc_end1-sq
+	.cfi_endproc
+                                        # -- End function
+	.section	.rodata.cst8,"aM",@progbits,8
+	.p2align	3                               # -- Begin function scale
+.LCPI2_0:
+	.quad	0x3ff8000000000000              # double 1.5
+	.text
+	.globl	scale
+	.p2align	4, 0x90
+	.type	scale,@function
+scale:                                  # @scale
+	.cfi_startproc
+# %bb.0:
+	pushq	%rax
+	.cfi_def_cfa_offset 16
+	cvtsi2sd	%edi, %xmm1
+	mulsd	%xmm1, %xmm0
+	callq	sq
+	addsd	.LCPI2_0(%rip), %xmm0
+	popq	%rax
+	.cfi_def_cfa_offset 8
+	retq
+.Lfunc_end2:
+	.size	scale, .Lfunc_end2-scale
+	.cfi_endproc
+                                        # -- End function
+	.text
+	.globl	ext                             # -- Begin function ext
+	.p2align	4, 0x90
+	.type	ext,@function
+ext:                                    # @ext
+	.cfi_startproc
+# %bb.0:
+	movslq	%edi, %rcx
+	movslq	%esi, %rax
+	addq	%rcx, %rax
+	retq
+.Lfunc_end3:
+	.size	ext, .Lfunc_end3-ext
+	.cfi_endproc
+                                        # -- End function
+	.section	".note.GNU-stack","",@progbits
@@ -0,0 +1,11 @@
+package foo
+
+//go:noescape
+func __sum(p *int64, n int64) (ret int64)
+
+//nocgo:nativestack
+//go:noescape
+func __scale(x float64, k int32) (ret float64)
+
+//go:noescape
+func __ext(a int8, b int16) (ret int64)
@@ -0,0 +1,99 @@
+// +build !noasm !appengine
+// Code generated by nocgo, DO NOT EDIT.
+
+#include "go_asm.h"
+#include "funcdata.h"
+#include "textflag.h"
+
+TEXT ·__native_entry__(SB), NOSPLIT, $0
+	NO_LOCAL_POINTERS
+	LONG $0xf9058d48; WORD $0xffff; BYTE $0xff // leaq	-7(%rip), %rax
+	LONG $0x24448948; BYTE $0x8 // movq	%rax, 8(%rsp)
+	BYTE $0xc3 // retq	
+	WORD $0x9090; BYTE $0x90
+
+// sum:
+	WORD $0xc031 // xorl	%eax, %eax
+	WORD $0x8548; BYTE $0xf6 // testq	%rsi, %rsi
+	WORD $0x157e // jle	44 // .LBB0_2
+	QUAD $0x9090909090909090; BYTE $0x90
+
+// .LBB0_1:
+	WORD $0x348; BYTE $0x7 // addq	(%rdi), %rax
+	LONG $0x8c78348 // addq	$8, %rdi
+	WORD $0xff48; BYTE $0xce // decq	%rsi
+	WORD $0xf475 // jne	32 // .LBB0_1
+
+// .LBB0_2:
+	BYTE $0xc3 // retq	
+	WORD $0x9090; BYTE $0x90
+
+// sq:
+	LONG $0xc0590ff2 // mulsd	%xmm0, %xmm0
+	BYTE $0xc3 // retq	
+	WORD $0x9090; BYTE $0x90
+
+// .LCPI2_0:
+	QUAD $0x3ff8000000000000
+
+// scale:
+	BYTE $0x50 // pushq	%rax
+	LONG $0xcf2a0ff2 // cvtsi2sd	%edi, %xmm1
+	LONG $0xc1590ff2 // mulsd	%xmm1, %xmm0
+	LONG $0xffffe2e8; BYTE $0xff // callq	48 // sq
+	QUAD $0xffffffe205580ff2 // addsd	-30(%rip), %xmm0 // .LCPI2_0
+	BYTE $0x58 // popq	%rax
+	BYTE $0xc3 // retq	
+	QUAD $0x9090909090909090
+
+// ext:
+	WORD $0x6348; BYTE $0xcf // movslq	%edi, %rcx
+	WORD $0x6348; BYTE $0xc6 // movslq	%esi, %rax
+	WORD $0x148; BYTE $0xc8 // addq	%rcx, %rax
+	BYTE $0xc3 // retq	
+
+TEXT ·__ext(SB), NOSPLIT | NOFRAME, $0 - 16
+	NO_LOCAL_POINTERS
+
+_ext:
+	MOVBQSX a+0(FP), DI
+	MOVWQSX b+2(FP), SI
+	MOVQ ·_subr__ext(SB), AX
+	MOVQ SP, BX
+	ANDQ $-16, SP
+	CALL AX
+	MOVQ BX, SP
+	MOVQ AX, ret+8(FP)
+	RET
+
+TEXT ·__scale(SB), NOSPLIT | NOFRAME, $0 - 24
+	NO_LOCAL_POINTERS
+	JMP ·_native__scale(SB)
+
+TEXT ·_ncall__scale(SB), NOSPLIT | NOFRAME, $0 - 32
+	NO_LOCAL_POINTERS
+
+_scale:
+	MOVSD x+0(FP), X0
+	MOVL k+8(FP), DI
+	MOVQ ·_subr__scale(SB), AX
+	MOVQ SP, BX
+	MOVQ nstack+16(FP), SP
+	CALL AX
+	MOVQ BX, SP
+	MOVSD X0, ret+24(FP)
+	RET
+
+TEXT ·__sum(SB), NOSPLIT | NOFRAME, $0 - 24
+	NO_LOCAL_POINTERS
+
+_sum:
+	MOVQ p+0(FP), DI
+	MOVQ n+8(FP), SI
+	MOVQ ·_subr__sum(SB), AX
+	MOVQ SP, BX
+	ANDQ $-16, SP
+	CALL AX
+	MOVQ BX, SP
+	MOVQ AX, ret+16(FP)
+	RET
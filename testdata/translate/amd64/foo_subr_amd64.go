@@ -0,0 +1,70 @@
+// +build !noasm !appengine
+// Code generated by nocgo, DO NOT EDIT.
+
+package foo
+
+import (
+	"runtime"
+	"unsafe"
+)
+
+//go:nosplit
+//go:noescape
+//goland:noinspection ALL
+func __native_entry__() uintptr
+
+var (
+	_subr__ext = __native_entry__() + 96
+	_subr__scale = __native_entry__() + 64
+	_subr__sum = __native_entry__() + 16
+)
+
+const (
+	_stack__ext = 0
+	_stack__scale = 16
+	_stack__sum = 0
+)
+
+// __native_stacks__ are the stacks out of the goroutines, the free tops are
+// in the channel.
+type __native_stacks__ struct {
+	free chan uintptr
+	mem  [][]byte
+}
+
+func __new_native_stacks__(size uintptr) *__native_stacks__ {
+	n := runtime.GOMAXPROCS(0)
+	s := &__native_stacks__{free: make(chan uintptr, n), mem: make([][]byte, n)}
+	for i := range s.mem {
+		s.mem[i] = make([]byte, size)
+		s.free <- (uintptr(unsafe.Pointer(&s.mem[i][0])) + size) &^ 15
+	}
+	return s
+}
+
+var (
+	_nstack__scale = __new_native_stacks__(_stack__scale + 208)
+)
+
+//go:nosplit
+//go:noescape
+func _ncall__scale(x float64, k int32, nstack uintptr) (ret float64)
+
+func _native__scale(x float64, k int32) (ret float64) {
+	nstack := <-_nstack__scale.free
+	ret = _ncall__scale(x, k, nstack)
+	_nstack__scale.free <- nstack
+	return
+}
+
+var (
+	_ = _subr__ext
+	_ = _subr__scale
+	_ = _subr__sum
+)
+
+const (
+	_ = _stack__ext
+	_ = _stack__scale
+	_ = _stack__sum
+)
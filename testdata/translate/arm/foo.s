@@ -0,0 +1,96 @@
+	.text
+	.syntax unified
+	.eabi_attribute	67, "2.09"	@ Tag_conformance
+	.eabi_attribute	6, 10	@ Tag_CPU_arch
+	.eabi_attribute	7, 65	@ Tag_CPU_arch_profile
+	.eabi_attribute	8, 1	@ Tag_ARM_ISA_use
+	.eabi_attribute	9, 2	@ Tag_THUMB_ISA_use
+	.fpu	vfpv3
+	.eabi_attribute	34, 1	@ Tag_CPU_unaligned_access
+	.eabi_attribute	15, 1	@ Tag_ABI_PCS_RW_data
+	.eabi_attribute	16, 1	@ Tag_ABI_PCS_RO_data
+	.eabi_attribute	17, 2	@ Tag_ABI_PCS_GOT_use
+	.eabi_attribute	20, 1	@ Tag_ABI_FP_denormal
+	.eabi_attribute	21, 1	@ Tag_ABI_FP_exceptions
+	.eabi_attribute	23, 3	@ Tag_ABI_FP_number_model
+	.eabi_attribute	24, 1	@ Tag_ABI_align_needed
+	.eabi_attribute	25, 1	@ Tag_ABI_align_preserved
+	.eabi_attribute	28, 1	@ Tag_ABI_VFP_args
+	.eabi_attribute	38, 1	@ Tag_ABI_FP_16bit_format
+	.eabi_attribute	14, 0	@ Tag_ABI_PCS_R9_use
+	.file	"foo.ll"
+	.globl	sum                             @ -- Begin function sum
+	.p2align	2
+	.type	sum,%function
+	.code	32                              @ @sum
+sum:
+	.fnstart
+@ %bb.0:                                @ %entry
+	.save	{r4, r5, r6, lr}
+	push	{r4, r5, r6, lr}
+	subs	r1, r2, #1
+	sbcs	r1, r3, #0
+	blt	.LBB0_4
+@ %bb.1:                                @ %loop.preheader
+	mov	lr, #0
+	mov	r4, #0
+	mov	r12, #0
+	mov	r1, #0
+.LBB0_2:                                @ %loop
+                                        @ =>This Inner Loop Header: Depth=1
+	mov	r5, r0
+	ldr	r6, [r5, lr, lsl #3]!
+	ldr	r5, [r5, #4]
+	adds	r12, r12, r6
+	adc	r1, r1, r5
+	adds	lr, lr, #1
+	adc	r4, r4, #0
+	eor	r5, lr, r2
+	eor	r6, r4, r3
+	orrs	r5, r5, r6
+	bne	.LBB0_2
+@ %bb.3:                                @ %done
+	mov	r0, r12
+	pop	{r4, r5, r6, pc}
+.LBB0_4:
+	mov	r0, #0
+	mov	r1, #0
+	pop	{r4, r5, r6, pc}
+.Lfunc_end0:
+	.size	sum, .Lfunc_end0-sum
+	.fnend
+                                        @ -- End function
+	.p2align	2                               @ -- Begin function sq
+	.type	sq,%function
+	.code	32                              @ @sq
+sq:
+	.fnstart
+@ %bb.0:
+	vmul.f64	d0, d0, d0
+	bx	lr
+.Lfunc_end1:
+	.size	sq, .Lfunc_end1-sq
+	.fnend
+                                        @ -- End function
+	.globl	scale                           @ -- Begin function scale
+	.p2align	2
+	.type	scale,%function
+	.code	32                              @ @scale
+scale:
+	.fnstart
+@ %bb.0:
+	.save	{r11, lr}
+	push	{r11, lr}
+	vmov	s2, r0
+	vcvt.f64.s32	d16, s2
+	vmul.f64	d0, d0, d16
+	bl	sq
+	vmov.f64	d16, #1.500000e+00
+	vadd.f64	d0, d0, d16
+	pop	{r11, pc}
+.Lfunc_end2:
+	.size	scale, .Lfunc_end2-scale
+	.fnend
+                                        @ -- End function
+	.section	".note.GNU-stack","",%progbits
+	.eabi_attribute	30, 1	@ Tag_ABI_optimization_goals
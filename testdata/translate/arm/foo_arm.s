@@ -0,0 +1,112 @@
+// +build !noasm !appengine
+// Code generated by nocgo, DO NOT EDIT.
+
+#include "go_asm.h"
+#include "funcdata.h"
+#include "textflag.h"
+
+TEXT ·__native_entry__(SB), NOSPLIT, $0
+	NO_LOCAL_POINTERS
+	WORD $0xe24f0008 // sub	r0, pc, #8
+	WORD $0xe58d0004 // str	r0, [sp, #4]
+	WORD $0xe12fff1e // bx	lr
+
+// sum:
+	WORD $0xe92d4070 // push	{r4, r5, r6, lr}
+	WORD $0xe2521001 // subs	r1, r2, #1
+	WORD $0xe2d31000 // sbcs	r1, r3, #0
+	WORD $0xba000010 // blt	96 // .LBB0_4
+	WORD $0xe3a0e000 // mov	lr, #0
+	WORD $0xe3a04000 // mov	r4, #0
+	WORD $0xe3a0c000 // mov	r12, #0
+	WORD $0xe3a01000 // mov	r1, #0
+
+// .LBB0_2:
+	WORD $0xe1a05000 // mov	r5, r0
+	WORD $0xe7b5618e // ldr	r6, [r5, lr, lsl #3]!
+	WORD $0xe5955004 // ldr	r5, [r5, #4]
+	WORD $0xe09cc006 // adds	r12, r12, r6
+	WORD $0xe0a11005 // adc	r1, r1, r5
+	WORD $0xe29ee001 // adds	lr, lr, #1
+	WORD $0xe2a44000 // adc	r4, r4, #0
+	WORD $0xe02e5002 // eor	r5, lr, r2
+	WORD $0xe0246003 // eor	r6, r4, r3
+	WORD $0xe1955006 // orrs	r5, r5, r6
+	WORD $0x1afffff4 // bne	44 // .LBB0_2
+	WORD $0xe1a0000c // mov	r0, r12
+	WORD $0xe8bd8070 // pop	{r4, r5, r6, pc}
+
+// .LBB0_4:
+	WORD $0xe3a00000 // mov	r0, #0
+	WORD $0xe3a01000 // mov	r1, #0
+	WORD $0xe8bd8070 // pop	{r4, r5, r6, pc}
+
+// sq:
+	WORD $0xee200b00 // vmul.f64	d0, d0, d0
+	WORD $0xe12fff1e // bx	lr
+
+// scale:
+	WORD $0xe92d4800 // push	{r11, lr}
+	WORD $0xee010a10 // vmov	s2, r0
+	WORD $0xeef80bc1 // vcvt.f64.s32	d16, s2
+	WORD $0xee200b20 // vmul.f64	d0, d0, d16
+	WORD $0xebfffff8 // bl	108 // sq
+	WORD $0xeef70b08 // vmov.f64	d16, #1.500000e+00
+	WORD $0xee300b20 // vadd.f64	d0, d0, d16
+	WORD $0xe8bd8800 // pop	{r11, pc}
+
+TEXT ·__scale(SB), NOSPLIT | NOFRAME, $0 - 20
+	NO_LOCAL_POINTERS
+
+_entry:
+	MOVW 8(g), R8
+	SUB $16, R13, R9
+	CMP R8, R9
+	BLS _stack_grow
+
+_scale:
+	MOVD x+0(FP), F0
+	MOVW k+8(FP), R0
+	MOVW R13, R4
+	MOVW R14, R5
+	BIC $7, R13
+	MOVW ·_subr__scale(SB), R12
+	BL (R12)
+	MOVW R4, R13
+	MOVW R5, R14
+	MOVD F0, ret+12(FP)
+	RET
+
+_stack_grow:
+	MOVW R14, R3
+	CALL runtime·morestack_noctxt<>(SB)
+	JMP _entry
+
+TEXT ·__sum(SB), NOSPLIT | NOFRAME, $0 - 20
+	NO_LOCAL_POINTERS
+
+_entry:
+	MOVW 8(g), R8
+	SUB $24, R13, R9
+	CMP R8, R9
+	BLS _stack_grow
+
+_sum:
+	MOVW p+0(FP), R0
+	MOVW n_lo+4(FP), R2
+	MOVW n_hi+8(FP), R3
+	MOVW R13, R4
+	MOVW R14, R5
+	BIC $7, R13
+	MOVW ·_subr__sum(SB), R12
+	BL (R12)
+	MOVW R4, R13
+	MOVW R5, R14
+	MOVW R0, ret_lo+12(FP)
+	MOVW R1, ret_hi+16(FP)
+	RET
+
+_stack_grow:
+	MOVW R14, R3
+	CALL runtime·morestack_noctxt<>(SB)
+	JMP _entry
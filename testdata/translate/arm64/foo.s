@@ -0,0 +1,60 @@
+	.text
+	.file	"foo.ll"
+	.globl	sum                             // -- Begin function sum
+	.p2align	2
+	.type	sum,@function
+sum:                                    // @sum
+	.cfi_startproc
+// %bb.0:                               // %entry
+	cmp	x1, #1
+	b.lt	.LBB0_4
+// %bb.1:                               // %loop.preheader
+	mov	x8, xzr
+.LBB0_2:                                // %loop
+                                        // =>This Inner Loop Header: Depth=1
+	ldr	x9, [x0], #8
+	subs	x1, x1, #1
+	add	x8, x8, x9
+	b.ne	.LBB0_2
+// %bb.3:                               // %done
+	mov	x0, x8
+	ret
+.LBB0_4:
+	mov	x0, xzr
+	ret
+.Lfunc_end0:
+	.size	sum, .Lfunc_end0-sum
+	.cfi_endproc
+                                        // -- End function
+	.p2align	2                               // -- Begin function sq
+	.type	sq,@function
+sq:                                     // @sq
+	.cfi_startproc
+// %bb.0:
+	fmul	d0, d0, d0
+	ret
+.Lfunc_end1:
+	.size	sq, .Lfunc_end1-sq
+	.cfi_endproc
+                                        // -- End function
+	.globl	scale                           // -- Begin function scale
+	.p2align	2
+	.type	scale,@function
+scale:                                  // @scale
+	.cfi_startproc
+// %bb.0:
+	str	x30, [sp, #-16]!                // 8-byte Folded Spill
+	.cfi_def_cfa_offset 16
+	.cfi_offset w30, -16
+	scvtf	d1, w0
+	fmul	d0, d0, d1
+	bl	sq
+	fmov	d1, #1.50000000
+	fadd	d0, d0, d1
+	ldr	x30, [sp], #16                  // 8-byte Folded Reload
+	ret
+.Lfunc_end2:
+	.size	scale, .Lfunc_end2-scale
+	.cfi_endproc
+                                        // -- End function
+	.section	".note.GNU-stack","",@progbits
@@ -0,0 +1,8 @@
+package foo
+
+//nocgo:nativestack
+//go:noescape
+func __sum(p *int64, n int64) (ret int64)
+
+//go:noescape
+func __scale(x float64, k int32) (ret float64)
@@ -0,0 +1,190 @@
+// +build !noasm !appengine
+// Code generated by nocgo, DO NOT EDIT.
+
+#include "go_asm.h"
+#include "funcdata.h"
+#include "textflag.h"
+
+TEXT ·__native_entry__(SB), NOSPLIT, $0
+	NO_LOCAL_POINTERS
+	PCALIGN $2048
+	WORD $0x10000000 // adr	x0, 0
+	WORD $0xf90007e0 // str	x0, [sp, #8]
+	WORD $0xd65f03c0 // ret	
+
+// sum:
+	WORD $0xf100043f // cmp	x1, #1
+	WORD $0x5400010b // b.lt	48 // .LBB0_4
+	WORD $0xaa1f03e8 // mov	x8, xzr
+
+// .LBB0_2:
+	WORD $0xf8408409 // ldr	x9, [x0], #8
+	WORD $0xf1000421 // subs	x1, x1, #1
+	WORD $0x8b090108 // add	x8, x8, x9
+	WORD $0x54ffffa1 // b.ne	24 // .LBB0_2
+	WORD $0xaa0803e0 // mov	x0, x8
+	WORD $0xd65f03c0 // ret	
+
+// .LBB0_4:
+	WORD $0xaa1f03e0 // mov	x0, xzr
+	WORD $0xd65f03c0 // ret	
+
+// sq:
+	WORD $0x1e600800 // fmul	d0, d0, d0
+	WORD $0xd65f03c0 // ret	
+
+// scale:
+	WORD $0xf81f0ffe // str	x30, [sp, #-16]!
+	WORD $0x1e620001 // scvtf	d1, w0
+	WORD $0x1e610800 // fmul	d0, d0, d1
+	WORD $0x97fffffb // bl	56 // sq
+	WORD $0x1e6f1001 // fmov	d1, #1.50000000
+	WORD $0x1e612800 // fadd	d0, d0, d1
+	WORD $0xf84107fe // ldr	x30, [sp], #16
+	WORD $0xd65f03c0 // ret	
+
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+	NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;NOOP;
+
+	WORD $0x10000000 // adr	x0, 0
+	WORD $0xf90007e0 // str	x0, [sp, #8]
+	WORD $0xd65f03c0 // ret	
+
+// sum:
+	WORD $0xf100043f // cmp	x1, #1
+	WORD $0x5400010b // b.lt	48 // .LBB0_4
+	WORD $0xaa1f03e8 // mov	x8, xzr
+
+// .LBB0_2:
+	WORD $0xf8408409 // ldr	x9, [x0], #8
+	WORD $0xf1000421 // subs	x1, x1, #1
+	WORD $0x8b090108 // add	x8, x8, x9
+	WORD $0x54ffffa1 // b.ne	24 // .LBB0_2
+	WORD $0xaa0803e0 // mov	x0, x8
+	WORD $0xd65f03c0 // ret	
+
+// .LBB0_4:
+	WORD $0xaa1f03e0 // mov	x0, xzr
+	WORD $0xd65f03c0 // ret	
+
+// sq:
+	WORD $0x1e600800 // fmul	d0, d0, d0
+	WORD $0xd65f03c0 // ret	
+
+// scale:
+	WORD $0xf81f0ffe // str	x30, [sp, #-16]!
+	WORD $0x1e620001 // scvtf	d1, w0
+	WORD $0x1e610800 // fmul	d0, d0, d1
+	WORD $0x97fffffb // bl	56 // sq
+	WORD $0x1e6f1001 // fmov	d1, #1.50000000
+	WORD $0x1e612800 // fadd	d0, d0, d1
+	WORD $0xf84107fe // ldr	x30, [sp], #16
+	WORD $0xd65f03c0 // ret	
+
+TEXT ·__scale(SB), NOSPLIT | NOFRAME, $0 - 24
+	NO_LOCAL_POINTERS
+
+_entry:
+	MOVD 16(g), R16
+	SUB	$16, RSP, R17
+	CMP	R16, R17
+	BLS _stack_grow
+
+_scale:
+	FMOVD x+0(FP), F0
+	MOVWU k+8(FP), R0
+	MOVD ·_subr__scale(SB), R1
+	MOVD R29, R19
+	MOVD R30, R20
+	CALL (R1)
+	FMOVD F0, ret+16(FP)
+	MOVD R20, R30
+	MOVD R19, R29
+	RET
+
+_stack_grow:
+	MOVD R30, R3
+	CALL runtime·morestack_noctxt<>(SB)
+	JMP  _entry
+
+TEXT ·__sum(SB), NOSPLIT | NOFRAME, $0 - 24
+	NO_LOCAL_POINTERS
+	JMP ·_native__sum(SB)
+
+TEXT ·_ncall__sum(SB), NOSPLIT | NOFRAME, $0 - 32
+	NO_LOCAL_POINTERS
+
+_sum:
+	MOVD p+0(FP), R0
+	MOVD n+8(FP), R1
+	MOVD ·_subr__sum(SB), R2
+	MOVD R29, R19
+	MOVD R30, R20
+	MOVD RSP, R22
+	MOVD nstack+16(FP), R10
+	MOVD R10, RSP
+	CALL (R2)
+	MOVD R22, RSP
+	MOVD R0, ret+24(FP)
+	MOVD R20, R30
+	MOVD R19, R29
+	RET
@@ -0,0 +1,80 @@
+// +build !noasm !appengine
+// Code generated by nocgo, DO NOT EDIT.
+
+package foo
+
+import (
+	"runtime"
+	"unsafe"
+)
+
+//go:nosplit
+//go:noescape
+//goland:noinspection ALL
+func __native_entry__() uintptr
+
+func alignEntry() uintptr {
+	r := __native_entry__()
+	if r&4095 == 0 {
+		return r
+	}
+
+	r += 2048
+	if r&4095 != 0 {
+		panic("oops")
+	}
+
+	return r
+}
+
+var (
+	_subr__scale = alignEntry() + 64
+	_subr__sum = alignEntry() + 12
+)
+
+const (
+	_stack__scale = 16
+	_stack__sum = 0
+)
+
+// __native_stacks__ are the stacks out of the goroutines, the free tops are
+// in the channel.
+type __native_stacks__ struct {
+	free chan uintptr
+	mem  [][]byte
+}
+
+func __new_native_stacks__(size uintptr) *__native_stacks__ {
+	n := runtime.GOMAXPROCS(0)
+	s := &__native_stacks__{free: make(chan uintptr, n), mem: make([][]byte, n)}
+	for i := range s.mem {
+		s.mem[i] = make([]byte, size)
+		s.free <- (uintptr(unsafe.Pointer(&s.mem[i][0])) + size) &^ 15
+	}
+	return s
+}
+
+var (
+	_nstack__sum = __new_native_stacks__(_stack__sum + 208)
+)
+
+//go:nosplit
+//go:noescape
+func _ncall__sum(p unsafe.Pointer, n int64, nstack uintptr) (ret int64)
+
+func _native__sum(p unsafe.Pointer, n int64) (ret int64) {
+	nstack := <-_nstack__sum.free
+	ret = _ncall__sum(p, n, nstack)
+	_nstack__sum.free <- nstack
+	return
+}
+
+var (
+	_ = _subr__scale
+	_ = _subr__sum
+)
+
+const (
+	_ = _stack__scale
+	_ = _stack__sum
+)
@@ -0,0 +1,30 @@
+define i64 @sum(ptr %p, i64 %n) {
+entry:
+  %c = icmp sgt i64 %n, 0
+  br i1 %c, label %loop, label %done
+loop:
+  %i = phi i64 [ 0, %entry ], [ %i1, %loop ]
+  %s = phi i64 [ 0, %entry ], [ %s1, %loop ]
+  %q = getelementptr i64, ptr %p, i64 %i
+  %v = load i64, ptr %q
+  %s1 = add i64 %s, %v
+  %i1 = add i64 %i, 1
+  %e = icmp eq i64 %i1, %n
+  br i1 %e, label %done, label %loop
+done:
+  %r = phi i64 [ 0, %entry ], [ %s1, %loop ]
+  ret i64 %r
+}
+
+define internal double @sq(double %x) noinline {
+  %y = fmul double %x, %x
+  ret double %y
+}
+
+define double @scale(double %x, i32 %k) {
+  %f = sitofp i32 %k to double
+  %m = fmul double %x, %f
+  %s = call double @sq(double %m)
+  %r = fadd double %s, 1.5
+  ret double %r
+}
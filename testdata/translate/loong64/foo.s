@@ -0,0 +1,114 @@
+	.text
+	.file	"foo.c"
+	.p2align	5
+	.type	sq,@function
+sq:                                     # @sq
+	mul.d	$a0, $a0, $a0
+	ret
+.Lfunc_end0:
+	.size	sq, .Lfunc_end0-sq
+
+	.globl	add                             # -- Begin function add
+	.p2align	5
+	.type	add,@function
+add:                                    # @add
+	.cfi_startproc
+# %bb.0:
+	addi.d	$sp, $sp, -48
+	.cfi_def_cfa_offset 48
+	st.d	$ra, $sp, 40                    # 8-byte Folded Spill
+	st.d	$fp, $sp, 32
+	st.d	$s0, $sp, 24
+	st.d	$s1, $sp, 16
+	st.d	$s2, $sp, 8
+	move	$fp, $a1
+	move	$s0, $a0
+	move	$s1, $zero
+	pcalau12i	$a0, %pc_hi20(tab)
+	addi.d	$s2, $a0, %pc_lo12(tab)
+	.p2align	4
+.LBB1_1:                                # =>This Inner Loop Header: Depth=1
+	andi	$a0, $s1, 3
+	slli.d	$a0, $a0, 3
+	ldx.d	$a0, $s2, $a0
+	bl	%plt(sq)
+	add.d	$s0, $s0, $a0
+	addi.d	$s1, $s1, 1
+	blt	$s1, $fp, .LBB1_1
+# %bb.2:
+	beqz	$s0, .LBB1_4
+# %bb.3:
+	pcalau12i	$a0, %got_pc_hi20(bias)
+	ld.d	$a0, $a0, %got_pc_lo12(bias)
+	ld.d	$a0, $a0, 0
+	add.d	$s0, $s0, $a0
+.LBB1_4:
+	move	$a0, $s0
+	ld.d	$s2, $sp, 8
+	ld.d	$s1, $sp, 16
+	ld.d	$s0, $sp, 24
+	ld.d	$fp, $sp, 32
+	ld.d	$ra, $sp, 40
+	addi.d	$sp, $sp, 48
+	ret
+.Lfunc_end1:
+	.size	add, .Lfunc_end1-add
+	.cfi_endproc
+
+	.globl	helper
+	.p2align	5
+	.type	helper,@function
+helper:
+	movgr2fr.w	$fa1, $a0
+	ffint.d.w	$fa1, $fa1
+	fmul.d	$fa0, $fa1, $fa0
+	pcalau12i	$a0, %pc_hi20(.LCPI2_0)
+	fld.d	$fa1, $a0, %pc_lo12(.LCPI2_0)
+	fadd.d	$fa0, $fa0, $fa1
+	andi	$a0, $a1, 255
+	movgr2fr.w	$fa1, $a0
+	ffint.d.w	$fa1, $fa1
+	fadd.d	$fa0, $fa0, $fa1
+	fcvt.s.d	$fa0, $fa0
+	ret
+.Lfunc_end2:
+	.size	helper, .Lfunc_end2-helper
+
+	.globl	callh
+	.p2align	5
+	.type	callh,@function
+callh:
+	addi.d	$sp, $sp, -16
+	st.d	$ra, $sp, 8
+	lu52i.d	$a1, $zero, 1024
+	movgr2fr.d	$fa0, $a1
+	ori	$a1, $zero, 1
+	bl	%plt(helper)
+	lu12i.w	$a0, 260096
+	movgr2fr.w	$fa1, $a0
+	fadd.s	$fa0, $fa0, $fa1
+	ld.d	$ra, $sp, 8
+	addi.d	$sp, $sp, 16
+	ret
+.Lfunc_end3:
+	.size	callh, .Lfunc_end3-callh
+
+	.section	.rodata.cst8,"aM",@progbits,8
+	.p2align	3
+.LCPI2_0:
+	.dword	0x3ff4000000000000
+	.type	tab,@object
+	.section	.rodata,"a",@progbits
+	.p2align	3
+tab:
+	.dword	1
+	.dword	2
+	.dword	3
+	.dword	4
+	.size	tab, 32
+	.type	bias,@object
+	.p2align	3
+bias:
+	.dword	100
+	.size	bias, 8
+	.section	".note.GNU-stack","",@progbits
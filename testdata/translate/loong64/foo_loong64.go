@@ -0,0 +1,7 @@
+package foo
+
+func __add(a, b int64) (ret int64)
+
+func __helper(a int32, f float64, c uint8) (ret float32)
+
+func __callh(a int32) (ret float32)
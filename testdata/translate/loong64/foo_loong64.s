@@ -0,0 +1,171 @@
+// +build !noasm !appengine
+// Code generated by nocgo, DO NOT EDIT.
+
+#include "go_asm.h"
+#include "funcdata.h"
+#include "textflag.h"
+
+TEXT ·__native_entry__(SB), NOSPLIT, $0
+	NO_LOCAL_POINTERS
+	WORD $0x18000004 // pcaddi	$a0, 0
+	WORD $0x29c02064 // st.d	$a0, $sp, 8
+	WORD $0x4c000020 // ret	
+	WORD $0x3400000
+
+// sq:
+	WORD $0x1d9084 // mul.d	$a0, $a0, $a0
+	WORD $0x4c000020 // ret	
+	WORD $0x3400000
+	WORD $0x3400000
+
+// add:
+	WORD $0x2ff4063 // addi.d	$sp, $sp, -48
+	WORD $0x29c0a061 // st.d	$ra, $sp, 40
+	WORD $0x29c08076 // st.d	$fp, $sp, 32
+	WORD $0x29c06077 // st.d	$s0, $sp, 24
+	WORD $0x29c04078 // st.d	$s1, $sp, 16
+	WORD $0x29c02079 // st.d	$s2, $sp, 8
+	WORD $0x1500b6 // move	$fp, $a1
+	WORD $0x150097 // move	$s0, $a0
+	WORD $0x150018 // move	$s1, $zero
+	WORD $0x1a000004 // pcalau12i	$a0, %pc_hi20(264) // tab
+	WORD $0x2c42099 // addi.d	$s2, $a0, %pc_lo12(264) // tab
+	WORD $0x3400000
+
+// .LBB1_1:
+	WORD $0x3400f04 // andi	$a0, $s1, 3
+	WORD $0x410c84 // slli.d	$a0, $a0, 3
+	WORD $0x380c1324 // ldx.d	$a0, $s2, $a0
+	WORD $0x57ffb7ff // bl	16 // sq
+	WORD $0x1092f7 // add.d	$s0, $s0, $a0
+	WORD $0x2c00718 // addi.d	$s1, $s1, 1
+	WORD $0x63ffeb16 // blt	$s1, $fp, 80 // .LBB1_1
+	WORD $0x400016e0 // beqz	$s0, 128 // .LBB1_4
+	WORD $0x1a000004 // pcalau12i	$a0, %pc_hi20(296) // bias
+	WORD $0x2c4a084 // addi.d	$a0, $a0, %pc_lo12(296) // bias
+	WORD $0x28c00084 // ld.d	$a0, $a0, 0
+	WORD $0x1092f7 // add.d	$s0, $s0, $a0
+
+// .LBB1_4:
+	WORD $0x1502e4 // move	$a0, $s0
+	WORD $0x28c02079 // ld.d	$s2, $sp, 8
+	WORD $0x28c04078 // ld.d	$s1, $sp, 16
+	WORD $0x28c06077 // ld.d	$s0, $sp, 24
+	WORD $0x28c08076 // ld.d	$fp, $sp, 32
+	WORD $0x28c0a061 // ld.d	$ra, $sp, 40
+	WORD $0x2c0c063 // addi.d	$sp, $sp, 48
+	WORD $0x4c000020 // ret	
+
+// helper:
+	WORD $0x114a481 // movgr2fr.w	$fa1, $a0
+	WORD $0x11d2021 // ffint.d.w	$fa1, $fa1
+	WORD $0x1050020 // fmul.d	$fa0, $fa1, $fa0
+	WORD $0x1a000004 // pcalau12i	$a0, %pc_hi20(256) // .LCPI2_0
+	WORD $0x2b840081 // fld.d	$fa1, $a0, %pc_lo12(256) // .LCPI2_0
+	WORD $0x1010400 // fadd.d	$fa0, $fa0, $fa1
+	WORD $0x343fca4 // andi	$a0, $a1, 255
+	WORD $0x114a481 // movgr2fr.w	$fa1, $a0
+	WORD $0x11d2021 // ffint.d.w	$fa1, $fa1
+	WORD $0x1010400 // fadd.d	$fa0, $fa0, $fa1
+	WORD $0x1191800 // fcvt.s.d	$fa0, $fa0
+	WORD $0x4c000020 // ret	
+
+// callh:
+	WORD $0x2ffc063 // addi.d	$sp, $sp, -16
+	WORD $0x29c02061 // st.d	$ra, $sp, 8
+	WORD $0x3100005 // lu52i.d	$a1, $zero, 1024
+	WORD $0x114a8a0 // movgr2fr.d	$fa0, $a1
+	WORD $0x3800405 // ori	$a1, $zero, 1
+	WORD $0x57ffbfff // bl	160 // helper
+	WORD $0x147f0004 // lu12i.w	$a0, 260096
+	WORD $0x114a481 // movgr2fr.w	$fa1, $a0
+	WORD $0x1008400 // fadd.s	$fa0, $fa0, $fa1
+	WORD $0x28c02061 // ld.d	$ra, $sp, 8
+	WORD $0x2c04063 // addi.d	$sp, $sp, 16
+	WORD $0x4c000020 // ret	
+
+// .LCPI2_0:
+	WORD $0x0 // .dword	0x3ff4000000000000
+	WORD $0x3ff40000
+
+// tab:
+	WORD $0x1 // .dword	1
+	WORD $0x0
+	WORD $0x2 // .dword	2
+	WORD $0x0
+	WORD $0x3 // .dword	3
+	WORD $0x0
+	WORD $0x4 // .dword	4
+	WORD $0x0
+
+// bias:
+	WORD $0x64 // .dword	100
+	WORD $0x0
+
+TEXT ·__add(SB), NOSPLIT | NOFRAME, $0 - 24
+	NO_LOCAL_POINTERS
+
+_entry:
+	MOVV 16(g), R19
+	ADDV $-64, R3, R20
+	BGEU R19, R20, _stack_grow
+
+_add:
+	MOVV a+0(FP), R4
+	MOVV b+8(FP), R5
+	MOVV ·_subr__add(SB), R20
+	MOVV R1, R24
+	MOVV R3, R23
+	AND $-16, R3
+	JAL (R20)
+	MOVV R23, R3
+	MOVV R24, R1
+	MOVV R4, ret+16(FP)
+	RET
+
+_stack_grow:
+	MOVV R1, R31
+	JAL runtime·morestack_noctxt<>(SB)
+	JMP _entry
+
+TEXT ·__callh(SB), NOSPLIT | NOFRAME, $0 - 16
+	NO_LOCAL_POINTERS
+
+_entry:
+	MOVV 16(g), R19
+	ADDV $-32, R3, R20
+	BGEU R19, R20, _stack_grow
+
+_callh:
+	MOVW a+0(FP), R4
+	MOVV ·_subr__callh(SB), R20
+	MOVV R1, R24
+	MOVV R3, R23
+	AND $-16, R3
+	JAL (R20)
+	MOVV R23, R3
+	MOVV R24, R1
+	MOVF F0, ret+8(FP)
+	RET
+
+_stack_grow:
+	MOVV R1, R31
+	JAL runtime·morestack_noctxt<>(SB)
+	JMP _entry
+
+TEXT ·__helper(SB), NOSPLIT | NOFRAME, $0 - 32
+	NO_LOCAL_POINTERS
+
+_helper:
+	MOVW a+0(FP), R4
+	MOVD f+8(FP), F0
+	MOVBU c+16(FP), R5
+	MOVV ·_subr__helper(SB), R20
+	MOVV R1, R24
+	MOVV R3, R23
+	AND $-16, R3
+	JAL (R20)
+	MOVV R23, R3
+	MOVV R24, R1
+	MOVF F0, ret+24(FP)
+	RET
@@ -0,0 +1,33 @@
+// +build !noasm !appengine
+// Code generated by nocgo, DO NOT EDIT.
+
+package foo
+
+//go:nosplit
+//go:noescape
+//goland:noinspection ALL
+func __native_entry__() uintptr
+
+var (
+	_subr__add = __native_entry__() + 32
+	_subr__callh = __native_entry__() + 208
+	_subr__helper = __native_entry__() + 160
+)
+
+const (
+	_stack__add = 48
+	_stack__callh = 16
+	_stack__helper = 0
+)
+
+var (
+	_ = _subr__add
+	_ = _subr__callh
+	_ = _subr__helper
+)
+
+const (
+	_ = _stack__add
+	_ = _stack__callh
+	_ = _stack__helper
+)
@@ -0,0 +1,87 @@
+	.text
+	.abiversion 2
+	.file	"foo.ll"
+	.globl	sum                             # -- Begin function sum
+	.p2align	4
+	.type	sum,@function
+sum:                                    # @sum
+.Lfunc_begin0:
+	.cfi_startproc
+# %bb.0:                                # %entry
+	cmpdi	4, 1
+	blt	0, .LBB0_4
+# %bb.1:                                # %loop.preheader
+	addi 5, 3, -8
+	li 3, 0
+	mtctr 4
+	.p2align	4
+.LBB0_2:                                # %loop
+                                        # =>This Inner Loop Header: Depth=1
+	ldu 4, 8(5)
+	add 3, 3, 4
+	bdnz .LBB0_2
+# %bb.3:                                # %done
+	blr
+.LBB0_4:
+	li 3, 0
+	blr
+	.long	0
+	.quad	0
+.Lfunc_end0:
+	.size	sum, .Lfunc_end0-.Lfunc_begin0
+	.cfi_endproc
+                                        # -- End function
+	.p2align	4                               # -- Begin function sq
+	.type	sq,@function
+sq:                                     # @sq
+.Lfunc_begin1:
+	.cfi_startproc
+# %bb.0:
+	xsmuldp 1, 1, 1
+	blr
+	.long	0
+	.quad	0
+.Lfunc_end1:
+	.size	sq, .Lfunc_end1-.Lfunc_begin1
+	.cfi_endproc
+                                        # -- End function
+	.section	.rodata.cst4,"aM",@progbits,4
+	.p2align	2                               # -- Begin function scale
+.LCPI2_0:
+	.long	0x3fc00000                      # float 1.5
+	.text
+	.globl	scale
+	.p2align	4
+	.type	scale,@function
+scale:                                  # @scale
+.Lfunc_begin2:
+	.cfi_startproc
+.Lfunc_gep2:
+	addis 2, 12, .TOC.-.Lfunc_gep2@ha
+	addi 2, 2, .TOC.-.Lfunc_gep2@l
+.Lfunc_lep2:
+	.localentry	scale, .Lfunc_lep2-.Lfunc_gep2
+# %bb.0:
+	mflr 0
+	std 0, 16(1)
+	stdu 1, -32(1)
+	.cfi_def_cfa_offset 32
+	.cfi_offset lr, 16
+	mtfprwa	0, 4
+	xscvsxddp 0, 0
+	xsmuldp 1, 1, 0
+	bl sq
+	addis 3, 2, .LCPI2_0@toc@ha
+	lfs 0, .LCPI2_0@toc@l(3)
+	xsadddp 1, 1, 0
+	addi 1, 1, 32
+	ld 0, 16(1)
+	mtlr 0
+	blr
+	.long	0
+	.quad	0
+.Lfunc_end2:
+	.size	scale, .Lfunc_end2-.Lfunc_begin2
+	.cfi_endproc
+                                        # -- End function
+	.section	".note.GNU-stack","",@progbits
@@ -0,0 +1,133 @@
+// +build !noasm !appengine
+// Code generated by nocgo, DO NOT EDIT.
+
+#include "go_asm.h"
+#include "funcdata.h"
+#include "textflag.h"
+
+TEXT ·__native_entry__(SB), NOSPLIT, $0
+	NO_LOCAL_POINTERS
+	WORD $0x7c8802a6 // mflr	4
+	WORD $0x429f0005 // bcl	20, 31, .+4
+	WORD $0x7c6802a6 // mflr	3
+	WORD $0x7c8803a6 // mtlr	4
+	WORD $0x3863fff8 // addi	3, 3, -8
+	WORD $0xf8610020 // std	3, 32(1)
+	WORD $0x4e800020 // blr	
+	WORD $0x60000000
+
+// sum:
+	WORD $0x2c240001 // cmpdi	4, 1
+	WORD $0x4180002c // blt	0, 80 // .LBB0_4
+	WORD $0x38a3fff8 // addi	5, 3, -8
+	WORD $0x38600000 // li	3, 0
+	WORD $0x7c8903a6 // mtctr	4
+	WORD $0x60000000
+	WORD $0x60000000
+	WORD $0x60000000
+
+// .LBB0_2:
+	WORD $0xe8850009 // ldu	4, 8(5)
+	WORD $0x7c632214 // add	3, 3, 4
+	WORD $0x4200fff8 // bdnz	64 // .LBB0_2
+	WORD $0x4e800020 // blr	
+
+// .LBB0_4:
+	WORD $0x38600000 // li	3, 0
+	WORD $0x4e800020 // blr	
+	WORD $0x0 // .long	0
+	WORD $0x0 // .quad	0
+	WORD $0x0
+	WORD $0x60000000
+	WORD $0x60000000
+	WORD $0x60000000
+
+// sq:
+	WORD $0xf0210980 // xsmuldp	1, 1, 1
+	WORD $0x4e800020 // blr	
+	WORD $0x0 // .long	0
+	WORD $0x0 // .quad	0
+	WORD $0x0
+
+// .LCPI2_0:
+	WORD $0x3fc00000 // .long	0x3fc00000
+	WORD $0x60000000
+	WORD $0x60000000
+
+// .Lfunc_gep2:
+	WORD $0x3c4c0000 // addis	2, 12, 0-144@ha // .Lfunc_gep2
+	WORD $0x3842ff70 // addi	2, 2, 0-144@l // .Lfunc_gep2
+
+// .Lfunc_lep2:
+	WORD $0x7c0802a6 // mflr	0
+	WORD $0xf8010010 // std	0, 16(1)
+	WORD $0xf821ffe1 // stdu	1, -32(1)
+	WORD $0x7c0401a6 // mtfprwa	0, 4
+	WORD $0xf00005e0 // xscvsxddp	0, 0
+	WORD $0xf0210180 // xsmuldp	1, 1, 0
+	WORD $0x4bffffc1 // bl	112 // sq
+	WORD $0x3c620000 // addis	3, 2, 132@ha // .LCPI2_0
+	WORD $0xc0030084 // lfs	0, 132@l(3) // .LCPI2_0
+	WORD $0xf0210100 // xsadddp	1, 1, 0
+	WORD $0x38210020 // addi	1, 1, 32
+	WORD $0xe8010010 // ld	0, 16(1)
+	WORD $0x7c0803a6 // mtlr	0
+	WORD $0x4e800020 // blr	
+	WORD $0x0 // .long	0
+	WORD $0x0 // .quad	0
+	WORD $0x0
+
+TEXT ·__scale(SB), NOSPLIT | NOFRAME, $0 - 24
+	NO_LOCAL_POINTERS
+
+_entry:
+	MOVD 16(g), R22
+	ADD $-368, R1, R23
+	CMPU R22, R23
+	BGE _stack_grow
+
+_scale:
+	FMOVD x+0(FP), F1
+	MOVW k+8(FP), R4
+	MOVD ·_subr__scale(SB), R12
+	MOVD LR, R14
+	MOVD R2, R16
+	MOVD R1, R15
+	SUB $32, R1, R10
+	RLDICR $0, R10, $59, R1
+	MOVD R0, 0(R1)
+	MOVD R12, CTR
+	BL (CTR)
+	XOR R0, R0
+	MOVD R15, R1
+	MOVD R16, R2
+	MOVD R14, LR
+	FMOVD F1, ret+16(FP)
+	RET
+
+_stack_grow:
+	MOVD LR, R5
+	CALL runtime·morestack_noctxt<>(SB)
+	JMP _entry
+
+TEXT ·__sum(SB), NOSPLIT | NOFRAME, $0 - 24
+	NO_LOCAL_POINTERS
+
+_sum:
+	MOVD p+0(FP), R3
+	MOVD n+8(FP), R4
+	MOVD ·_subr__sum(SB), R12
+	MOVD LR, R14
+	MOVD R2, R16
+	MOVD R1, R15
+	SUB $32, R1, R10
+	RLDICR $0, R10, $59, R1
+	MOVD R0, 0(R1)
+	MOVD R12, CTR
+	BL (CTR)
+	XOR R0, R0
+	MOVD R15, R1
+	MOVD R16, R2
+	MOVD R14, LR
+	MOVD R3, ret+16(FP)
+	RET
@@ -0,0 +1,29 @@
+// +build !noasm !appengine
+// Code generated by nocgo, DO NOT EDIT.
+
+package foo
+
+//go:nosplit
+//go:noescape
+//goland:noinspection ALL
+func __native_entry__() uintptr
+
+var (
+	_subr__scale = __native_entry__() + 144
+	_subr__sum = __native_entry__() + 32
+)
+
+const (
+	_stack__scale = 32
+	_stack__sum = 0
+)
+
+var (
+	_ = _subr__scale
+	_ = _subr__sum
+)
+
+const (
+	_ = _stack__scale
+	_ = _stack__sum
+)
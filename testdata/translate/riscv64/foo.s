@@ -0,0 +1,74 @@
+	.text
+	.attribute	4, 16
+	.attribute	5, "rv64i2p0_m2p0_a2p0_f2p0_d2p0_c2p0"
+	.file	"foo.ll"
+	.globl	sum                             # -- Begin function sum
+	.p2align	1
+	.type	sum,@function
+sum:                                    # @sum
+	.cfi_startproc
+# %bb.0:                                # %entry
+	li	a2, 0
+	blez	a1, .LBB0_2
+.LBB0_1:                                # %loop
+                                        # =>This Inner Loop Header: Depth=1
+	ld	a3, 0(a0)
+	add	a2, a2, a3
+	addi	a1, a1, -1
+	addi	a0, a0, 8
+	bnez	a1, .LBB0_1
+.LBB0_2:                                # %done
+	mv	a0, a2
+	ret
+.Lfunc_end0:
+	.size	sum, .Lfunc_end0-sum
+	.cfi_endproc
+                                        # -- End function
+	.p2align	1                               # -- Begin function sq
+	.type	sq,@function
+sq:                                     # @sq
+	.cfi_startproc
+# %bb.0:
+	fmv.d.x	ft0, a0
+	fmul.d	ft0, ft0, ft0
+	fmv.x.d	a0, ft0
+	ret
+.Lfunc_end1:
+	.size	sq, .Lfunc_end1-sq
+	.cfi_endproc
+                                        # -- End function
+	.section	.sdata,"aw",@progbits
+	.p2align	3                               # -- Begin function scale
+.LCPI2_0:
+	.quad	0x3ff8000000000000              # double 1.5
+	.text
+	.globl	scale
+	.p2align	1
+	.type	scale,@function
+scale:                                  # @scale
+	.cfi_startproc
+# %bb.0:
+	addi	sp, sp, -16
+	.cfi_def_cfa_offset 16
+	sd	ra, 8(sp)                       # 8-byte Folded Spill
+	.cfi_offset ra, -8
+	fmv.d.x	ft0, a0
+	fcvt.d.w	ft1, a1
+	fmul.d	ft0, ft0, ft1
+	fmv.x.d	a0, ft0
+	call	sq
+.LBB2_1:                                # Label of block must be emitted
+	auipc	a1, %pcrel_hi(.LCPI2_0)
+	addi	a1, a1, %pcrel_lo(.LBB2_1)
+	fld	ft0, 0(a1)
+	fmv.d.x	ft1, a0
+	fadd.d	ft0, ft1, ft0
+	fmv.x.d	a0, ft0
+	ld	ra, 8(sp)                       # 8-byte Folded Reload
+	addi	sp, sp, 16
+	ret
+.Lfunc_end2:
+	.size	scale, .Lfunc_end2-scale
+	.cfi_endproc
+                                        # -- End function
+	.section	".note.GNU-stack","",@progbits
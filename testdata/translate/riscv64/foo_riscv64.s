@@ -0,0 +1,102 @@
+// +build !noasm !appengine
+// Code generated by nocgo, DO NOT EDIT.
+
+#include "go_asm.h"
+#include "funcdata.h"
+#include "textflag.h"
+
+TEXT ·__native_entry__(SB), NOSPLIT, $0
+	NO_LOCAL_POINTERS
+	WORD $0x517 // auipc	a0, 0
+	WORD $0xa13423 // sd	a0, 8(sp)
+	WORD $0x8067 // ret	
+
+// sum:
+	WORD $0x613 // li	a2, 0
+	WORD $0xb05c63 // blez	a1, 40 // .LBB0_2
+
+// .LBB0_1:
+	WORD $0x53683 // ld	a3, 0(a0)
+	WORD $0xd60633 // add	a2, a2, a3
+	WORD $0xfff58593 // addi	a1, a1, -1
+	WORD $0x850513 // addi	a0, a0, 8
+	WORD $0xfe0598e3 // bnez	a1, 20 // .LBB0_1
+
+// .LBB0_2:
+	WORD $0x60513 // mv	a0, a2
+	WORD $0x8067 // ret	
+
+// sq:
+	WORD $0xf2050053 // fmv.d.x	ft0, a0
+	WORD $0x12007053 // fmul.d	ft0, ft0, ft0
+	WORD $0xe2000553 // fmv.x.d	a0, ft0
+	WORD $0x8067 // ret	
+
+// .LCPI2_0:
+	WORD $0x0 // .quad	0x3ff8000000000000
+	WORD $0x3ff80000
+
+// scale:
+	WORD $0xff010113 // addi	sp, sp, -16
+	WORD $0x113423 // sd	ra, 8(sp)
+	WORD $0xf2050053 // fmv.d.x	ft0, a0
+	WORD $0xd20580d3 // fcvt.d.w	ft1, a1
+	WORD $0x12107053 // fmul.d	ft0, ft0, ft1
+	WORD $0xe2000553 // fmv.x.d	a0, ft0
+	WORD $0x97 // call	48 // sq
+	WORD $0xfd0080e7
+
+// .LBB2_1:
+	WORD $0x597 // auipc	a1, %pcrel_hi(64) // .LCPI2_0
+	WORD $0xfd858593 // addi	a1, a1, -40 // .LBB2_1
+	WORD $0x5b007 // fld	ft0, 0(a1)
+	WORD $0xf20500d3 // fmv.d.x	ft1, a0
+	WORD $0x200f053 // fadd.d	ft0, ft1, ft0
+	WORD $0xe2000553 // fmv.x.d	a0, ft0
+	WORD $0x813083 // ld	ra, 8(sp)
+	WORD $0x1010113 // addi	sp, sp, 16
+	WORD $0x8067 // ret	
+
+TEXT ·__scale(SB), NOSPLIT | NOFRAME, $0 - 24
+	NO_LOCAL_POINTERS
+
+_entry:
+	MOV 16(g), X6
+	ADD $-32, X2, X7
+	BGEU X6, X7, _stack_grow
+
+_scale:
+	MOVD x+0(FP), F10
+	MOVW k+8(FP), X10
+	MOV ·_subr__scale(SB), X5
+	MOV X1, X9
+	MOV X2, X8
+	AND $-16, X2
+	JALR X1, (X5)
+	MOV X8, X2
+	MOV X9, X1
+	MOVD F10, ret+16(FP)
+	RET
+
+_stack_grow:
+	JAL X5, _morestack
+	JMP _entry
+
+_morestack:
+	JMP runtime·morestack_noctxt<>(SB)
+
+TEXT ·__sum(SB), NOSPLIT | NOFRAME, $0 - 24
+	NO_LOCAL_POINTERS
+
+_sum:
+	MOV p+0(FP), X10
+	MOV n+8(FP), X11
+	MOV ·_subr__sum(SB), X5
+	MOV X1, X9
+	MOV X2, X8
+	AND $-16, X2
+	JALR X1, (X5)
+	MOV X8, X2
+	MOV X9, X1
+	MOV X10, ret+16(FP)
+	RET
@@ -0,0 +1,65 @@
+	.text
+	.file	"foo.ll"
+	.globl	sum                             # -- Begin function sum
+	.p2align	4
+	.type	sum,@function
+sum:                                    # @sum
+	.cfi_startproc
+# %bb.0:                                # %entry
+	cgijle	%r3, 0, .LBB0_4
+# %bb.1:                                # %loop.preheader
+	lgr	%r1, %r2
+	lghi	%r2, 0
+.LBB0_2:                                # %loop
+                                        # =>This Inner Loop Header: Depth=1
+	ag	%r2, 0(%r1)
+	la	%r1, 8(%r1)
+	brctg	%r3, .LBB0_2
+# %bb.3:                                # %done
+	br	%r14
+.LBB0_4:
+	lghi	%r2, 0
+	br	%r14
+.Lfunc_end0:
+	.size	sum, .Lfunc_end0-sum
+	.cfi_endproc
+                                        # -- End function
+	.p2align	4                               # -- Begin function sq
+	.type	sq,@function
+sq:                                     # @sq
+	.cfi_startproc
+# %bb.0:
+	mdbr	%f0, %f0
+	br	%r14
+.Lfunc_end1:
+	.size	sq, .Lfunc_end1-sq
+	.cfi_endproc
+                                        # -- End function
+	.section	.rodata.cst8,"aM",@progbits,8
+	.p2align	3                               # -- Begin function scale
+.LCPI2_0:
+	.quad	0x3ff8000000000000              # double 1.5
+	.text
+	.globl	scale
+	.p2align	4
+	.type	scale,@function
+scale:                                  # @scale
+	.cfi_startproc
+# %bb.0:
+	stmg	%r14, %r15, 112(%r15)
+	.cfi_offset %r14, -48
+	.cfi_offset %r15, -40
+	aghi	%r15, -160
+	.cfi_def_cfa_offset 320
+	cdfbr	%f1, %r2
+	mdbr	%f0, %f1
+	brasl	%r14, sq@PLT
+	larl	%r1, .LCPI2_0
+	adb	%f0, 0(%r1)
+	lmg	%r14, %r15, 272(%r15)
+	br	%r14
+.Lfunc_end2:
+	.size	scale, .Lfunc_end2-scale
+	.cfi_endproc
+                                        # -- End function
+	.section	".note.GNU-stack","",@progbits
@@ -0,0 +1,90 @@
+// +build !noasm !appengine
+// Code generated by nocgo, DO NOT EDIT.
+
+#include "go_asm.h"
+#include "funcdata.h"
+#include "textflag.h"
+
+TEXT ·__native_entry__(SB), NOSPLIT, $0
+	NO_LOCAL_POINTERS
+	WORD $0xc0200000; BYTE $0x0; BYTE $0x0 // larl	%r2, 0
+	WORD $0xe320f008; BYTE $0x0; BYTE $0x24 // stg	%r2, 8(%r15)
+	BYTE $0x7; BYTE $0xfe // br	%r14
+	BYTE $0x7; BYTE $0x0
+
+// sum:
+	WORD $0xec3c000f; BYTE $0x0; BYTE $0x7c // cgijle	%r3, 0, 46 // .LBB0_4
+	WORD $0xb9040012 // lgr	%r1, %r2
+	WORD $0xa7290000 // lghi	%r2, 0
+
+// .LBB0_2:
+	WORD $0xe3201000; BYTE $0x0; BYTE $0x8 // ag	%r2, 0(%r1)
+	WORD $0x41101008 // la	%r1, 8(%r1)
+	WORD $0xa737fffb // brctg	%r3, 30 // .LBB0_2
+	BYTE $0x7; BYTE $0xfe // br	%r14
+
+// .LBB0_4:
+	WORD $0xa7290000 // lghi	%r2, 0
+	BYTE $0x7; BYTE $0xfe // br	%r14
+	WORD $0x7000700; WORD $0x7000700; WORD $0x7000700
+
+// sq:
+	WORD $0xb31c0000 // mdbr	%f0, %f0
+	BYTE $0x7; BYTE $0xfe // br	%r14
+	BYTE $0x7; BYTE $0x0
+
+// .LCPI2_0:
+	WORD $0x3ff80000; WORD $0x0
+
+// scale:
+	WORD $0xebeff070; BYTE $0x0; BYTE $0x24 // stmg	%r14, %r15, 112(%r15)
+	WORD $0xa7fbff60 // aghi	%r15, -160
+	WORD $0xb3950012 // cdfbr	%f1, %r2
+	WORD $0xb31c0001 // mdbr	%f0, %f1
+	WORD $0xc0e5ffff; BYTE $0xff; BYTE $0xef // brasl	%r14, 64 // sq
+	WORD $0xc010ffff; BYTE $0xff; BYTE $0xf0 // larl	%r1, 72 // .LCPI2_0
+	WORD $0xed001000; BYTE $0x0; BYTE $0x1a // adb	%f0, 0(%r1)
+	WORD $0xebeff110; BYTE $0x0; BYTE $0x4 // lmg	%r14, %r15, 272(%r15)
+	BYTE $0x7; BYTE $0xfe // br	%r14
+
+TEXT ·__scale(SB), NOSPLIT | NOFRAME, $0 - 24
+	NO_LOCAL_POINTERS
+
+_entry:
+	MOVD 16(g), R10
+	MOVD $-320(R15), R11
+	CMPUBGE R10, R11, _stack_grow
+
+_scale:
+	FMOVD x+0(FP), F0
+	MOVW k+8(FP), R2
+	MOVD ·_subr__scale(SB), R1
+	MOVD R14, R7
+	SUB $160, R15
+	BL R1
+	ADD $160, R15
+	XOR R0, R0
+	MOVD R7, R14
+	FMOVD F0, ret+16(FP)
+	RET
+
+_stack_grow:
+	MOVD LR, R5
+	CALL runtime·morestack_noctxt<>(SB)
+	JMP _entry
+
+TEXT ·__sum(SB), NOSPLIT | NOFRAME, $0 - 24
+	NO_LOCAL_POINTERS
+
+_sum:
+	MOVD p+0(FP), R2
+	MOVD n+8(FP), R3
+	MOVD ·_subr__sum(SB), R1
+	MOVD R14, R7
+	SUB $160, R15
+	BL R1
+	ADD $160, R15
+	XOR R0, R0
+	MOVD R7, R14
+	MOVD R2, ret+16(FP)
+	RET
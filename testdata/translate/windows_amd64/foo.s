@@ -0,0 +1,27 @@
+	.text
+	.def	@feat.00;
+	.scl	3;
+	.type	0;
+	.endef
+	.globl	@feat.00
+.set @feat.00, 0
+	.file	"foo.ll"
+	.def	ext;
+	.scl	2;
+	.type	32;
+	.endef
+	.globl	ext                             # -- Begin function ext
+	.p2align	4, 0x90
+ext:                                    # @ext
+# %bb.0:
+	movswq	48(%rsp), %r10
+	movsbq	40(%rsp), %r11
+	movsbq	%cl, %rax
+	movswq	%r9w, %rcx
+	addq	%rdx, %rax
+	addq	%r8, %rax
+	addq	%rcx, %rax
+	addq	%r11, %rax
+	addq	%r10, %rax
+	retq
+                                        # -- End function
@@ -0,0 +1,25 @@
+// +build !noasm !appengine
+// Code generated by nocgo, DO NOT EDIT.
+
+package foo
+
+//go:nosplit
+//go:noescape
+//goland:noinspection ALL
+func __native_entry__() uintptr
+
+var (
+	_subr__ext = __native_entry__() + 16
+)
+
+const (
+	_stack__ext = 0
+)
+
+var (
+	_ = _subr__ext
+)
+
+const (
+	_ = _stack__ext
+)
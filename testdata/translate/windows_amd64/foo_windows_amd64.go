@@ -0,0 +1,4 @@
+package foo
+
+//go:noescape
+func __ext(a int8, b int64, c int64, d int16, e int8, f int16) (ret int64)
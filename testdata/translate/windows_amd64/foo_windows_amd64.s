@@ -0,0 +1,46 @@
+// +build !noasm !appengine
+// Code generated by nocgo, DO NOT EDIT.
+
+#include "go_asm.h"
+#include "funcdata.h"
+#include "textflag.h"
+
+TEXT ·__native_entry__(SB), NOSPLIT, $0
+	NO_LOCAL_POINTERS
+	LONG $0xf9058d48; WORD $0xffff; BYTE $0xff // leaq	-7(%rip), %rax
+	LONG $0x24448948; BYTE $0x8 // movq	%rax, 8(%rsp)
+	BYTE $0xc3 // retq	
+	WORD $0x9090; BYTE $0x90
+
+// ext:
+	LONG $0x54bf0f4c; WORD $0x3024 // movswq	48(%rsp), %r10
+	LONG $0x5cbe0f4c; WORD $0x2824 // movsbq	40(%rsp), %r11
+	LONG $0xc1be0f48 // movsbq	%cl, %rax
+	LONG $0xc9bf0f49 // movswq	%r9w, %rcx
+	WORD $0x148; BYTE $0xd0 // addq	%rdx, %rax
+	WORD $0x14c; BYTE $0xc0 // addq	%r8, %rax
+	WORD $0x148; BYTE $0xc8 // addq	%rcx, %rax
+	WORD $0x14c; BYTE $0xd8 // addq	%r11, %rax
+	WORD $0x14c; BYTE $0xd0 // addq	%r10, %rax
+	BYTE $0xc3 // retq	
+
+TEXT ·__ext(SB), NOSPLIT | NOFRAME, $0 - 40
+	NO_LOCAL_POINTERS
+
+_ext:
+	MOVBQSX a+0(FP), CX
+	MOVQ b+8(FP), DX
+	MOVQ c+16(FP), R8
+	MOVWQSX d+24(FP), R9
+	MOVQ ·_subr__ext(SB), AX
+	MOVQ SP, BX
+	SUBQ $48, SP
+	ANDQ $-16, SP
+	MOVBQSX 34(BX), R11
+	MOVQ R11, 32(SP)
+	MOVWQSX 36(BX), R11
+	MOVQ R11, 40(SP)
+	CALL AX
+	MOVQ BX, SP
+	MOVQ AX, ret+32(FP)
+	RET
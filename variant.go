@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// cpuFeatures are the golang.org/x/sys/cpu flags of the target features,
+// the names follow the -mattr of clang.
+var cpuFeatures = map[string]map[string]string{
+	"arm64": {
+		"aes":     "cpu.ARM64.HasAES",
+		"pmull":   "cpu.ARM64.HasPMULL",
+		"sha1":    "cpu.ARM64.HasSHA1",
+		"sha2":    "cpu.ARM64.HasSHA2",
+		"sha3":    "cpu.ARM64.HasSHA3",
+		"sha512":  "cpu.ARM64.HasSHA512",
+		"crc":     "cpu.ARM64.HasCRC32",
+		"lse":     "cpu.ARM64.HasATOMICS",
+		"rdm":     "cpu.ARM64.HasASIMDRDM",
+		"fp16":    "cpu.ARM64.HasFPHP && cpu.ARM64.HasASIMDHP",
+		"fp16fml": "cpu.ARM64.HasASIMDFHM",
+		"dotprod": "cpu.ARM64.HasASIMDDP",
+		"sve":     "cpu.ARM64.HasSVE",
+		"sve2":    "cpu.ARM64.HasSVE2",
+	},
+	"amd64": {
+		"sse3":       "cpu.X86.HasSSE3",
+		"ssse3":      "cpu.X86.HasSSSE3",
+		"sse4.1":     "cpu.X86.HasSSE41",
+		"sse4.2":     "cpu.X86.HasSSE42",
+		"popcnt":     "cpu.X86.HasPOPCNT",
+		"aes":        "cpu.X86.HasAES",
+		"pclmul":     "cpu.X86.HasPCLMULQDQ",
+		"avx":        "cpu.X86.HasAVX",
+		"avx2":       "cpu.X86.HasAVX2",
+		"fma":        "cpu.X86.HasFMA",
+		"bmi":        "cpu.X86.HasBMI1",
+		"bmi2":       "cpu.X86.HasBMI2",
+		"avx512f":    "cpu.X86.HasAVX512F",
+		"avx512bw":   "cpu.X86.HasAVX512BW",
+		"avx512cd":   "cpu.X86.HasAVX512CD",
+		"avx512dq":   "cpu.X86.HasAVX512DQ",
+		"avx512vl":   "cpu.X86.HasAVX512VL",
+		"avx512vnni": "cpu.X86.HasAVX512VNNI",
+		"avx512vbmi": "cpu.X86.HasAVX512VBMI",
+	},
+	"ppc64le": {
+		"power9": "cpu.PPC64.IsPOWER9",
+	},
+	"s390x": {
+		"vx":  "cpu.S390X.HasVX",
+		"vxe": "cpu.S390X.HasVXE",
+	},
+}
+
+// asmVariant is the clang output of the same functions built with more
+// target features, it is picked at init when the cpu has them all.
+type asmVariant struct {
+	features []string
+	ifile    string
+	// the go expression of the features
+	cond string
+}
+
+// parseVariant parses feature[+feature]=clang-asm.
+func parseVariant(arg string) (v asmVariant, err error) {
+	idx := strings.IndexByte(arg, '=')
+	if idx <= 0 || idx == len(arg)-1 {
+		err = fmt.Errorf("invalid variant: %s, want feature[+feature]=file", arg)
+		return
+	}
+	v.features = strings.Split(arg[:idx], "+")
+	sort.Strings(v.features)
+	v.ifile = arg[idx+1:]
+	return
+}
+
+// Name is the label suffix of the variant.
+func (v asmVariant) Name() string {
+	return strings.Join(v.features, "_")
+}
+
+func (v asmVariant) String() string {
+	return strings.Join(v.features, "+")
+}
+
+// goCond is the go expression checking the features on goarch.
+func (v asmVariant) goCond(goarch string) (_ string, err error) {
+	flags := cpuFeatures[goarch]
+	if flags == nil {
+		err = fmt.Errorf("cpu features are unsupported on %s", goarch)
+		return
+	}
+	conds := make([]string, 0, len(v.features))
+	for _, f := range v.features {
+		c, ok := flags[f]
+		if !ok {
+			err = fmt.Errorf("unknown cpu feature on %s: %s", goarch, f)
+			return
+		}
+		conds = append(conds, c)
+	}
+	return strings.Join(conds, " && "), nil
+}
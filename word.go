@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// wordWriter writes the program by WORD, for the archs with fixed 32-bit
+// instructions.
+type wordWriter struct {
+	order binary.ByteOrder
+}
+
+func (ww wordWriter) bytes(w io.Writer, data []byte, comment string) (_ []byte, err error) {
+	flag := comment == ""
+	for len(data) >= 4 {
+		switch {
+		// disable because
+		// 1. cause align padding, (WORD PAD DWORD)
+		// 2. operand must be great than 2^32
+		// case len(data) >= 8:
+		// 	_, err = fmt.Fprintf(w, "\tDWORD $0x%x", ww.order.Uint64(data[:8]))
+		// 	data = data[8:]
+		case len(data) >= 4:
+			_, err = fmt.Fprintf(w, "\tWORD $0x%x", ww.order.Uint32(data[:4]))
+			data = data[4:]
+		}
+		if err != nil {
+			return
+		}
+
+		if !flag {
+			flag = true
+
+			if _, err = fmt.Fprintf(w, " // %s", comment); err != nil {
+				return
+			}
+		}
+
+		if _, err = w.Write([]byte{'\n'}); err != nil {
+			return
+		}
+	}
+	return data, nil
+}
+
+func (ww wordWriter) writeBB(w io.Writer, bb *BasicBlock, prev []byte) (_ []byte, err error) {
+	if len(prev) > 0 {
+		if bb.Instrs[0].Kind() != InstrKind_Data {
+			err = errors.New("only data instr can be appended")
+			return
+		}
+	}
+
+	for _, v := range bb.Instrs {
+		if len(prev) > 0 || (v.Kind() == InstrKind_Data || v.Kind() == InstrKind_P2Align) &&
+			v.Mnemonic() != ".long" && v.Mnemonic() != ".quad" && v.Mnemonic() != ".word" && v.Mnemonic() != ".xword" && v.Mnemonic() != ".dword" {
+			prev = append(prev, v.Byte()...)
+			continue
+		}
+
+		comment := fmt.Sprintf("%s\t%s", v.Mnemonic(), v.Operands())
+		if lbl := v.LabelNames(); lbl != "" {
+			comment = fmt.Sprintf("%s // %s", comment, lbl)
+		}
+		if data, err1 := ww.bytes(w, v.Byte(), comment); err1 != nil {
+			err = err1
+			return
+		} else if len(data) > 0 {
+			err = newDiag(DiagKind_Encoding, v.Pos(), fmt.Errorf("size is not a multiple of 4: %s %s", v.Mnemonic(), v.Operands()))
+			return
+		}
+	}
+
+	if len(prev) > 0 {
+		return ww.bytes(w, prev, "")
+	}
+	return
+}
+
+// writeProg writes all the basic blocks, sz is the total size.
+func (ww wordWriter) writeProg(w io.Writer, p *Prog) (sz int64, err error) {
+	var prev []byte
+	for _, bb := range p.bbs {
+		if bb.ID != "" {
+			var sdif string
+			if sz := len(prev); sz > 0 {
+				sdif = fmt.Sprintf(" // +%d", sz)
+			}
+			if _, err = fmt.Fprintf(w, "\n// %s:%s\n", bb.ID, sdif); err != nil {
+				return
+			}
+		}
+		var data []byte
+		if data, err = ww.writeBB(w, bb, prev); err != nil {
+			return
+		} else if len(data) > 0 {
+			prev = data
+		} else {
+			prev = prev[:0]
+		}
+		sz += bb.Size()
+	}
+	// the data at the end, like the .byte jump table, is padded to a word
+	if n := len(prev); n > 0 {
+		pad := (4 - n&3) & 3
+		if _, err = ww.bytes(w, append(prev, make([]byte, pad)...), ""); err != nil {
+			return
+		}
+		sz += int64(pad)
+	}
+	return
+}
+
+////////////////////////
+
+// byteWriter writes the program by the data directives of 8, 4, 2 and 1
+// bytes, for the archs with variable length instructions. the names are
+// in that order, an empty one skips the size.
+type byteWriter struct {
+	order binary.ByteOrder
+	names [4]string
+}
+
+func (bw byteWriter) uint(data []byte) uint64 {
+	switch len(data) {
+	case 8:
+		return bw.order.Uint64(data)
+	case 4:
+		return uint64(bw.order.Uint32(data))
+	case 2:
+		return uint64(bw.order.Uint16(data))
+	}
+	return uint64(data[0])
+}
+
+func (bw byteWriter) bytes(w io.Writer, data []byte, comment string) (err error) {
+	for len(data) > 0 {
+		var line []string
+		for n := 0; len(data) > 0 && n < 16; {
+			for i, sz := range []int{8, 4, 2, 1} {
+				if len(data) < sz || bw.names[i] == "" {
+					continue
+				}
+				line = append(line, fmt.Sprintf("%s $0x%x", bw.names[i], bw.uint(data[:sz])))
+				data, n = data[sz:], n+sz
+				break
+			}
+		}
+
+		if _, err = fmt.Fprintf(w, "\t%s", strings.Join(line, "; ")); err != nil {
+			return
+		}
+		if comment != "" {
+			if _, err = fmt.Fprintf(w, " // %s", comment); err != nil {
+				return
+			}
+			comment = ""
+		}
+		if _, err = w.Write([]byte{'\n'}); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (bw byteWriter) writeProg(w io.Writer, p *Prog) error {
+	for _, bb := range p.bbs {
+		if bb.ID != "" {
+			if _, err := fmt.Fprintf(w, "\n// %s:\n", bb.ID); err != nil {
+				return err
+			}
+		}
+
+		for _, v := range bb.Instrs {
+			var comment string
+			if v.Kind() != InstrKind_Data && v.Kind() != InstrKind_P2Align {
+				comment = fmt.Sprintf("%s\t%s", v.Mnemonic(), v.Operands())
+				if lbl := v.LabelNames(); lbl != "" {
+					comment = fmt.Sprintf("%s // %s", comment, lbl)
+				}
+			}
+			if err := bw.bytes(w, v.Byte(), comment); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -18,8 +18,32 @@ TEXT ·__native_entry__(SB), NOSPLIT, $0
 	NO_LOCAL_POINTERS
 `
 
-func writeGoASM(w io.Writer, p *Prog, funcs Functions, arch Arch) (err error) {
-	if _, err = fmt.Fprint(w, asmHead); err != nil {
+// withTags adds the build constraint tags to head, the +build lines are
+// and-ed.
+func withTags(head, tags string) string {
+	if tags == "" {
+		return head
+	}
+	idx := strings.IndexByte(head, '\n') + 1
+	return head[:idx] + "// +build " + tags + "\n" + head[idx:]
+}
+
+// withNote adds the comment lines of note after the generated line.
+func withNote(head, note string) string {
+	if note == "" {
+		return head
+	}
+	const gen = "// Code generated by nocgo, DO NOT EDIT.\n"
+	idx := strings.Index(head, gen) + len(gen)
+	var buf strings.Builder
+	for _, v := range strings.Split(strings.TrimRight(note, "\n"), "\n") {
+		buf.WriteString("// " + v + "\n")
+	}
+	return head[:idx] + buf.String() + head[idx:]
+}
+
+func writeGoASM(w io.Writer, p *Prog, funcs Functions, arch Arch, tags, note string) (err error) {
+	if _, err = fmt.Fprint(w, withNote(withTags(asmHead, tags), note)); err != nil {
 		return
 	}
 	if err = arch.WriteHead(w); err != nil {
@@ -28,14 +52,29 @@ func writeGoASM(w io.Writer, p *Prog, funcs Functions, arch Arch) (err error) {
 	if err = arch.WriteProg(w, p); err != nil {
 		return
 	}
+	sizes, err := p.StackSizes(funcs)
+	if err != nil {
+		return
+	}
 	for _, v := range funcs {
 		if _, err = fmt.Fprintf(w, "\nTEXT ·%s(SB), NOSPLIT | NOFRAME, $0 - %d\n\tNO_LOCAL_POINTERS\n", v.Name, v.ArgsSize()); err != nil {
 			return
 		}
+		if v.NativeStack {
+			// the go wrapper takes a native stack and calls _ncall
+			nf := *v
+			nf.Args = v.nativeArgs()
+			if _, err = fmt.Fprintf(w, "\tJMP ·_native%s(SB)\n\nTEXT ·_ncall%[1]s(SB), NOSPLIT | NOFRAME, $0 - %d\n\tNO_LOCAL_POINTERS\n", v.Name, nf.ArgsSize()); err != nil {
+				return
+			}
+		}
 
-		bb := p.GetBB(v.Name[1:])
-		spsize := -p.SPDetect(bb, make(map[*BasicBlock]bool))
-		if err = arch.WriteFunc(w, v, spsize, bb.EA()); err != nil {
+		var bb *BasicBlock
+		if bb, err = p.FuncBB(v); err != nil {
+			err = newDiag(DiagKind_UnresolvedLabel, v.Pos, err)
+			return
+		}
+		if err = arch.WriteFunc(w, v, sizes[v], bb.EA()); err != nil {
 			return
 		}
 	}
@@ -70,6 +109,7 @@ var KnownArch = map[string]bool{
 	"armbe":       true,
 	"arm64":       true,
 	"arm64be":     true,
+	"loong64":     true,
 	"ppc64":       true,
 	"ppc64le":     true,
 	"mips":        true,
@@ -88,11 +128,16 @@ var KnownArch = map[string]bool{
 	"wasm":        true,
 }
 
-func subrFileName(fpath string) string {
-	fdir, fname := filepath.Split(fpath)
-	arr := strings.Split(fname[:len(fname)-2], "_")
+// splitFileName splits the base name of fpath at the _GOOS_GOARCH suffix,
+// idx is the index of the first suffix element.
+func splitFileName(fpath string) (arr []string, idx int) {
+	fname := filepath.Base(fpath)
+	if i := strings.LastIndexByte(fname, '.'); i != -1 {
+		fname = fname[:i]
+	}
+	arr = strings.Split(fname, "_")
 	n := len(arr)
-	idx := n
+	idx = n
 
 	switch {
 	case n > 2 && KnownOS[arr[n-2]] && KnownArch[arr[n-1]]:
@@ -100,23 +145,80 @@ func subrFileName(fpath string) string {
 	case n > 1 && (KnownOS[arr[n-1]] || KnownArch[arr[n-1]]):
 		idx = n - 1
 	}
+	return
+}
+
+func fileNameTarget(fpath string) (goos, goarch string) {
+	arr, idx := splitFileName(fpath)
+	for _, v := range arr[idx:] {
+		if KnownOS[v] {
+			goos = v
+		} else {
+			goarch = v
+		}
+	}
+	return
+}
+
+func subrFileName(fpath string) string {
+	arr, idx := splitFileName(fpath)
 	arr = append(arr[:idx], append([]string{"subr"}, arr[idx:]...)...)
-	return filepath.Join(fdir, strings.Join(arr, "_")+".go")
+	return filepath.Join(filepath.Dir(fpath), strings.Join(arr, "_")+".go")
 }
 
 const subrHead = `// +build !noasm !appengine
 // Code generated by nocgo, DO NOT EDIT.
 
 package %s
-
+%s
 //go:nosplit
 //go:noescape
 //goland:noinspection ALL
 func __native_entry__() uintptr
 `
 
-func writeSubr(w io.Writer, p *Prog, funcs Functions, pkg string, arch Arch) (err error) {
-	if _, err = fmt.Fprintf(w, subrHead, pkg); err != nil {
+// nativeStacks are the stacks of the functions with //nocgo:nativestack,
+// the goroutine keeps its P in the C code, so GOMAXPROCS of them are enough
+// and the go wrappers wait for a free one before the asm switches to it.
+const nativeStacks = `
+// __native_stacks__ are the stacks out of the goroutines, the free tops are
+// in the channel.
+type __native_stacks__ struct {
+	free chan uintptr
+	mem  [][]byte
+}
+
+func __new_native_stacks__(size uintptr) *__native_stacks__ {
+	n := runtime.GOMAXPROCS(0)
+	s := &__native_stacks__{free: make(chan uintptr, n), mem: make([][]byte, n)}
+	for i := range s.mem {
+		s.mem[i] = make([]byte, size)
+		s.free <- (uintptr(unsafe.Pointer(&s.mem[i][0])) + size) &^ 15
+	}
+	return s
+}
+`
+
+func writeSubr(w io.Writer, p *Prog, funcs Functions, pkg string, arch Arch, tags, note string) (err error) {
+	var natives Functions
+	for _, v := range funcs {
+		if v.NativeStack {
+			natives = append(natives, v)
+		}
+	}
+
+	var pkgs []string
+	if len(p.variants) > 0 {
+		pkgs = append(pkgs, "golang.org/x/sys/cpu")
+	}
+	if len(natives) > 0 {
+		pkgs = append(pkgs, "runtime", "unsafe")
+	}
+	var imports string
+	if len(pkgs) > 0 {
+		imports = "\nimport (\n\t\"" + strings.Join(pkgs, "\"\n\t\"") + "\"\n)\n"
+	}
+	if _, err = fmt.Fprint(w, withNote(fmt.Sprintf(withTags(subrHead, tags), pkg, imports), note)); err != nil {
 		return
 	}
 
@@ -144,7 +246,10 @@ func writeSubr(w io.Writer, p *Prog, funcs Functions, pkg string, arch Arch) (er
 	}
 
 	if err = rangeFuncs([]byte("\nvar (\n"), func(f *Function) error {
-		bb := p.GetBB(f.Name[1:])
+		bb, err := p.FuncBB(f)
+		if err != nil {
+			return err
+		}
 		if _, err := fmt.Fprintf(w, "\t_subr%s = %s() + %d\n", f.Name, entry, bb.EA()); err != nil {
 			return err
 		}
@@ -153,10 +258,18 @@ func writeSubr(w io.Writer, p *Prog, funcs Functions, pkg string, arch Arch) (er
 		return
 	}
 
+	if len(p.variants) > 0 {
+		if err = writeDispatch(w, p, funcs, entry); err != nil {
+			return
+		}
+	}
+
+	sizes, err := p.StackSizes(funcs)
+	if err != nil {
+		return
+	}
 	if err = rangeFuncs([]byte("\nconst (\n"), func(f *Function) error {
-		bb := p.GetBB(f.Name[1:])
-		spsize := -p.SPDetect(bb, make(map[*BasicBlock]bool))
-		if _, err := fmt.Fprintf(w, "\t_stack%s = %d\n", f.Name, spsize); err != nil {
+		if _, err := fmt.Fprintf(w, "\t_stack%s = %d\n", f.Name, sizes[f]); err != nil {
 			return err
 		}
 		return nil
@@ -164,6 +277,12 @@ func writeSubr(w io.Writer, p *Prog, funcs Functions, pkg string, arch Arch) (er
 		return
 	}
 
+	if len(natives) > 0 {
+		if err = writeNativeStacks(w, natives); err != nil {
+			return
+		}
+	}
+
 	if err = rangeFuncs([]byte("\nvar (\n"), func(f *Function) error {
 		if _, err := fmt.Fprintf(w, "\t_ = _subr%s\n", f.Name); err != nil {
 			return err
@@ -183,3 +302,75 @@ func writeSubr(w io.Writer, p *Prog, funcs Functions, pkg string, arch Arch) (er
 	}
 	return
 }
+
+// writeNativeStacks allocates the native stacks of funcs, with the red
+// zone, the return address and the stack arguments over the stack size.
+// the asm of the prototype jumps to the go wrapper, it takes a stack and
+// passes the top to the asm call.
+func writeNativeStacks(w io.Writer, funcs Functions) (err error) {
+	if _, err = fmt.Fprint(w, nativeStacks); err != nil {
+		return
+	}
+	if _, err = fmt.Fprint(w, "\nvar (\n"); err != nil {
+		return
+	}
+	for _, f := range funcs {
+		if _, err = fmt.Fprintf(w, "\t_nstack%s = __new_native_stacks__(_stack%s + %d)\n", f.Name, f.Name, 192+8*len(f.Args)); err != nil {
+			return
+		}
+	}
+	if _, err = fmt.Fprint(w, ")\n"); err != nil {
+		return
+	}
+
+	for _, f := range funcs {
+		var names, params []string
+		for _, v := range f.Args {
+			names = append(names, v.Name)
+			params = append(params, v.Name+" "+v.goType())
+		}
+		var result, assign string
+		if f.Ret != nil {
+			result, assign = fmt.Sprintf(" (%s %s)", f.Ret.Name, f.Ret.goType()), f.Ret.Name+" = "
+		}
+		if _, err = fmt.Fprintf(w, `
+//go:nosplit
+//go:noescape
+func _ncall%[1]s(%[2]s)%[3]s
+
+func _native%[1]s(%[4]s)%[3]s {
+	nstack := <-_nstack%[1]s.free
+	%[5]s_ncall%[1]s(%[6]s)
+	_nstack%[1]s.free <- nstack
+	return
+}
+`, f.Name, strings.Join(append(params, "nstack uintptr"), ", "), result,
+			strings.Join(params, ", "), assign, strings.Join(append(names, "nstack"), ", ")); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// writeDispatch binds the subrs to the best variants the cpu has.
+func writeDispatch(w io.Writer, p *Prog, funcs Functions, entry string) (err error) {
+	if _, err = fmt.Fprint(w, "\nfunc init() {\n"); err != nil {
+		return
+	}
+	for _, f := range funcs {
+		var cases []string
+		for _, v := range p.variants {
+			if bb := p.VariantBB(f, v); bb != nil {
+				cases = append(cases, fmt.Sprintf("\tcase %s:\n\t\t_subr%s = %s() + %d\n", v.cond, f.Name, entry, bb.EA()))
+			}
+		}
+		if len(cases) == 0 {
+			continue
+		}
+		if _, err = fmt.Fprintf(w, "\tswitch {\n%s\t}\n", strings.Join(cases, "")); err != nil {
+			return
+		}
+	}
+	_, err = fmt.Fprint(w, "}\n")
+	return
+}